@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const annotateUsage = `Usage: note <ref> [<text>]
+       tag <ref> <tag>...
+       untag <ref> <tag>...
+Notes and tags are sent to LunchMoney when the transaction is synced, quote tags that contain spaces.`
+
+// handleAnnotate parses the note, tag and untag commands
+// Format: note <ref> [<text>] | tag <ref> <tag>... | untag <ref> <tag>...
+func (r *replState) handleAnnotate(input string) {
+	parts := utils.SplitQuoted(input)
+	if len(parts) < 2 || parts[0] != "note" && len(parts) < 3 {
+		fmt.Printf("Invalid %s command format.\n", parts[0])
+		fmt.Println(annotateUsage)
+		return
+	}
+
+	switch parts[0] {
+	case "note":
+		r.setNote(parts[1], strings.Join(parts[2:], " "))
+	case "tag":
+		r.tagTransaction(parts[1], parts[2:], true)
+	case "untag":
+		r.tagTransaction(parts[1], parts[2:], false)
+	default:
+		fmt.Println(annotateUsage)
+	}
+}
+
+// unsyncedTransaction returns the transaction to annotate, notes and tags are only
+// sent with new transactions, so synced ones are edited in LunchMoney instead
+func (r *replState) unsyncedTransaction(referenceNumber string) *models.TransactionWithAccount {
+	tx, err := r.db.GetTransactionByReference(referenceNumber)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching transaction")
+		return nil
+	}
+	if tx == nil {
+		fmt.Printf("No transaction with reference number %s\n", referenceNumber)
+		return nil
+	}
+	if tx.LunchMoneyID > 0 {
+		fmt.Printf("Transaction %s is already synced as LunchMoney transaction %d, edit it in LunchMoney instead\n",
+			referenceNumber, tx.LunchMoneyID)
+		return nil
+	}
+	return tx
+}
+
+func (r *replState) setNote(referenceNumber, note string) {
+	tx := r.unsyncedTransaction(referenceNumber)
+	if tx == nil {
+		return
+	}
+
+	tx.Notes = note
+	if err := r.db.UpdateTransaction(tx); err != nil {
+		log.Error().Err(err).Msg("Error updating transaction")
+		return
+	}
+
+	detail := "notes cleared"
+	if note != "" {
+		detail = fmt.Sprintf("notes set to %q", note)
+	}
+	services.RecordTransactionEvent(r.db, 0, &tx.Transaction, models.TransactionEventUpdated, detail)
+	fmt.Printf("Transaction %s: %s\n", referenceNumber, detail)
+}
+
+func (r *replState) tagTransaction(referenceNumber string, tags []string, add bool) {
+	tx := r.unsyncedTransaction(referenceNumber)
+	if tx == nil {
+		return
+	}
+
+	changed, verb := false, "untagged"
+	if add {
+		changed, verb = tx.AddTags(tags...), "tagged"
+	} else {
+		changed = tx.RemoveTags(tags...)
+	}
+	if !changed {
+		fmt.Printf("Transaction %s is unchanged, its tags are: %s\n", referenceNumber, strings.Join(tx.Tags, ", "))
+		return
+	}
+
+	if err := r.db.UpdateTransaction(tx); err != nil {
+		log.Error().Err(err).Msg("Error updating transaction")
+		return
+	}
+
+	detail := fmt.Sprintf("%s %s", verb, strings.Join(tags, ", "))
+	services.RecordTransactionEvent(r.db, 0, &tx.Transaction, models.TransactionEventUpdated, detail)
+	fmt.Printf("Transaction %s %s, its tags are: %s\n", referenceNumber, verb, strings.Join(tx.Tags, ", "))
+}
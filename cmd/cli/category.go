@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const categoriesUsage = `Usage: categories [list]
+       categories refresh
+       categories mappings
+       categories map <category_code> <lunchmoney_category_id|none>
+       categories unmap <category_code>
+Quote category codes that contain spaces.`
+
+// handleCategories parses the categories command
+// Format: categories [list|refresh|mappings|map <code> <id|none>|unmap <code>]
+func (r *replState) handleCategories(input string) {
+	parts := utils.SplitQuoted(input)
+	if len(parts) == 1 {
+		r.listCategories(false)
+		return
+	}
+
+	switch {
+	case parts[1] == "list" && len(parts) == 2:
+		r.listCategories(false)
+	case parts[1] == "refresh" && len(parts) == 2:
+		r.listCategories(true)
+	case parts[1] == "mappings" && len(parts) == 2:
+		r.listCategoryMappings()
+	case parts[1] == "map" && len(parts) == 4:
+		r.setCategoryMapping(parts[2], parts[3])
+	case parts[1] == "unmap" && len(parts) == 3:
+		r.deleteCategoryMapping(parts[2])
+	default:
+		fmt.Println("Invalid categories command format.")
+		fmt.Println(categoriesUsage)
+	}
+}
+
+// listCategories prints the cached LunchMoney categories, refreshing them from LunchMoney first if asked
+func (r *replState) listCategories(refresh bool) {
+	mapper := r.lmSyncer.GetCategoryMapper()
+	categories, err := mapper.Categories(context.Background())
+	if refresh {
+		categories, err = mapper.RefreshCategories(context.Background())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching categories")
+		return
+	}
+
+	if len(categories) == 0 {
+		fmt.Println("No categories found")
+		return
+	}
+
+	groups := lo.SliceToMap(categories, func(c models.LunchMoneyCategory) (int64, string) {
+		return c.ID, c.Name
+	})
+	fmt.Printf("%-10s %-30s %-8s %s\n", "ID", "Name", "Type", "Group")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, category := range categories {
+		kind := "expense"
+		switch {
+		case category.IsGroup:
+			kind = "group"
+		case category.IsIncome:
+			kind = "income"
+		}
+		fmt.Printf("%-10d %-30s %-8s %s\n", category.ID, category.Name[:min(30, len(category.Name))], kind, groups[category.GroupID])
+	}
+}
+
+func (r *replState) listCategoryMappings() {
+	mappings, err := r.db.GetCategoryMappings()
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching category mappings")
+		return
+	}
+
+	if len(mappings) == 0 {
+		fmt.Println("No category mappings found")
+		return
+	}
+
+	categories, err := r.lmSyncer.GetCategoryMapper().Categories(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching categories")
+		return
+	}
+	names := lo.SliceToMap(categories, func(c models.LunchMoneyCategory) (int64, string) {
+		return c.ID, c.Name
+	})
+
+	fmt.Printf("%-30s %-10s %s\n", "Category Code", "LM ID", "LM Category")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, mapping := range mappings {
+		lmId, name := strconv.FormatInt(mapping.LunchMoneyCategoryId, 10), names[mapping.LunchMoneyCategoryId]
+		if mapping.IsUncategorized() {
+			lmId, name = "-", "(uncategorized)"
+		} else if name == "" {
+			name = "(unknown category)"
+		}
+		fmt.Printf("%-30s %-10s %s\n", mapping.CategoryCode[:min(30, len(mapping.CategoryCode))], lmId, name)
+	}
+}
+
+func (r *replState) setCategoryMapping(categoryCode, target string) {
+	categoryId := models.Uncategorized
+	if target != "none" {
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil || id <= 0 {
+			fmt.Println("Invalid LunchMoney category ID, use a positive ID or 'none'")
+			return
+		}
+		categoryId = id
+	}
+
+	mapping, err := r.lmSyncer.GetCategoryMapper().SetMapping(context.Background(), categoryCode, categoryId)
+	if err != nil {
+		log.Error().Err(err).Msg("Error setting category mapping")
+		return
+	}
+
+	if mapping.IsUncategorized() {
+		fmt.Printf("Transactions with category code %q are now left uncategorized\n", categoryCode)
+		return
+	}
+	fmt.Printf("Category code %q is now mapped to LunchMoney category %d\n", categoryCode, mapping.LunchMoneyCategoryId)
+}
+
+func (r *replState) deleteCategoryMapping(categoryCode string) {
+	if err := r.db.DeleteCategoryMapping(categoryCode); err != nil {
+		log.Error().Err(err).Msg("Error removing category mapping")
+		return
+	}
+	fmt.Printf("Removed the mapping of category code %q, it will be mapped the next time it is seen\n", categoryCode)
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/notify"
+	"github.com/vpnda/sandwich-sync/pkg/scheduler"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+)
+
+// daemon fetches from the providers and syncs on their schedules. The authenticated
+// fetchers are kept between runs, they are only accessed from the scheduled runs
+// which never overlap, so neither do the database writes.
+type daemon struct {
+	r        *replState
+	cfg      *config.Config
+	fetchers map[string]http.Fetcher
+}
+
+// runDaemon schedules a fetch and sync for every configured provider and runs them
+// until ctx is canceled
+func (r *replState) runDaemon(ctx context.Context) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	d := &daemon{r: r, cfg: cfg, fetchers: make(map[string]http.Fetcher)}
+	jobs := make([]scheduler.Job, 0)
+	for _, provider := range http.Providers() {
+		if err := provider.ValidateConfig(cfg); err != nil {
+			log.Warn().Err(err).Str("provider", provider.Name).Msg("Skipping provider that is not configured")
+			continue
+		}
+
+		spec := cfg.Daemon.ProviderSchedule(provider.Name)
+		schedule, err := scheduler.Parse(spec)
+		if err != nil {
+			return fmt.Errorf("invalid schedule for provider %s: %w", provider.Name, err)
+		}
+		jobs = append(jobs, scheduler.Job{
+			Name:     provider.Name,
+			Schedule: schedule,
+			Run: func(ctx context.Context) error {
+				err := d.fetchAndSync(ctx, provider)
+				writeMetricsFile()
+				return err
+			},
+		})
+		log.Info().Str("provider", provider.Name).Str("schedule", spec).Msg("Provider scheduled")
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no configured providers to schedule")
+	}
+
+	scheduler.New(jobs, cfg.Daemon.Backoff()).Run(ctx)
+	log.Info().Msg("Daemon stopped")
+	return nil
+}
+
+// fetchAndSync fetches from a provider, reusing its fetcher from the previous run,
+// and syncs with LunchMoney
+func (d *daemon) fetchAndSync(ctx context.Context, provider http.Provider) error {
+	window, err := windowFlags{}.resolve(d.cfg.ProviderSyncWindowDays(provider.Name))
+	if err != nil {
+		return err
+	}
+
+	recorder := services.StartRun(d.r.db, models.SyncRunKindFetch, provider.Name)
+	client, ok := d.fetchers[provider.Name]
+	if !ok {
+		client, err = provider.NewFetcher(ctx, d.cfg)
+		if err != nil {
+			recorder.Finish(err)
+			d.r.notifyFetchFailure(ctx, provider.Name, notify.KindAuthFailure, err)
+			return fmt.Errorf("failed to create client: %w", err)
+		}
+		d.fetchers[provider.Name] = client
+	}
+
+	err = d.r.syncFromFetcher(ctx, client, window, window, provider.Name, recorder)
+	recorder.Finish(err)
+	if err != nil {
+		// the session may have expired, authenticate again on the next attempt
+		delete(d.fetchers, provider.Name)
+		d.r.notifyFetchFailure(ctx, provider.Name, notify.KindFetchError, err)
+		return err
+	}
+	d.r.resolveFetchFailures(provider.Name)
+
+	if err := d.r.setSyncWindow(windowFlags{}); err != nil {
+		return err
+	}
+	if err := d.r.syncState(ctx); err != nil {
+		return err
+	}
+	d.r.reportUnmapped()
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+)
+
+const duplicatesUsage = `Usage: duplicates [list]
+       duplicates confirm <ref> <lunchmoney_id>
+       duplicates reject <ref>`
+
+// handleDuplicates parses the duplicates command
+// Format: duplicates [list|confirm <ref> <lunchmoney_id>|reject <ref>]
+func (r *replState) handleDuplicates(input string) {
+	parts := strings.Fields(input)
+	if len(parts) == 1 {
+		r.listDuplicates()
+		return
+	}
+
+	switch {
+	case parts[1] == "list" && len(parts) == 2:
+		r.listDuplicates()
+	case parts[1] == "confirm" && len(parts) == 4:
+		r.confirmDuplicate(parts[2], parts[3])
+	case parts[1] == "reject" && len(parts) == 3:
+		r.rejectDuplicate(parts[2])
+	default:
+		fmt.Println("Invalid duplicates command format.")
+		fmt.Println(duplicatesUsage)
+	}
+}
+
+// listDuplicates prints the transactions that may already exist in LunchMoney
+func (r *replState) listDuplicates() {
+	matches, err := r.lmSyncer.FindAmbiguousDuplicates(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Error finding possible duplicates")
+		return
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No possible duplicates to review")
+		return
+	}
+
+	fmt.Printf("Found %d transactions that may already exist in LunchMoney:\n\n", len(matches))
+	printDuplicates(matches)
+	fmt.Println("Use 'duplicates confirm <ref> <lunchmoney_id>' to link a transaction to its duplicate, or")
+	fmt.Println("'duplicates reject <ref>' to insert it into LunchMoney with the next sync.")
+}
+
+func printDuplicates(matches []*services.DuplicateMatch) {
+	for _, match := range matches {
+		tx := match.Transaction
+		fmt.Printf("%s  %s %s  %s  %s\n", tx.ReferenceNumber, tx.Amount.Value, tx.Amount.Currency, tx.Date, tx.Merchant.Name)
+		for _, candidate := range match.Candidates {
+			lunchTx := candidate.LunchMoney
+			fmt.Printf("  %-12d %-12s %-30s %s\n",
+				lunchTx.LunchMoneyID,
+				lunchTx.Date,
+				lunchTx.Merchant.Name[:min(30, len(lunchTx.Merchant.Name))],
+				candidate.Reason)
+		}
+		fmt.Println()
+	}
+}
+
+func (r *replState) confirmDuplicate(ref string, idArg string) {
+	lunchMoneyID, err := strconv.ParseInt(idArg, 10, 64)
+	if err != nil {
+		fmt.Println("Invalid LunchMoney ID. Must be a number.")
+		return
+	}
+
+	if err := r.lmSyncer.ConfirmDuplicate(context.Background(), ref, lunchMoneyID); err != nil {
+		log.Error().Err(err).Str("transaction", ref).Msg("Error confirming duplicate")
+		return
+	}
+	log.Info().Str("transaction", ref).Int64("lunchMoneyId", lunchMoneyID).Msg("Transaction linked to its LunchMoney duplicate")
+}
+
+func (r *replState) rejectDuplicate(ref string) {
+	if err := r.lmSyncer.RejectDuplicate(context.Background(), ref); err != nil {
+		log.Error().Err(err).Str("transaction", ref).Msg("Error rejecting duplicates")
+		return
+	}
+	log.Info().Str("transaction", ref).Msg("Transaction is inserted into LunchMoney by the next sync")
+}
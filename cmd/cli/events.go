@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// serverEvent is sent to the clients of /api/events, Type is the event name and
+// Data is sent as JSON
+type serverEvent struct {
+	Type string
+	Data any
+}
+
+// eventBroker fans the server events out to the subscribed clients. It is also an
+// io.Writer for zerolog, so the log entries of the runs are streamed as log events.
+type eventBroker struct {
+	mu      sync.Mutex
+	clients map[chan serverEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{clients: make(map[chan serverEvent]struct{})}
+}
+
+func (b *eventBroker) subscribe() chan serverEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := make(chan serverEvent, 64)
+	b.clients[events] = struct{}{}
+	return events
+}
+
+func (b *eventBroker) unsubscribe(events chan serverEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.clients, events)
+}
+
+// publish sends an event to every client, clients too slow to keep up miss it
+func (b *eventBroker) publish(event serverEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.clients {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// Write publishes a JSON log entry written by zerolog. It must not log itself.
+func (b *eventBroker) Write(p []byte) (int, error) {
+	entry := bytes.TrimSpace(p)
+	if json.Valid(entry) {
+		// zerolog reuses its buffer once Write returns
+		b.publish(serverEvent{Type: "log", Data: json.RawMessage(bytes.Clone(entry))})
+	}
+	return len(p), nil
+}
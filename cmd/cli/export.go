@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/export"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const exportUsage = `Usage: export <transactions|balances> [--format csv|jsonl|ledger] [--output <file>]
+       [--since YYYY-MM-DD] [--until YYYY-MM-DD] [--account <external name>] [--status synced|unsynced|ignored]`
+
+// exportFlags holds the options of the export command
+type exportFlags struct {
+	format  string
+	output  string
+	account string
+	status  string
+	window  windowFlags
+}
+
+// processExport parses the export command
+// Format: export <transactions|balances> [--format <format>] [--output <file>] [--since <date>] [--until <date>] [--account <name>] [--status <status>]
+func (r *replState) processExport(input string) {
+	flags := exportFlags{format: string(export.FormatCSV)}
+	parts, err := flags.window.parseArgs(utils.SplitQuoted(input))
+
+	args := make([]string, 0, len(parts))
+	for i := 0; err == nil && i < len(parts); i++ {
+		var dest *string
+		switch parts[i] {
+		case "--format":
+			dest = &flags.format
+		case "--output", "-o":
+			dest = &flags.output
+		case "--account":
+			dest = &flags.account
+		case "--status":
+			dest = &flags.status
+		default:
+			args = append(args, parts[i])
+			continue
+		}
+		if i+1 >= len(parts) {
+			err = fmt.Errorf("missing value after %s", parts[i])
+			break
+		}
+		*dest = parts[i+1]
+		i++
+	}
+
+	if err != nil || len(args) != 2 {
+		fmt.Println("Invalid export command format.")
+		fmt.Println(exportUsage)
+		return
+	}
+	r.export(args[1], flags)
+}
+
+// export writes the transactions or balances of the local database to a file or stdout
+func (r *replState) export(what string, flags exportFlags) {
+	format, err := export.ParseFormat(flags.format)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid export format")
+		return
+	}
+
+	filter := &models.TransactionFilter{SourceAccount: flags.account, SortBy: models.SortByDate, Ascending: true}
+	if filter.Window, err = flags.window.resolveOptional(); err != nil {
+		log.Error().Err(err).Msg("Invalid export window")
+		return
+	}
+	if flags.status != "" {
+		if filter.SyncState, err = models.ParseSyncState(flags.status); err != nil {
+			log.Error().Err(err).Msg("Invalid export status")
+			return
+		}
+	}
+
+	liabilities := r.liabilityAccounts()
+
+	var write func(w io.Writer) error
+	var count int
+	switch strings.ToLower(what) {
+	case "transactions", "tx":
+		transactions, err := r.db.FilterTransactions(filter)
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching transactions")
+			return
+		}
+		sourceAccounts := lo.Uniq(lo.Map(transactions, func(tx *models.TransactionWithAccount, _ int) string {
+			return tx.SourceAccountName
+		}))
+		mappings, err := r.accountMappings(context.Background(), sourceAccounts)
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching account mappings")
+			return
+		}
+		records := export.NewTransactionRecords(transactions, mappings, liabilities)
+		count = len(records)
+		write = func(w io.Writer) error { return export.WriteTransactions(w, format, records) }
+	case "balances":
+		mappings, err := r.accountMappings(context.Background(), nil)
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching account mappings")
+			return
+		}
+		records := export.NewBalanceRecords(mappings, flags.account, liabilities)
+		count = len(records)
+		write = func(w io.Writer) error { return export.WriteBalances(w, format, records) }
+	default:
+		fmt.Printf("Unknown export %s, use transactions or balances\n", what)
+		return
+	}
+
+	if flags.output == "" || flags.output == "-" {
+		if err := write(os.Stdout); err != nil {
+			log.Error().Err(err).Msg("Error exporting")
+		}
+		return
+	}
+
+	f, err := os.Create(flags.output)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating export file")
+		return
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		log.Error().Err(err).Msg("Error exporting")
+		return
+	}
+	// a failed close may lose the last buffered writes
+	if err := f.Close(); err != nil {
+		log.Error().Err(err).Msg("Error writing export file")
+		return
+	}
+	log.Info().Int("count", count).Str("file", flags.output).Msg("Export written")
+}
+
+// liabilityAccounts returns the LunchMoney accounts that are credit cards or loans,
+// without them every account is exported as an asset
+func (r *replState) liabilityAccounts() map[int64]bool {
+	liabilities := make(map[int64]bool)
+	accounts, err := r.lmSyncer.GetClient().ListAccounts(context.Background())
+	if err != nil {
+		log.Warn().Err(err).Msg("Error fetching LunchMoney accounts, exporting every account as an asset")
+		return liabilities
+	}
+	for _, account := range accounts {
+		if account.IsLiability() {
+			liabilities[account.LunchMoneyId] = true
+		}
+	}
+	return liabilities
+}
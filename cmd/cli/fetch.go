@@ -2,109 +2,146 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vpnda/sandwich-sync/pkg/config"
 	"github.com/vpnda/sandwich-sync/pkg/http"
-	"github.com/vpnda/sandwich-sync/pkg/http/rogers"
-	"github.com/vpnda/sandwich-sync/pkg/http/scotia"
-	"github.com/vpnda/sandwich-sync/pkg/http/ws"
 	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/notify"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+
+	// Register the supported providers
+	_ "github.com/vpnda/sandwich-sync/pkg/http/rogers"
+	_ "github.com/vpnda/sandwich-sync/pkg/http/scotia"
+	_ "github.com/vpnda/sandwich-sync/pkg/http/ws"
 )
 
 func (r *replState) processTransactionFetch(trimmedLine string) {
 	// Parse the fetch command
-	parts := strings.Fields(trimmedLine)
-	if len(parts) < 2 {
+	// Format: fetch <type> [--since <date>] [--until <date>]
+	var wf windowFlags
+	parts, err := wf.parseArgs(strings.Fields(trimmedLine))
+	if err != nil || len(parts) < 2 {
 		fmt.Println("Invalid fetch command format.")
-		fmt.Println("Usage: fetch <type>")
+		fmt.Println("Usage: fetch <type> [--since YYYY-MM-DD] [--until YYYY-MM-DD]")
 		fmt.Println("Example: fetch wealthsimple")
 		return
 	}
+	r.fetch(context.Background(), parts[1], wf)
+}
 
-	fetchTypes := []string{parts[1]}
-	if fetchTypes[0] == "all" {
-		fetchTypes = []string{"wealthsimple", "rogers", "scotia"}
+// fetch fetches balances and transactions from a provider, or from every configured one
+// for "all", and returns the errors of the providers that failed
+func (r *replState) fetch(ctx context.Context, providerName string, wf windowFlags) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading configuration")
+		return err
 	}
 
-	for _, fetfetchTypes := range fetchTypes {
-		switch fetfetchTypes {
-		case "wealthsimple":
-			r.fetchTransactionsWs()
-		case "rogers":
-			r.fetchTransactionsRogers()
-		case "scotia":
-			r.fetchTransactionsScotia()
-		default:
-			fmt.Println("Unknown fetch type. Supported types are: wealthsimple, rogers, scotia, all")
+	if providerName == "all" {
+		errs := make([]error, 0)
+		for _, provider := range http.Providers() {
+			if err := provider.ValidateConfig(cfg); err != nil {
+				log.Warn().Err(err).Str("provider", provider.Name).Msg("Skipping provider that is not configured")
+				continue
+			}
+			if err := r.fetchFromProvider(ctx, provider, cfg, wf); err != nil {
+				errs = append(errs, fmt.Errorf("failed to fetch from %s: %w", provider.Name, err))
+			}
 		}
+		return errors.Join(errs...)
+	}
+
+	provider, ok := http.GetProvider(providerName)
+	if !ok {
+		fmt.Printf("Unknown fetch type. Supported types are: %s, all\n", strings.Join(http.ProviderNames(), ", "))
+		return fmt.Errorf("unknown provider %s", providerName)
 	}
+	return r.fetchFromProvider(ctx, provider, cfg, wf)
 }
 
-func (r *replState) fetchTransactionsScotia() {
-	client, err := scotia.NewScotiaClient()
+func (r *replState) fetchFromProvider(ctx context.Context, provider http.Provider, cfg *config.Config, wf windowFlags) error {
+	window, err := wf.resolve(cfg.ProviderSyncWindowDays(provider.Name))
 	if err != nil {
-		log.Error().Err(err).Msg("Error creating Scotia client")
-		return
-	}
-	if err := client.AuthenticateDynamic(context.Background()); err != nil {
-		log.Error().Err(err).Msg("Error authenticating Scotia client")
-		return
+		log.Error().Err(err).Msg("Invalid fetch window")
+		return err
 	}
-	r.syncFromFetcher(client)
-}
 
-func (r *replState) fetchTransactionsWs() {
-	client, err := ws.NewWealthsimpleClient(context.Background())
+	recorder := services.StartRun(r.db, models.SyncRunKindFetch, provider.Name)
+	client, err := provider.NewFetcher(ctx, cfg)
 	if err != nil {
-		log.Error().Err(err).Msg("Error creating Wealthsimple client")
-		return
+		log.Error().Err(err).Str("provider", provider.Name).Msg("Error creating client")
+		recorder.Finish(err)
+		r.notifyFetchFailure(ctx, provider.Name, notify.KindAuthFailure, err)
+		return err
+	}
+	live := models.LastDays(cfg.ProviderSyncWindowDays(provider.Name))
+	err = r.syncFromFetcher(ctx, client, window, live, provider.Name, recorder)
+	recorder.Finish(err)
+	if err != nil {
+		r.notifyFetchFailure(ctx, provider.Name, notify.KindFetchError, err)
+		return err
 	}
-	r.syncFromFetcher(client)
+	r.resolveFetchFailures(provider.Name)
+	return nil
 }
 
-func (r *replState) fetchTransactionsRogers() {
-	deviceId, err := config.GetRogersDeviceId()
+// syncFromFetcher stores the balances and the transactions of the window fetched from
+// client, the transactions are tagged with their source and the ones missing from the
+// window are reported as vanished. Large transactions are only notified within the
+// live window, the provider's usual one, so backfills do not notify of old ones.
+func (r *replState) syncFromFetcher(ctx context.Context, client http.Fetcher, window models.DateRange,
+	live models.DateRange, source string, recorder *services.RunRecorder) error {
+	transactions, err := r.storeFromFetcher(ctx, client, window, &live, source, recorder)
 	if err != nil {
-		log.Error().Err(err).Msg("Error getting Rogers device ID")
-		return
+		return err
 	}
-	client := rogers.NewRogersBankClient(deviceId)
 
-	username, password, err := config.GetRogersCredentials()
+	vanished, err := services.RecordFetchWindows(r.db, window, transactions)
 	if err != nil {
-		log.Error().Err(err).Msg("Error getting Rogers credentials")
-		return
+		log.Error().Err(err).Msg("Error recording fetch window")
+		return err
 	}
-
-	if err := client.Authenticate(context.Background(), username, password); err != nil {
-		log.Error().Err(err).Msg("Error authenticating Rogers client")
-		return
+	for _, ref := range vanished {
+		if tx, err := r.db.GetTransactionByReference(ref); err == nil && tx != nil {
+			recorder.Record(&tx.Transaction, models.TransactionEventUpdated, "vanished upstream")
+		}
 	}
-	r.syncFromFetcher(client)
+	if len(vanished) != 0 {
+		log.Warn().Int("count", len(vanished)).Msg("Transactions vanished upstream, review them with 'vanished list'")
+	}
+	return nil
 }
 
-func (r *replState) syncFromFetcher(client http.Fetcher) {
-	accountBalances, err := client.FetchAccountBalances(context.Background())
+// storeFromFetcher stores the balances and the transactions of the window fetched from
+// client and returns the transactions, see insertTransactionsToDb for live
+func (r *replState) storeFromFetcher(ctx context.Context, client http.Fetcher, window models.DateRange,
+	live *models.DateRange, source string, recorder *services.RunRecorder) ([]models.TransactionWithAccount, error) {
+	accountBalances, err := client.FetchAccountBalances(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("Error fetching account balances")
-		return
+		return nil, err
 	}
 	err = r.updateAccountBalances(accountBalances)
 	if err != nil {
 		log.Error().Err(err).Msg("Error updating account balances")
-		return
+		return nil, err
 	}
 
 	// Fetch transactions
-	transactions, err := client.FetchTransactions(context.Background())
+	transactions, err := client.FetchTransactions(ctx, window)
 	if err != nil {
 		log.Error().Err(err).Msg("Error fetching transactions")
-		return
+		return nil, err
 	}
-	r.insertTransactionsToDb(transactions)
+	r.insertTransactionsToDb(ctx, transactions, live, source, recorder)
+	r.notifyUnmapped(ctx)
+	return transactions, nil
 }
 
 func (r *replState) updateAccountBalances(accountBalances []models.ExternalAccount) error {
@@ -114,7 +151,11 @@ func (r *replState) updateAccountBalances(accountBalances []models.ExternalAccou
 			log.Error().Err(err).Msg("Error finding account mapping")
 			continue
 		}
-		if err := r.db.UpsertAccountBalance(account.Name, account.Balance); err != nil {
+		asOf := account.BalanceAsOf
+		if asOf.IsZero() {
+			asOf = time.Now()
+		}
+		if err := r.db.UpsertAccountBalance(account.Name, account.Balance, asOf); err != nil {
 			log.Error().Err(err).Msg("Error updating account balance")
 			return err
 		}
@@ -123,11 +164,38 @@ func (r *replState) updateAccountBalances(accountBalances []models.ExternalAccou
 	return nil
 }
 
-func (r *replState) insertTransactionsToDb(transactions []models.TransactionWithAccount) {
-	inserted, skipped := 0, 0
+// insertTransactionsToDb stores the fetched transactions and updates the ones that
+// changed upstream. New transactions within live are checked for large amounts, it is
+// nil for imported statements.
+func (r *replState) insertTransactionsToDb(ctx context.Context, transactions []models.TransactionWithAccount,
+	live *models.DateRange, source string, recorder *services.RunRecorder) {
+	inserted, updated, skipped := 0, 0, 0
+	normalizer, tagger := r.lmSyncer.GetMerchantNormalizer(), r.lmSyncer.GetTagger()
 	for _, tx := range transactions {
-		if tx, err := r.db.GetTransactionByReference(tx.ReferenceNumber); tx != nil && err == nil {
-			skipped++
+		// stored names are normalized, so they are compared after the rules apply
+		normalizer.Normalize(&tx.Transaction)
+		tx.AddTags(models.SourceTag(source))
+		tagger.Tag(&tx)
+
+		if existing, err := r.db.GetTransactionByReference(tx.ReferenceNumber); existing != nil && err == nil {
+			if !hasUpstreamChanges(existing, &tx) {
+				skipped++
+				continue
+			}
+
+			// Keep the upstream edit locally, the syncer pushes it to LunchMoney. Notes
+			// and tags added locally are kept.
+			tx.Notes = existing.Notes
+			tags := tx.Tags
+			tx.Tags = existing.Tags
+			tx.AddTags(tags...)
+			if err := r.db.SaveTransaction(&tx); err != nil {
+				log.Error().Err(err).Msg("Error updating transaction")
+				continue
+			}
+			log.Info().Str("transaction", tx.ReferenceNumber).Msg("Transaction updated with upstream changes")
+			recorder.Record(&tx.Transaction, models.TransactionEventUpdated, "changed upstream")
+			updated++
 			continue
 		} else if err != nil {
 			log.Error().Err(err).Msg("Error checking transaction")
@@ -140,7 +208,26 @@ func (r *replState) insertTransactionsToDb(transactions []models.TransactionWith
 			continue
 		}
 		log.Info().Str("transaction", tx.ReferenceNumber).Msg("Transaction saved successfully")
+		recorder.Record(&tx.Transaction, models.TransactionEventFetched, "fetched from "+tx.SourceAccountName)
+		if live != nil {
+			r.notifyLargeTransaction(ctx, &tx, *live)
+		}
 		inserted++
 	}
-	log.Info().Int("inserted", inserted).Int("skipped", skipped).Msg("Transactions processed")
+	recorder.SetCounts(len(transactions), inserted, updated, skipped)
+	log.Info().Int("inserted", inserted).Int("updated", updated).Int("skipped", skipped).Msg("Transactions processed")
+
+	r.reportTransfers(recorder)
+}
+
+// hasUpstreamChanges returns true if the provider changed the fields of a transaction we already stored
+func hasUpstreamChanges(existing *models.TransactionWithAccount, fetched *models.TransactionWithAccount) bool {
+	if fetched.Merchant == nil {
+		return false
+	}
+	return !existing.Amount.Equal(&fetched.Amount) ||
+		existing.Date != fetched.Date ||
+		existing.Merchant.Name != fetched.Merchant.Name ||
+		existing.Status != fetched.Status ||
+		existing.PostedDate != fetched.PostedDate
 }
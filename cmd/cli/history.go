@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+const defaultHistoryRuns = 20
+
+// handleHistory shows the recorded runs or the audit trail of a run or transaction
+// Format: history [run <id>|<ref>]
+func (r *replState) handleHistory(input string) {
+	parts := strings.Fields(input)
+	switch {
+	case len(parts) == 1:
+		r.listRuns(defaultHistoryRuns)
+	case len(parts) == 3 && parts[1] == "run":
+		runID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid run id %s\n", parts[2])
+			return
+		}
+		r.showRunEvents(runID)
+	case len(parts) == 2:
+		r.showTransactionHistory(parts[1])
+	default:
+		fmt.Println("Invalid history command format.")
+		fmt.Println("Usage: history [run <id>|<ref>]")
+	}
+}
+
+func (r *replState) listRuns(limit int) {
+	runs, err := r.db.GetSyncRuns(limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching sync runs")
+		return
+	}
+
+	if len(runs) == 0 {
+		fmt.Println("No runs recorded")
+		return
+	}
+
+	fmt.Printf("%-6s %-6s %-10s %-20s %-10s %-8s %-9s %-8s %-8s %s\n",
+		"Run", "Kind", "Provider", "Started At", "Duration", "Fetched", "Inserted", "Updated", "Skipped", "Error")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, run := range runs {
+		duration := "running"
+		if run.FinishedAt != nil {
+			duration = run.FinishedAt.Sub(run.StartedAt).Round(100 * time.Millisecond).String()
+		}
+		fmt.Printf("%-6d %-6s %-10s %-20s %-10s %-8d %-9d %-8d %-8d %s\n",
+			run.ID, run.Kind, run.Provider, run.StartedAt.Local().Format("2006-01-02 15:04:05"), duration,
+			run.Fetched, run.Inserted, run.Updated, run.Skipped, run.Error)
+	}
+}
+
+func (r *replState) showRunEvents(runID int64) {
+	events, err := r.db.GetSyncRunEvents(runID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching run events")
+		return
+	}
+
+	if len(events) == 0 {
+		fmt.Printf("No transaction events recorded for run %d\n", runID)
+		return
+	}
+	printTransactionEvents(events, true)
+}
+
+func (r *replState) showTransactionHistory(referenceNumber string) {
+	events, err := r.db.GetTransactionEvents(referenceNumber)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching transaction events")
+		return
+	}
+
+	if len(events) == 0 {
+		fmt.Printf("No history recorded for transaction %s\n", referenceNumber)
+		return
+	}
+	printTransactionEvents(events, false)
+}
+
+// printTransactionEvents prints an audit trail, withReference adds the transaction column
+func printTransactionEvents(events []*models.TransactionEvent, withReference bool) {
+	if withReference {
+		fmt.Printf("%-30s ", "Reference Number")
+	}
+	fmt.Printf("%-20s %-6s %-8s %-14s %s\n", "Time", "Run", "Event", "LunchMoney ID", "Detail")
+	fmt.Println(strings.Repeat("-", 100))
+	for _, event := range events {
+		if withReference {
+			fmt.Printf("%-30s ", event.ReferenceNumber[:min(30, len(event.ReferenceNumber))])
+		}
+		run, lunchMoneyID := "-", "-"
+		if event.RunID != 0 {
+			run = strconv.FormatInt(event.RunID, 10)
+		}
+		if event.LunchMoneyID != 0 {
+			lunchMoneyID = strconv.FormatInt(event.LunchMoneyID, 10)
+		}
+		fmt.Printf("%-20s %-6s %-8s %-14s %s\n",
+			event.CreatedAt.Local().Format("2006-01-02 15:04:05"), run, event.Event, lunchMoneyID, event.Detail)
+	}
+}
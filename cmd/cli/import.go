@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/csvimport"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/parser/ofx"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const importUsage = `Usage: import csv <file> --profile <name> --account <external name> [--since YYYY-MM-DD] [--until YYYY-MM-DD]
+       import ofx <file> [--account <external name>] [--since YYYY-MM-DD] [--until YYYY-MM-DD]`
+
+// importFlags holds the options of the import command
+type importFlags struct {
+	profile string
+	account string
+	window  windowFlags
+}
+
+// processImport parses the import command
+// Format: import csv <file> --profile <name> --account <external name> [--since <date>] [--until <date>]
+// Format: import ofx <file> [--account <external name>] [--since <date>] [--until <date>]
+func (r *replState) processImport(input string) {
+	var flags importFlags
+	parts, err := flags.window.parseArgs(utils.SplitQuoted(input))
+
+	args := make([]string, 0, len(parts))
+	for i := 0; err == nil && i < len(parts); i++ {
+		switch parts[i] {
+		case "--profile", "--account":
+			if i+1 >= len(parts) {
+				err = fmt.Errorf("missing value after %s", parts[i])
+				break
+			}
+			if parts[i] == "--profile" {
+				flags.profile = parts[i+1]
+			} else {
+				flags.account = parts[i+1]
+			}
+			i++
+		default:
+			args = append(args, parts[i])
+		}
+	}
+
+	if err != nil || len(args) != 3 {
+		fmt.Println("Invalid import command format.")
+		fmt.Println(importUsage)
+		return
+	}
+
+	switch args[1] {
+	case "csv":
+		r.importCSV(args[2], flags)
+	case "ofx", "qfx":
+		r.importOFX(args[2], flags)
+	default:
+		fmt.Printf("Unknown import format %s, supported formats are csv and ofx\n", args[1])
+	}
+}
+
+// importCSV stores the transactions of a CSV statement like the ones of a fetch,
+// the whole statement is imported unless --since or --until is given
+func (r *replState) importCSV(path string, flags importFlags) {
+	if flags.profile == "" || flags.account == "" {
+		fmt.Println("Both --profile and --account are required.")
+		fmt.Println(importUsage)
+		return
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading configuration")
+		return
+	}
+	profile, err := cfg.GetCSVProfile(flags.profile)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading CSV profile")
+		return
+	}
+
+	var window models.DateRange
+	if flags.window.since != "" || flags.window.until != "" {
+		if window, err = flags.window.resolve(cfg.SyncWindowDays()); err != nil {
+			log.Error().Err(err).Msg("Invalid import window")
+			return
+		}
+	}
+
+	recorder := services.StartRun(r.db, models.SyncRunKindFetch, "csv")
+	importer := csvimport.NewImporter(path, profile, flags.account)
+	transactions, err := importer.FetchTransactions(context.Background(), window)
+	if err != nil {
+		log.Error().Err(err).Msg("Error importing CSV statement")
+		recorder.Finish(err)
+		return
+	}
+	log.Info().Int("count", len(transactions)).Str("account", flags.account).Msg("Read transactions from CSV statement")
+
+	r.insertTransactionsToDb(context.Background(), transactions, nil, "csv", recorder)
+	recorder.Finish(nil)
+}
+
+// importOFX stores the balances and transactions of an OFX or QFX file like a live
+// provider. An old statement says nothing about the transactions fetched since, so
+// transactions missing from it are not reported as vanished.
+func (r *replState) importOFX(path string, flags importFlags) {
+	if flags.profile != "" {
+		fmt.Println("OFX files need no --profile.")
+		fmt.Println(importUsage)
+		return
+	}
+
+	fetcher := ofx.NewFileFetcher(path, flags.account)
+	window, err := fetcher.Window()
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading OFX file")
+		return
+	}
+	if flags.window.since != "" || flags.window.until != "" {
+		if window, err = flags.window.resolve(config.DefaultSyncWindowDays); err != nil {
+			log.Error().Err(err).Msg("Invalid import window")
+			return
+		}
+	}
+
+	recorder := services.StartRun(r.db, models.SyncRunKindFetch, "ofx")
+	_, err = r.storeFromFetcher(context.Background(), fetcher, window, nil, "ofx", recorder)
+	recorder.Finish(err)
+}
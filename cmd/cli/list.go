@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const defaultListLimit = 50
+
+// listFlags holds the filters, sort and page of the list command
+type listFlags struct {
+	window        windowFlags
+	account       string
+	merchant      string
+	merchantRegex string
+	minAmount     string
+	maxAmount     string
+	status        string
+	currency      string
+	tag           string
+	sortBy        string
+	ascending     bool
+	limit         int
+	page          int
+}
+
+func (l *listFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&l.window.since, "since", "", "Only transactions on or after this date (YYYY-MM-DD)")
+	fs.StringVar(&l.window.until, "until", "", "Only transactions on or before this date (YYYY-MM-DD)")
+	fs.StringVar(&l.account, "account", "", "Only transactions of this external account")
+	fs.StringVar(&l.merchant, "merchant", "", "Only merchants containing this text, ignoring case")
+	fs.StringVar(&l.merchantRegex, "merchant-regex", "", "Only merchants matching this regular expression")
+	fs.StringVar(&l.minAmount, "min", "", "Minimum amount, outflows are positive")
+	fs.StringVar(&l.maxAmount, "max", "", "Maximum amount, outflows are positive")
+	fs.StringVar(&l.status, "status", "", "Only synced, unsynced or ignored transactions")
+	fs.StringVar(&l.currency, "currency", "", "Only transactions in this currency")
+	fs.StringVar(&l.tag, "tag", "", "Only transactions with this tag, ignoring case")
+	fs.StringVar(&l.sortBy, "sort", string(models.SortByDate), "Sort by date, amount, merchant or account")
+	fs.BoolVar(&l.ascending, "asc", false, "Sort in ascending order")
+	fs.IntVar(&l.limit, "limit", defaultListLimit, "Number of transactions per page, 0 lists all of them")
+	fs.IntVar(&l.page, "page", 1, "Page to show")
+}
+
+// filter builds the database filter of the flags
+func (l *listFlags) filter() (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{
+		SourceAccount: l.account,
+		Merchant:      l.merchant,
+		Currency:      l.currency,
+		Tag:           l.tag,
+		Ascending:     l.ascending,
+		Limit:         l.limit,
+	}
+
+	var err error
+	if filter.Window, err = l.window.resolveOptional(); err != nil {
+		return nil, err
+	}
+	if l.merchantRegex != "" {
+		if filter.MerchantPattern, err = regexp.Compile("(?i)" + l.merchantRegex); err != nil {
+			return nil, fmt.Errorf("invalid --merchant-regex: %w", err)
+		}
+	}
+	for _, bound := range []struct {
+		flag  string
+		value string
+		dest  **float64
+	}{{"--min", l.minAmount, &filter.MinAmount}, {"--max", l.maxAmount, &filter.MaxAmount}} {
+		if bound.value == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(bound.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s amount: %w", bound.flag, err)
+		}
+		*bound.dest = &amount
+	}
+	if l.status != "" {
+		if filter.SyncState, err = models.ParseSyncState(l.status); err != nil {
+			return nil, err
+		}
+	}
+	if filter.SortBy, err = models.ParseTransactionSort(l.sortBy); err != nil {
+		return nil, err
+	}
+	if l.limit < 0 || l.page < 1 {
+		return nil, fmt.Errorf("--limit must not be negative and --page must be at least 1")
+	}
+	filter.Offset = (l.page - 1) * l.limit
+	return filter, nil
+}
+
+// processList parses the list command
+// Format: list [<ref>] [--since <date>] [--until <date>] [--account <name>] [--merchant <text>] [--merchant-regex <re>]
+// [--min <amount>] [--max <amount>] [--status <status>] [--currency <code>] [--sort <field>] [--asc] [--limit <n>] [--page <n>]
+func (r *replState) processList(input string) {
+	var flags listFlags
+	fs := pflag.NewFlagSet("list", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags.register(fs)
+	if err := fs.Parse(utils.SplitQuoted(input)[1:]); err != nil {
+		fmt.Printf("Invalid list command format: %v\n", err)
+		fmt.Println("Usage: list [<ref>] [flags]")
+		fmt.Print(fs.FlagUsages())
+		return
+	}
+
+	switch fs.NArg() {
+	case 0:
+		r.listTransactions(flags)
+	case 1:
+		r.showTransaction(fs.Arg(0))
+	default:
+		fmt.Println("Usage: list [<ref>] [flags]")
+	}
+}
+
+func (r *replState) listTransactions(flags listFlags) {
+	filter, err := flags.filter()
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid list filter")
+		return
+	}
+
+	// one more row tells whether there is a next page
+	if filter.Limit > 0 {
+		filter.Limit++
+	}
+	transactions, err := r.db.FilterTransactions(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching transactions")
+		return
+	}
+	hasMore := filter.Limit > 0 && len(transactions) == filter.Limit
+	if hasMore {
+		transactions = transactions[:len(transactions)-1]
+	}
+
+	if len(transactions) == 0 {
+		fmt.Println("No transactions found")
+		return
+	}
+
+	headers := []string{"SourceAccount", "Reference Number", "Amount", "Merchant Name", "Date", "Status", "LunchMoney ID"}
+	rows := make([][]string, 0, len(transactions))
+	for _, tx := range transactions {
+		rows = append(rows, []string{
+			tx.SourceAccountName,
+			tx.ReferenceNumber,
+			tx.Amount.Value + " " + tx.Amount.Currency,
+			tx.Merchant.Name,
+			tx.Date,
+			string(tx.Status),
+			strconv.FormatInt(tx.LunchMoneyID, 10),
+		})
+	}
+
+	fmt.Printf("Showing transactions %d-%d:\n\n", filter.Offset+1, filter.Offset+len(transactions))
+	printTable(headers, rows)
+	if hasMore {
+		fmt.Printf("\nMore transactions match, use --page %d to see them\n", flags.page+1)
+	}
+}
+
+// showTransaction prints the details of one transaction
+func (r *replState) showTransaction(referenceNumber string) {
+	tx, err := r.db.GetTransactionByReference(referenceNumber)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching transaction")
+		return
+	}
+	if tx == nil {
+		fmt.Printf("No transaction with reference number %s\n", referenceNumber)
+		return
+	}
+
+	tx.PrintFormatted()
+	fmt.Printf("	Source Account: %s\n", tx.SourceAccountName)
+	if tx.Synced != nil {
+		fmt.Printf("	Last Synced: %s %s on %s\n", tx.Synced.Amount, tx.Synced.Payee, tx.Synced.Date)
+	}
+	if tx.VanishedAt != nil {
+		fmt.Printf("	Vanished At: %s %s\n", tx.VanishedAt.Format("2006-01-02 15:04"), tx.VanishedAction)
+	}
+	fmt.Printf("\nUse 'history %s' to see when and why it changed\n", referenceNumber)
+}
+
+// printTable prints rows in columns as wide as their longest value
+func printTable(headers []string, rows [][]string) {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = len(header)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			widths[i] = max(widths[i], len(value))
+		}
+	}
+
+	printRow := func(row []string) {
+		for i, value := range row {
+			if i == len(row)-1 {
+				fmt.Println(value)
+				break
+			}
+			fmt.Printf("%-*s ", widths[i], value)
+		}
+	}
+
+	printRow(headers)
+	total := len(widths) - 1
+	for _, width := range widths {
+		total += width
+	}
+	fmt.Println(strings.Repeat("-", total))
+	for _, row := range rows {
+		printRow(row)
+	}
+}
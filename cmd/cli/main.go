@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -14,15 +16,32 @@ import (
 
 	"github.com/vpnda/sandwich-sync/db"
 	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/export"
+	"github.com/vpnda/sandwich-sync/pkg/http"
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
 	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/notify"
 	"github.com/vpnda/sandwich-sync/pkg/services"
 )
 
 var (
-	dbPath  string
-	rootCmd *cobra.Command
+	dbPath         string
+	nonInteractive bool
+	metricsFile    string
+	vaultPath      string
+	rootCmd        *cobra.Command
 )
 
+// writeMetricsFile writes the metrics to the --metrics-file, if set
+func writeMetricsFile() {
+	if metricsFile == "" {
+		return
+	}
+	if err := metrics.WriteFile(metricsFile); err != nil {
+		log.Error().Err(err).Msg("Failed to write metrics file")
+	}
+}
+
 // Execute executes the root command
 func Execute() error {
 	return rootCmd.Execute()
@@ -50,9 +69,18 @@ func init() {
 		Use:   "lunchmoney",
 		Short: "A CLI tool for fetching and storing transactions",
 		Long:  `A CLI tool that fetches transactions from an API and stores them in a SQLite database.`,
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			writeMetricsFile()
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", defaultDBPath, "Path to the SQLite database")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false,
+		"Skip and report unmapped accounts instead of prompting, for scheduled runs")
+	rootCmd.PersistentFlags().StringVar(&metricsFile, "metrics-file", "",
+		"Write Prometheus metrics to this file for the node_exporter textfile collector")
+	rootCmd.PersistentFlags().StringVar(&vaultPath, "vault", filepath.Join(homeDir, ".lunchmoney", "secrets.vault"),
+		"Path to the encrypted vault holding the secret:// values of config.yaml")
 
 	replCmd := &cobra.Command{
 		Use:   "repl",
@@ -74,28 +102,554 @@ func init() {
 
 	rootCmd.AddCommand(replCmd, configCmd)
 
+	var fetchAndSyncWindow windowFlags
 	fetchAndSyncCmd := &cobra.Command{
 		Use:   "fetch-and-sync",
 		Short: "Fetch and sync transactions",
-		Long:  `Fetch transactions from the configured API and sync them with the database.`,
+		Long:  `Fetch transactions from every configured provider and sync them with the database.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := cmd.Context()
 			r := initReplState(ctx)
-			r.processTransactionFetch("fetch all")
-			r.syncState()
+			defer r.db.Close()
+			r.fetch(ctx, "all", fetchAndSyncWindow)
+			if err := r.setSyncWindow(fetchAndSyncWindow); err != nil {
+				log.Error().Err(err).Msg("Invalid sync window")
+				return
+			}
+			r.syncState(ctx)
+			r.reportUnmapped()
+		},
+	}
+	fetchAndSyncWindow.register(fetchAndSyncCmd)
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Fetch and sync on a schedule",
+		Long: `Fetch from every configured provider and sync with LunchMoney on the schedules in config.yaml,
+keeping the provider sessions between runs, until interrupted. Unknown accounts are skipped as with
+--non-interactive.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			nonInteractive = true
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			r := initReplState(ctx)
+			defer r.db.Close()
+			if err := r.runDaemon(ctx); err != nil {
+				log.Error().Err(err).Msg("Error running daemon")
+			}
+		},
+	}
+
+	var serveAddr, serveToken string
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a JSON HTTP API",
+		Long: `Serve a JSON HTTP API to list transactions, accounts and mappings, start fetches and syncs and
+stream their progress as server-sent events. Unknown accounts are skipped as with --non-interactive.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			nonInteractive = true
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// stream the log entries to the API clients as well
+			events := newEventBroker()
+			log.Logger = log.Output(zerolog.MultiLevelWriter(zerolog.ConsoleWriter{Out: os.Stderr}, events))
+			if err := checkListenAddr(serveAddr, serveToken); err != nil {
+				log.Error().Err(err).Msg("Error running API server")
+				return
+			}
+			if serveToken == "" {
+				log.Warn().Msg("No --token set, anyone who can connect to the API from this machine can use it")
+			}
+
+			r := initReplState(ctx)
+			defer r.db.Close()
+			if err := r.runServer(ctx, serveAddr, serveToken, events); err != nil {
+				log.Error().Err(err).Msg("Error running API server")
+			}
+		},
+	}
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Bearer token the API clients must send")
+
+	var fetchWindow windowFlags
+	fetchCmd := &cobra.Command{
+		Use:   "fetch <provider|all>",
+		Short: "Fetch transactions from a provider",
+		Long:  `Fetch balances and transactions from a provider, or every configured provider, into the database.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.fetch(cmd.Context(), args[0], fetchWindow)
+			r.reportUnmapped()
+		},
+	}
+	fetchWindow.register(fetchCmd)
+
+	var dryRun, asJSON bool
+	var syncWindow windowFlags
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync the database with LunchMoney",
+		Long:  `Push unsynced transactions and newer balances from the database to LunchMoney.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			if err := r.setSyncWindow(syncWindow); err != nil {
+				log.Error().Err(err).Msg("Invalid sync window")
+				return
+			}
+			if dryRun {
+				r.planSync(asJSON)
+			} else {
+				r.syncState(cmd.Context())
+			}
+			r.reportUnmapped()
+		},
+	}
+	syncCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be synced without calling LunchMoney")
+	syncCmd.Flags().BoolVar(&asJSON, "json", false, "Print the dry-run plan as JSON")
+	syncWindow.register(syncCmd)
+
+	vanishedCmd := &cobra.Command{
+		Use:   "vanished [list|mark <ref|all>|dismiss <ref|all>]",
+		Short: "Review transactions that disappeared upstream",
+		Long: `List the transactions a provider stopped returning, mark them in LunchMoney or dismiss them.
+The LunchMoney API can not delete transactions, delete them in the LunchMoney web app and dismiss them here.`,
+		Args: cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.handleVanished(strings.Join(append([]string{"vanished"}, args...), " "))
+		},
+	}
+
+	mappingCmd := &cobra.Command{
+		Use:   "mapping",
+		Short: "Manage external account mappings",
+		Long:  `List, change, un-ignore or delete the mappings of external accounts to LunchMoney accounts.`,
+	}
+	mappingCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List external account mappings",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listMappings()
+		},
+	})
+	var reassign bool
+	mappingSetCmd := &cobra.Command{
+		Use:   "set <external_name> <lunchmoney_id|ignore>",
+		Short: "Map an external account to a LunchMoney account or ignore it",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.setMapping(args[0], args[1], reassign)
+		},
+	}
+	mappingSetCmd.Flags().BoolVar(&reassign, "reassign", false, "Move already synced transactions to the new LunchMoney account")
+	mappingCmd.AddCommand(mappingSetCmd, &cobra.Command{
+		Use:   "unignore <external_name>",
+		Short: "Stop ignoring an external account",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.unignoreMapping(args[0])
+		},
+	}, &cobra.Command{
+		Use:   "delete <external_name>",
+		Short: "Remove the mapping of an external account",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.deleteMapping(args[0])
+		},
+	})
+
+	var historyLimit int
+	historyCmd := &cobra.Command{
+		Use:   "history [run <id>|<ref>]",
+		Short: "Show the history of fetch and sync runs",
+		Long: `List the recent fetch and sync runs, the transaction events recorded by a run,
+or the audit trail of a single transaction.`,
+		Args: cobra.MaximumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			if len(args) == 0 {
+				r.listRuns(historyLimit)
+				return
+			}
+			r.handleHistory(strings.Join(append([]string{"history"}, args...), " "))
+		},
+	}
+	historyCmd.Flags().IntVar(&historyLimit, "limit", defaultHistoryRuns, "Number of runs to list")
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import transactions from downloaded statements",
+	}
+	var csvFlags importFlags
+	importCSVCmd := &cobra.Command{
+		Use:   "csv <file>",
+		Short: "Import a CSV statement",
+		Long: `Import the transactions of a CSV statement into the database using a column mapping
+profile from config.yaml. Rows without a reference number get one derived from their content,
+so importing the same statement again does not duplicate transactions.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.importCSV(args[0], csvFlags)
+		},
+	}
+	importCSVCmd.Flags().StringVar(&csvFlags.profile, "profile", "", "Name of the CSV profile in config.yaml")
+	importCSVCmd.Flags().StringVar(&csvFlags.account, "account", "", "External account name the transactions belong to")
+	csvFlags.window.register(importCSVCmd)
+	var ofxFlags importFlags
+	importOFXCmd := &cobra.Command{
+		Use:     "ofx <file>",
+		Aliases: []string{"qfx"},
+		Short:   "Import an OFX or QFX file",
+		Long: `Import the ledger balances and transactions of an OFX or QFX file like a live provider.
+FITIDs are used as reference numbers and accounts are named after their type and id
+unless --account is given for a file with a single account.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.importOFX(args[0], ofxFlags)
+		},
+	}
+	importOFXCmd.Flags().StringVar(&ofxFlags.account, "account", "", "External account name of a single account file")
+	ofxFlags.window.register(importOFXCmd)
+	importCmd.AddCommand(importCSVCmd, importOFXCmd)
+
+	exportFlagsOf := func(cmd *cobra.Command, flags *exportFlags) {
+		cmd.Flags().StringVar(&flags.format, "format", string(export.FormatCSV), "Export format: csv, jsonl or ledger")
+		cmd.Flags().StringVarP(&flags.output, "output", "o", "", "File to write, defaults to stdout")
+		cmd.Flags().StringVar(&flags.account, "account", "", "Only export this external account")
+	}
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the database to CSV, JSON lines or a ledger journal",
+	}
+	var txExportFlags exportFlags
+	exportTransactionsCmd := &cobra.Command{
+		Use:   "transactions",
+		Short: "Export transactions with their source account, LunchMoney account and sync status",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.export("transactions", txExportFlags)
+		},
+	}
+	exportFlagsOf(exportTransactionsCmd, &txExportFlags)
+	exportTransactionsCmd.Flags().StringVar(&txExportFlags.status, "status", "", "Only export synced, unsynced or ignored transactions")
+	exportTransactionsCmd.Flags().StringVar(&txExportFlags.window.since, "since", "", "Start date (YYYY-MM-DD)")
+	exportTransactionsCmd.Flags().StringVar(&txExportFlags.window.until, "until", "", "End date (YYYY-MM-DD)")
+	var balanceExportFlags exportFlags
+	exportBalancesCmd := &cobra.Command{
+		Use:   "balances",
+		Short: "Export the last fetched balance of every mapped account",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.export("balances", balanceExportFlags)
+		},
+	}
+	exportFlagsOf(exportBalancesCmd, &balanceExportFlags)
+	exportCmd.AddCommand(exportTransactionsCmd, exportBalancesCmd)
+
+	var listCmdFlags listFlags
+	listCmd := &cobra.Command{
+		Use:   "list [<ref>]",
+		Short: "List transactions in the database",
+		Long:  `List the transactions in the database matching the filters, or show the details of one transaction.`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			if len(args) == 1 {
+				r.showTransaction(args[0])
+				return
+			}
+			r.listTransactions(listCmdFlags)
+		},
+	}
+	listCmdFlags.register(listCmd.Flags())
+
+	noteCmd := &cobra.Command{
+		Use:   "note <ref> [<text>]",
+		Short: "Set or clear the notes of an unsynced transaction",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.setNote(args[0], strings.Join(args[1:], " "))
+		},
+	}
+	tagCmd := &cobra.Command{
+		Use:   "tag <ref> <tag>...",
+		Short: "Tag an unsynced transaction",
+		Long:  `Add tags to an unsynced transaction, they are created in LunchMoney when the transaction is synced.`,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.tagTransaction(args[0], args[1:], true)
+		},
+	}
+	untagCmd := &cobra.Command{
+		Use:   "untag <ref> <tag>...",
+		Short: "Remove tags from an unsynced transaction",
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.tagTransaction(args[0], args[1:], false)
+		},
+	}
+
+	rulesCmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Manage the merchant name rules",
+		Long:  `List the merchant rules from config.yaml or re-apply them to the transactions in the database.`,
+	}
+	rulesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the merchant rules in the order they are tried",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listRules()
+		},
+	})
+	var rulesDryRun bool
+	rulesApplyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Re-apply the merchant rules to every stored transaction",
+		Long: `Rename the merchants of the stored transactions with the current rules. Transactions no
+rule matches anymore get their original description back. Renamed transactions that were
+already synced are updated in LunchMoney by the next sync.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.applyRules(rulesDryRun)
 		},
 	}
+	rulesApplyCmd.Flags().BoolVarP(&rulesDryRun, "dry-run", "n", false, "Only show the merchants that would be renamed")
+	rulesCmd.AddCommand(rulesApplyCmd)
 
-	rootCmd.AddCommand(fetchAndSyncCmd)
+	categoriesCmd := &cobra.Command{
+		Use:   "categories",
+		Short: "Manage LunchMoney categories and category code mappings",
+		Long: `List the LunchMoney categories cached locally and manage the mappings of provider category
+codes to them. Inserted transactions get the category of the first matching categoryRules
+entry in config.yaml, or else the mapping of their category code.`,
+	}
+	categoriesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the cached LunchMoney categories",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listCategories(false)
+		},
+	}, &cobra.Command{
+		Use:   "refresh",
+		Short: "Fetch the LunchMoney categories again",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listCategories(true)
+		},
+	}, &cobra.Command{
+		Use:   "mappings",
+		Short: "List the mappings of provider category codes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listCategoryMappings()
+		},
+	}, &cobra.Command{
+		Use:   "map <category_code> <lunchmoney_category_id|none>",
+		Short: "Map a category code to a LunchMoney category or leave it uncategorized",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.setCategoryMapping(args[0], args[1])
+		},
+	}, &cobra.Command{
+		Use:   "unmap <category_code>",
+		Short: "Remove the mapping of a category code",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.deleteCategoryMapping(args[0])
+		},
+	})
+
+	transfersCmd := &cobra.Command{
+		Use:   "transfers",
+		Short: "Review transfers between mapped accounts",
+		Long: `Transactions of equal amount and opposite sign in two mapped accounts, dated within
+transfers.toleranceDays of each other, are matched as a transfer when they are fetched or
+synced. Both sides are held back until the pair is reviewed: confirmed transfers are
+inserted into LunchMoney with the transfers.categoryId category and the transfer tag,
+broken pairs are synced on their own.`,
+	}
+	var transfersDryRun bool
+	transfersMatchCmd := &cobra.Command{
+		Use:   "match",
+		Short: "Match the unsynced transactions that look like transfers",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.matchTransfers(transfersDryRun)
+		},
+	}
+	transfersMatchCmd.Flags().BoolVarP(&transfersDryRun, "dry-run", "n", false, "Only show the transfers that would be matched")
+	transfersCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the matched transfers",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listTransfers()
+		},
+	}, transfersMatchCmd, &cobra.Command{
+		Use:   "confirm <id|all>",
+		Short: "Confirm matched transfers",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.confirmTransfers(args[0])
+		},
+	}, &cobra.Command{
+		Use:   "break <id>",
+		Short: "Sync both transactions of an unsynced transfer on their own",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.breakTransfer(args[0])
+		},
+	})
+
+	duplicatesCmd := &cobra.Command{
+		Use:   "duplicates",
+		Short: "Review transactions that may already exist in LunchMoney",
+		Long: `Unsynced transactions are matched against the LunchMoney transactions with the same
+amount, scored on how close their date, payee and account are. Transactions whose best
+candidate scores between duplicates.ambiguousThreshold and duplicates.threshold, or that
+have several close candidates, are not synced until they are reviewed.`,
+	}
+	duplicatesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the transactions that may already exist in LunchMoney",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.listDuplicates()
+		},
+	}, &cobra.Command{
+		Use:   "confirm <ref> <lunchmoney_id>",
+		Short: "Link a transaction to the LunchMoney transaction it duplicates",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.confirmDuplicate(args[0], args[1])
+		},
+	}, &cobra.Command{
+		Use:   "reject <ref>",
+		Short: "Insert a possible duplicate into LunchMoney with the next sync",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			r := initReplState(cmd.Context())
+			defer r.db.Close()
+			r.rejectDuplicate(args[0])
+		},
+	})
+
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage the encrypted vault of credentials",
+		Long: `Manage the secrets config.yaml references as secret://<name>, e.g. password: secret://rogers.
+The vault is encrypted with a passphrase that is prompted for or read from ` + vaultPassphraseEnv + `.`,
+	}
+	secretsCmd.AddCommand(&cobra.Command{
+		Use:   "set <name>",
+		Short: "Add or replace a secret, its value is prompted for or read from stdin",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			setSecret(args[0])
+		},
+	}, &cobra.Command{
+		Use:   "get <name>",
+		Short: "Print a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			getSecret(args[0])
+		},
+	}, &cobra.Command{
+		Use:   "list",
+		Short: "List the names of the secrets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			listSecrets()
+		},
+	}, &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Remove a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			removeSecret(args[0])
+		},
+	})
+
+	rootCmd.AddCommand(listCmd, fetchAndSyncCmd, daemonCmd, serveCmd, fetchCmd, syncCmd, vanishedCmd, mappingCmd, historyCmd, importCmd, exportCmd,
+		noteCmd, tagCmd, untagCmd, rulesCmd, categoriesCmd, transfersCmd, duplicatesCmd, secretsCmd, newDBCmd())
 
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
 
 func initReplState(ctx context.Context) replState {
 	// Initialize database
-	database, err := db.New(dbPath)
+	database := openDB()
+	if err := database.Initialize(); err != nil {
+		logMigrationError(err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.GetConfig()
 	if err != nil {
-		log.Error().Err(err).Msg("Error connecting to database")
+		log.Error().Err(err).Msg("Error loading configuration")
+		os.Exit(1)
+	}
+	if err := unlockSecrets(cfg); err != nil {
+		log.Error().Err(err).Msg("Error unlocking secrets")
 		os.Exit(1)
 	}
 
@@ -112,15 +666,76 @@ func initReplState(ctx context.Context) replState {
 		log.Error().Err(err).Msg("Error creating LunchMoney syncer")
 		os.Exit(1)
 	}
+
+	lsyncer.GetAccountMapper().SetMappingRules(cfg.AccountMappings)
+	lsyncer.GetAccountMapper().SetNonInteractive(nonInteractive)
+	lsyncer.GetCategoryMapper().SetCategoryRules(cfg.CategoryRules)
+	lsyncer.GetCategoryMapper().SetNonInteractive(nonInteractive)
+
+	normalizer, err := services.NewMerchantNormalizer(cfg.MerchantRules)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading merchant rules")
+		os.Exit(1)
+	}
+	lsyncer.SetMerchantNormalizer(normalizer)
+
+	tagger, err := services.NewTagger(cfg.TagRules)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading tag rules")
+		os.Exit(1)
+	}
+	lsyncer.SetTagger(tagger)
+	lsyncer.SetTransferOptions(cfg.Transfers)
+	lsyncer.SetDuplicateOptions(cfg.Duplicates)
+
+	routes, err := cfg.Notifications.Routes()
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading notification sinks")
+		os.Exit(1)
+	}
 	return replState{
 		db:       database,
 		lmSyncer: lsyncer,
+		notifier: notify.New(database, routes, cfg.Notifications.Repeat()),
+	}
+}
+
+// reportUnmapped lists the accounts skipped and the category codes left uncategorized
+// because they could not be mapped without prompting, and the transfers held until
+// they are reviewed
+func (r *replState) reportUnmapped() {
+	unmapped := r.lmSyncer.GetAccountMapper().Unmapped()
+	if len(unmapped) != 0 {
+		fmt.Printf("Skipped %d unmapped accounts, add accountMappings rules to config.yaml or run interactively:\n", len(unmapped))
+		for _, name := range unmapped {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	uncategorized := r.lmSyncer.GetCategoryMapper().Uncategorized()
+	if len(uncategorized) != 0 {
+		fmt.Printf("Left %d unmapped category codes uncategorized, add categoryRules to config.yaml or map them with categories map:\n",
+			len(uncategorized))
+		for _, code := range uncategorized {
+			fmt.Printf("  %s\n", code)
+		}
+	}
+
+	held, err := r.lmSyncer.HeldTransfers()
+	if err != nil {
+		log.Error().Err(err).Msg("Error listing transfers")
+		return
+	}
+	if len(held) != 0 {
+		fmt.Printf("Holding %d transfers until they are reviewed, confirm or break them with transfers confirm|break <id>:\n", len(held))
+		printTransfers(held)
 	}
 }
 
 type replState struct {
 	db       db.DBInterface
 	lmSyncer *services.LunchMoneySyncer
+	notifier *notify.Notifier
 }
 
 func runREPL(state replState) {
@@ -132,11 +747,6 @@ func runREPL(state replState) {
 	// Close the database once you are done
 	defer state.db.Close()
 
-	if err := state.db.Initialize(); err != nil {
-		log.Error().Err(err).Msg("Error initializing database")
-		os.Exit(1)
-	}
-
 	// Start REPL
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -169,7 +779,7 @@ func runREPL(state replState) {
 		}
 
 		if strings.HasPrefix(trimmedLine, "list") {
-			state.listTransactions()
+			state.processList(trimmedLine)
 			continue
 		}
 
@@ -184,7 +794,7 @@ func runREPL(state replState) {
 		}
 
 		if strings.HasPrefix(trimmedLine, "sync") {
-			state.syncState()
+			state.processSync(trimmedLine)
 			continue
 		}
 
@@ -193,54 +803,65 @@ func runREPL(state replState) {
 			continue
 		}
 
-		if strings.HasPrefix(trimmedLine, "remove") || strings.HasPrefix(trimmedLine, "delete") {
-			state.removeTransaction(trimmedLine)
+		if strings.HasPrefix(trimmedLine, "mapping") {
+			state.handleMapping(trimmedLine)
 			continue
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error().Err(err).Msg("Error reading input")
-	}
-}
+		if strings.HasPrefix(trimmedLine, "vanished") {
+			state.handleVanished(trimmedLine)
+			continue
+		}
 
-func (r *replState) syncState() {
-	err := r.lmSyncer.SyncTransactions(context.Background())
-	if err != nil {
-		log.Error().Err(err).Msg("Error syncing transactions")
-		return
-	}
+		if strings.HasPrefix(trimmedLine, "export") {
+			state.processExport(trimmedLine)
+			continue
+		}
 
-	err = r.lmSyncer.SyncBalances(context.Background())
-	if err != nil {
-		log.Error().Err(err).Msg("Error syncing balances")
-		return
-	}
-}
+		if strings.HasPrefix(trimmedLine, "import") {
+			state.processImport(trimmedLine)
+			continue
+		}
 
-func (r *replState) listTransactions() {
-	transactions, err := r.db.GetTransactions()
-	if err != nil {
-		log.Error().Err(err).Msg("Error fetching transactions")
-		return
-	}
+		if strings.HasPrefix(trimmedLine, "categories") {
+			state.handleCategories(trimmedLine)
+			continue
+		}
 
-	if len(transactions) == 0 {
-		fmt.Println("No transactions found")
-		return
+		if strings.HasPrefix(trimmedLine, "transfers") {
+			state.handleTransfers(trimmedLine)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "duplicates") {
+			state.handleDuplicates(trimmedLine)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "note") || strings.HasPrefix(trimmedLine, "tag") ||
+			strings.HasPrefix(trimmedLine, "untag") {
+			state.handleAnnotate(trimmedLine)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "rules") {
+			state.handleRules(trimmedLine)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "history") {
+			state.handleHistory(trimmedLine)
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "remove") || strings.HasPrefix(trimmedLine, "delete") {
+			state.removeTransaction(trimmedLine)
+			continue
+		}
 	}
 
-	fmt.Printf("Found %d transactions:\n\n", len(transactions))
-	fmt.Printf("%-20s %-30s %-15s %-30s %-15s %-15s\n", "SourceAccount", "Reference Number", "Amount", "Merchant Name", "Date", "LunchMoney ID")
-	fmt.Println(strings.Repeat("-", 130))
-	for _, tx := range transactions {
-		fmt.Printf("%-20s %-30s %-15s %-30s %-15s %-15d\n",
-			tx.SourceAccountName[:min(20, len(tx.SourceAccountName))],
-			tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))],
-			tx.Amount.Value+" "+tx.Amount.Currency,
-			tx.Merchant.Name[:min(30, len(tx.Merchant.Name))],
-			tx.Date,
-			tx.LunchMoneyID)
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Msg("Error reading input")
 	}
 }
 
@@ -308,12 +929,16 @@ func (r *replState) addTransaction(input string) {
 		},
 		SourceAccountName: "Manual Entry",
 	}
+	r.lmSyncer.GetMerchantNormalizer().Normalize(&tx.Transaction)
+	tx.AddTags(models.SourceTag("manual"))
+	r.lmSyncer.GetTagger().Tag(tx)
 
 	// Save transaction
 	if err := r.db.AddManualTransaction(tx); err != nil {
 		log.Error().Err(err).Msg("Error adding transaction")
 		return
 	}
+	services.RecordTransactionEvent(r.db, 0, &tx.Transaction, models.TransactionEventFetched, "added manually")
 
 	log.Info().Str("transaction", referenceNumber).Msg("Transaction added successfully")
 }
@@ -332,11 +957,21 @@ func (r *replState) removeTransaction(input string) {
 	// Extract reference number
 	referenceNumber := parts[1]
 
+	// Keep the LunchMoney ID in the audit trail
+	tx, err := r.db.GetTransactionByReference(referenceNumber)
+	if err != nil {
+		log.Error().Err(err).Msg("Error checking transaction")
+		return
+	}
+
 	// Remove transaction
 	if err := r.db.RemoveTransaction(referenceNumber); err != nil {
 		log.Error().Err(err).Msg("Error removing transaction")
 		return
 	}
+	if tx != nil {
+		services.RecordTransactionEvent(r.db, 0, &tx.Transaction, models.TransactionEventDeleted, "removed manually")
+	}
 
 	log.Info().Str("transaction", referenceNumber).Msg("Transaction removed successfully")
 }
@@ -345,15 +980,68 @@ func printHelp() {
 	fmt.Println("Available commands:")
 	fmt.Println("  help                 - Show this help message")
 	fmt.Println("  config               - Show the current configuration")
-	fmt.Println("  list                 - List all transactions in the database")
-	fmt.Println("  fetch <type>         - Fetch transactions from either 'wealthsimple', ")
-	fmt.Println("                         'rogers', or 'scotia'")
-	fmt.Println("  sync                 - Sync database with LunchMoney API")
+	fmt.Println("  list [flags]         - List transactions, filtered with --since, --until, --account, --merchant,")
+	fmt.Println("                         --merchant-regex, --min, --max, --status, --currency, --tag, sorted with")
+	fmt.Println("                         --sort date|amount|merchant|account [--asc], paged with --limit and --page")
+	fmt.Println("  list <ref>           - Show the details of a transaction")
+	fmt.Println("  fetch <type> [--since <date>] [--until <date>]")
+	fmt.Println("                       - Fetch transactions from a provider or 'all' of them:")
+	for _, provider := range http.Providers() {
+		fmt.Printf("                         %-14s %s\n", provider.Name, provider.Description)
+	}
+	fmt.Println("  sync [--dry-run] [--json] [--since <date>] [--until <date>]")
+	fmt.Println("                       - Sync database with LunchMoney API, or only show the plan")
 	fmt.Println("  add <ref> <amount> <currency> <merchant> <date> [<category>]")
 	fmt.Println("                       - Add a transaction manually")
+	fmt.Println("  import csv <file> --profile <name> --account <name> [--since <date>] [--until <date>]")
+	fmt.Println("                       - Import a CSV statement with a profile from config.yaml")
+	fmt.Println("  import ofx <file> [--account <name>] [--since <date>] [--until <date>]")
+	fmt.Println("                       - Import the balances and transactions of an OFX or QFX file")
+	fmt.Println("  export <transactions|balances> [--format csv|jsonl|ledger] [--output <file>]")
+	fmt.Println("         [--since <date>] [--until <date>] [--account <name>] [--status <status>]")
+	fmt.Println("                       - Export the database for spreadsheets or accounting tools")
 	fmt.Println("  remove <ref>         - Remove a transaction by reference number")
+	fmt.Println("  note <ref> [<text>]  - Set or clear the notes of an unsynced transaction")
+	fmt.Println("  tag <ref> <tag>...   - Tag an unsynced transaction, e.g. tag TX123 reimbursable")
+	fmt.Println("  untag <ref> <tag>... - Remove tags from an unsynced transaction")
+	fmt.Println("  vanished [list]      - List transactions that disappeared upstream")
+	fmt.Println("  vanished mark <ref|all>")
+	fmt.Println("                       - Un-clear and annotate vanished transactions in LunchMoney")
+	fmt.Println("  vanished dismiss <ref|all>")
+	fmt.Println("                       - Stop reporting vanished transactions, e.g. after deleting them")
+	fmt.Println("  history              - List the recent fetch and sync runs")
+	fmt.Println("  history run <id>     - Show the transaction events recorded by a run")
+	fmt.Println("  history <ref>        - Show when and why a transaction was fetched, pushed or changed")
+	fmt.Println("  categories [list|refresh]")
+	fmt.Println("                       - List the cached LunchMoney categories, or fetch them again")
+	fmt.Println("  categories mappings  - List the mappings of provider category codes to LunchMoney categories")
+	fmt.Println("  categories map <code> <id|none>")
+	fmt.Println("                       - Map a category code to a LunchMoney category or leave it uncategorized")
+	fmt.Println("  categories unmap <code>")
+	fmt.Println("                       - Remove a category mapping so the code is mapped again")
+	fmt.Println("  transfers [list]     - List the transfers matched between mapped accounts")
+	fmt.Println("  transfers match [--dry-run]")
+	fmt.Println("                       - Match the unsynced transactions that look like transfers")
+	fmt.Println("  transfers confirm <id|all>")
+	fmt.Println("                       - Confirm matched transfers")
+	fmt.Println("  transfers break <id> - Sync both transactions of an unsynced transfer on their own")
+	fmt.Println("  duplicates [list]    - List the transactions that may already exist in LunchMoney")
+	fmt.Println("  duplicates confirm <ref> <lunchmoney_id>")
+	fmt.Println("                       - Link a transaction to the LunchMoney transaction it duplicates")
+	fmt.Println("  duplicates reject <ref>")
+	fmt.Println("                       - Insert a possible duplicate into LunchMoney with the next sync")
+	fmt.Println("  rules list           - List the merchant rules in the order they are tried")
+	fmt.Println("  rules apply [--dry-run]")
+	fmt.Println("                       - Re-apply the merchant rules to every stored transaction")
 	fmt.Println("  account list         - List all accounts with balances and sync status")
 	fmt.Println("  account disable <id> - Disable syncing for an account by its LunchMoney ID")
+	fmt.Println("  mapping list         - List external account mappings with their last balance")
+	fmt.Println("  mapping set <name> <id|ignore> [--reassign]")
+	fmt.Println("                       - Map an external account, optionally moving its synced transactions")
+	fmt.Println("  mapping unignore <name>")
+	fmt.Println("                       - Stop ignoring an external account")
+	fmt.Println("  mapping delete <name>")
+	fmt.Println("                       - Remove a mapping so the account is mapped again")
 	fmt.Println("  exit, quit           - Exit the REPL")
 	fmt.Println("  curl [command]       - Execute a curl-like command to fetch transactions")
 	fmt.Println()
@@ -389,4 +1077,24 @@ func showConfig() {
 		fmt.Println("\nPlease set your API key in config.yaml to use the sync command.")
 		fmt.Println("You can get your API key from https://my.lunchmoney.app/developers")
 	}
+
+	fmt.Println()
+	fmt.Println("Providers:")
+	for _, provider := range http.Providers() {
+		if err := provider.ValidateConfig(cfg); err != nil {
+			fmt.Printf("  %-14s Not configured (%v)\n", provider.Name, err)
+			continue
+		}
+		fmt.Printf("  %-14s Configured\n", provider.Name)
+	}
+
+	fmt.Println()
+	fmt.Printf("Account Mapping Rules (%d):\n", len(cfg.AccountMappings))
+	for _, rule := range cfg.AccountMappings {
+		target := fmt.Sprintf("LunchMoney account %d", rule.LunchMoneyId)
+		if rule.Ignore {
+			target = "ignore"
+		}
+		fmt.Printf("  %-30s -> %s\n", rule.String(), target)
+	}
 }
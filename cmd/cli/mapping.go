@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+const mappingUsage = `Usage: mapping list
+       mapping set <external_name> <lunchmoney_id|ignore> [--reassign]
+       mapping unignore <external_name>
+       mapping delete <external_name>
+Quote external names that contain spaces.`
+
+// handleMapping parses the mapping command
+// Format: mapping <list|set|unignore|delete> [<external_name>] [<lunchmoney_id|ignore>] [--reassign]
+func (r *replState) handleMapping(input string) {
+	parts := utils.SplitQuoted(input)
+	if len(parts) < 2 {
+		fmt.Println("Invalid mapping command format.")
+		fmt.Println(mappingUsage)
+		return
+	}
+
+	switch parts[1] {
+	case "list", "l":
+		r.listMappings()
+	case "set":
+		reassign := lo.Contains(parts, "--reassign")
+		parts = lo.Without(parts, "--reassign")
+		if len(parts) != 4 {
+			fmt.Println(mappingUsage)
+			return
+		}
+		r.setMapping(parts[2], parts[3], reassign)
+	case "unignore":
+		if len(parts) != 3 {
+			fmt.Println(mappingUsage)
+			return
+		}
+		r.unignoreMapping(parts[2])
+	case "delete":
+		if len(parts) != 3 {
+			fmt.Println(mappingUsage)
+			return
+		}
+		r.deleteMapping(parts[2])
+	default:
+		fmt.Println("Invalid mapping command format.")
+		fmt.Println(mappingUsage)
+	}
+}
+
+// accountMappings returns the stored account mappings with the configured rules applied,
+// and the rule mappings of the given external accounts that are not stored yet
+func (r *replState) accountMappings(ctx context.Context, externalNames []string) ([]*models.AccountMappingDetails, error) {
+	stored, err := r.db.GetAccountMappings()
+	if err != nil {
+		return nil, err
+	}
+	return r.lmSyncer.GetAccountMapper().ApplyMappingRules(ctx, stored, externalNames)
+}
+
+func (r *replState) listMappings() {
+	mappings, err := r.accountMappings(context.Background(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching account mappings")
+		return
+	}
+
+	if len(mappings) == 0 {
+		fmt.Println("No account mappings found")
+		return
+	}
+
+	lmAccounts, err := r.lmSyncer.GetClient().ListAccounts(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching accounts")
+		return
+	}
+	lmAccountsMap := lo.SliceToMap(lmAccounts, func(account models.LunchMoneyAccount) (int64, models.LunchMoneyAccount) {
+		return account.LunchMoneyId, account
+	})
+
+	fmt.Printf("Found %d account mappings:\n\n", len(mappings))
+	fmt.Printf("%-30s %-10s %-30s %-8s %-6s %15s %-8s %-16s\n", "External Account", "LM ID", "LM Account", "Status", "Source", "Balance", "Currency", "Balance Updated")
+	fmt.Println(strings.Repeat("-", 131))
+	for _, mapping := range mappings {
+		lmId, lmName, status, source := strconv.FormatInt(mapping.LunchMoneyId, 10), "", "mapped", "manual"
+		if mapping.FromRule {
+			source = "rule"
+		}
+		if mapping.IsIgnored() {
+			lmId, status = "-", "ignored"
+		} else if account, ok := lmAccountsMap[mapping.LunchMoneyId]; ok {
+			lmName = account.DisplayName
+			if lmName == "" {
+				lmName = account.Name
+			}
+		} else {
+			lmName = "(unknown account)"
+		}
+
+		balance, currency, updated := "", "", ""
+		if mapping.Balance != nil {
+			balance, currency = mapping.Balance.Value, mapping.Balance.Currency
+		}
+		if mapping.BalanceUpdatedAt != nil {
+			updated = mapping.BalanceUpdatedAt.Format("2006-01-02 15:04")
+		}
+
+		fmt.Printf("%-30s %-10s %-30s %-8s %-6s %15s %-8s %-16s\n",
+			mapping.ExternalName[:min(30, len(mapping.ExternalName))],
+			lmId,
+			lmName[:min(30, len(lmName))],
+			status,
+			source,
+			balance[:min(15, len(balance))],
+			currency,
+			updated)
+	}
+}
+
+func (r *replState) setMapping(externalName, target string, reassign bool) {
+	lunchMoneyId := int64(-1)
+	if target != "ignore" {
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil || id <= 0 {
+			fmt.Println("Invalid LunchMoney account ID, use a positive ID or 'ignore'")
+			return
+		}
+		lunchMoneyId = id
+	}
+
+	mapping, reassigned, err := r.lmSyncer.GetAccountMapper().SetMapping(context.Background(), externalName, lunchMoneyId, reassign)
+	if err != nil {
+		log.Error().Err(err).Int("reassigned", reassigned).Msg("Error setting account mapping")
+		return
+	}
+
+	if mapping.IsIgnored() {
+		fmt.Printf("External account %q is now ignored\n", externalName)
+		return
+	}
+	fmt.Printf("External account %q is now mapped to LunchMoney account %d\n", externalName, mapping.LunchMoneyId)
+	if reassign {
+		fmt.Printf("Reassigned %d synced transactions\n", reassigned)
+	}
+}
+
+// checkNoMappingRule reports whether the mapping of an external account can be changed,
+// a configured rule that matches it wins over any stored mapping
+func (r *replState) checkNoMappingRule(externalName string) bool {
+	rule := r.lmSyncer.GetAccountMapper().MatchingRule(externalName)
+	if rule == nil {
+		return true
+	}
+	fmt.Printf("External account %q is mapped by the configured rule %s, change the rule in the config file instead\n",
+		externalName, rule.String())
+	return false
+}
+
+func (r *replState) unignoreMapping(externalName string) {
+	if !r.checkNoMappingRule(externalName) {
+		return
+	}
+	mapping, err := r.db.GetAccountMapping(externalName)
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching account mapping")
+		return
+	}
+	if mapping == nil || !mapping.IsIgnored() {
+		fmt.Printf("External account %q is not ignored\n", externalName)
+		return
+	}
+
+	if err := r.db.DeleteAccountMapping(externalName); err != nil {
+		log.Error().Err(err).Msg("Error removing account mapping")
+		return
+	}
+	fmt.Printf("External account %q is no longer ignored, it will be mapped the next time it is seen\n", externalName)
+}
+
+func (r *replState) deleteMapping(externalName string) {
+	if !r.checkNoMappingRule(externalName) {
+		return
+	}
+	if err := r.db.DeleteAccountMapping(externalName); err != nil {
+		log.Error().Err(err).Msg("Error removing account mapping")
+		return
+	}
+	fmt.Printf("Removed the mapping of external account %q, it will be mapped the next time it is seen\n", externalName)
+}
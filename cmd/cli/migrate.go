@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/vpnda/sandwich-sync/db"
+)
+
+// newDBCmd returns the database maintenance commands, they only need the database
+// and not the LunchMoney API key
+func newDBCmd() *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database maintenance",
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply the pending schema migrations",
+		Long:  `Apply the pending schema migrations, they are also applied whenever the database is opened.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			database := openDB()
+			defer database.Close()
+			if err := database.Migrate(); err != nil {
+				logMigrationError(err)
+				os.Exit(1)
+			}
+			printMigrationStatus(database)
+		},
+	}
+
+	migrateCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the applied and pending schema migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			database := openDB()
+			defer database.Close()
+			printMigrationStatus(database)
+		},
+	})
+
+	dbCmd.AddCommand(migrateCmd)
+	return dbCmd
+}
+
+func openDB() *db.DB {
+	database, err := db.New(dbPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Error connecting to database")
+		os.Exit(1)
+	}
+	return database
+}
+
+func logMigrationError(err error) {
+	if errors.Is(err, db.ErrSchemaTooNew) {
+		log.Error().Err(err).Msg("The database was created by a newer version, please upgrade")
+		return
+	}
+	log.Error().Err(err).Msg("Error migrating database")
+}
+
+func printMigrationStatus(database *db.DB) {
+	status, err := database.MigrationStatus()
+	if err != nil {
+		logMigrationError(err)
+		return
+	}
+
+	fmt.Printf("Database: %s\n", dbPath)
+	fmt.Printf("Schema version supported by this binary: %d\n\n", db.LatestSchemaVersion())
+	fmt.Printf("%-8s %-30s %-20s\n", "Version", "Name", "Applied At")
+	for _, s := range status {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-8d %-30s %-20s\n", s.Version, s.Name, appliedAt)
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/notify"
+)
+
+// notifyFetchFailure notifies that logging in to or fetching from a provider failed
+func (r *replState) notifyFetchFailure(ctx context.Context, provider string, kind notify.Kind, err error) {
+	title := fmt.Sprintf("Fetching from %s failed", provider)
+	if kind == notify.KindAuthFailure {
+		title = fmt.Sprintf("Logging in to %s failed", provider)
+	}
+	r.notifier.Notify(ctx, notify.Event{
+		Kind:    kind,
+		Key:     string(kind) + ":" + provider,
+		Title:   title,
+		Message: err.Error(),
+	})
+}
+
+// resolveFetchFailures notifies of the next failure of a provider right away
+func (r *replState) resolveFetchFailures(provider string) {
+	r.notifier.Resolve(string(notify.KindAuthFailure)+":"+provider, string(notify.KindFetchError)+":"+provider)
+}
+
+// notifySyncResult notifies that syncing with LunchMoney failed, or resolves the
+// previous failure when it succeeded
+func (r *replState) notifySyncResult(ctx context.Context, err error) {
+	if err == nil {
+		r.notifier.Resolve(string(notify.KindSyncError))
+		return
+	}
+	r.notifier.Notify(ctx, notify.Event{
+		Kind:    notify.KindSyncError,
+		Key:     string(notify.KindSyncError),
+		Title:   "Syncing with LunchMoney failed",
+		Message: err.Error(),
+	})
+}
+
+// notifyUnmapped notifies of the accounts skipped because they are not mapped
+func (r *replState) notifyUnmapped(ctx context.Context) {
+	for _, name := range r.lmSyncer.GetAccountMapper().Unmapped() {
+		r.notifier.Notify(ctx, notify.Event{
+			Kind:    notify.KindUnmappedAccount,
+			Key:     string(notify.KindUnmappedAccount) + ":" + name,
+			Title:   "New account " + name,
+			Message: fmt.Sprintf("Transactions of %s are skipped until it is mapped to a LunchMoney account.", name),
+		})
+	}
+}
+
+// notifyHeldTransfers notifies of the transfer pairs held from syncing until they are reviewed
+func (r *replState) notifyHeldTransfers(ctx context.Context) {
+	if r.notifier == nil {
+		return
+	}
+	held, err := r.lmSyncer.HeldTransfers()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list the transfers held for review")
+		return
+	}
+	for _, match := range held {
+		r.notifier.Notify(ctx, notify.Event{
+			Kind:  notify.KindTransferReview,
+			Key:   fmt.Sprintf("%s:%d", notify.KindTransferReview, match.Pair.ID),
+			Title: fmt.Sprintf("Transfer %d held for review", match.Pair.ID),
+			Message: fmt.Sprintf("%s and %s are not synced until the transfer is reviewed with 'transfers confirm %d' or 'transfers break %d'.",
+				transferSide(match.Outflow, match.Pair.OutflowReference), transferSide(match.Inflow, match.Pair.InflowReference),
+				match.Pair.ID, match.Pair.ID),
+		})
+	}
+}
+
+// notifyLargeTransaction notifies of a fetched transaction over the configured amount
+// that is dated within the live fetch window
+func (r *replState) notifyLargeTransaction(ctx context.Context, tx *models.TransactionWithAccount, live models.DateRange) {
+	cfg, err := config.GetConfig()
+	if err != nil || cfg.Notifications.LargeTransactionAmount == 0 {
+		return
+	}
+	if inWindow, err := live.ContainsDate(tx.Date); err != nil || !inWindow {
+		return
+	}
+	amount, err := strconv.ParseFloat(tx.Amount.Value, 64)
+	if err != nil || math.Abs(amount) < cfg.Notifications.LargeTransactionAmount {
+		return
+	}
+
+	payee := "unknown payee"
+	if tx.Merchant != nil {
+		payee = tx.Merchant.Name
+	}
+	r.notifier.Notify(ctx, notify.Event{
+		Kind:  notify.KindLargeTransaction,
+		Key:   string(notify.KindLargeTransaction) + ":" + tx.ReferenceNumber,
+		Title: fmt.Sprintf("Large transaction of %s %s", tx.Amount.Value, tx.Amount.Currency),
+		Message: fmt.Sprintf("%s %s %s at %s in %s (%s)",
+			tx.Date, tx.Amount.Value, tx.Amount.Currency, payee, tx.SourceAccountName, tx.ReferenceNumber),
+	})
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// handleRules lists or re-applies the merchant rules
+// Format: rules [list|apply [--dry-run]]
+func (r *replState) handleRules(input string) {
+	parts := strings.Fields(input)
+	switch {
+	case len(parts) == 1 || len(parts) == 2 && parts[1] == "list":
+		r.listRules()
+	case len(parts) == 2 && parts[1] == "apply":
+		r.applyRules(false)
+	case len(parts) == 3 && parts[1] == "apply" && (parts[2] == "--dry-run" || parts[2] == "-n"):
+		r.applyRules(true)
+	default:
+		fmt.Println("Invalid rules command format.")
+		fmt.Println("Usage: rules [list|apply [--dry-run]]")
+	}
+}
+
+func (r *replState) listRules() {
+	rules := r.lmSyncer.GetMerchantNormalizer().Rules()
+	if len(rules) == 0 {
+		fmt.Println("No merchant rules configured, add merchantRules to config.yaml")
+		return
+	}
+
+	fmt.Printf("%-4s %-8s %-40s %s\n", "#", "Priority", "Match", "Name")
+	fmt.Println(strings.Repeat("-", 80))
+	for i, rule := range rules {
+		fmt.Printf("%-4d %-8d %-40s %s\n", i+1, rule.Priority, rule.String(), rule.Name)
+	}
+}
+
+func (r *replState) applyRules(dryRun bool) {
+	renames, err := r.lmSyncer.ApplyMerchantRules(dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("Error applying merchant rules")
+		return
+	}
+
+	if len(renames) == 0 {
+		fmt.Println("No merchants to rename")
+		return
+	}
+
+	fmt.Printf("%-20s %-30s %s\n", "Reference Number", "From", "To")
+	fmt.Println(strings.Repeat("-", 80))
+	for _, rename := range renames {
+		fmt.Printf("%-20s %-30s %s\n", rename.Transaction.ReferenceNumber, rename.From, rename.To)
+	}
+	if dryRun {
+		fmt.Printf("%d merchants would be renamed\n", len(renames))
+		return
+	}
+	fmt.Printf("Renamed %d merchants, run sync to update the synced ones in LunchMoney\n", len(renames))
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/secrets"
+	"golang.org/x/term"
+)
+
+// vaultPassphraseEnv unlocks the vault without prompting, e.g. for the daemon
+const vaultPassphraseEnv = "SANDWICH_SYNC_VAULT_PASSPHRASE"
+
+// openVault unlocks the vault with the passphrase from the environment or the
+// terminal, when create is set a missing vault is created and its passphrase asked twice
+func openVault(create bool) (*secrets.Vault, error) {
+	_, err := os.Stat(vaultPath)
+	exists := err == nil
+	if !exists && !create {
+		return nil, fmt.Errorf("no vault at %s, add secrets with 'secrets set <name>'", vaultPath)
+	}
+
+	if passphrase := os.Getenv(vaultPassphraseEnv); passphrase != "" {
+		return secrets.Open(vaultPath, passphrase)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("set %s to unlock the vault %s without a terminal", vaultPassphraseEnv, vaultPath)
+	}
+
+	passphrase, err := promptHidden("Vault passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		confirmation, err := promptHidden("Creating " + vaultPath + ", repeat the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if confirmation != passphrase {
+			return nil, errors.New("the passphrases do not match")
+		}
+	}
+	return secrets.Open(vaultPath, passphrase)
+}
+
+// promptHidden reads a line from the terminal without echoing it
+func promptHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	value, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from the terminal: %w", err)
+	}
+	return string(value), nil
+}
+
+// unlockSecrets resolves the secret:// references of the configuration, the vault
+// is only opened when there are some. Otherwise it is opened, or created, once a
+// session token is stored, so the token never ends up in config.yaml.
+func unlockSecrets(cfg *config.Config) error {
+	if len(cfg.SecretRefs()) == 0 {
+		config.UseSecretStoreOpener(openSessionVault)
+		return nil
+	}
+	vault, err := openVault(false)
+	if err != nil {
+		return err
+	}
+	return config.UseSecrets(vault)
+}
+
+// openSessionVault opens the vault the session tokens are stored in, it returns nil
+// when there is no vault and no passphrase to create one
+func openSessionVault() (config.SecretStore, error) {
+	if _, err := os.Stat(vaultPath); err != nil {
+		if os.Getenv(vaultPassphraseEnv) == "" && !term.IsTerminal(int(os.Stdin.Fd())) {
+			log.Warn().Msgf("Storing the session in config.yaml, set %s to keep it in a vault", vaultPassphraseEnv)
+			return nil, nil
+		}
+		fmt.Fprintf(os.Stderr, "Creating a vault at %s for the session tokens\n", vaultPath)
+	}
+	vault, err := openVault(true)
+	if err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+func setSecret(name string) {
+	if strings.TrimSpace(name) == "" || strings.Contains(name, "/") {
+		fmt.Println("Invalid secret name, it must not be empty or contain a slash.")
+		return
+	}
+	vault, err := openVault(true)
+	if err != nil {
+		log.Error().Err(err).Msg("Error opening vault")
+		return
+	}
+
+	// read the value rather than taking it as an argument, which would end up in the shell history
+	var value string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		value, err = promptHidden("Value of " + name + ": ")
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Scan()
+		value, err = scanner.Text(), scanner.Err()
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error reading secret")
+		return
+	}
+	if value == "" {
+		fmt.Println("The secret must not be empty.")
+		return
+	}
+
+	vault.Set(name, value)
+	if err := vault.Save(); err != nil {
+		log.Error().Err(err).Msg("Error saving vault")
+		return
+	}
+	fmt.Printf("Secret %s saved, reference it in config.yaml as %s\n", name, secrets.Ref(name))
+}
+
+func getSecret(name string) {
+	vault, err := openVault(false)
+	if err != nil {
+		log.Error().Err(err).Msg("Error opening vault")
+		return
+	}
+	value, ok := vault.Get(name)
+	if !ok {
+		fmt.Printf("No secret named %s\n", name)
+		return
+	}
+	fmt.Println(value)
+}
+
+func listSecrets() {
+	vault, err := openVault(false)
+	if err != nil {
+		log.Error().Err(err).Msg("Error opening vault")
+		return
+	}
+	names := vault.Names()
+	if len(names) == 0 {
+		fmt.Println("No secrets")
+		return
+	}
+
+	referenced := make(map[string]bool)
+	if cfg, err := config.GetConfig(); err == nil {
+		for _, name := range cfg.SecretRefs() {
+			referenced[name] = true
+		}
+	}
+	for _, name := range names {
+		if referenced[name] {
+			fmt.Printf("%s (referenced by config.yaml)\n", name)
+		} else {
+			fmt.Println(name)
+		}
+	}
+}
+
+func removeSecret(name string) {
+	vault, err := openVault(false)
+	if err != nil {
+		log.Error().Err(err).Msg("Error opening vault")
+		return
+	}
+	if !vault.Delete(name) {
+		fmt.Printf("No secret named %s\n", name)
+		return
+	}
+	if err := vault.Save(); err != nil {
+		log.Error().Err(err).Msg("Error saving vault")
+		return
+	}
+	fmt.Printf("Secret %s removed\n", name)
+}
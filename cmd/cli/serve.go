@@ -0,0 +1,419 @@
+package cli
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/pflag"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http"
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// apiServer exposes the database and the syncer as a JSON HTTP API. Fetches and syncs
+// run one at a time in the background and their progress is streamed to the clients
+// of /api/events.
+type apiServer struct {
+	r      *replState
+	token  string
+	events *eventBroker
+	// ctx is canceled when the server shuts down, background runs are stopped with it
+	ctx context.Context
+	// running is held while a fetch, sync or sync plan runs
+	running sync.Mutex
+	runs    sync.WaitGroup
+	lastRun int64
+}
+
+// apiRun is the progress of a fetch or sync started through the API
+type apiRun struct {
+	ID       int64  `json:"id"`
+	Action   string `json:"action"`
+	Provider string `json:"provider,omitempty"`
+	// Status is started, finished or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// apiAccount is a LunchMoney account with its last fetched balance
+type apiAccount struct {
+	LunchMoneyId       int64         `json:"lunchMoneyId"`
+	Name               string        `json:"name"`
+	DisplayName        string        `json:"displayName,omitempty"`
+	Balance            models.Amount `json:"balance"`
+	BalanceLastUpdated *time.Time    `json:"balanceLastUpdated,omitempty"`
+	SyncTransactions   bool          `json:"syncTransactions"`
+	SyncBalance        bool          `json:"syncBalance"`
+}
+
+// apiMapping is an external account and the LunchMoney account it is synced to
+type apiMapping struct {
+	ExternalName     string         `json:"externalName"`
+	LunchMoneyId     int64          `json:"lunchMoneyId"`
+	IsPlaid          bool           `json:"isPlaid"`
+	Ignored          bool           `json:"ignored"`
+	FromRule         bool           `json:"fromRule"`
+	Balance          *models.Amount `json:"balance,omitempty"`
+	BalanceUpdatedAt *time.Time     `json:"balanceUpdatedAt,omitempty"`
+}
+
+// apiProvider is a provider that can be fetched from
+type apiProvider struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Configured  bool   `json:"configured"`
+}
+
+// checkListenAddr refuses to serve the API without a token on an address that other
+// machines can connect to
+func checkListenAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("refusing to listen on %s without --token, set one or listen on a loopback address", addr)
+}
+
+// runServer serves the API on addr until ctx is canceled, then waits for the run in progress
+func (r *replState) runServer(ctx context.Context, addr, token string, events *eventBroker) error {
+	if err := checkListenAddr(addr, token); err != nil {
+		return err
+	}
+	s := newAPIServer(ctx, r, token, events)
+	server := &nethttp.Server{
+		Addr:              addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	log.Info().Str("addr", addr).Msg("API server listening")
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	s.wait()
+	log.Info().Msg("API server stopped")
+	return err
+}
+
+func newAPIServer(ctx context.Context, r *replState, token string, events *eventBroker) *apiServer {
+	return &apiServer{r: r, token: token, events: events, ctx: ctx}
+}
+
+// handler returns the routes of the API
+func (s *apiServer) handler() nethttp.Handler {
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("GET /api/transactions", s.listTransactions)
+	mux.HandleFunc("GET /api/transactions/{ref}", s.getTransaction)
+	mux.HandleFunc("GET /api/accounts", s.listAccounts)
+	mux.HandleFunc("GET /api/mappings", s.listMappings)
+	mux.HandleFunc("GET /api/providers", s.listProviders)
+	mux.HandleFunc("GET /api/runs", s.listRuns)
+	mux.HandleFunc("POST /api/fetch/{provider}", s.startFetch)
+	mux.HandleFunc("POST /api/sync", s.startSync)
+	mux.HandleFunc("GET /api/events", s.streamEvents)
+	mux.Handle("GET /metrics", metrics.Handler())
+	return s.authorize(mux)
+}
+
+// authorize requires the bearer token when one is set, event streams may pass it as
+// the access_token query parameter since browsers can not set headers on them
+func (s *apiServer) authorize(next nethttp.Handler) nethttp.Handler {
+	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		if s.token != "" && !s.validToken(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")) &&
+			!s.validToken(req.URL.Query().Get("access_token")) {
+			writeAPIError(w, nethttp.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (s *apiServer) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// listTransactions filters the transactions with the flags of the list command
+// given as query parameters, e.g. ?status=unsynced&since=2025-01-01&limit=20
+func (s *apiServer) listTransactions(w nethttp.ResponseWriter, req *nethttp.Request) {
+	var flags listFlags
+	fs := pflag.NewFlagSet("list", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags.register(fs)
+	for name, values := range req.URL.Query() {
+		if name == "access_token" {
+			continue
+		}
+		if err := fs.Set(name, values[len(values)-1]); err != nil {
+			writeAPIError(w, nethttp.StatusBadRequest, fmt.Errorf("invalid query parameter %s: %w", name, err))
+			return
+		}
+	}
+
+	filter, err := flags.filter()
+	if err != nil {
+		writeAPIError(w, nethttp.StatusBadRequest, err)
+		return
+	}
+	transactions, err := s.r.db.FilterTransactions(filter)
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, transactions)
+}
+
+// getTransaction returns a transaction with its history
+func (s *apiServer) getTransaction(w nethttp.ResponseWriter, req *nethttp.Request) {
+	ref := req.PathValue("ref")
+	tx, err := s.r.db.GetTransactionByReference(ref)
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	if tx == nil {
+		writeAPIError(w, nethttp.StatusNotFound, fmt.Errorf("no transaction found with reference number %s", ref))
+		return
+	}
+	events, err := s.r.db.GetTransactionEvents(ref)
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, struct {
+		*models.TransactionWithAccount
+		Events []*models.TransactionEvent `json:"events"`
+	}{tx, events})
+}
+
+func (s *apiServer) listAccounts(w nethttp.ResponseWriter, req *nethttp.Request) {
+	accounts, err := s.r.db.GetAccounts()
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	response := make([]apiAccount, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, apiAccount{
+			LunchMoneyId:       account.LunchMoneyId,
+			Name:               account.Name,
+			DisplayName:        account.DisplayName,
+			Balance:            account.Balance,
+			BalanceLastUpdated: account.BalanceLastUpdated,
+			SyncTransactions:   account.SyncStrategy&models.SyncOptionTransactions != 0,
+			SyncBalance:        account.SyncStrategy&models.SyncOptionBalance != 0,
+		})
+	}
+	writeJSON(w, nethttp.StatusOK, response)
+}
+
+func (s *apiServer) listMappings(w nethttp.ResponseWriter, req *nethttp.Request) {
+	mappings, err := s.r.accountMappings(req.Context(), nil)
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	response := make([]apiMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		response = append(response, apiMapping{
+			ExternalName:     mapping.ExternalName,
+			LunchMoneyId:     mapping.LunchMoneyId,
+			IsPlaid:          mapping.IsPlaid,
+			Ignored:          mapping.IsIgnored(),
+			FromRule:         mapping.FromRule,
+			Balance:          mapping.Balance,
+			BalanceUpdatedAt: mapping.BalanceUpdatedAt,
+		})
+	}
+	writeJSON(w, nethttp.StatusOK, response)
+}
+
+func (s *apiServer) listProviders(w nethttp.ResponseWriter, req *nethttp.Request) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	response := make([]apiProvider, 0)
+	for _, provider := range http.Providers() {
+		response = append(response, apiProvider{
+			Name:        provider.Name,
+			Description: provider.Description,
+			Configured:  provider.ValidateConfig(cfg) == nil,
+		})
+	}
+	writeJSON(w, nethttp.StatusOK, response)
+}
+
+// listRuns returns the recent fetch and sync runs, ?limit= defaults to 20
+func (s *apiServer) listRuns(w nethttp.ResponseWriter, req *nethttp.Request) {
+	limit := 20
+	if value := req.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			writeAPIError(w, nethttp.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+	}
+	runs, err := s.r.db.GetSyncRuns(limit)
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, runs)
+}
+
+// startFetch fetches from a provider, or every configured one for "all", in the background
+func (s *apiServer) startFetch(w nethttp.ResponseWriter, req *nethttp.Request) {
+	provider := req.PathValue("provider")
+	if _, ok := http.GetProvider(provider); !ok && provider != "all" {
+		writeAPIError(w, nethttp.StatusNotFound, fmt.Errorf("unknown provider %s", provider))
+		return
+	}
+	s.start(w, apiRun{Action: "fetch", Provider: provider}, func(ctx context.Context) error {
+		return s.r.fetch(ctx, provider, windowFlags{})
+	})
+}
+
+// startSync syncs with LunchMoney in the background, with ?dryRun=true the sync plan
+// is returned instead
+func (s *apiServer) startSync(w nethttp.ResponseWriter, req *nethttp.Request) {
+	dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	if !dryRun {
+		s.start(w, apiRun{Action: "sync"}, func(ctx context.Context) error {
+			if err := s.r.setSyncWindow(windowFlags{}); err != nil {
+				return err
+			}
+			return s.r.syncState(ctx)
+		})
+		return
+	}
+
+	if !s.running.TryLock() {
+		writeAPIError(w, nethttp.StatusConflict, errors.New("a fetch or sync is already running"))
+		return
+	}
+	defer s.running.Unlock()
+	if err := s.r.setSyncWindow(windowFlags{}); err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	plan, err := s.r.lmSyncer.Plan(req.Context())
+	if err != nil {
+		writeAPIError(w, nethttp.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, nethttp.StatusOK, plan)
+}
+
+// start runs fn in the background unless another run is in progress and publishes
+// when it starts and ends
+func (s *apiServer) start(w nethttp.ResponseWriter, run apiRun, fn func(ctx context.Context) error) {
+	if !s.running.TryLock() {
+		writeAPIError(w, nethttp.StatusConflict, errors.New("a fetch or sync is already running"))
+		return
+	}
+	s.lastRun++
+	run.ID = s.lastRun
+	run.Status = "started"
+	s.events.publish(serverEvent{Type: "run", Data: run})
+
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer s.running.Unlock()
+
+		run.Status = "finished"
+		if err := fn(s.ctx); err != nil {
+			run.Status, run.Error = "failed", err.Error()
+		}
+		s.r.reportUnmapped()
+		s.events.publish(serverEvent{Type: "run", Data: run})
+	}()
+	writeJSON(w, nethttp.StatusAccepted, run)
+}
+
+// wait waits for the background run in progress, if any
+func (s *apiServer) wait() {
+	s.runs.Wait()
+}
+
+// streamEvents sends the run and log events as server-sent events until the client
+// disconnects or the server shuts down
+func (s *apiServer) streamEvents(w nethttp.ResponseWriter, req *nethttp.Request) {
+	flusher, ok := w.(nethttp.Flusher)
+	if !ok {
+		writeAPIError(w, nethttp.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	events := s.events.subscribe()
+	defer s.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(nethttp.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w nethttp.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Error writing API response")
+	}
+}
+
+func writeAPIError(w nethttp.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+)
+
+func newTestAPIServer(t *testing.T, token string) (*apiServer, *db.MockDB, *httptest.Server) {
+	t.Helper()
+	mockDB := db.NewMockDB()
+	syncer, err := services.NewLunchMoneySyncer(context.Background(), "test-key", mockDB)
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newAPIServer(ctx, &replState{db: mockDB, lmSyncer: syncer}, token, newEventBroker())
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return s, mockDB, server
+}
+
+func TestAPIListTransactions(t *testing.T) {
+	_, mockDB, server := newTestAPIServer(t, "")
+	for ref, lunchMoneyID := range map[string]int64{"TX1": 0, "TX2": 42} {
+		mockDB.Transactions[ref] = &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				LunchMoneyID:    lunchMoneyID,
+				Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: "Coffee", Address: &models.Address{}},
+				Date:            "2025-03-01",
+			},
+			SourceAccountName: "Visa",
+		}
+	}
+
+	resp, err := nethttp.Get(server.URL + "/api/transactions?status=unsynced")
+	if err != nil {
+		t.Fatalf("Failed to list transactions: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var transactions []models.TransactionWithAccount
+	if err := json.NewDecoder(resp.Body).Decode(&transactions); err != nil {
+		t.Fatalf("Failed to decode transactions: %v", err)
+	}
+	if len(transactions) != 1 || transactions[0].ReferenceNumber != "TX1" {
+		t.Errorf("Expected only the unsynced TX1, got %+v", transactions)
+	}
+
+	for _, query := range []string{"?status=maybe", "?unknown=1"} {
+		resp, err := nethttp.Get(server.URL + "/api/transactions" + query)
+		if err != nil {
+			t.Fatalf("Failed to list transactions: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != nethttp.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, resp.StatusCode)
+		}
+	}
+
+	resp, err = nethttp.Get(server.URL + "/api/transactions/TX9")
+	if err != nil {
+		t.Fatalf("Failed to get transaction: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown transaction, got %d", resp.StatusCode)
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	_, _, server := newTestAPIServer(t, "secret")
+
+	tests := []struct {
+		header   string
+		query    string
+		expected int
+	}{
+		{"", "", nethttp.StatusUnauthorized},
+		{"Bearer wrong", "", nethttp.StatusUnauthorized},
+		{"Bearer secret", "", nethttp.StatusOK},
+		{"", "?access_token=secret", nethttp.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL+"/api/mappings"+tt.query, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		resp, err := nethttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to list mappings: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.expected {
+			t.Errorf("Expected %d with header %q and query %q, got %d", tt.expected, tt.header, tt.query, resp.StatusCode)
+		}
+	}
+}
+
+func TestCheckListenAddr(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		wantErr     bool
+	}{
+		{"127.0.0.1:8080", "", false},
+		{"localhost:8080", "", false},
+		{"[::1]:8080", "", false},
+		{":8080", "", true},
+		{"0.0.0.0:8080", "", true},
+		{"192.168.1.10:8080", "", true},
+		{"0.0.0.0:8080", "secret", false},
+		{"127.0.0.1", "", true},
+	}
+	for _, tt := range tests {
+		if err := checkListenAddr(tt.addr, tt.token); (err != nil) != tt.wantErr {
+			t.Errorf("checkListenAddr(%q, %q) error = %v, wantErr %v", tt.addr, tt.token, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAPIRunsOneAtATime(t *testing.T) {
+	s, _, server := newTestAPIServer(t, "")
+
+	resp, err := nethttp.Post(server.URL+"/api/fetch/nowhere", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to start fetch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown provider, got %d", resp.StatusCode)
+	}
+
+	s.running.Lock()
+	defer s.running.Unlock()
+	for _, path := range []string{"/api/sync", "/api/sync?dryRun=true"} {
+		resp, err := nethttp.Post(server.URL+path, "", nil)
+		if err != nil {
+			t.Fatalf("Failed to start sync: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != nethttp.StatusConflict {
+			t.Errorf("Expected 409 for %s while a run is in progress, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	s, _, server := newTestAPIServer(t, "")
+
+	resp, err := nethttp.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("Failed to stream events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", ct)
+	}
+
+	// the client is subscribed once the headers are sent
+	s.events.publish(serverEvent{Type: "run", Data: apiRun{ID: 1, Action: "sync", Status: "started"}})
+	if _, err := s.events.Write([]byte(`{"level":"info","message":"Transactions processed"}` + "\n")); err != nil {
+		t.Fatalf("Failed to write log entry: %v", err)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	expected := []string{
+		"event: run",
+		`data: {"id":1,"action":"sync","status":"started"}`,
+		"",
+		"event: log",
+		`data: {"level":"info","message":"Transactions processed"}`,
+	}
+	for _, want := range expected {
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Errorf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+)
+
+// processSync parses the sync command
+// Format: sync [--dry-run] [--json] [--since <date>] [--until <date>]
+func (r *replState) processSync(input string) {
+	var wf windowFlags
+	parts, err := wf.parseArgs(strings.Fields(input))
+
+	dryRun, asJSON := false, false
+	for _, part := range parts[min(1, len(parts)):] {
+		switch part {
+		case "--dry-run", "-n":
+			dryRun = true
+		case "--json":
+			asJSON = true
+		default:
+			err = fmt.Errorf("unknown argument %s", part)
+		}
+	}
+
+	if err != nil {
+		fmt.Println("Invalid sync command format.")
+		fmt.Println("Usage: sync [--dry-run] [--json] [--since YYYY-MM-DD] [--until YYYY-MM-DD]")
+		return
+	}
+
+	if err := r.setSyncWindow(wf); err != nil {
+		log.Error().Err(err).Msg("Invalid sync window")
+		return
+	}
+
+	if dryRun {
+		r.planSync(asJSON)
+		return
+	}
+	r.syncState(context.Background())
+}
+
+// setSyncWindow configures the syncer with the configured window overridden by the flags
+func (r *replState) setSyncWindow(wf windowFlags) error {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	window, err := wf.resolve(cfg.SyncWindowDays())
+	if err != nil {
+		return err
+	}
+	r.lmSyncer.SetSyncWindow(window)
+	return nil
+}
+
+// syncState pushes the unsynced transactions and newer balances to LunchMoney
+func (r *replState) syncState(ctx context.Context) error {
+	recorder := services.StartRun(r.db, models.SyncRunKindSync, "")
+	r.lmSyncer.SetRecorder(recorder)
+	defer r.lmSyncer.SetRecorder(nil)
+
+	err := r.lmSyncer.SyncTransactions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error syncing transactions")
+		recorder.Finish(err)
+		r.notifySyncResult(ctx, err)
+		return err
+	}
+
+	err = r.lmSyncer.SyncBalances(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Error syncing balances")
+		recorder.Finish(err)
+		r.notifySyncResult(ctx, err)
+		return err
+	}
+	recorder.Finish(nil)
+	r.notifySyncResult(ctx, nil)
+	r.notifyUnmapped(ctx)
+	r.notifyHeldTransfers(ctx)
+	r.reportVanished()
+	return nil
+}
+
+func (r *replState) planSync(asJSON bool) {
+	plan, err := r.lmSyncer.Plan(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Error planning sync")
+		return
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			log.Error().Err(err).Msg("Error encoding sync plan")
+		}
+		return
+	}
+	printSyncPlan(plan)
+}
+
+func printSyncPlan(plan *services.SyncPlan) {
+	if plan.IsEmpty() && len(plan.Skipped) == 0 && len(plan.Vanished) == 0 && len(plan.Ambiguous) == 0 {
+		fmt.Println("Nothing to sync")
+		return
+	}
+
+	fmt.Printf("Transactions to insert (%d):\n", len(plan.Inserts))
+	if len(plan.Inserts) != 0 {
+		fmt.Printf("%-30s %-15s %-30s %-12s %-12s %-12s\n", "Reference Number", "Amount", "Merchant Name", "Date", "LM Account", "LM Category")
+		fmt.Println(strings.Repeat("-", 116))
+		for _, insert := range plan.Inserts {
+			tx := insert.Transaction
+			category := "-"
+			if insert.CategoryID != models.Uncategorized {
+				category = strconv.FormatInt(insert.CategoryID, 10)
+			}
+			fmt.Printf("%-30s %-15s %-30s %-12s %-12d %-12s\n",
+				tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))],
+				tx.Amount.Value+" "+tx.Amount.Currency,
+				tx.Merchant.Name[:min(30, len(tx.Merchant.Name))],
+				tx.Date,
+				insert.Mapping.LunchMoneyId,
+				category)
+		}
+	}
+	fmt.Println()
+
+	fmt.Printf("New transfers (%d):\n", len(plan.Transfers))
+	for _, match := range plan.Transfers {
+		fmt.Printf("  %-30s -> %-30s %s\n",
+			match.Outflow.ReferenceNumber[:min(30, len(match.Outflow.ReferenceNumber))],
+			match.Inflow.ReferenceNumber[:min(30, len(match.Inflow.ReferenceNumber))],
+			match.Outflow.Amount.Value+" "+match.Outflow.Amount.Currency)
+	}
+	fmt.Println()
+
+	fmt.Printf("Transactions to update (%d):\n", len(plan.Updates))
+	for _, update := range plan.Updates {
+		tx := update.Transaction
+		changes := make([]string, 0)
+		if update.Update.Amount != nil {
+			changes = append(changes, fmt.Sprintf("amount %s -> %s", tx.Synced.Amount, update.Update.Amount.Value))
+		}
+		if update.Update.Date != nil {
+			changes = append(changes, fmt.Sprintf("date %s -> %s", tx.Synced.Date, *update.Update.Date))
+		}
+		if update.Update.Payee != nil {
+			changes = append(changes, fmt.Sprintf("payee %q -> %q", tx.Synced.Payee, *update.Update.Payee))
+		}
+		if update.Update.ExternalID != nil {
+			changes = append(changes, "reference -> "+*update.Update.ExternalID)
+		}
+		if len(update.Preserved) != 0 {
+			changes = append(changes, "keeping LunchMoney edits to "+strings.Join(update.Preserved, ", "))
+		}
+		fmt.Printf("  %-30s %-12d %s\n", tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))], tx.LunchMoneyID, strings.Join(changes, "; "))
+	}
+	fmt.Println()
+
+	fmt.Printf("Pending transactions that posted (%d):\n", len(plan.Posted))
+	for _, match := range plan.Posted {
+		fmt.Printf("  %-30s -> %-30s %-12d\n",
+			match.Pending.ReferenceNumber[:min(30, len(match.Pending.ReferenceNumber))],
+			match.Posted.ReferenceNumber[:min(30, len(match.Posted.ReferenceNumber))],
+			match.Posted.LunchMoneyID)
+	}
+	fmt.Println()
+
+	reasons := make(map[*models.TransactionWithAccount]string, len(plan.Matches))
+	for _, match := range plan.Matches {
+		reasons[match.Transaction] = match.Best().Reason
+	}
+	fmt.Printf("Local LunchMoney IDs to back-fill (%d):\n", len(plan.Backfills))
+	for _, tx := range plan.Backfills {
+		fmt.Printf("  %-30s -> %-12d %s\n", tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))], tx.LunchMoneyID, reasons[tx])
+	}
+	fmt.Println()
+
+	fmt.Printf("Possible duplicates to review with 'duplicates list' (%d):\n", len(plan.Ambiguous))
+	for _, match := range plan.Ambiguous {
+		best := match.Best()
+		fmt.Printf("  %-30s ~> %-12d %s\n",
+			match.Transaction.ReferenceNumber[:min(30, len(match.Transaction.ReferenceNumber))],
+			best.LunchMoney.LunchMoneyID,
+			best.Reason)
+	}
+	fmt.Println()
+
+	fmt.Printf("Skipped transactions (%d):\n", len(plan.Skipped))
+	for _, skipped := range plan.Skipped {
+		tx := skipped.Transaction
+		fmt.Printf("  %-30s %-20s %s\n",
+			tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))],
+			tx.SourceAccountName[:min(20, len(tx.SourceAccountName))],
+			skipped.Reason)
+	}
+	fmt.Println()
+
+	fmt.Printf("Vanished upstream, left untouched (%d):\n", len(plan.Vanished))
+	for _, tx := range plan.Vanished {
+		fmt.Printf("  %-30s %-12d %s\n", tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))], tx.LunchMoneyID, tx.Date)
+	}
+	fmt.Println()
+
+	fmt.Printf("Balances to update (%d):\n", len(plan.BalanceUpdates))
+	for _, update := range plan.BalanceUpdates {
+		fmt.Printf("  %-10d %-30s %15s -> %-15s %s\n",
+			update.LunchMoneyId,
+			update.AccountName[:min(30, len(update.AccountName))],
+			update.From.Value,
+			update.To.Value,
+			strings.ToUpper(update.To.Currency))
+	}
+}
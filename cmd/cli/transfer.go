@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/services"
+)
+
+const transfersUsage = `Usage: transfers [list]
+       transfers match [--dry-run]
+       transfers confirm <id|all>
+       transfers break <id>`
+
+// handleTransfers parses the transfers command
+// Format: transfers [list|match [--dry-run]|confirm <id|all>|break <id>]
+func (r *replState) handleTransfers(input string) {
+	parts := strings.Fields(input)
+	if len(parts) == 1 {
+		r.listTransfers()
+		return
+	}
+
+	switch {
+	case parts[1] == "list" && len(parts) == 2:
+		r.listTransfers()
+	case parts[1] == "match" && len(parts) == 2:
+		r.matchTransfers(false)
+	case parts[1] == "match" && len(parts) == 3 && (parts[2] == "--dry-run" || parts[2] == "-n"):
+		r.matchTransfers(true)
+	case parts[1] == "confirm" && len(parts) == 3:
+		r.confirmTransfers(parts[2])
+	case parts[1] == "break" && len(parts) == 3:
+		r.breakTransfer(parts[2])
+	default:
+		fmt.Println("Invalid transfers command format.")
+		fmt.Println(transfersUsage)
+	}
+}
+
+// listTransfers prints the transfer pairs that were not broken
+func (r *replState) listTransfers() {
+	matches, err := r.lmSyncer.GetTransfers()
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching transfers")
+		return
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No transfers")
+		return
+	}
+	printTransfers(matches)
+	fmt.Println()
+	fmt.Println("Use 'transfers confirm <id|all>' to confirm them or 'transfers break <id>' to sync")
+	fmt.Println("both transactions on their own.")
+}
+
+func printTransfers(matches []*services.TransferMatch) {
+	fmt.Printf("%-6s %-10s %-15s %-12s %-30s %-30s\n", "ID", "Status", "Amount", "Date", "From", "To")
+	fmt.Println(strings.Repeat("-", 108))
+	for _, match := range matches {
+		amount, date := "-", "-"
+		if match.Outflow != nil {
+			amount = match.Outflow.Amount.Value + " " + match.Outflow.Amount.Currency
+			date = match.Outflow.Date
+		}
+		fmt.Printf("%-6d %-10s %-15s %-12s %-30s %-30s\n",
+			match.Pair.ID,
+			match.Pair.Status,
+			amount,
+			date,
+			transferSide(match.Outflow, match.Pair.OutflowReference),
+			transferSide(match.Inflow, match.Pair.InflowReference))
+	}
+}
+
+// transferSide describes one side of a transfer by its account and reference number
+func transferSide(tx *models.TransactionWithAccount, referenceNumber string) string {
+	side := referenceNumber + " (deleted)"
+	if tx != nil {
+		side = tx.SourceAccountName + " " + referenceNumber
+	}
+	return side[:min(30, len(side))]
+}
+
+// matchTransfers pairs the unsynced transactions that look like transfers between
+// mapped accounts, only printing the pairs if dryRun is set
+func (r *replState) matchTransfers(dryRun bool) {
+	matches, err := r.lmSyncer.MatchTransfers(context.Background(), dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("Error matching transfers")
+		return
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No new transfers")
+		return
+	}
+	if dryRun {
+		fmt.Printf("Would match %d transfers:\n\n", len(matches))
+	} else {
+		fmt.Printf("Matched %d transfers:\n\n", len(matches))
+	}
+	printTransfers(matches)
+}
+
+// reportTransfers matches new transfers after a fetch and prints a reminder to review them
+func (r *replState) reportTransfers(recorder *services.RunRecorder) {
+	r.lmSyncer.SetRecorder(recorder)
+	defer r.lmSyncer.SetRecorder(nil)
+
+	matches, err := r.lmSyncer.MatchTransfers(context.Background(), false)
+	if err != nil {
+		log.Error().Err(err).Msg("Error matching transfers")
+		return
+	}
+	if len(matches) != 0 {
+		log.Info().Int("count", len(matches)).Msg("Matched transfers between accounts, review them with 'transfers list'")
+	}
+}
+
+func (r *replState) confirmTransfers(idArg string) {
+	ids := make([]int64, 0)
+	if idArg == "all" {
+		matches, err := r.lmSyncer.GetTransfers()
+		if err != nil {
+			log.Error().Err(err).Msg("Error fetching transfers")
+			return
+		}
+		for _, match := range matches {
+			if match.Pair.Status == models.TransferStatusMatched {
+				ids = append(ids, match.Pair.ID)
+			}
+		}
+	} else {
+		id, err := strconv.ParseInt(idArg, 10, 64)
+		if err != nil {
+			fmt.Println("Invalid transfer ID. Must be a number or all.")
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids {
+		if err := r.lmSyncer.ConfirmTransfer(id); err != nil {
+			log.Error().Err(err).Int64("id", id).Msg("Error confirming transfer")
+			return
+		}
+		log.Info().Int64("id", id).Msg("Transfer confirmed")
+	}
+}
+
+func (r *replState) breakTransfer(idArg string) {
+	id, err := strconv.ParseInt(idArg, 10, 64)
+	if err != nil {
+		fmt.Println("Invalid transfer ID. Must be a number.")
+		return
+	}
+
+	if err := r.lmSyncer.BreakTransfer(id); err != nil {
+		log.Error().Err(err).Int64("id", id).Msg("Error breaking transfer")
+		return
+	}
+	log.Info().Int64("id", id).Msg("Transfer pair broken, both transactions are synced on their own")
+}
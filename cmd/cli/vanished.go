@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// handleVanished lists and resolves transactions that disappeared upstream
+// Format: vanished [list|mark <ref|all>|dismiss <ref|all>]
+func (r *replState) handleVanished(input string) {
+	parts := strings.Fields(input)
+	if len(parts) < 2 || parts[1] == "list" || parts[1] == "l" {
+		r.listVanished()
+		return
+	}
+
+	if len(parts) < 3 || (parts[1] != "mark" && parts[1] != "dismiss") {
+		fmt.Println("Invalid vanished command format.")
+		fmt.Println("Usage: vanished [list|mark <ref|all>|dismiss <ref|all>]")
+		return
+	}
+
+	transactions, err := r.db.GetVanishedTransactions()
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching vanished transactions")
+		return
+	}
+
+	if parts[2] != "all" {
+		var match *models.TransactionWithAccount
+		for _, tx := range transactions {
+			if tx.ReferenceNumber == parts[2] {
+				match = tx
+				break
+			}
+		}
+		if match == nil {
+			fmt.Printf("No unresolved vanished transaction with reference number %s\n", parts[2])
+			return
+		}
+		transactions = []*models.TransactionWithAccount{match}
+	}
+
+	for _, tx := range transactions {
+		if parts[1] == "mark" {
+			err = r.lmSyncer.MarkVanished(context.Background(), tx)
+		} else {
+			err = r.lmSyncer.DismissVanished(tx)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("transaction", tx.ReferenceNumber).Msg("Error resolving vanished transaction")
+			return
+		}
+		log.Info().Str("transaction", tx.ReferenceNumber).Str("action", string(tx.VanishedAction)).
+			Msg("Vanished transaction resolved")
+	}
+}
+
+func (r *replState) listVanished() {
+	transactions, err := r.db.GetVanishedTransactions()
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching vanished transactions")
+		return
+	}
+
+	if len(transactions) == 0 {
+		fmt.Println("No vanished transactions")
+		return
+	}
+
+	fmt.Printf("Found %d transactions that vanished upstream:\n\n", len(transactions))
+	fmt.Printf("%-30s %-15s %-30s %-12s %-20s %-12s\n", "Reference Number", "Amount", "Merchant Name", "Date", "Vanished At", "LunchMoney ID")
+	fmt.Println(strings.Repeat("-", 124))
+	for _, tx := range transactions {
+		fmt.Printf("%-30s %-15s %-30s %-12s %-20s %-12d\n",
+			tx.ReferenceNumber[:min(30, len(tx.ReferenceNumber))],
+			tx.Amount.Value+" "+tx.Amount.Currency,
+			tx.Merchant.Name[:min(30, len(tx.Merchant.Name))],
+			tx.Date,
+			tx.VanishedAt.Format("2006-01-02 15:04"),
+			tx.LunchMoneyID)
+	}
+	fmt.Println()
+	fmt.Println("Use 'vanished mark <ref|all>' to flag them in LunchMoney, or delete them in the")
+	fmt.Println("LunchMoney web app and use 'vanished dismiss <ref|all>'.")
+}
+
+// reportVanished prints a reminder when vanished transactions need to be reviewed
+func (r *replState) reportVanished() {
+	transactions, err := r.db.GetVanishedTransactions()
+	if err != nil {
+		log.Error().Err(err).Msg("Error fetching vanished transactions")
+		return
+	}
+	if len(transactions) != 0 {
+		fmt.Printf("%d transactions vanished upstream, review them with 'vanished list'\n", len(transactions))
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// windowFlags holds the --since/--until overrides of the configured sync window
+type windowFlags struct {
+	since string
+	until string
+}
+
+func (w *windowFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&w.since, "since", "", "Start date (YYYY-MM-DD), overrides the configured sync window")
+	cmd.Flags().StringVar(&w.until, "until", "", "End date (YYYY-MM-DD), defaults to today")
+}
+
+// parseArgs consumes --since/--until from REPL arguments and returns the remaining ones
+func (w *windowFlags) parseArgs(args []string) ([]string, error) {
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--since", "--until":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("missing date after %s", args[i])
+			}
+			if args[i] == "--since" {
+				w.since = args[i+1]
+			} else {
+				w.until = args[i+1]
+			}
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, nil
+}
+
+// resolveOptional returns the window given by the flags, or the zero window that
+// matches every date when neither flag is set
+func (w windowFlags) resolveOptional() (models.DateRange, error) {
+	if w.since == "" && w.until == "" {
+		return models.DateRange{}, nil
+	}
+	if w.since == "" {
+		w.since = "1900-01-01"
+	}
+	return w.resolve(0)
+}
+
+// resolve returns the last days window with the start and end overridden by the flags
+func (w windowFlags) resolve(days int) (models.DateRange, error) {
+	window := models.LastDays(days)
+	if w.since != "" {
+		since, err := time.Parse(time.DateOnly, w.since)
+		if err != nil {
+			return models.DateRange{}, fmt.Errorf("invalid --since date: %w", err)
+		}
+		window.Start = since
+	}
+	if w.until != "" {
+		until, err := time.Parse(time.DateOnly, w.until)
+		if err != nil {
+			return models.DateRange{}, fmt.Errorf("invalid --until date: %w", err)
+		}
+		window.End = until
+	}
+	if window.Start.After(window.End) {
+		return models.DateRange{}, fmt.Errorf("start date %s is after end date %s", window.StartDate(), window.EndDate())
+	}
+	return window, nil
+}
@@ -7,12 +7,13 @@ import (
 
 	"github.com/samber/lo"
 	"github.com/vpnda/sandwich-sync/pkg/http/scotia"
+	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
 func main() {
 	c := lo.Must(scotia.NewScotiaClient())
 	lo.Must0(c.AuthenticateDynamic(context.Background()))
-	transactions := lo.Must(c.FetchTransactions(context.Background()))
+	transactions := lo.Must(c.FetchTransactions(context.Background(), models.LastDays(30)))
 
 	fmt.Printf("%-20s %-30s %-15s %-30s %-15s %-15s\n", "SourceAccount", "Reference Number", "Amount", "Merchant Name", "Date", "LunchMoney ID")
 	fmt.Println(strings.Repeat("-", 130))
@@ -3,53 +3,12 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
-func (db *DB) createAccountInfoTable() error {
-	query := fmt.Sprintf(`
-	CREATE TABLE IF NOT EXISTS account_info (
-		lunchmoney_account_id INTEGER PRIMARY KEY,
-		balance_value TEXT,
-		balance_currency TEXT,
-		balance_updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		is_plaid BOOLEAN DEFAULT false,
-		sync_strategy INTEGER DEFAULT %d
-	)`, models.AllSyncOption)
-
-	_, err := db.Exec(query, models.AllSyncOption)
-	if err != nil {
-		return fmt.Errorf("failed to create account_info table: %w", err)
-	}
-
-	// Add source account name column if it doesn't exist
-	query = `
-	select count(*) from
-	pragma_table_info('account_info')
-	where name='sync_strategy';
-	`
-	var count int
-	err = db.QueryRow(query).Scan(&count)
-	if err != nil {
-		return fmt.Errorf("failed to check for sync_strategy column: %w", err)
-	}
-
-	if count == 0 {
-		query = fmt.Sprintf(`
-		ALTER TABLE account_info ADD COLUMN sync_strategy INTEGER DEFAULT %d;
-		`, models.AllSyncOption)
-
-		_, err = db.Exec(query, models.AllSyncOption)
-		if err != nil {
-			return fmt.Errorf("failed to add sync_strategy column: %w", err)
-		}
-	}
-
-	return err
-}
-
 func (db *DB) DisableSyncOptions(lunchMoneyId string, syncOption models.SyncOption) error {
 	query := `
 	UPDATE account_info
@@ -79,8 +38,9 @@ func (db *DB) IsSyncOptionEnabled(lunchMoneyId int64, syncOption models.SyncOpti
 	return (models.SyncOption(storedSyncOptions) & syncOption) > 0, nil
 }
 
-// UpsertAccountBalance saves the balance for a given external account ID
-func (db *DB) UpsertAccountBalance(externalId string, balance models.Amount) error {
+// UpsertAccountBalance saves the balance for a given external account ID as of the
+// given time, a balance older than the saved one is skipped
+func (db *DB) UpsertAccountBalance(externalId string, balance models.Amount, asOf time.Time) error {
 	// first check if we've mapped the account
 	query := `
 	SELECT CAST(lunchmoney_account_id as INTEGER) FROM account_mappings WHERE external_name = ?
@@ -100,19 +60,24 @@ func (db *DB) UpsertAccountBalance(externalId string, balance models.Amount) err
 	// if we have, upsert the balance
 	query = `
 	INSERT INTO account_info (lunchmoney_account_id, balance_value, balance_currency, balance_updated_at, is_plaid)
-	VALUES (?, ?, ?, CURRENT_TIMESTAMP, false)
+	VALUES (?, ?, ?, ?, false)
 	ON CONFLICT(lunchmoney_account_id) 
 	DO UPDATE SET 
 		balance_value = excluded.balance_value,
 		balance_currency = excluded.balance_currency,
-		balance_updated_at = CURRENT_TIMESTAMP,
+		balance_updated_at = excluded.balance_updated_at,
 		is_plaid = excluded.is_plaid
+	WHERE account_info.balance_updated_at IS NULL OR account_info.balance_updated_at <= excluded.balance_updated_at
 	`
 
-	_, err = db.Exec(query, lunchmoneyAccountId, balance.Value, balance.Currency)
+	// formatted like CURRENT_TIMESTAMP so the stored times compare as text
+	result, err := db.Exec(query, lunchmoneyAccountId, balance.Value, balance.Currency, asOf.UTC().Format(time.DateTime))
 	if err != nil {
 		return fmt.Errorf("failed to upsert account balance: %w", err)
 	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		log.Info().Str("external_id", externalId).Time("asOf", asOf).Msg("balance is older than the saved one, skip balance update")
+	}
 
 	return nil
 }
@@ -13,8 +13,8 @@ func TestCreateAccountInfoTable(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	// The table should be created during initialization, but we can test the method directly
-	err := db.createAccountInfoTable()
+	// The table is created by the migrations, initializing again must be a no-op
+	err := db.Initialize()
 	assert.NoError(t, err)
 
 	// Verify table exists by inserting a record
@@ -56,21 +56,30 @@ func TestUpsertAccountBalance(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test upserting balance
+	asOf := time.Now().UTC().Truncate(time.Second)
 	err = db.UpsertAccountBalance("test-external-name", models.Amount{
 		Value:    "200.50",
 		Currency: "CAD",
-	})
+	}, asOf)
 	assert.NoError(t, err)
 
 	// Verify the balance was saved correctly
 	var value, currency string
 	var updatedAt time.Time
-	err = db.QueryRow("SELECT balance_value, balance_currency, balance_updated_at FROM account_info WHERE lunchmoney_account_id = ?",
-		12).Scan(&value, &currency, &updatedAt)
+	query := "SELECT balance_value, balance_currency, balance_updated_at FROM account_info WHERE lunchmoney_account_id = ?"
+	err = db.QueryRow(query, 12).Scan(&value, &currency, &updatedAt)
 	assert.NoError(t, err)
 	assert.Equal(t, "200.50", value)
 	assert.Equal(t, "CAD", currency)
-	assert.NotEmpty(t, updatedAt)
+	assert.True(t, asOf.Equal(updatedAt))
+
+	// an older balance, like the one of an old statement, is skipped
+	err = db.UpsertAccountBalance("test-external-name", models.Amount{Value: "10.00", Currency: "CAD"}, asOf.AddDate(0, -1, 0))
+	assert.NoError(t, err)
+	err = db.QueryRow(query, 12).Scan(&value, &currency, &updatedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "200.50", value)
+	assert.True(t, asOf.Equal(updatedAt))
 }
 
 func TestGetAccounts(t *testing.T) {
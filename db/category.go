@@ -0,0 +1,129 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// ReplaceLunchMoneyCategories replaces the cached LunchMoney categories
+func (db *DB) ReplaceLunchMoneyCategories(categories []models.LunchMoneyCategory) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM lunchmoney_categories`); err != nil {
+		return fmt.Errorf("failed to clear categories: %w", err)
+	}
+
+	query := `
+	INSERT INTO lunchmoney_categories (id, name, is_income, is_group, group_id)
+	VALUES (?, ?, ?, ?, ?)
+	`
+	for _, category := range categories {
+		groupID := sql.NullInt64{Int64: category.GroupID, Valid: category.GroupID != 0}
+		if _, err := tx.Exec(query, category.ID, category.Name, category.IsIncome, category.IsGroup, groupID); err != nil {
+			return fmt.Errorf("failed to insert category %d: %w", category.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit categories: %w", err)
+	}
+	return nil
+}
+
+// GetLunchMoneyCategories returns the cached LunchMoney categories ordered by name
+func (db *DB) GetLunchMoneyCategories() ([]models.LunchMoneyCategory, error) {
+	rows, err := db.Query(`SELECT id, name, is_income, is_group, group_id FROM lunchmoney_categories ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make([]models.LunchMoneyCategory, 0)
+	for rows.Next() {
+		var category models.LunchMoneyCategory
+		var groupID sql.NullInt64
+		if err := rows.Scan(&category.ID, &category.Name, &category.IsIncome, &category.IsGroup, &groupID); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		category.GroupID = groupID.Int64
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %w", err)
+	}
+	return categories, nil
+}
+
+// UpsertCategoryMapping saves the LunchMoney category of a provider category code
+func (db *DB) UpsertCategoryMapping(cm *models.CategoryMapping) error {
+	query := `
+	INSERT INTO category_mappings (category_code, lunchmoney_category_id)
+	VALUES (?, ?)
+	ON CONFLICT(category_code) DO UPDATE SET
+		lunchmoney_category_id = excluded.lunchmoney_category_id
+	`
+	if _, err := db.Exec(query, cm.CategoryCode, cm.LunchMoneyCategoryId); err != nil {
+		return fmt.Errorf("failed to upsert category mapping: %w", err)
+	}
+	return nil
+}
+
+// GetCategoryMapping returns the mapping of a provider category code, or nil if it is not mapped
+func (db *DB) GetCategoryMapping(categoryCode string) (*models.CategoryMapping, error) {
+	var cm models.CategoryMapping
+	err := db.QueryRow(`SELECT category_code, lunchmoney_category_id FROM category_mappings WHERE category_code = ?`,
+		categoryCode).Scan(&cm.CategoryCode, &cm.LunchMoneyCategoryId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get category mapping: %w", err)
+	}
+	return &cm, nil
+}
+
+// GetCategoryMappings returns every category mapping ordered by category code
+func (db *DB) GetCategoryMappings() ([]*models.CategoryMapping, error) {
+	rows, err := db.Query(`SELECT category_code, lunchmoney_category_id FROM category_mappings ORDER BY category_code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := make([]*models.CategoryMapping, 0)
+	for rows.Next() {
+		var cm models.CategoryMapping
+		if err := rows.Scan(&cm.CategoryCode, &cm.LunchMoneyCategoryId); err != nil {
+			return nil, fmt.Errorf("failed to scan category mapping: %w", err)
+		}
+		mappings = append(mappings, &cm)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+// DeleteCategoryMapping removes the mapping of a provider category code so it is mapped again
+func (db *DB) DeleteCategoryMapping(categoryCode string) error {
+	result, err := db.Exec(`DELETE FROM category_mappings WHERE category_code = ?`, categoryCode)
+	if err != nil {
+		return fmt.Errorf("failed to delete category mapping: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no category mapping found for category code: %s", categoryCode)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestLunchMoneyCategories(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	assert.NoError(t, db.ReplaceLunchMoneyCategories([]models.LunchMoneyCategory{
+		{ID: 1, Name: "Food", IsGroup: true},
+		{ID: 10, Name: "Coffee", GroupID: 1},
+	}))
+	assert.NoError(t, db.ReplaceLunchMoneyCategories([]models.LunchMoneyCategory{
+		{ID: 1, Name: "Food", IsGroup: true},
+		{ID: 20, Name: "Groceries", GroupID: 1},
+		{ID: 30, Name: "Salary", IsIncome: true},
+	}))
+
+	categories, err := db.GetLunchMoneyCategories()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.LunchMoneyCategory{
+		{ID: 1, Name: "Food", IsGroup: true},
+		{ID: 20, Name: "Groceries", GroupID: 1},
+		{ID: 30, Name: "Salary", IsIncome: true},
+	}, categories)
+}
+
+func TestCategoryMappings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	result, err := db.GetCategoryMapping("5411")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	assert.NoError(t, db.UpsertCategoryMapping(&models.CategoryMapping{CategoryCode: "5411", LunchMoneyCategoryId: 20}))
+	assert.NoError(t, db.UpsertCategoryMapping(&models.CategoryMapping{CategoryCode: "FEES", LunchMoneyCategoryId: models.Uncategorized}))
+	assert.NoError(t, db.UpsertCategoryMapping(&models.CategoryMapping{CategoryCode: "5411", LunchMoneyCategoryId: 21}))
+
+	result, err = db.GetCategoryMapping("5411")
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, int64(21), result.LunchMoneyCategoryId)
+	}
+
+	mappings, err := db.GetCategoryMappings()
+	assert.NoError(t, err)
+	if assert.Len(t, mappings, 2) {
+		assert.Equal(t, "5411", mappings[0].CategoryCode)
+		assert.True(t, mappings[1].IsUncategorized())
+	}
+
+	assert.NoError(t, db.DeleteCategoryMapping("FEES"))
+	assert.Error(t, db.DeleteCategoryMapping("FEES"))
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,7 +25,9 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// the daemon and the API server write from several goroutines, WAL lets readers
+	// run during a write and the busy timeout makes writers wait for each other
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000&_journal_mode=WAL")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -36,66 +39,9 @@ func New(dbPath string) (*DB, error) {
 	return &DB{DB: db}, nil
 }
 
-// Initialize creates the necessary tables if they don't exist
+// Initialize brings the schema up to date by applying the pending migrations
 func (db *DB) Initialize() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS transactions (
-		reference_number TEXT PRIMARY KEY,
-		amount_value TEXT,
-		amount_currency TEXT,
-		merchant_name TEXT,
-		merchant_category_code TEXT,
-		merchant_city TEXT,
-		merchant_state_province TEXT,
-		transaction_date TEXT,
-		activity_category_code TEXT,
-		customer_id TEXT,
-		posted_date TEXT,
-		name_on_card TEXT,
-		source_account_name TEXT,
-		lunchmoney_id INTEGER,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)
-	`
-
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create transactions table: %w", err)
-	}
-
-	// Add source account name column if it doesn't exist
-	query = `
-	select count(*) from
-	pragma_table_info('transactions')
-	where name='source_account_name';
-	`
-	var count int
-	err = db.QueryRow(query).Scan(&count)
-	if err != nil {
-		return fmt.Errorf("failed to check for source_account_name column: %w", err)
-	}
-
-	if count == 0 {
-		query = `
-		ALTER TABLE transactions ADD COLUMN source_account_name TEXT;
-		`
-		_, err = db.Exec(query)
-		if err != nil {
-			return fmt.Errorf("failed to add source_account_name column: %w", err)
-		}
-	}
-
-	err = db.createAccountMappingsTable()
-	if err != nil {
-		return fmt.Errorf("failed to create account_mappings table: %w", err)
-	}
-
-	err = db.createAccountInfoTable()
-	if err != nil {
-		return fmt.Errorf("failed to create account_info table: %w", err)
-	}
-
-	return nil
+	return db.Migrate()
 }
 
 // UpdateTransaction updates an existing transaction in the database
@@ -106,12 +52,18 @@ func (db *DB) UpdateTransaction(tx *models.TransactionWithAccount) error {
 		amount_value = ?, amount_currency = ?, merchant_name = ?, 
 		merchant_category_code = ?,
 		merchant_city = ?, merchant_state_province = ?, 
-		transaction_date = ?, posted_date = ?, source_account_name = ?
+		transaction_date = ?, posted_date = ?, source_account_name = ?, status = ?,
+		merchant_original_name = ?, notes = ?, tags = ?
 		` + func() string {
 		if tx.LunchMoneyID != 0 {
 			return `, lunchmoney_id = ? `
 		}
 		return ``
+	}() + func() string {
+		if tx.Synced != nil {
+			return `, synced_amount = ?, synced_date = ?, synced_payee = ? `
+		}
+		return ``
 	}() + `
 	WHERE reference_number = ?
 	`
@@ -126,12 +78,20 @@ func (db *DB) UpdateTransaction(tx *models.TransactionWithAccount) error {
 		tx.Date,
 		tx.PostedDate,
 		tx.SourceAccountName,
+		nullableStatus(tx.Status),
+		nullableString(tx.Merchant.OriginalName),
+		nullableString(tx.Notes),
+		nullableTags(tx.Tags),
 	}
 
 	if tx.LunchMoneyID != 0 {
 		args = append(args, tx.LunchMoneyID)
 	}
 
+	if tx.Synced != nil {
+		args = append(args, tx.Synced.Amount, tx.Synced.Date, tx.Synced.Payee)
+	}
+
 	args = append(args, tx.ReferenceNumber)
 
 	result, err := db.Exec(query, args...)
@@ -159,11 +119,6 @@ func (db *DB) SaveTransaction(tx *models.TransactionWithAccount) error {
 		return fmt.Errorf("failed to check for existing transaction: %w", err)
 	}
 
-	if existingTx != nil {
-		// Update the existing transaction
-		return db.UpdateTransaction(tx)
-	}
-
 	if tx.Merchant == nil {
 		tx.Merchant = &models.Merchant{}
 	}
@@ -172,16 +127,30 @@ func (db *DB) SaveTransaction(tx *models.TransactionWithAccount) error {
 		tx.Merchant.Address = &models.Address{}
 	}
 
+	if existingTx != nil {
+		// Update the existing transaction
+		return db.UpdateTransaction(tx)
+	}
+
 	// Insert a new transaction
 	query := `
 	INSERT INTO transactions (
 		reference_number, amount_value, amount_currency,
 		merchant_name, merchant_category_code,
 		merchant_city, merchant_state_province,
-		transaction_date, posted_date, source_account_name, lunchmoney_id
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		transaction_date, posted_date, source_account_name, lunchmoney_id,
+		synced_amount, synced_date, synced_payee, status,
+		merchant_original_name, notes, tags
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	var syncedAmount, syncedDate, syncedPayee sql.NullString
+	if tx.Synced != nil {
+		syncedAmount = sql.NullString{String: tx.Synced.Amount, Valid: true}
+		syncedDate = sql.NullString{String: tx.Synced.Date, Valid: true}
+		syncedPayee = sql.NullString{String: tx.Synced.Payee, Valid: true}
+	}
+
 	_, err = db.Exec(
 		query,
 		tx.ReferenceNumber,
@@ -195,6 +164,13 @@ func (db *DB) SaveTransaction(tx *models.TransactionWithAccount) error {
 		tx.PostedDate,
 		tx.SourceAccountName,
 		tx.LunchMoneyID,
+		syncedAmount,
+		syncedDate,
+		syncedPayee,
+		nullableStatus(tx.Status),
+		nullableString(tx.Merchant.OriginalName),
+		nullableString(tx.Notes),
+		nullableTags(tx.Tags),
 	)
 
 	if err != nil {
@@ -204,14 +180,113 @@ func (db *DB) SaveTransaction(tx *models.TransactionWithAccount) error {
 	return nil
 }
 
-// GetTransactions retrieves all transactions from the database
-func (db *DB) GetTransactions() ([]*models.TransactionWithAccount, error) {
-	query := `
-	SELECT 
+// transactionColumns are the columns read by scanTransaction, in order
+const transactionColumns = `
 		reference_number, amount_value, amount_currency,
 		merchant_name, merchant_category_code,
 		merchant_city, merchant_state_province,
-		transaction_date, posted_date, source_account_name, lunchmoney_id
+		transaction_date, posted_date, source_account_name, lunchmoney_id,
+		synced_amount, synced_date, synced_payee,
+		vanished_at, vanished_action, status, merchant_original_name, notes, tags`
+
+// nullableStatus stores an unknown status as NULL
+func nullableStatus(status models.TransactionStatus) sql.NullString {
+	return nullableString(string(status))
+}
+
+// nullableString stores an empty string as NULL
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullableTags stores tags as a JSON array and no tags as NULL
+func nullableTags(tags []string) sql.NullString {
+	if len(tags) == 0 {
+		return sql.NullString{}
+	}
+	encoded, _ := json.Marshal(tags)
+	return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTransaction reads a row selected with transactionColumns
+func scanTransaction(row rowScanner) (*models.TransactionWithAccount, error) {
+	tx := &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			Amount:   models.Amount{},
+			Merchant: &models.Merchant{Address: &models.Address{}},
+		},
+	}
+
+	var nullStr sql.NullString
+	var syncedAmount, syncedDate, syncedPayee sql.NullString
+	var vanishedAction, status, originalName, notes, tags sql.NullString
+
+	err := row.Scan(
+		&tx.ReferenceNumber,
+		&tx.Amount.Value,
+		&tx.Amount.Currency,
+		&tx.Merchant.Name,
+		&tx.Merchant.CategoryCode,
+		&tx.Merchant.Address.City,
+		&tx.Merchant.Address.StateProvince,
+		&tx.Date,
+		&tx.PostedDate,
+		&nullStr,
+		&tx.LunchMoneyID,
+		&syncedAmount,
+		&syncedDate,
+		&syncedPayee,
+		&tx.VanishedAt,
+		&vanishedAction,
+		&status,
+		&originalName,
+		&notes,
+		&tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if vanishedAction.Valid {
+		tx.VanishedAction = models.VanishedAction(vanishedAction.String)
+	}
+
+	if nullStr.Valid {
+		tx.SourceAccountName = nullStr.String
+	}
+
+	if status.Valid {
+		tx.Status = models.TransactionStatus(status.String)
+	}
+
+	tx.Merchant.OriginalName = originalName.String
+	tx.Notes = notes.String
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &tx.Tags); err != nil {
+			return nil, fmt.Errorf("failed to decode tags: %w", err)
+		}
+	}
+
+	if syncedAmount.Valid {
+		tx.Synced = &models.SyncedFields{
+			Amount: syncedAmount.String,
+			Date:   syncedDate.String,
+			Payee:  syncedPayee.String,
+		}
+	}
+
+	return tx, nil
+}
+
+// GetTransactions retrieves all transactions from the database
+func (db *DB) GetTransactions() ([]*models.TransactionWithAccount, error) {
+	query := `
+	SELECT ` + transactionColumns + `
 	FROM transactions
 	ORDER BY transaction_date DESC
 	`
@@ -224,33 +299,7 @@ func (db *DB) GetTransactions() ([]*models.TransactionWithAccount, error) {
 
 	var transactions []*models.TransactionWithAccount
 	for rows.Next() {
-		tx := &models.TransactionWithAccount{
-			Transaction: models.Transaction{
-				Amount:   models.Amount{},
-				Merchant: &models.Merchant{Address: &models.Address{}},
-			},
-		}
-
-		var nullStr sql.NullString
-
-		err := rows.Scan(
-			&tx.ReferenceNumber,
-			&tx.Amount.Value,
-			&tx.Amount.Currency,
-			&tx.Merchant.Name,
-			&tx.Merchant.CategoryCode,
-			&tx.Merchant.Address.City,
-			&tx.Merchant.Address.StateProvince,
-			&tx.Date,
-			&tx.PostedDate,
-			&nullStr,
-			&tx.LunchMoneyID,
-		)
-
-		if nullStr.Valid {
-			tx.SourceAccountName = nullStr.String
-		}
-
+		tx, err := scanTransaction(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
@@ -268,43 +317,13 @@ func (db *DB) GetTransactions() ([]*models.TransactionWithAccount, error) {
 // GetTransactionByReference retrieves a transaction by its reference number
 func (db *DB) GetTransactionByReference(referenceNumber string) (*models.TransactionWithAccount, error) {
 	query := `
-	SELECT
-		reference_number, amount_value, amount_currency,
-		merchant_name, merchant_category_code,
-		merchant_city, merchant_state_province,
-		transaction_date, posted_date, source_account_name, lunchmoney_id
+	SELECT ` + transactionColumns + `
 	FROM transactions
 	WHERE reference_number = ?
 	LIMIT 1
 	`
 
-	tx := &models.TransactionWithAccount{
-		Transaction: models.Transaction{
-			Amount:   models.Amount{},
-			Merchant: &models.Merchant{Address: &models.Address{}},
-		},
-	}
-
-	var nullStr sql.NullString
-
-	err := db.QueryRow(query, referenceNumber).Scan(
-		&tx.ReferenceNumber,
-		&tx.Amount.Value,
-		&tx.Amount.Currency,
-		&tx.Merchant.Name,
-		&tx.Merchant.CategoryCode,
-		&tx.Merchant.Address.City,
-		&tx.Merchant.Address.StateProvince,
-		&tx.Date,
-		&tx.PostedDate,
-		&nullStr,
-		&tx.LunchMoneyID,
-	)
-
-	if nullStr.Valid {
-		tx.SourceAccountName = nullStr.String
-	}
-
+	tx, err := scanTransaction(db.QueryRow(query, referenceNumber))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
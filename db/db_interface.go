@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
@@ -9,17 +11,49 @@ type DBInterface interface {
 	Initialize() error
 	Close() error
 	GetTransactions() ([]*models.TransactionWithAccount, error)
+	FilterTransactions(filter *models.TransactionFilter) ([]*models.TransactionWithAccount, error)
 	GetTransactionByReference(referenceNumber string) (*models.TransactionWithAccount, error)
 	SaveTransaction(tx *models.TransactionWithAccount) error
 	UpdateTransaction(tx *models.TransactionWithAccount) error
 	RemoveTransaction(referenceNumber string) error
 	AddManualTransaction(tx *models.TransactionWithAccount) error
 
+	RecordFetchWindow(sourceAccountName string, window models.DateRange, referenceNumbers []string) ([]string, error)
+	GetVanishedTransactions() ([]*models.TransactionWithAccount, error)
+	SetVanishedAction(referenceNumber string, action models.VanishedAction) error
+
+	StartSyncRun(run *models.SyncRun) error
+	FinishSyncRun(run *models.SyncRun) error
+	GetSyncRuns(limit int) ([]*models.SyncRun, error)
+	AddTransactionEvent(event *models.TransactionEvent) error
+	GetTransactionEvents(referenceNumber string) ([]*models.TransactionEvent, error)
+	GetSyncRunEvents(runID int64) ([]*models.TransactionEvent, error)
+
 	UpsertAccountMapping(am *models.AccountMapping) error
 	GetAccountMapping(externalId string) (*models.AccountMapping, error)
+	GetAccountMappings() ([]*models.AccountMappingDetails, error)
+	DeleteAccountMapping(externalName string) error
+
+	ReplaceLunchMoneyCategories(categories []models.LunchMoneyCategory) error
+	GetLunchMoneyCategories() ([]models.LunchMoneyCategory, error)
+	UpsertCategoryMapping(cm *models.CategoryMapping) error
+	GetCategoryMapping(categoryCode string) (*models.CategoryMapping, error)
+	GetCategoryMappings() ([]*models.CategoryMapping, error)
+	DeleteCategoryMapping(categoryCode string) error
+
+	SaveTransferPair(pair *models.TransferPair) error
+	GetTransferPairs() ([]*models.TransferPair, error)
+	SetTransferStatus(id int64, status models.TransferStatus) error
+
+	AddDuplicateRejection(referenceNumber string, lunchMoneyID int64) error
+	GetDuplicateRejections() ([]*models.DuplicateRejection, error)
+
+	GetNotificationSentAt(key string) (*time.Time, error)
+	RecordNotificationSent(key string, sentAt time.Time) error
+	ClearNotification(key string) error
 
 	GetAccounts() ([]models.LunchMoneyAccount, error)
-	UpsertAccountBalance(externalAccountName string, balance models.Amount) error
+	UpsertAccountBalance(externalAccountName string, balance models.Amount, asOf time.Time) error
 	DisableSyncOptions(lunchMoneyId string, syncOption models.SyncOption) error
 	IsSyncOptionEnabled(lunchMoneyId int64, syncOption models.SyncOption) (bool, error)
 }
@@ -330,3 +330,194 @@ func TestSaveAndGetAccountMapping(t *testing.T) {
 		t.Errorf("Expected IsPlaid '%v', got '%v'", accountMapping.IsPlaid, retrievedMapping.IsPlaid)
 	}
 }
+
+func TestSyncedFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tx := &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "TEST123",
+			Amount:          models.Amount{Value: "25.99", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Test Merchant"},
+			Date:            "2025-04-29",
+		},
+		SourceAccountName: "Test Account",
+	}
+
+	if err := db.SaveTransaction(tx); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err := db.GetTransactionByReference("TEST123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Synced != nil {
+		t.Errorf("Expected no synced fields before syncing, got %+v", retrievedTx.Synced)
+	}
+
+	tx.LunchMoneyID = 42
+	tx.Synced = models.SyncedFieldsOf(&tx.Transaction)
+	if err := db.UpdateTransaction(tx); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+
+	// Saving the fetched transaction again must not clear the synced fields
+	fetched := *tx
+	fetched.LunchMoneyID = 0
+	fetched.Synced = nil
+	fetched.Amount.Value = "30.99"
+	if err := db.SaveTransaction(&fetched); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err = db.GetTransactionByReference("TEST123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.LunchMoneyID != 42 {
+		t.Errorf("Expected LunchMoneyID 42, got %d", retrievedTx.LunchMoneyID)
+	}
+	if retrievedTx.Amount.Value != "30.99" {
+		t.Errorf("Expected amount '30.99', got '%s'", retrievedTx.Amount.Value)
+	}
+	if retrievedTx.Synced == nil || retrievedTx.Synced.Amount != "25.99" || retrievedTx.Synced.Payee != "Test Merchant" {
+		t.Errorf("Expected synced fields to be kept, got %+v", retrievedTx.Synced)
+	}
+}
+
+func TestTransactionStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tx := &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "PENDING123",
+			Amount:          models.Amount{Value: "4.50", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Coffee"},
+			Date:            "2025-04-29",
+			Status:          models.TransactionStatusPending,
+		},
+		SourceAccountName: "Test Account",
+	}
+	if err := db.SaveTransaction(tx); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err := db.GetTransactionByReference("PENDING123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if !retrievedTx.IsPending() {
+		t.Errorf("Expected status pending, got %q", retrievedTx.Status)
+	}
+
+	tx.Status = models.TransactionStatusPosted
+	tx.PostedDate = "2025-04-30"
+	if err := db.SaveTransaction(tx); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err = db.GetTransactionByReference("PENDING123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Status != models.TransactionStatusPosted || retrievedTx.PostedDate != "2025-04-30" {
+		t.Errorf("Expected posted on 2025-04-30, got %q on %q", retrievedTx.Status, retrievedTx.PostedDate)
+	}
+}
+
+func TestMerchantOriginalName(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tx := &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "AMZN123",
+			Amount:          models.Amount{Value: "19.99", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Amazon", OriginalName: "AMZN Mktp CA*2X4KL"},
+			Date:            "2025-04-29",
+		},
+		SourceAccountName: "Test Account",
+	}
+	if err := db.SaveTransaction(tx); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err := db.GetTransactionByReference("AMZN123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Merchant.Name != "Amazon" || retrievedTx.Merchant.OriginalName != "AMZN Mktp CA*2X4KL" {
+		t.Errorf("Expected renamed merchant with its original name, got %+v", retrievedTx.Merchant)
+	}
+
+	tx.Merchant = &models.Merchant{Name: "AMZN Mktp CA*2X4KL", Address: &models.Address{}}
+	if err := db.UpdateTransaction(tx); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+	retrievedTx, err = db.GetTransactionByReference("AMZN123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Merchant.OriginalName != "" {
+		t.Errorf("Expected original name to be cleared, got %q", retrievedTx.Merchant.OriginalName)
+	}
+}
+
+func TestNotesAndTags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tx := &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "LUNCH123",
+			Amount:          models.Amount{Value: "42.00", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Bistro"},
+			Date:            "2025-04-29",
+			Notes:           "Client lunch",
+			Tags:            []string{"reimbursable", "source:rogers"},
+		},
+		SourceAccountName: "Test Account",
+	}
+	if err := db.SaveTransaction(tx); err != nil {
+		t.Fatalf("Failed to save transaction: %v", err)
+	}
+
+	retrievedTx, err := db.GetTransactionByReference("LUNCH123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Notes != "Client lunch" || len(retrievedTx.Tags) != 2 || retrievedTx.Tags[0] != "reimbursable" {
+		t.Errorf("Expected notes and tags to be kept, got %q %v", retrievedTx.Notes, retrievedTx.Tags)
+	}
+
+	tagged, err := db.FilterTransactions(&models.TransactionFilter{Tag: "Reimbursable"})
+	if err != nil {
+		t.Fatalf("Failed to filter transactions: %v", err)
+	}
+	if len(tagged) != 1 {
+		t.Errorf("Expected one reimbursable transaction, got %d", len(tagged))
+	}
+
+	tx.Notes, tx.Tags = "", nil
+	if err := db.UpdateTransaction(tx); err != nil {
+		t.Fatalf("Failed to update transaction: %v", err)
+	}
+	retrievedTx, err = db.GetTransactionByReference("LUNCH123")
+	if err != nil {
+		t.Fatalf("Failed to retrieve transaction: %v", err)
+	}
+	if retrievedTx.Notes != "" || retrievedTx.Tags != nil {
+		t.Errorf("Expected notes and tags to be cleared, got %q %v", retrievedTx.Notes, retrievedTx.Tags)
+	}
+
+	tagged, err = db.FilterTransactions(&models.TransactionFilter{Tag: "reimbursable"})
+	if err != nil {
+		t.Fatalf("Failed to filter transactions: %v", err)
+	}
+	if len(tagged) != 0 {
+		t.Errorf("Expected no reimbursable transaction, got %d", len(tagged))
+	}
+}
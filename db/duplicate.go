@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// AddDuplicateRejection records that a local transaction is not the same as a LunchMoney transaction
+func (db *DB) AddDuplicateRejection(referenceNumber string, lunchMoneyID int64) error {
+	query := `
+	INSERT INTO duplicate_rejections (reference_number, lunchmoney_id)
+	VALUES (?, ?)
+	ON CONFLICT(reference_number, lunchmoney_id) DO NOTHING
+	`
+	if _, err := db.Exec(query, referenceNumber, lunchMoneyID); err != nil {
+		return fmt.Errorf("failed to insert duplicate rejection: %w", err)
+	}
+	return nil
+}
+
+// GetDuplicateRejections returns every rejected duplicate candidate, oldest first
+func (db *DB) GetDuplicateRejections() ([]*models.DuplicateRejection, error) {
+	rows, err := db.Query(`
+	SELECT reference_number, lunchmoney_id, created_at
+	FROM duplicate_rejections
+	ORDER BY created_at, reference_number, lunchmoney_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate rejections: %w", err)
+	}
+	defer rows.Close()
+
+	rejections := make([]*models.DuplicateRejection, 0)
+	for rows.Next() {
+		var rejection models.DuplicateRejection
+		if err := rows.Scan(&rejection.ReferenceNumber, &rejection.LunchMoneyID, &rejection.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate rejection: %w", err)
+		}
+		rejections = append(rejections, &rejection)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating duplicate rejections: %w", err)
+	}
+	return rejections, nil
+}
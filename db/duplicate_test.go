@@ -0,0 +1,24 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateRejections(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	assert.NoError(t, db.AddDuplicateRejection("TX1", 100))
+	assert.NoError(t, db.AddDuplicateRejection("TX1", 101))
+	assert.NoError(t, db.AddDuplicateRejection("TX1", 100), "rejecting a candidate twice is not an error")
+
+	rejections, err := db.GetDuplicateRejections()
+	assert.NoError(t, err)
+	if assert.Len(t, rejections, 2) {
+		assert.Equal(t, "TX1", rejections[0].ReferenceNumber)
+		assert.Equal(t, int64(100), rejections[0].LunchMoneyID)
+		assert.Equal(t, int64(101), rejections[1].LunchMoneyID)
+	}
+}
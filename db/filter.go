@@ -0,0 +1,112 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// ignoredAccountCondition is true for transactions of always ignored source accounts
+const ignoredAccountCondition = `source_account_name IN (SELECT external_name FROM ignored_external_accounts)`
+
+// transactionOrder maps the sorts of a filter to their columns
+var transactionOrder = map[models.TransactionSort]string{
+	models.SortByDate:     "transaction_date",
+	models.SortByAmount:   "CAST(amount_value AS REAL)",
+	models.SortByMerchant: "merchant_name COLLATE NOCASE",
+	models.SortByAccount:  "source_account_name COLLATE NOCASE",
+}
+
+// FilterTransactions returns the transactions selected by filter in its order. SQLite
+// has no regular expressions, so a merchant pattern is matched and paged after the query.
+func (db *DB) FilterTransactions(filter *models.TransactionFilter) ([]*models.TransactionWithAccount, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	where := func(condition string, values ...any) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if !filter.Window.Start.IsZero() {
+		where("transaction_date BETWEEN ? AND ?", filter.Window.StartDate(), filter.Window.EndDate())
+	}
+	if filter.SourceAccount != "" {
+		where("source_account_name = ? COLLATE NOCASE", filter.SourceAccount)
+	}
+	if filter.Merchant != "" {
+		where("instr(lower(merchant_name), lower(?)) > 0", filter.Merchant)
+	}
+	if filter.MinAmount != nil {
+		where("CAST(amount_value AS REAL) >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		where("CAST(amount_value AS REAL) <= ?", *filter.MaxAmount)
+	}
+	if filter.Tag != "" {
+		where("EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ? COLLATE NOCASE)", filter.Tag)
+	}
+	if filter.Currency != "" {
+		where("amount_currency = ? COLLATE NOCASE", filter.Currency)
+	}
+	switch filter.SyncState {
+	case models.SyncStateSynced:
+		where("COALESCE(lunchmoney_id, 0) > 0")
+	case models.SyncStateIgnored:
+		where("(COALESCE(lunchmoney_id, 0) < 0 OR " + ignoredAccountCondition + ")")
+	case models.SyncStateUnsynced:
+		where("COALESCE(lunchmoney_id, 0) = 0 AND NOT " + ignoredAccountCondition)
+	}
+
+	query := `SELECT ` + transactionColumns + ` FROM transactions`
+	if len(conditions) != 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	order, ok := transactionOrder[filter.SortBy]
+	if !ok {
+		order = transactionOrder[models.SortByDate]
+	}
+	direction := "DESC"
+	if filter.Ascending {
+		direction = "ASC"
+	}
+	query += fmt.Sprintf(` ORDER BY %s %s, reference_number %s`, order, direction, direction)
+
+	pageInQuery := filter.MerchantPattern == nil
+	if pageInQuery && (filter.Limit > 0 || filter.Offset > 0) {
+		query += ` LIMIT ? OFFSET ?`
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		args = append(args, limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := make([]*models.TransactionWithAccount, 0)
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if filter.MerchantPattern != nil && !filter.MerchantPattern.MatchString(tx.Merchant.Name) {
+			continue
+		}
+		transactions = append(transactions, tx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+
+	if !pageInQuery {
+		transactions = filter.Page(transactions)
+	}
+	return transactions, nil
+}
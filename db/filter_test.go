@@ -0,0 +1,89 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestFilterTransactions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	newTx := func(ref, date, amount, currency, merchant, account string, lunchMoneyID int64) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				LunchMoneyID:    lunchMoneyID,
+				Amount:          models.Amount{Value: amount, Currency: currency},
+				Merchant:        &models.Merchant{Name: merchant, Address: &models.Address{}},
+				Date:            date,
+			},
+			SourceAccountName: account,
+		}
+	}
+	for _, tx := range []*models.TransactionWithAccount{
+		newTx("TX1", "2025-04-01", "4.50", "CAD", "Coffee Shop", "Visa", 11),
+		newTx("TX2", "2025-04-02", "120.00", "CAD", "Grocery Store", "Visa", 0),
+		newTx("TX3", "2025-04-03", "-1500.00", "CAD", "Payroll", "Chequing", 0),
+		newTx("TX4", "2025-04-04", "9.99", "USD", "Streaming", "USD Card", 0),
+		newTx("TX5", "2025-03-01", "30.00", "CAD", "Coffee Beans", "TFSA", 0),
+	} {
+		assert.NoError(t, db.SaveTransaction(tx))
+		if tx.LunchMoneyID != 0 {
+			assert.NoError(t, db.UpdateTransaction(tx))
+		}
+	}
+	assert.NoError(t, db.UpsertAccountMapping(&models.AccountMapping{ExternalName: "TFSA", LunchMoneyId: -1}))
+
+	april, _ := models.NewDateRange("2025-04-01", "2025-04-30")
+	minAmount, maxAmount := 5.0, 200.0
+	tests := []struct {
+		name     string
+		filter   models.TransactionFilter
+		expected []string
+	}{
+		{"no filter, most recent first", models.TransactionFilter{}, []string{"TX4", "TX3", "TX2", "TX1", "TX5"}},
+		{"window", models.TransactionFilter{Window: april, Ascending: true}, []string{"TX1", "TX2", "TX3", "TX4"}},
+		{"account", models.TransactionFilter{SourceAccount: "visa"}, []string{"TX2", "TX1"}},
+		{"merchant", models.TransactionFilter{Merchant: "COFFEE"}, []string{"TX1", "TX5"}},
+		{"merchant pattern", models.TransactionFilter{MerchantPattern: regexp.MustCompile("^(Pay|Stream)")}, []string{"TX4", "TX3"}},
+		{"amount range", models.TransactionFilter{MinAmount: &minAmount, MaxAmount: &maxAmount, SortBy: models.SortByAmount},
+			[]string{"TX2", "TX5", "TX4"}},
+		{"synced", models.TransactionFilter{SyncState: models.SyncStateSynced}, []string{"TX1"}},
+		{"ignored", models.TransactionFilter{SyncState: models.SyncStateIgnored}, []string{"TX5"}},
+		{"unsynced", models.TransactionFilter{SyncState: models.SyncStateUnsynced}, []string{"TX4", "TX3", "TX2"}},
+		{"currency", models.TransactionFilter{Currency: "usd"}, []string{"TX4"}},
+		{"sort by merchant", models.TransactionFilter{SortBy: models.SortByMerchant, Ascending: true, Limit: 2},
+			[]string{"TX5", "TX1"}},
+		{"page", models.TransactionFilter{Limit: 2, Offset: 2}, []string{"TX2", "TX1"}},
+		{"page with pattern", models.TransactionFilter{MerchantPattern: regexp.MustCompile("o"), Limit: 1, Offset: 1},
+			[]string{"TX2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactions, err := db.FilterTransactions(&tt.filter)
+			assert.NoError(t, err)
+
+			refs := make([]string, 0, len(transactions))
+			for _, tx := range transactions {
+				refs = append(refs, tx.ReferenceNumber)
+			}
+			assert.Equal(t, tt.expected, refs)
+
+			// the mock applies the same filter in memory
+			mock := NewMockDB()
+			all, err := db.GetTransactions()
+			assert.NoError(t, err)
+			for _, tx := range all {
+				mock.Transactions[tx.ReferenceNumber] = tx
+			}
+			assert.NoError(t, mock.UpsertAccountMapping(&models.AccountMapping{ExternalName: "TFSA", LunchMoneyId: -1}))
+			mocked, err := mock.FilterTransactions(&tt.filter)
+			assert.NoError(t, err)
+			assert.Len(t, mocked, len(tt.expected))
+		})
+	}
+}
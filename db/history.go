@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// StartSyncRun records the start of a run and sets its ID
+func (db *DB) StartSyncRun(run *models.SyncRun) error {
+	result, err := db.Exec(`
+	INSERT INTO sync_runs (kind, provider, started_at) VALUES (?, ?, ?)
+	`, run.Kind, run.Provider, run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert sync run: %w", err)
+	}
+
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get sync run id: %w", err)
+	}
+	return nil
+}
+
+// FinishSyncRun records the end, counts and error of a run
+func (db *DB) FinishSyncRun(run *models.SyncRun) error {
+	_, err := db.Exec(`
+	UPDATE sync_runs
+	SET finished_at = ?, fetched = ?, inserted = ?, updated = ?, skipped = ?, error = ?
+	WHERE id = ?
+	`, run.FinishedAt, run.Fetched, run.Inserted, run.Updated, run.Skipped,
+		sql.NullString{String: run.Error, Valid: run.Error != ""}, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update sync run: %w", err)
+	}
+	return nil
+}
+
+// GetSyncRuns returns the most recent runs first
+func (db *DB) GetSyncRuns(limit int) ([]*models.SyncRun, error) {
+	rows, err := db.Query(`
+	SELECT id, kind, provider, started_at, finished_at, fetched, inserted, updated, skipped, error
+	FROM sync_runs
+	ORDER BY id DESC
+	LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.SyncRun
+	for rows.Next() {
+		var run models.SyncRun
+		var provider, runErr sql.NullString
+		err := rows.Scan(&run.ID, &run.Kind, &provider, &run.StartedAt, &run.FinishedAt,
+			&run.Fetched, &run.Inserted, &run.Updated, &run.Skipped, &runErr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync run: %w", err)
+		}
+		run.Provider = provider.String
+		run.Error = runErr.String
+		runs = append(runs, &run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sync runs: %w", err)
+	}
+	return runs, nil
+}
+
+// AddTransactionEvent appends an entry to the audit trail of a transaction
+func (db *DB) AddTransactionEvent(event *models.TransactionEvent) error {
+	result, err := db.Exec(`
+	INSERT INTO transaction_events (run_id, reference_number, event, lunchmoney_id, detail, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, sql.NullInt64{Int64: event.RunID, Valid: event.RunID != 0}, event.ReferenceNumber, event.Event,
+		sql.NullInt64{Int64: event.LunchMoneyID, Valid: event.LunchMoneyID != 0}, event.Detail, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert transaction event: %w", err)
+	}
+
+	event.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction event id: %w", err)
+	}
+	return nil
+}
+
+// GetTransactionEvents returns the audit trail of a transaction, oldest first
+func (db *DB) GetTransactionEvents(referenceNumber string) ([]*models.TransactionEvent, error) {
+	return db.queryTransactionEvents(`WHERE reference_number = ?`, referenceNumber)
+}
+
+// GetSyncRunEvents returns the transaction events recorded by a run, oldest first
+func (db *DB) GetSyncRunEvents(runID int64) ([]*models.TransactionEvent, error) {
+	return db.queryTransactionEvents(`WHERE run_id = ?`, runID)
+}
+
+func (db *DB) queryTransactionEvents(where string, args ...any) ([]*models.TransactionEvent, error) {
+	rows, err := db.Query(`
+	SELECT id, run_id, reference_number, event, lunchmoney_id, detail, created_at
+	FROM transaction_events
+	`+where+`
+	ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.TransactionEvent
+	for rows.Next() {
+		var event models.TransactionEvent
+		var runID, lunchMoneyID sql.NullInt64
+		var detail sql.NullString
+		err := rows.Scan(&event.ID, &runID, &event.ReferenceNumber, &event.Event, &lunchMoneyID, &detail, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction event: %w", err)
+		}
+		event.RunID = runID.Int64
+		event.LunchMoneyID = lunchMoneyID.Int64
+		event.Detail = detail.String
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transaction events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestSyncRunHistory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fetch := &models.SyncRun{Kind: models.SyncRunKindFetch, Provider: "rogers", StartedAt: time.Now()}
+	assert.NoError(t, db.StartSyncRun(fetch))
+	assert.NotZero(t, fetch.ID)
+
+	finished := time.Now()
+	fetch.FinishedAt = &finished
+	fetch.Fetched, fetch.Inserted, fetch.Updated, fetch.Skipped = 5, 3, 1, 1
+	assert.NoError(t, db.FinishSyncRun(fetch))
+
+	sync := &models.SyncRun{Kind: models.SyncRunKindSync, StartedAt: time.Now()}
+	assert.NoError(t, db.StartSyncRun(sync))
+	sync.FinishedAt = &finished
+	sync.Error = "LunchMoney unavailable"
+	assert.NoError(t, db.FinishSyncRun(sync))
+
+	runs, err := db.GetSyncRuns(10)
+	assert.NoError(t, err)
+	if assert.Len(t, runs, 2) {
+		assert.Equal(t, sync.ID, runs[0].ID, "most recent run first")
+		assert.Equal(t, "LunchMoney unavailable", runs[0].Error)
+		assert.Equal(t, "", runs[0].Provider)
+
+		assert.Equal(t, "rogers", runs[1].Provider)
+		assert.Equal(t, 5, runs[1].Fetched)
+		assert.Equal(t, 3, runs[1].Inserted)
+		assert.NotNil(t, runs[1].FinishedAt)
+		assert.Empty(t, runs[1].Error)
+	}
+
+	runs, err = db.GetSyncRuns(1)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	t.Run("Transaction events", func(t *testing.T) {
+		events := []*models.TransactionEvent{
+			{RunID: fetch.ID, ReferenceNumber: "TX1", Event: models.TransactionEventFetched, Detail: "fetched from Visa"},
+			{RunID: sync.ID, ReferenceNumber: "TX1", Event: models.TransactionEventPushed, LunchMoneyID: 42},
+			{RunID: sync.ID, ReferenceNumber: "TX2", Event: models.TransactionEventPushed, LunchMoneyID: 43},
+			{ReferenceNumber: "TX1", Event: models.TransactionEventDeleted, LunchMoneyID: 42, Detail: "removed manually"},
+		}
+		for _, event := range events {
+			event.CreatedAt = time.Now()
+			assert.NoError(t, db.AddTransactionEvent(event))
+		}
+
+		trail, err := db.GetTransactionEvents("TX1")
+		assert.NoError(t, err)
+		if assert.Len(t, trail, 3) {
+			assert.Equal(t, models.TransactionEventFetched, trail[0].Event)
+			assert.Equal(t, "fetched from Visa", trail[0].Detail)
+			assert.Equal(t, int64(42), trail[1].LunchMoneyID)
+			assert.Zero(t, trail[2].RunID)
+		}
+
+		runEvents, err := db.GetSyncRunEvents(sync.ID)
+		assert.NoError(t, err)
+		assert.Len(t, runEvents, 2)
+	})
+}
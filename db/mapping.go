@@ -7,32 +7,6 @@ import (
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
-func (db *DB) createAccountMappingsTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS account_mappings (
-		external_name TEXT PRIMARY KEY,
-		lunchmoney_account_id TEXT,
-		is_plaid BOOLEAN
-	)
-	`
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create account_mappings table: %w", err)
-	}
-
-	query = `
-	CREATE TABLE IF NOT EXISTS ignored_external_accounts (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		external_name TEXT NOT NULL
-	)
-	`
-	_, err = db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create ignored_external_accounts table: %w", err)
-	}
-	return nil
-}
-
 // UpsertAccountMapping saves the mapping for a given external account ID
 // and LunchMoney account ID. If the mapping already exists, it updates the
 // LunchMoney account ID and is_plaid flag. If the mapping does not exist,
@@ -64,25 +38,111 @@ func (db *DB) UpsertAccountMapping(am *models.AccountMapping) error {
 		am.LunchMoneyId = -1 * am.LunchMoneyId
 	}
 	query := `
-	INSERT INTO account_mappings (external_name, lunchmoney_account_id, is_plaid)
-	VALUES (?, ?, ?)
+	INSERT INTO account_mappings (external_name, lunchmoney_account_id, is_plaid, from_rule)
+	VALUES (?, ?, ?, ?)
 	ON CONFLICT(external_name) DO UPDATE SET
 		lunchmoney_account_id = excluded.lunchmoney_account_id,
-		is_plaid = excluded.is_plaid
+		is_plaid = excluded.is_plaid,
+		from_rule = excluded.from_rule
 	`
 
-	_, err := db.Exec(query, am.ExternalName, am.LunchMoneyId, am.IsPlaid)
+	_, err := db.Exec(query, am.ExternalName, am.LunchMoneyId, am.IsPlaid, am.FromRule)
 	if err != nil {
 		return fmt.Errorf("failed to upsert account mapping: %w", err)
 	}
 
+	if am.LunchMoneyId >= 0 {
+		// the account is no longer ignored
+		_, err = db.Exec(`DELETE FROM ignored_external_accounts WHERE external_name = ?`, am.ExternalName)
+		if err != nil {
+			return fmt.Errorf("failed to remove ignored account: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetAccountMappings returns every account mapping with the last fetched balance of
+// its LunchMoney account, ordered by external name
+func (db *DB) GetAccountMappings() ([]*models.AccountMappingDetails, error) {
+	query := `
+	SELECT
+		am.external_name, CAST(am.lunchmoney_account_id AS INTEGER), am.is_plaid, am.from_rule,
+		ai.balance_value, ai.balance_currency, ai.balance_updated_at
+	FROM account_mappings am
+	LEFT JOIN account_info ai ON ai.lunchmoney_account_id = CAST(am.lunchmoney_account_id AS INTEGER)
+	ORDER BY am.external_name
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var mappings []*models.AccountMappingDetails
+	for rows.Next() {
+		var am models.AccountMappingDetails
+		var isPlaid sql.NullBool
+		var balanceValue, balanceCurrency sql.NullString
+		var balanceUpdatedAt sql.NullTime
+		err := rows.Scan(
+			&am.ExternalName,
+			&am.LunchMoneyId,
+			&isPlaid,
+			&am.FromRule,
+			&balanceValue,
+			&balanceCurrency,
+			&balanceUpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account mapping: %w", err)
+		}
+
+		am.IsPlaid = isPlaid.Bool
+		if balanceValue.Valid {
+			am.Balance = &models.Amount{Value: balanceValue.String, Currency: balanceCurrency.String}
+		}
+		if balanceUpdatedAt.Valid {
+			am.BalanceUpdatedAt = &balanceUpdatedAt.Time
+		}
+		mappings = append(mappings, &am)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating account mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+// DeleteAccountMapping removes the mapping of an external account, including its
+// ignored status, so it is mapped again the next time it is seen
+func (db *DB) DeleteAccountMapping(externalName string) error {
+	result, err := db.Exec(`DELETE FROM account_mappings WHERE external_name = ?`, externalName)
+	if err != nil {
+		return fmt.Errorf("failed to delete account mapping: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no account mapping found for external account: %s", externalName)
+	}
+
+	_, err = db.Exec(`DELETE FROM ignored_external_accounts WHERE external_name = ?`, externalName)
+	if err != nil {
+		return fmt.Errorf("failed to remove ignored account: %w", err)
+	}
 	return nil
 }
 
 func (db *DB) GetAccountMapping(externalId string) (*models.AccountMapping, error) {
 	query := `
 	SELECT 
-		external_name, lunchmoney_account_id, is_plaid
+		external_name, lunchmoney_account_id, is_plaid, from_rule
 	FROM account_mappings
 	WHERE external_name = ?
 	LIMIT 1
@@ -93,6 +153,7 @@ func (db *DB) GetAccountMapping(externalId string) (*models.AccountMapping, erro
 		&am.ExternalName,
 		&am.LunchMoneyId,
 		&am.IsPlaid,
+		&am.FromRule,
 	)
 
 	if err == sql.ErrNoRows {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vpnda/sandwich-sync/pkg/models"
@@ -48,6 +49,7 @@ func TestUpsertAccountMapping(t *testing.T) {
 			ExternalName: "test_account_2",
 			LunchMoneyId: 201,
 			IsPlaid:      true,
+			FromRule:     true,
 		}
 
 		err = db.UpsertAccountMapping(updatedAm)
@@ -60,6 +62,7 @@ func TestUpsertAccountMapping(t *testing.T) {
 		assert.Equal(t, updatedAm.ExternalName, result.ExternalName)
 		assert.Equal(t, updatedAm.LunchMoneyId, result.LunchMoneyId)
 		assert.Equal(t, updatedAm.IsPlaid, result.IsPlaid)
+		assert.True(t, result.FromRule)
 	})
 
 	t.Run("Ignore account mapping with LunchMoneyId = -1", func(t *testing.T) {
@@ -119,3 +122,46 @@ func TestUpsertAccountMapping(t *testing.T) {
 		assert.Equal(t, updatedAm.IsPlaid, result.IsPlaid)
 	})
 }
+
+func TestGetAndDeleteAccountMappings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	assert.NoError(t, db.UpsertAccountMapping(&models.AccountMapping{ExternalName: "b_account", LunchMoneyId: 100}))
+	assert.NoError(t, db.UpsertAccountMapping(&models.AccountMapping{ExternalName: "a_ignored", LunchMoneyId: -1}))
+	assert.NoError(t, db.UpsertAccountBalance("b_account", models.Amount{Value: "12.34", Currency: "CAD"}, time.Now()))
+
+	mappings, err := db.GetAccountMappings()
+	assert.NoError(t, err)
+	assert.Len(t, mappings, 2)
+
+	assert.Equal(t, "a_ignored", mappings[0].ExternalName)
+	assert.True(t, mappings[0].IsIgnored())
+	assert.Nil(t, mappings[0].Balance)
+
+	assert.Equal(t, "b_account", mappings[1].ExternalName)
+	assert.Equal(t, int64(100), mappings[1].LunchMoneyId)
+	if assert.NotNil(t, mappings[1].Balance) {
+		assert.Equal(t, "12.34", mappings[1].Balance.Value)
+	}
+	assert.NotNil(t, mappings[1].BalanceUpdatedAt)
+
+	t.Run("Mapping an ignored account removes its ignored status", func(t *testing.T) {
+		assert.NoError(t, db.UpsertAccountMapping(&models.AccountMapping{ExternalName: "a_ignored", LunchMoneyId: 200}))
+
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM ignored_external_accounts WHERE external_name = ?", "a_ignored").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Delete account mapping", func(t *testing.T) {
+		assert.NoError(t, db.DeleteAccountMapping("b_account"))
+
+		result, err := db.GetAccountMapping("b_account")
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+
+		assert.Error(t, db.DeleteAccountMapping("b_account"))
+	})
+}
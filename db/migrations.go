@@ -0,0 +1,406 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer binary
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// migration is a versioned schema change, migrations are applied in order and
+// each one runs in its own transaction. Applied migrations must never be edited,
+// change the schema by appending a new migration instead.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{version: 1, name: "baseline", up: migrateBaseline},
+	{version: 2, name: "sync history", up: migrateSyncHistory},
+	{version: 3, name: "merchant original name", up: migrateMerchantOriginalName},
+	{version: 4, name: "categories", up: migrateCategories},
+	{version: 5, name: "notes and tags", up: migrateNotesAndTags},
+	{version: 6, name: "transfer pairs", up: migrateTransferPairs},
+	{version: 7, name: "duplicate rejections", up: migrateDuplicateRejections},
+	{version: 8, name: "notifications", up: migrateNotifications},
+	{version: 9, name: "account mapping rules", up: migrateAccountMappingRules},
+}
+
+// MigrationStatus is the state of a schema migration
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// AppliedAt is nil for pending migrations
+	AppliedAt *time.Time
+}
+
+// LatestSchemaVersion returns the schema version this binary migrates to
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func (db *DB) createSchemaMigrationsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)
+	`
+	_, err := db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns when each applied migration ran keyed by version
+func (db *DB) appliedMigrations() (map[int]time.Time, error) {
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for schema_migrations table: %w", err)
+	}
+
+	applied := make(map[int]time.Time)
+	if count == 0 {
+		return applied, nil
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// checkSchemaVersion returns ErrSchemaTooNew if a migration unknown to this binary was applied
+func checkSchemaVersion(applied map[int]time.Time) error {
+	for version := range applied {
+		if version > LatestSchemaVersion() {
+			return fmt.Errorf("%w: database is at version %d, this binary supports up to version %d",
+				ErrSchemaTooNew, version, LatestSchemaVersion())
+		}
+	}
+	return nil
+}
+
+// Migrate applies the pending schema migrations in order
+func (db *DB) Migrate() error {
+	if err := db.createSchemaMigrationsTable(); err != nil {
+		return err
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	if err := checkSchemaVersion(applied); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return err
+		}
+		log.Debug().Int("version", m.version).Str("name", m.name).Msg("Applied schema migration")
+	}
+	return nil
+}
+
+func (db *DB) applyMigration(m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// MigrationStatus returns every known migration and when it was applied, it does not
+// change the database. It fails with ErrSchemaTooNew if a newer binary migrated it.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if appliedAt, ok := applied[m.version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// migrateBaseline creates the schema as it was when migrations were introduced.
+// Databases created before that already have some of the tables, the columns
+// they may miss are added here, later migrations must not check for existence.
+func migrateBaseline(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE IF NOT EXISTS transactions (
+		reference_number TEXT PRIMARY KEY,
+		amount_value TEXT,
+		amount_currency TEXT,
+		merchant_name TEXT,
+		merchant_category_code TEXT,
+		merchant_city TEXT,
+		merchant_state_province TEXT,
+		transaction_date TEXT,
+		activity_category_code TEXT,
+		customer_id TEXT,
+		posted_date TEXT,
+		name_on_card TEXT,
+		source_account_name TEXT,
+		lunchmoney_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		synced_amount TEXT,
+		synced_date TEXT,
+		synced_payee TEXT,
+		vanished_at TIMESTAMP,
+		vanished_action TEXT,
+		status TEXT
+	)`, `
+	CREATE TABLE IF NOT EXISTS account_mappings (
+		external_name TEXT PRIMARY KEY,
+		lunchmoney_account_id TEXT,
+		is_plaid BOOLEAN
+	)`, `
+	CREATE TABLE IF NOT EXISTS ignored_external_accounts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		external_name TEXT NOT NULL
+	)`, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS account_info (
+		lunchmoney_account_id INTEGER PRIMARY KEY,
+		balance_value TEXT,
+		balance_currency TEXT,
+		balance_updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		is_plaid BOOLEAN DEFAULT false,
+		sync_strategy INTEGER DEFAULT %d
+	)`, models.AllSyncOption), `
+	CREATE TABLE IF NOT EXISTS fetch_windows (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_account_name TEXT NOT NULL,
+		window_start TEXT NOT NULL,
+		window_end TEXT NOT NULL,
+		fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`, `
+	CREATE TABLE IF NOT EXISTS fetch_window_references (
+		fetch_window_id INTEGER NOT NULL REFERENCES fetch_windows(id) ON DELETE CASCADE,
+		reference_number TEXT NOT NULL,
+		PRIMARY KEY (fetch_window_id, reference_number)
+	)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	legacyColumns := []struct {
+		table, column, definition string
+	}{
+		{"transactions", "source_account_name", "TEXT"},
+		{"transactions", "synced_amount", "TEXT"},
+		{"transactions", "synced_date", "TEXT"},
+		{"transactions", "synced_payee", "TEXT"},
+		{"transactions", "vanished_at", "TIMESTAMP"},
+		{"transactions", "vanished_action", "TEXT"},
+		{"transactions", "status", "TEXT"},
+		{"account_info", "sync_strategy", fmt.Sprintf("INTEGER DEFAULT %d", models.AllSyncOption)},
+	}
+	for _, c := range legacyColumns {
+		var count int
+		err := tx.QueryRow(`SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check for %s column: %w", c.column, err)
+		}
+		if count != 0 {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", c.column, err)
+		}
+	}
+	return nil
+}
+
+// migrateSyncHistory adds the record of fetch and sync runs and the audit trail of transactions
+func migrateSyncHistory(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE sync_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		provider TEXT,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP,
+		fetched INTEGER NOT NULL DEFAULT 0,
+		inserted INTEGER NOT NULL DEFAULT 0,
+		updated INTEGER NOT NULL DEFAULT 0,
+		skipped INTEGER NOT NULL DEFAULT 0,
+		error TEXT
+	)`, `
+	CREATE TABLE transaction_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER REFERENCES sync_runs(id),
+		reference_number TEXT NOT NULL,
+		event TEXT NOT NULL,
+		lunchmoney_id INTEGER,
+		detail TEXT,
+		created_at TIMESTAMP NOT NULL
+	)`,
+		`CREATE INDEX transaction_events_reference_number ON transaction_events (reference_number)`,
+		`CREATE INDEX transaction_events_run_id ON transaction_events (run_id)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMerchantOriginalName keeps the merchant name as the provider returned it
+// next to the name rewritten by the merchant rules
+func migrateMerchantOriginalName(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE transactions ADD COLUMN merchant_original_name TEXT`)
+	return err
+}
+
+// migrateCategories adds the local cache of LunchMoney categories and the mappings
+// of provider category codes to them
+func migrateCategories(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE lunchmoney_categories (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		is_income BOOLEAN NOT NULL DEFAULT false,
+		is_group BOOLEAN NOT NULL DEFAULT false,
+		group_id INTEGER,
+		fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`, `
+	CREATE TABLE category_mappings (
+		category_code TEXT PRIMARY KEY,
+		lunchmoney_category_id INTEGER NOT NULL
+	)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateNotesAndTags adds the notes and tags sent to LunchMoney with a transaction,
+// tags are stored as a JSON array
+func migrateNotesAndTags(tx *sql.Tx) error {
+	statements := []string{
+		`ALTER TABLE transactions ADD COLUMN notes TEXT`,
+		`ALTER TABLE transactions ADD COLUMN tags TEXT`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTransferPairs adds the transfers paired between our own accounts with their
+// review status
+func migrateTransferPairs(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE transfer_pairs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		outflow_reference TEXT NOT NULL,
+		inflow_reference TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (outflow_reference, inflow_reference)
+	)
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateDuplicateRejections adds the LunchMoney transactions rejected as duplicates
+// of a local transaction, so they are not proposed again
+func migrateDuplicateRejections(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE duplicate_rejections (
+		reference_number TEXT NOT NULL,
+		lunchmoney_id INTEGER NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (reference_number, lunchmoney_id)
+	)
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateNotifications adds when each notification was last sent, so a lasting
+// failure is not notified on every run
+func migrateNotifications(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE notifications (
+		key TEXT PRIMARY KEY,
+		sent_at TIMESTAMP NOT NULL
+	)
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateAccountMappingRules marks the account mappings stored from a configured rule,
+// they follow the rule instead of being kept like the selected ones
+func migrateAccountMappingRules(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE account_mappings ADD COLUMN from_rule BOOLEAN NOT NULL DEFAULT false`)
+	return err
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func newUninitializedTestDB(t *testing.T) *DB {
+	tempFile, err := os.CreateTemp("", "test-db-*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tempFile.Close()
+	t.Cleanup(func() {
+		os.Remove(tempFile.Name())
+	})
+
+	db, err := New(tempFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	return db
+}
+
+func TestMigrationStatus(t *testing.T) {
+	db := newUninitializedTestDB(t)
+	defer db.Close()
+
+	status, err := db.MigrationStatus()
+	assert.NoError(t, err)
+	assert.Len(t, status, len(migrations))
+	for _, s := range status {
+		assert.Nil(t, s.AppliedAt, "migration %d should be pending", s.Version)
+	}
+
+	assert.NoError(t, db.Initialize())
+	// applying again is a no-op
+	assert.NoError(t, db.Initialize())
+
+	status, err = db.MigrationStatus()
+	assert.NoError(t, err)
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt, "migration %d should be applied", s.Version)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	db := newUninitializedTestDB(t)
+	defer db.Close()
+
+	// the schema of a database created before source accounts and migrations existed
+	_, err := db.Exec(`
+	CREATE TABLE transactions (
+		reference_number TEXT PRIMARY KEY,
+		amount_value TEXT,
+		amount_currency TEXT,
+		merchant_name TEXT,
+		merchant_category_code TEXT,
+		merchant_city TEXT,
+		merchant_state_province TEXT,
+		transaction_date TEXT,
+		activity_category_code TEXT,
+		customer_id TEXT,
+		posted_date TEXT,
+		name_on_card TEXT,
+		lunchmoney_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO transactions (reference_number, amount_value, amount_currency, merchant_name,
+		merchant_category_code, merchant_city, merchant_state_province, transaction_date, posted_date, lunchmoney_id)
+		VALUES ('LEGACY', '1.00', 'CAD', 'Shop', '', '', '', '2025-01-01', '', 5)`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Initialize())
+
+	tx, err := db.GetTransactionByReference("LEGACY")
+	assert.NoError(t, err)
+	if assert.NotNil(t, tx) {
+		assert.Equal(t, int64(5), tx.LunchMoneyID)
+		assert.Empty(t, tx.SourceAccountName)
+	}
+
+	assert.NoError(t, db.SaveTransaction(&models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "NEW",
+			Amount:          models.Amount{Value: "2.00", Currency: "CAD"},
+			Date:            "2025-01-02",
+			Status:          models.TransactionStatusPending,
+		},
+		SourceAccountName: "Visa",
+	}))
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	db := newUninitializedTestDB(t)
+	defer db.Close()
+
+	assert.NoError(t, db.Initialize())
+	_, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'from the future')`, LatestSchemaVersion()+1)
+	assert.NoError(t, err)
+
+	err = db.Initialize()
+	assert.True(t, errors.Is(err, ErrSchemaTooNew), "expected ErrSchemaTooNew, got %v", err)
+
+	_, err = db.MigrationStatus()
+	assert.True(t, errors.Is(err, ErrSchemaTooNew), "expected ErrSchemaTooNew, got %v", err)
+}
@@ -2,7 +2,12 @@ package db
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
@@ -12,6 +17,20 @@ type MockDB struct {
 	Transactions map[string]*models.TransactionWithAccount
 	// Mock data for account mappings
 	AccountMappings map[string]*models.AccountMapping
+	// Cached LunchMoney categories and category mappings keyed by category code
+	Categories       []models.LunchMoneyCategory
+	CategoryMappings map[string]*models.CategoryMapping
+	// Transfer pairs in the order they were saved
+	TransferPairs []*models.TransferPair
+	// Rejected duplicate candidates in the order they were added
+	DuplicateRejections []*models.DuplicateRejection
+	// When the notifications were last sent keyed by their deduplication key
+	Notifications map[string]time.Time
+	// LunchMoney accounts with their local balances
+	Accounts []models.LunchMoneyAccount
+	// Recorded runs and transaction events
+	SyncRuns          []*models.SyncRun
+	TransactionEvents []*models.TransactionEvent
 
 	// Error values to return
 	GetTransactionsErr           error
@@ -20,6 +39,7 @@ type MockDB struct {
 	UpdateTransactionErr         error
 	RemoveTransactionErr         error
 	AddManualTransactionErr      error
+	RecordFetchWindowErr         error
 
 	GetAccountMappingErr    error
 	UpsertAccountMappingErr error
@@ -30,19 +50,48 @@ func (m *MockDB) DisableSyncOptions(lunchMoneyId string, syncOption models.SyncO
 	return nil
 }
 
-// IsSyncOptionEnabled implements DBInterface.
+// IsSyncOptionEnabled implements DBInterface. Like the DB, every option is disabled
+// for the accounts without a balance.
 func (m *MockDB) IsSyncOptionEnabled(lunchMoneyId int64, syncOption models.SyncOption) (bool, error) {
-	return true, nil
+	for _, account := range m.Accounts {
+		if account.LunchMoneyId == lunchMoneyId {
+			return account.SyncStrategy&syncOption != 0, nil
+		}
+	}
+	return false, nil
 }
 
 // GetAccounts implements DBInterface.
 func (m *MockDB) GetAccounts() ([]models.LunchMoneyAccount, error) {
-	panic("unimplemented")
+	return m.Accounts, nil
 }
 
-// UpsertAccountBalance implements DBInterface.
-func (m *MockDB) UpsertAccountBalance(lunchMoneyId string, balance models.Amount) error {
-	panic("unimplemented")
+// UpsertAccountBalance implements DBInterface. Like the DB, the balance of an
+// unmapped account or older than the saved one is skipped and a new account has
+// every sync option enabled.
+func (m *MockDB) UpsertAccountBalance(externalAccountName string, balance models.Amount, asOf time.Time) error {
+	am, ok := m.AccountMappings[externalAccountName]
+	if !ok {
+		return nil
+	}
+	for i := range m.Accounts {
+		account := &m.Accounts[i]
+		if account.LunchMoneyId != am.LunchMoneyId {
+			continue
+		}
+		if account.BalanceLastUpdated == nil || !account.BalanceLastUpdated.After(asOf) {
+			account.Balance = balance
+			account.BalanceLastUpdated = lo.ToPtr(asOf)
+		}
+		return nil
+	}
+	m.Accounts = append(m.Accounts, models.LunchMoneyAccount{
+		LunchMoneyId:       am.LunchMoneyId,
+		Balance:            balance,
+		BalanceLastUpdated: lo.ToPtr(asOf),
+		SyncStrategy:       models.AllSyncOption,
+	})
+	return nil
 }
 
 // GetAccountMapping implements DBInterface.
@@ -72,10 +121,188 @@ func (m *MockDB) UpsertAccountMapping(am *models.AccountMapping) error {
 	return nil
 }
 
+// GetAccountMappings implements DBInterface.
+func (m *MockDB) GetAccountMappings() ([]*models.AccountMappingDetails, error) {
+	mappings := make([]*models.AccountMappingDetails, 0, len(m.AccountMappings))
+	for _, am := range m.AccountMappings {
+		mappings = append(mappings, &models.AccountMappingDetails{AccountMapping: *am})
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].ExternalName < mappings[j].ExternalName
+	})
+	return mappings, nil
+}
+
+// DeleteAccountMapping implements DBInterface.
+func (m *MockDB) DeleteAccountMapping(externalName string) error {
+	if _, ok := m.AccountMappings[externalName]; !ok {
+		return fmt.Errorf("no account mapping found for external account: %s", externalName)
+	}
+	delete(m.AccountMappings, externalName)
+	return nil
+}
+
+// ReplaceLunchMoneyCategories implements DBInterface.
+func (m *MockDB) ReplaceLunchMoneyCategories(categories []models.LunchMoneyCategory) error {
+	m.Categories = categories
+	return nil
+}
+
+// GetLunchMoneyCategories implements DBInterface.
+func (m *MockDB) GetLunchMoneyCategories() ([]models.LunchMoneyCategory, error) {
+	return m.Categories, nil
+}
+
+// UpsertCategoryMapping implements DBInterface.
+func (m *MockDB) UpsertCategoryMapping(cm *models.CategoryMapping) error {
+	if m.CategoryMappings == nil {
+		m.CategoryMappings = make(map[string]*models.CategoryMapping)
+	}
+	m.CategoryMappings[cm.CategoryCode] = cm
+	return nil
+}
+
+// GetCategoryMapping implements DBInterface.
+func (m *MockDB) GetCategoryMapping(categoryCode string) (*models.CategoryMapping, error) {
+	return m.CategoryMappings[categoryCode], nil
+}
+
+// GetCategoryMappings implements DBInterface.
+func (m *MockDB) GetCategoryMappings() ([]*models.CategoryMapping, error) {
+	mappings := make([]*models.CategoryMapping, 0, len(m.CategoryMappings))
+	for _, cm := range m.CategoryMappings {
+		mappings = append(mappings, cm)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].CategoryCode < mappings[j].CategoryCode
+	})
+	return mappings, nil
+}
+
+// DeleteCategoryMapping implements DBInterface.
+func (m *MockDB) DeleteCategoryMapping(categoryCode string) error {
+	if _, ok := m.CategoryMappings[categoryCode]; !ok {
+		return fmt.Errorf("no category mapping found for category code: %s", categoryCode)
+	}
+	delete(m.CategoryMappings, categoryCode)
+	return nil
+}
+
+// SaveTransferPair implements DBInterface.
+func (m *MockDB) SaveTransferPair(pair *models.TransferPair) error {
+	m.TransferPairs = append(m.TransferPairs, pair)
+	pair.ID = int64(len(m.TransferPairs))
+	pair.CreatedAt = time.Now()
+	return nil
+}
+
+// GetTransferPairs implements DBInterface.
+func (m *MockDB) GetTransferPairs() ([]*models.TransferPair, error) {
+	return m.TransferPairs, nil
+}
+
+// SetTransferStatus implements DBInterface.
+func (m *MockDB) SetTransferStatus(id int64, status models.TransferStatus) error {
+	for _, pair := range m.TransferPairs {
+		if pair.ID == id {
+			pair.Status = status
+			return nil
+		}
+	}
+	return fmt.Errorf("no transfer pair found with id: %d", id)
+}
+
+// AddDuplicateRejection implements DBInterface.
+func (m *MockDB) AddDuplicateRejection(referenceNumber string, lunchMoneyID int64) error {
+	m.DuplicateRejections = append(m.DuplicateRejections, &models.DuplicateRejection{
+		ReferenceNumber: referenceNumber,
+		LunchMoneyID:    lunchMoneyID,
+		CreatedAt:       time.Now(),
+	})
+	return nil
+}
+
+// GetDuplicateRejections implements DBInterface.
+func (m *MockDB) GetDuplicateRejections() ([]*models.DuplicateRejection, error) {
+	return m.DuplicateRejections, nil
+}
+
+// GetNotificationSentAt implements DBInterface.
+func (m *MockDB) GetNotificationSentAt(key string) (*time.Time, error) {
+	sentAt, ok := m.Notifications[key]
+	if !ok {
+		return nil, nil
+	}
+	return &sentAt, nil
+}
+
+// RecordNotificationSent implements DBInterface.
+func (m *MockDB) RecordNotificationSent(key string, sentAt time.Time) error {
+	m.Notifications[key] = sentAt
+	return nil
+}
+
+// ClearNotification implements DBInterface.
+func (m *MockDB) ClearNotification(key string) error {
+	delete(m.Notifications, key)
+	return nil
+}
+
+// StartSyncRun implements DBInterface.
+func (m *MockDB) StartSyncRun(run *models.SyncRun) error {
+	m.SyncRuns = append(m.SyncRuns, run)
+	run.ID = int64(len(m.SyncRuns))
+	return nil
+}
+
+// FinishSyncRun implements DBInterface.
+func (m *MockDB) FinishSyncRun(run *models.SyncRun) error {
+	return nil
+}
+
+// GetSyncRuns implements DBInterface.
+func (m *MockDB) GetSyncRuns(limit int) ([]*models.SyncRun, error) {
+	runs := make([]*models.SyncRun, 0, limit)
+	for i := len(m.SyncRuns) - 1; i >= 0 && len(runs) < limit; i-- {
+		runs = append(runs, m.SyncRuns[i])
+	}
+	return runs, nil
+}
+
+// AddTransactionEvent implements DBInterface.
+func (m *MockDB) AddTransactionEvent(event *models.TransactionEvent) error {
+	m.TransactionEvents = append(m.TransactionEvents, event)
+	event.ID = int64(len(m.TransactionEvents))
+	return nil
+}
+
+// GetTransactionEvents implements DBInterface.
+func (m *MockDB) GetTransactionEvents(referenceNumber string) ([]*models.TransactionEvent, error) {
+	events := make([]*models.TransactionEvent, 0)
+	for _, event := range m.TransactionEvents {
+		if event.ReferenceNumber == referenceNumber {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// GetSyncRunEvents implements DBInterface.
+func (m *MockDB) GetSyncRunEvents(runID int64) ([]*models.TransactionEvent, error) {
+	events := make([]*models.TransactionEvent, 0)
+	for _, event := range m.TransactionEvents {
+		if event.RunID == runID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
 // NewMockDB creates a new mock database
 func NewMockDB() *MockDB {
 	return &MockDB{
-		Transactions: make(map[string]*models.TransactionWithAccount),
+		Transactions:  make(map[string]*models.TransactionWithAccount),
+		Notifications: make(map[string]time.Time),
 	}
 }
 
@@ -93,6 +320,101 @@ func (m *MockDB) GetTransactions() ([]*models.TransactionWithAccount, error) {
 	return transactions, nil
 }
 
+// FilterTransactions implements DBInterface.
+func (m *MockDB) FilterTransactions(filter *models.TransactionFilter) ([]*models.TransactionWithAccount, error) {
+	if m.GetTransactionsErr != nil {
+		return nil, m.GetTransactionsErr
+	}
+
+	transactions := make([]*models.TransactionWithAccount, 0, len(m.Transactions))
+	for _, tx := range m.Transactions {
+		mapping := m.AccountMappings[tx.SourceAccountName]
+		if filterMatches(filter, tx, mapping != nil && mapping.IsIgnored()) {
+			transactions = append(transactions, tx)
+		}
+	}
+	filterSort(filter, transactions)
+	return filter.Page(transactions), nil
+}
+
+// filterMatches imitates the conditions of the FilterTransactions query, ignoredAccount
+// is set when the source account of the transaction is always ignored
+func filterMatches(f *models.TransactionFilter, tx *models.TransactionWithAccount, ignoredAccount bool) bool {
+	if !f.Window.Start.IsZero() {
+		if ok, err := f.Window.ContainsDate(tx.Date); err != nil || !ok {
+			return false
+		}
+	}
+	if f.SourceAccount != "" && !strings.EqualFold(f.SourceAccount, tx.SourceAccountName) {
+		return false
+	}
+
+	merchant := ""
+	if tx.Merchant != nil {
+		merchant = tx.Merchant.Name
+	}
+	if f.Merchant != "" && !strings.Contains(strings.ToLower(merchant), strings.ToLower(f.Merchant)) {
+		return false
+	}
+	if f.MerchantPattern != nil && !f.MerchantPattern.MatchString(merchant) {
+		return false
+	}
+
+	if f.MinAmount != nil || f.MaxAmount != nil {
+		amount, err := strconv.ParseFloat(tx.Amount.Value, 64)
+		if err != nil {
+			return false
+		}
+		if (f.MinAmount != nil && amount < *f.MinAmount) || (f.MaxAmount != nil && amount > *f.MaxAmount) {
+			return false
+		}
+	}
+	if f.SyncState != "" && f.SyncState != models.SyncStateOf(&tx.Transaction, ignoredAccount) {
+		return false
+	}
+	if f.Tag != "" && !tx.HasTag(f.Tag) {
+		return false
+	}
+	return f.Currency == "" || strings.EqualFold(f.Currency, tx.Amount.Currency)
+}
+
+// filterSort imitates the order of the FilterTransactions query
+func filterSort(f *models.TransactionFilter, transactions []*models.TransactionWithAccount) {
+	key := func(tx *models.TransactionWithAccount) string {
+		switch f.SortBy {
+		case models.SortByMerchant:
+			if tx.Merchant != nil {
+				return strings.ToLower(tx.Merchant.Name)
+			}
+			return ""
+		case models.SortByAccount:
+			return strings.ToLower(tx.SourceAccountName)
+		}
+		return tx.Date
+	}
+	compare := func(a, b *models.TransactionWithAccount) int {
+		if f.SortBy == models.SortByAmount {
+			x, _ := strconv.ParseFloat(a.Amount.Value, 64)
+			y, _ := strconv.ParseFloat(b.Amount.Value, 64)
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		} else if c := strings.Compare(key(a), key(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ReferenceNumber, b.ReferenceNumber)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if f.Ascending {
+			return compare(transactions[i], transactions[j]) < 0
+		}
+		return compare(transactions[i], transactions[j]) > 0
+	})
+}
+
 // GetTransactionByReference returns a transaction by its reference number
 func (m *MockDB) GetTransactionByReference(referenceNumber string) (*models.TransactionWithAccount, error) {
 	if m.GetTransactionByReferenceErr != nil {
@@ -159,6 +481,62 @@ func (m *MockDB) AddManualTransaction(tx *models.TransactionWithAccount) error {
 	return nil
 }
 
+// RecordFetchWindow flags the transactions of the account within the window that
+// are not in referenceNumbers as vanished
+func (m *MockDB) RecordFetchWindow(sourceAccountName string, window models.DateRange, referenceNumbers []string) ([]string, error) {
+	if m.RecordFetchWindowErr != nil {
+		return nil, m.RecordFetchWindowErr
+	}
+
+	seen := make(map[string]bool, len(referenceNumbers))
+	for _, ref := range referenceNumbers {
+		seen[ref] = true
+	}
+
+	vanished := make([]string, 0)
+	for ref, tx := range m.Transactions {
+		if seen[ref] {
+			tx.VanishedAt = nil
+			tx.VanishedAction = ""
+			continue
+		}
+		if tx.SourceAccountName != sourceAccountName || tx.VanishedAt != nil {
+			continue
+		}
+		inWindow, err := window.ContainsDate(tx.Date)
+		if err != nil {
+			return nil, err
+		}
+		if inWindow {
+			now := time.Now()
+			tx.VanishedAt = &now
+			vanished = append(vanished, ref)
+		}
+	}
+	return vanished, nil
+}
+
+// GetVanishedTransactions returns the vanished transactions that have not been handled yet
+func (m *MockDB) GetVanishedTransactions() ([]*models.TransactionWithAccount, error) {
+	transactions := make([]*models.TransactionWithAccount, 0)
+	for _, tx := range m.Transactions {
+		if tx.VanishedAt != nil && tx.VanishedAction == "" {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, nil
+}
+
+// SetVanishedAction records how a vanished transaction was handled
+func (m *MockDB) SetVanishedAction(referenceNumber string, action models.VanishedAction) error {
+	tx, ok := m.Transactions[referenceNumber]
+	if !ok || tx.VanishedAt == nil {
+		return fmt.Errorf("no vanished transaction found with reference number: %s", referenceNumber)
+	}
+	tx.VanishedAction = action
+	return nil
+}
+
 // Initialize is a no-op for the mock database
 func (m *MockDB) Initialize() error {
 	return nil
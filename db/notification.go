@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetNotificationSentAt returns when the notification with a deduplication key was
+// last sent, or nil if it was never sent
+func (db *DB) GetNotificationSentAt(key string) (*time.Time, error) {
+	var sentAt time.Time
+	err := db.QueryRow(`SELECT sent_at FROM notifications WHERE key = ?`, key).Scan(&sentAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification: %w", err)
+	}
+	return &sentAt, nil
+}
+
+// RecordNotificationSent records when the notification with a deduplication key was sent
+func (db *DB) RecordNotificationSent(key string, sentAt time.Time) error {
+	query := `
+	INSERT INTO notifications (key, sent_at)
+	VALUES (?, ?)
+	ON CONFLICT(key) DO UPDATE SET sent_at = excluded.sent_at
+	`
+	if _, err := db.Exec(query, key, sentAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record notification: %w", err)
+	}
+	return nil
+}
+
+// ClearNotification forgets the notification with a deduplication key, so that it is
+// sent again on its next occurrence
+func (db *DB) ClearNotification(key string) error {
+	if _, err := db.Exec(`DELETE FROM notifications WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to clear notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifications(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	sentAt, err := db.GetNotificationSentAt("auth_failure:rogers")
+	assert.NoError(t, err)
+	assert.Nil(t, sentAt, "a notification that was never sent has no time")
+
+	first := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, db.RecordNotificationSent("auth_failure:rogers", first))
+	assert.NoError(t, db.RecordNotificationSent("auth_failure:rogers", first.Add(time.Hour)))
+
+	sentAt, err = db.GetNotificationSentAt("auth_failure:rogers")
+	assert.NoError(t, err)
+	if assert.NotNil(t, sentAt) {
+		assert.True(t, sentAt.Equal(first.Add(time.Hour)), "expected the last time it was sent, got %v", sentAt)
+	}
+
+	assert.NoError(t, db.ClearNotification("auth_failure:rogers"))
+	sentAt, err = db.GetNotificationSentAt("auth_failure:rogers")
+	assert.NoError(t, err)
+	assert.Nil(t, sentAt)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// SaveTransferPair stores a new transfer pair and sets its ID
+func (db *DB) SaveTransferPair(pair *models.TransferPair) error {
+	result, err := db.Exec(`
+	INSERT INTO transfer_pairs (outflow_reference, inflow_reference, status)
+	VALUES (?, ?, ?)
+	`, pair.OutflowReference, pair.InflowReference, pair.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert transfer pair: %w", err)
+	}
+
+	pair.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get transfer pair id: %w", err)
+	}
+	return nil
+}
+
+// GetTransferPairs returns every transfer pair, including broken ones, oldest first
+func (db *DB) GetTransferPairs() ([]*models.TransferPair, error) {
+	rows, err := db.Query(`
+	SELECT id, outflow_reference, inflow_reference, status, created_at
+	FROM transfer_pairs
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer pairs: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := make([]*models.TransferPair, 0)
+	for rows.Next() {
+		var pair models.TransferPair
+		err := rows.Scan(&pair.ID, &pair.OutflowReference, &pair.InflowReference, &pair.Status, &pair.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer pair: %w", err)
+		}
+		pairs = append(pairs, &pair)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transfer pairs: %w", err)
+	}
+	return pairs, nil
+}
+
+// SetTransferStatus records the review of a transfer pair
+func (db *DB) SetTransferStatus(id int64, status models.TransferStatus) error {
+	result, err := db.Exec(`UPDATE transfer_pairs SET status = ? WHERE id = ?`, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer pair: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no transfer pair found with id: %d", id)
+	}
+	return nil
+}
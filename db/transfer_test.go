@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestTransferPairs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	pair := &models.TransferPair{OutflowReference: "PAY", InflowReference: "CARD", Status: models.TransferStatusMatched}
+	assert.NoError(t, db.SaveTransferPair(pair))
+	assert.NotZero(t, pair.ID)
+
+	assert.Error(t, db.SaveTransferPair(&models.TransferPair{
+		OutflowReference: "PAY", InflowReference: "CARD", Status: models.TransferStatusMatched,
+	}), "the same pair can not be saved twice")
+
+	assert.NoError(t, db.SetTransferStatus(pair.ID, models.TransferStatusBroken))
+	assert.Error(t, db.SetTransferStatus(pair.ID+1, models.TransferStatusConfirmed))
+
+	pairs, err := db.GetTransferPairs()
+	assert.NoError(t, err)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, "PAY", pairs[0].OutflowReference)
+		assert.Equal(t, "CARD", pairs[0].InflowReference)
+		assert.Equal(t, models.TransferStatusBroken, pairs[0].Status)
+		assert.False(t, pairs[0].IsActive())
+		assert.False(t, pairs[0].CreatedAt.IsZero())
+	}
+}
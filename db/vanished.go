@@ -0,0 +1,171 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// RecordFetchWindow records the reference numbers a source account returned for a
+// window that the provider fully covered. Local transactions of the account within
+// the window that were not returned are flagged as vanished and their reference
+// numbers returned, transactions seen again lose their vanished flag. Earlier windows
+// of the account that ended before this one starts are pruned.
+func (db *DB) RecordFetchWindow(sourceAccountName string, window models.DateRange, referenceNumbers []string) ([]string, error) {
+	sqlTx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	result, err := sqlTx.Exec(`
+	INSERT INTO fetch_windows (source_account_name, window_start, window_end)
+	VALUES (?, ?, ?)
+	`, sourceAccountName, window.StartDate(), window.EndDate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert fetch window: %w", err)
+	}
+
+	windowId, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fetch window id: %w", err)
+	}
+
+	for _, ref := range referenceNumbers {
+		_, err := sqlTx.Exec(`
+		INSERT OR IGNORE INTO fetch_window_references (fetch_window_id, reference_number)
+		VALUES (?, ?)
+		`, windowId, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert fetch window reference: %w", err)
+		}
+	}
+
+	// transactions seen again are no longer vanished
+	_, err = sqlTx.Exec(`
+	UPDATE transactions
+	SET vanished_at = NULL, vanished_action = NULL
+	WHERE vanished_at IS NOT NULL AND reference_number IN (
+		SELECT reference_number FROM fetch_window_references WHERE fetch_window_id = ?
+	)
+	`, windowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear vanished transactions: %w", err)
+	}
+
+	rows, err := sqlTx.Query(`
+	SELECT reference_number FROM transactions
+	WHERE source_account_name = ?
+		AND transaction_date BETWEEN ? AND ?
+		AND vanished_at IS NULL
+		AND reference_number NOT IN (
+			SELECT reference_number FROM fetch_window_references WHERE fetch_window_id = ?
+		)
+	`, sourceAccountName, window.StartDate(), window.EndDate(), windowId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vanished transactions: %w", err)
+	}
+
+	var vanished []string
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan vanished transaction: %w", err)
+		}
+		vanished = append(vanished, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vanished transactions: %w", err)
+	}
+
+	if len(vanished) != 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(vanished)), ",")
+		args := make([]any, 0, len(vanished))
+		for _, ref := range vanished {
+			args = append(args, ref)
+		}
+		_, err = sqlTx.Exec(`
+		UPDATE transactions SET vanished_at = CURRENT_TIMESTAMP
+		WHERE reference_number IN (`+placeholders+`)
+		`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to flag vanished transactions: %w", err)
+		}
+	}
+
+	// only the latest window is compared, the ones older than it are not needed anymore
+	_, err = sqlTx.Exec(`
+	DELETE FROM fetch_window_references WHERE fetch_window_id IN (
+		SELECT id FROM fetch_windows
+		WHERE source_account_name = ? AND id != ? AND window_end < ?
+	)
+	`, sourceAccountName, windowId, window.StartDate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune fetch window references: %w", err)
+	}
+	_, err = sqlTx.Exec(`
+	DELETE FROM fetch_windows WHERE source_account_name = ? AND id != ? AND window_end < ?
+	`, sourceAccountName, windowId, window.StartDate())
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune fetch windows: %w", err)
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit fetch window: %w", err)
+	}
+	return vanished, nil
+}
+
+// GetVanishedTransactions returns the vanished transactions that have not been handled yet
+func (db *DB) GetVanishedTransactions() ([]*models.TransactionWithAccount, error) {
+	query := `
+	SELECT ` + transactionColumns + `
+	FROM transactions
+	WHERE vanished_at IS NOT NULL AND vanished_action IS NULL
+	ORDER BY transaction_date DESC
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vanished transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []*models.TransactionWithAccount
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// SetVanishedAction records how a vanished transaction was handled
+func (db *DB) SetVanishedAction(referenceNumber string, action models.VanishedAction) error {
+	result, err := db.Exec(`
+	UPDATE transactions SET vanished_action = ?
+	WHERE reference_number = ? AND vanished_at IS NOT NULL
+	`, action, referenceNumber)
+	if err != nil {
+		return fmt.Errorf("failed to set vanished action: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no vanished transaction found with reference number: %s", referenceNumber)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestRecordFetchWindow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	save := func(ref, account, date string) {
+		err := db.SaveTransaction(&models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: "Merchant " + ref},
+				Date:            date,
+			},
+			SourceAccountName: account,
+		})
+		assert.NoError(t, err)
+	}
+	save("SEEN", "Visa", "2025-04-10")
+	save("GONE", "Visa", "2025-04-12")
+	save("OLD", "Visa", "2025-03-01")
+	save("OTHER", "Chequing", "2025-04-12")
+
+	window, err := models.NewDateRange("2025-04-01", "2025-04-30")
+	assert.NoError(t, err)
+
+	t.Run("Flags missing transactions within the window", func(t *testing.T) {
+		vanished, err := db.RecordFetchWindow("Visa", window, []string{"SEEN"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"GONE"}, vanished)
+
+		tx, err := db.GetTransactionByReference("GONE")
+		assert.NoError(t, err)
+		assert.NotNil(t, tx.VanishedAt)
+
+		list, err := db.GetVanishedTransactions()
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "GONE", list[0].ReferenceNumber)
+	})
+
+	t.Run("Already vanished transactions are not reported twice", func(t *testing.T) {
+		vanished, err := db.RecordFetchWindow("Visa", window, []string{"SEEN"})
+		assert.NoError(t, err)
+		assert.Empty(t, vanished)
+	})
+
+	t.Run("Resolved transactions are no longer listed", func(t *testing.T) {
+		assert.NoError(t, db.SetVanishedAction("GONE", models.VanishedActionMarked))
+
+		list, err := db.GetVanishedTransactions()
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+
+		tx, err := db.GetTransactionByReference("GONE")
+		assert.NoError(t, err)
+		assert.Equal(t, models.VanishedActionMarked, tx.VanishedAction)
+
+		assert.Error(t, db.SetVanishedAction("SEEN", models.VanishedActionMarked))
+	})
+
+	t.Run("Transactions seen again are restored", func(t *testing.T) {
+		vanished, err := db.RecordFetchWindow("Visa", window, []string{"SEEN", "GONE"})
+		assert.NoError(t, err)
+		assert.Empty(t, vanished)
+
+		tx, err := db.GetTransactionByReference("GONE")
+		assert.NoError(t, err)
+		assert.Nil(t, tx.VanishedAt)
+		assert.Empty(t, tx.VanishedAction)
+	})
+
+	t.Run("Windows older than the fetched window are pruned", func(t *testing.T) {
+		later, err := models.NewDateRange("2025-05-01", "2025-05-31")
+		assert.NoError(t, err)
+		_, err = db.RecordFetchWindow("Visa", later, []string{"MAY"})
+		assert.NoError(t, err)
+
+		var windows, references int
+		assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM fetch_windows WHERE source_account_name = 'Visa'`).Scan(&windows))
+		assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM fetch_window_references`).Scan(&references))
+		assert.Equal(t, 1, windows)
+		assert.Equal(t, 1, references)
+	})
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CategoryRule assigns a LunchMoney category to the transactions inserted into LunchMoney.
+// Exactly one of CategoryCode, Contains or Regex selects the transactions and exactly
+// one of CategoryId or Uncategorized says which category they get.
+type CategoryRule struct {
+	// CategoryCode matches the provider's category code exactly
+	CategoryCode string `yaml:"categoryCode,omitempty"`
+	// Contains matches merchant names containing the text, ignoring case
+	Contains string `yaml:"contains,omitempty"`
+	// Regex matches merchant names with a regular expression
+	Regex string `yaml:"regex,omitempty"`
+	// CategoryId is the LunchMoney category the matched transactions are assigned to
+	CategoryId int64 `yaml:"categoryId,omitempty"`
+	// Uncategorized inserts the matched transactions without a category
+	Uncategorized bool `yaml:"uncategorized,omitempty"`
+}
+
+// Validate checks that the rule has exactly one matcher and one target
+func (r *CategoryRule) Validate() error {
+	if err := validateMatchers(r.Regex,
+		ruleMatcher{"categoryCode", r.CategoryCode}, ruleMatcher{"contains", r.Contains}, ruleMatcher{"regex", r.Regex}); err != nil {
+		return err
+	}
+
+	if r.Uncategorized == (r.CategoryId != 0) {
+		return fmt.Errorf("exactly one of categoryId or uncategorized must be set")
+	}
+	if r.CategoryId < 0 {
+		return fmt.Errorf("categoryId must be positive")
+	}
+	return nil
+}
+
+// Matches returns true if the rule applies to a transaction with the category code
+// and any of the merchant names
+func (r *CategoryRule) Matches(categoryCode string, merchantNames ...string) bool {
+	if r.CategoryCode != "" {
+		return r.CategoryCode == categoryCode
+	}
+
+	var re *regexp.Regexp
+	if r.Regex != "" {
+		var err error
+		if re, err = regexp.Compile(r.Regex); err != nil {
+			return false
+		}
+	}
+	for _, name := range merchantNames {
+		if name == "" {
+			continue
+		}
+		if re != nil && re.MatchString(name) ||
+			re == nil && strings.Contains(strings.ToLower(name), strings.ToLower(r.Contains)) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the rule's matcher
+func (r *CategoryRule) String() string {
+	switch {
+	case r.CategoryCode != "":
+		return "categoryCode " + r.CategoryCode
+	case r.Contains != "":
+		return "contains " + r.Contains
+	}
+	return "regex " + r.Regex
+}
+
+// validateCategoryRules checks every configured category rule
+func (c *Config) validateCategoryRules() error {
+	for i := range c.CategoryRules {
+		if err := c.CategoryRules[i].Validate(); err != nil {
+			return fmt.Errorf("invalid category rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
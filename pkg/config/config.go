@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/vpnda/sandwich-sync/pkg/secrets"
 )
 
 type RogersOptions struct {
@@ -28,12 +29,61 @@ type ScotiabankOptions struct {
 	Password string `yaml:"password"`
 }
 
+// DefaultSyncWindowDays is used when no sync window is configured
+const DefaultSyncWindowDays = 30
+
+// SyncWindowOptions controls how many days of history are fetched and synced
+type SyncWindowOptions struct {
+	// Days is the global number of days to look back
+	Days int `yaml:"days,omitempty"`
+	// Providers overrides Days for individual providers by name
+	Providers map[string]int `yaml:"providers,omitempty"`
+}
+
 // Config holds the application configuration
 type Config struct {
 	LunchMoneyAPIKey       string              `yaml:"lunchMoneyApiKey"`
 	RogersApiOptions       RogersOptions       `yaml:"rogers"`
 	WealthsimpleApiOptions WealthsimpleOptions `yaml:"wealthsimple"`
 	ScotiabankOptions      ScotiabankOptions   `yaml:"scotia"`
+	SyncWindow             SyncWindowOptions   `yaml:"syncWindow,omitempty"`
+	// AccountMappings are applied to unknown external accounts before prompting,
+	// the first matching rule wins
+	AccountMappings []AccountMappingRule `yaml:"accountMappings,omitempty"`
+	// CSVProfiles describe the statement formats of the banks imported from CSV, by name
+	CSVProfiles map[string]CSVProfile `yaml:"csvProfiles,omitempty"`
+	// MerchantRules rewrite raw merchant names into canonical payees
+	MerchantRules []MerchantRule `yaml:"merchantRules,omitempty"`
+	// CategoryRules assign LunchMoney categories to inserted transactions before the
+	// stored category code mappings are used
+	CategoryRules []CategoryRule `yaml:"categoryRules,omitempty"`
+	// TagRules add tags to transactions when they are fetched and before they are synced
+	TagRules []TagRule `yaml:"tagRules,omitempty"`
+	// Transfers controls the detection of transfers between mapped accounts
+	Transfers TransferOptions `yaml:"transfers,omitempty"`
+	// Duplicates controls how local transactions are matched against the ones in LunchMoney
+	Duplicates DuplicateOptions `yaml:"duplicates,omitempty"`
+	// Daemon schedules the fetches and syncs of the daemon command
+	Daemon DaemonOptions `yaml:"daemon,omitempty"`
+	// Notifications sends failures and notable transactions to webhooks, email and more
+	Notifications NotificationOptions `yaml:"notifications,omitempty"`
+}
+
+// SyncWindowDays returns the global number of days to fetch and sync
+func (c *Config) SyncWindowDays() int {
+	if c.SyncWindow.Days > 0 {
+		return c.SyncWindow.Days
+	}
+	return DefaultSyncWindowDays
+}
+
+// ProviderSyncWindowDays returns the number of days to fetch for a provider,
+// falling back to the global sync window
+func (c *Config) ProviderSyncWindowDays(provider string) int {
+	if days, ok := c.SyncWindow.Providers[provider]; ok && days > 0 {
+		return days
+	}
+	return c.SyncWindowDays()
 }
 
 var (
@@ -59,6 +109,34 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("error parsing config file: %w", err)
 	}
 
+	if err := config.validateAccountMappings(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateCSVProfiles(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateMerchantRules(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateCategoryRules(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateTagRules(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateTransfers(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateDuplicates(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateDaemon(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+	if err := config.validateNotifications(); err != nil {
+		return nil, fmt.Errorf("error validating config file: %w", err)
+	}
+
 	return &config, nil
 }
 
@@ -118,7 +196,7 @@ func GetConfig() (*Config, error) {
 			}
 
 			// Write the default configuration to the file
-			if err := os.WriteFile(configPath, data, 0644); err != nil {
+			if err := os.WriteFile(configPath, data, 0600); err != nil {
 				return nil, fmt.Errorf("error writing default config: %w", err)
 			}
 
@@ -206,27 +284,44 @@ func GetWealthsimplePrevSession() (string, error) {
 	return config.WealthsimpleApiOptions.PrevSession, nil
 }
 
+// SetWealthsimplePrevSession stores the session to resume next time, in the vault when
+// one exists or can be created and otherwise in config.yaml
 func SetWealthsimplePrevSession(session string) error {
 	config, err := GetConfig()
 	if err != nil {
 		return err
 	}
 
-	config.WealthsimpleApiOptions.PrevSession = session
-
 	configMutex.Lock()
 	defer configMutex.Unlock()
 
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("error marshalling config: %w", err)
+	if secretStore == nil && openSecretStore != nil {
+		store, err := openSecretStore()
+		if err != nil {
+			return fmt.Errorf("error opening the vault for the session: %w", err)
+		}
+		secretStore = store
+		openSecretStore = nil
 	}
 
-	if err := os.WriteFile("config.yaml", data, 0644); err != nil {
-		return fmt.Errorf("error writing config file: %w", err)
-	}
+	config.WealthsimpleApiOptions.PrevSession = session
+	return updateConfigFile(func(raw *Config) error {
+		if secretStore == nil {
+			raw.WealthsimpleApiOptions.PrevSession = session
+			return nil
+		}
 
-	return nil
+		name, ok := secrets.ParseRef(raw.WealthsimpleApiOptions.PrevSession)
+		if !ok {
+			name = WealthsimpleSessionSecret
+		}
+		secretStore.Set(name, session)
+		if err := secretStore.Save(); err != nil {
+			return fmt.Errorf("error saving session to the vault: %w", err)
+		}
+		raw.WealthsimpleApiOptions.PrevSession = secrets.Ref(name)
+		return nil
+	})
 }
 
 func GetWealthsimpleStartSyncDate() (time.Time, error) {
@@ -3,7 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/notify"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -170,3 +174,540 @@ func TestGetLunchMoneyAPIKey(t *testing.T) {
 		t.Errorf("Expected error when API key is empty, got nil")
 	}
 }
+
+func TestSyncWindowDays(t *testing.T) {
+	// Defaults apply when nothing is configured
+	config := &Config{}
+	if days := config.ProviderSyncWindowDays("rogers"); days != DefaultSyncWindowDays {
+		t.Errorf("Expected default window %d, got %d", DefaultSyncWindowDays, days)
+	}
+
+	config, err := parseTestConfig(`
+syncWindow:
+  days: 60
+  providers:
+    wealthsimple: 180
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if days := config.SyncWindowDays(); days != 60 {
+		t.Errorf("Expected global window 60, got %d", days)
+	}
+	if days := config.ProviderSyncWindowDays("rogers"); days != 60 {
+		t.Errorf("Expected rogers to use the global window 60, got %d", days)
+	}
+	if days := config.ProviderSyncWindowDays("wealthsimple"); days != 180 {
+		t.Errorf("Expected wealthsimple window 180, got %d", days)
+	}
+}
+
+func parseTestConfig(content string) (*Config, error) {
+	tempDir, err := os.MkdirTemp("", "config-test")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		return nil, err
+	}
+	return LoadConfig(configPath)
+}
+
+func TestAccountMappingRules(t *testing.T) {
+	config, err := parseTestConfig(`
+accountMappings:
+  - name: "Visa"
+    lunchMoneyId: 1
+  - glob: "TFSA*"
+    ignore: true
+  - regex: "^ws-.*-cash$"
+    lunchMoneyId: 2
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.AccountMappings) != 3 {
+		t.Fatalf("Expected 3 account mapping rules, got %d", len(config.AccountMappings))
+	}
+
+	tests := []struct {
+		rule    int
+		name    string
+		matches bool
+	}{
+		{0, "Visa", true},
+		{0, "Visa Infinite", false},
+		{1, "TFSA 2024", true},
+		{1, "RRSP", false},
+		{2, "ws-123-cash", true},
+		{2, "ws-123-cash-usd", false},
+	}
+	for _, tt := range tests {
+		if got := config.AccountMappings[tt.rule].Matches(tt.name); got != tt.matches {
+			t.Errorf("Rule %d matching %q: expected %v, got %v", tt.rule, tt.name, tt.matches, got)
+		}
+	}
+
+	invalid := []string{
+		"accountMappings:\n  - name: Visa\n",
+		"accountMappings:\n  - name: Visa\n    glob: Visa*\n    lunchMoneyId: 1\n",
+		"accountMappings:\n  - name: Visa\n    lunchMoneyId: 1\n    ignore: true\n",
+		"accountMappings:\n  - regex: \"(\"\n    ignore: true\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid rule:\n%s", content)
+		}
+	}
+}
+
+func TestCSVProfiles(t *testing.T) {
+	config, err := parseTestConfig(`
+csvProfiles:
+  rbc:
+    dateFormat: "1/2/2006"
+    dateColumn: "Transaction Date"
+    descriptionColumn: "Description 1"
+    amountColumn: "CAD$"
+  td:
+    noHeader: true
+    dateColumn: "1"
+    descriptionColumn: "2"
+    debitColumn: "3"
+    creditColumn: "4"
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	profile, err := config.GetCSVProfile("rbc")
+	if err != nil {
+		t.Fatalf("Failed to get profile: %v", err)
+	}
+	if profile.GetDateFormat() != "1/2/2006" || profile.GetDelimiter() != ',' || profile.GetCurrency() != "CAD" {
+		t.Errorf("Unexpected profile defaults: %+v", profile)
+	}
+	if _, err := config.GetCSVProfile("unknown"); err == nil {
+		t.Errorf("Expected error for unknown profile")
+	}
+
+	invalid := []string{
+		"csvProfiles:\n  a:\n    dateColumn: Date\n    amountColumn: Amount\n",
+		"csvProfiles:\n  a:\n    dateColumn: Date\n    descriptionColumn: Desc\n",
+		"csvProfiles:\n  a:\n    dateColumn: Date\n    descriptionColumn: Desc\n    amountColumn: Amount\n    debitColumn: Debit\n",
+		"csvProfiles:\n  a:\n    noHeader: true\n    dateColumn: Date\n    descriptionColumn: \"2\"\n    amountColumn: \"3\"\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid profile:\n%s", content)
+		}
+	}
+}
+
+func TestMerchantRules(t *testing.T) {
+	config, err := parseTestConfig(`
+merchantRules:
+  - contains: "AMZN MKTP"
+    name: "Amazon"
+  - regex: "(?i)^uber\\s*eats"
+    name: "Uber Eats"
+    priority: 10
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.MerchantRules) != 2 || config.MerchantRules[1].Priority != 10 {
+		t.Fatalf("Unexpected merchant rules: %+v", config.MerchantRules)
+	}
+
+	invalid := []string{
+		"merchantRules:\n  - contains: AMZN\n",
+		"merchantRules:\n  - name: Amazon\n",
+		"merchantRules:\n  - contains: AMZN\n    regex: AMZN\n    name: Amazon\n",
+		"merchantRules:\n  - regex: \"(\"\n    name: Amazon\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid rule:\n%s", content)
+		}
+	}
+}
+
+func TestCategoryRules(t *testing.T) {
+	config, err := parseTestConfig(`
+categoryRules:
+  - categoryCode: "5411"
+    categoryId: 20
+  - contains: "starbucks"
+    categoryId: 10
+  - regex: "(?i)^amazon"
+    uncategorized: true
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.CategoryRules) != 3 {
+		t.Fatalf("Expected 3 category rules, got %d", len(config.CategoryRules))
+	}
+
+	tests := []struct {
+		rule     int
+		code     string
+		merchant string
+		matches  bool
+	}{
+		{0, "5411", "Loblaws", true},
+		{0, "5412", "Loblaws", false},
+		{1, "", "STARBUCKS #123", true},
+		{1, "5814", "Tim Hortons", false},
+		{2, "", "Amazon.ca", true},
+		{2, "", "Not Amazon", false},
+	}
+	for _, tt := range tests {
+		if got := config.CategoryRules[tt.rule].Matches(tt.code, tt.merchant); got != tt.matches {
+			t.Errorf("Rule %d matching %q %q: expected %v, got %v", tt.rule, tt.code, tt.merchant, tt.matches, got)
+		}
+	}
+
+	invalid := []string{
+		"categoryRules:\n  - categoryCode: \"5411\"\n",
+		"categoryRules:\n  - categoryCode: \"5411\"\n    contains: food\n    categoryId: 1\n",
+		"categoryRules:\n  - contains: food\n    categoryId: 1\n    uncategorized: true\n",
+		"categoryRules:\n  - regex: \"(\"\n    uncategorized: true\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid rule:\n%s", content)
+		}
+	}
+}
+
+func TestTagRules(t *testing.T) {
+	config, err := parseTestConfig(`
+tagRules:
+  - account: "Corporate Visa"
+    tags: ["reimbursable"]
+  - regex: "(?i)^air canada"
+    tags: ["travel", "reimbursable"]
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.TagRules) != 2 || len(config.TagRules[1].Tags) != 2 {
+		t.Fatalf("Unexpected tag rules: %+v", config.TagRules)
+	}
+
+	invalid := []string{
+		"tagRules:\n  - contains: uber\n",
+		"tagRules:\n  - tags: [travel]\n",
+		"tagRules:\n  - contains: uber\n    account: Visa\n    tags: [travel]\n",
+		"tagRules:\n  - contains: uber\n    tags: [\" \"]\n",
+		"tagRules:\n  - regex: \"(\"\n    tags: [travel]\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid rule:\n%s", content)
+		}
+	}
+}
+
+func TestTransferOptions(t *testing.T) {
+	config, err := parseTestConfig("transfers:\n  categoryId: 30\n")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Transfers.CategoryId != 30 || config.Transfers.Tolerance() != DefaultTransferToleranceDays {
+		t.Errorf("Unexpected transfer options: %+v", config.Transfers)
+	}
+
+	config, err = parseTestConfig("transfers:\n  toleranceDays: 5\n")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Transfers.Tolerance() != 5 {
+		t.Errorf("Expected a tolerance of 5 days, got %d", config.Transfers.Tolerance())
+	}
+
+	for _, content := range []string{"transfers:\n  toleranceDays: -1\n", "transfers:\n  categoryId: -2\n"} {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid transfer options:\n%s", content)
+		}
+	}
+}
+
+func TestDuplicateOptions(t *testing.T) {
+	config, err := parseTestConfig("lunchMoneyApiKey: key\n")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Duplicates.DateTolerance() != DefaultDuplicateDateToleranceDays ||
+		config.Duplicates.MatchThreshold() != DefaultDuplicateThreshold ||
+		config.Duplicates.AmbiguityThreshold() != DefaultDuplicateAmbiguousThreshold {
+		t.Errorf("Unexpected default duplicate options: %+v", config.Duplicates)
+	}
+
+	config, err = parseTestConfig("duplicates:\n  threshold: 0.5\n")
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Duplicates.AmbiguityThreshold() != 0.5 {
+		t.Errorf("Expected the ambiguous threshold not to exceed the threshold, got %.2f", config.Duplicates.AmbiguityThreshold())
+	}
+
+	invalid := []string{
+		"duplicates:\n  dateToleranceDays: -1\n",
+		"duplicates:\n  threshold: 1.5\n",
+		"duplicates:\n  ambiguousThreshold: -0.1\n",
+		"duplicates:\n  threshold: 0.7\n  ambiguousThreshold: 0.9\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid duplicate options:\n%s", content)
+		}
+	}
+}
+
+func TestDaemonOptions(t *testing.T) {
+	config, err := parseTestConfig(`daemon:
+  schedule: "0 */6 * * *"
+  providers:
+    rogers: 12h
+  retryBackoff: 2m
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if schedule := config.Daemon.ProviderSchedule("rogers"); schedule != "12h" {
+		t.Errorf("Expected the rogers schedule to be 12h, got %s", schedule)
+	}
+	if schedule := config.Daemon.ProviderSchedule("scotia"); schedule != "0 */6 * * *" {
+		t.Errorf("Expected scotia to use the global schedule, got %s", schedule)
+	}
+	backoff := config.Daemon.Backoff()
+	if backoff.Initial != 2*time.Minute || backoff.Max != DefaultMaxRetryBackoff {
+		t.Errorf("Unexpected backoff: %+v", backoff)
+	}
+
+	invalid := []string{
+		"daemon:\n  schedule: sometimes\n",
+		"daemon:\n  providers:\n    rogers: \"61 * * * *\"\n",
+		"daemon:\n  retryBackoff: -1m\n",
+		"daemon:\n  retryBackoff: 1h\n  maxRetryBackoff: 1m\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid daemon options:\n%s", content)
+		}
+	}
+}
+
+func TestNotificationOptions(t *testing.T) {
+	config, err := parseTestConfig(`notifications:
+  largeTransactionAmount: 1000
+  sinks:
+    - type: ntfy
+      url: https://ntfy.sh/sandwich
+      events: [auth_failure, sync_error]
+    - type: email
+      host: smtp.example.com
+      from: sandwich@example.com
+      to: [me@example.com]
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Notifications.Repeat() != DefaultNotificationRepeatAfter {
+		t.Errorf("Expected the default repeat interval, got %v", config.Notifications.Repeat())
+	}
+	routes, err := config.Notifications.Routes()
+	if err != nil {
+		t.Fatalf("Failed to build notification routes: %v", err)
+	}
+	if len(routes) != 2 || len(routes[0].Kinds) != 2 || len(routes[1].Kinds) != 0 {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+	if email, ok := routes[1].Sink.(*notify.EmailSink); !ok || email.Port != 587 {
+		t.Errorf("Expected an email sink on the submission port, got %+v", routes[1].Sink)
+	}
+
+	invalid := []string{
+		"notifications:\n  sinks:\n    - type: pager\n",
+		"notifications:\n  sinks:\n    - type: webhook\n",
+		"notifications:\n  sinks:\n    - type: command\n      command: [notify-send]\n      events: [everything]\n",
+		"notifications:\n  repeatAfter: -1h\n",
+	}
+	for _, content := range invalid {
+		if _, err := parseTestConfig(content); err == nil {
+			t.Errorf("Expected error for invalid notification options:\n%s", content)
+		}
+	}
+}
+
+// memorySecrets is a SecretStore kept in memory
+type memorySecrets map[string]string
+
+func (m memorySecrets) Get(name string) (string, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+func (m memorySecrets) Set(name string, value string) {
+	m[name] = value
+}
+
+func (m memorySecrets) Save() error {
+	return nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	config, err := parseTestConfig(`lunchMoneyApiKey: secret://lunchmoney
+rogers:
+  username: me@example.com
+  password: secret://rogers
+wealthsimple:
+  prevSession: secret://wealthsimple-session
+notifications:
+  sinks:
+    - type: webhook
+      url: https://example.com/hook
+      headers:
+        Authorization: secret://hook
+`)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	refs := config.SecretRefs()
+	if len(refs) != 4 {
+		t.Errorf("Expected 4 secret references, got %v", refs)
+	}
+
+	if err := config.ResolveSecrets(memorySecrets{"lunchmoney": "api-key"}); err == nil {
+		t.Errorf("Expected an error for secrets missing from the vault")
+	}
+
+	store := memorySecrets{"lunchmoney": "api-key", "rogers": "hunter2", "hook": "Bearer hook"}
+	if err := config.ResolveSecrets(store); err != nil {
+		t.Fatalf("Failed to resolve secrets: %v", err)
+	}
+	if config.LunchMoneyAPIKey != "api-key" || config.RogersApiOptions.Password != "hunter2" ||
+		config.RogersApiOptions.Username != "me@example.com" {
+		t.Errorf("Unexpected resolved credentials: %+v", config.RogersApiOptions)
+	}
+	if config.Notifications.Sinks[0].Headers["Authorization"] != "Bearer hook" {
+		t.Errorf("Expected the webhook header to be resolved")
+	}
+	if config.WealthsimpleApiOptions.PrevSession != "" {
+		t.Errorf("Expected no session before one was stored, got %q", config.WealthsimpleApiOptions.PrevSession)
+	}
+}
+
+func TestSetWealthsimplePrevSessionInVault(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	content := "lunchMoneyApiKey: secret://lunchmoney\nwealthsimple:\n  username: me@example.com\n"
+	if err := os.WriteFile("config.yaml", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := InitGlobalConfig("config.yaml"); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	store := memorySecrets{"lunchmoney": "api-key"}
+	if err := UseSecrets(store); err != nil {
+		t.Fatalf("Failed to use secrets: %v", err)
+	}
+	defer func() {
+		configMutex.Lock()
+		secretStore = nil
+		configMutex.Unlock()
+	}()
+
+	if err := SetWealthsimplePrevSession(`{"access_token":"live"}`); err != nil {
+		t.Fatalf("Failed to set session: %v", err)
+	}
+	if store[WealthsimpleSessionSecret] != `{"access_token":"live"}` {
+		t.Errorf("Expected the session in the vault, got %v", store)
+	}
+	if session, _ := GetWealthsimplePrevSession(); session != `{"access_token":"live"}` {
+		t.Errorf("Expected the session to be used right away, got %q", session)
+	}
+
+	data, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "live") || strings.Contains(string(data), "api-key") {
+		t.Errorf("Expected no secrets in config.yaml, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "secret://wealthsimple-session") ||
+		!strings.Contains(string(data), "secret://lunchmoney") {
+		t.Errorf("Expected config.yaml to reference the secrets, got:\n%s", data)
+	}
+	if info, _ := os.Stat("config.yaml"); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected config.yaml to be readable by its owner only, got %v", info.Mode().Perm())
+	}
+}
+
+func TestSetWealthsimplePrevSessionOpensVault(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.WriteFile("config.yaml", []byte("lunchMoneyApiKey: api-key\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := InitGlobalConfig("config.yaml"); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	store := memorySecrets{}
+	opened := 0
+	UseSecretStoreOpener(func() (SecretStore, error) {
+		opened++
+		return store, nil
+	})
+	defer func() {
+		configMutex.Lock()
+		secretStore, openSecretStore = nil, nil
+		configMutex.Unlock()
+	}()
+
+	for _, session := range []string{`{"access_token":"first"}`, `{"access_token":"live"}`} {
+		if err := SetWealthsimplePrevSession(session); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+	}
+	if opened != 1 {
+		t.Errorf("Expected the vault to be opened once, got %d", opened)
+	}
+	if store[WealthsimpleSessionSecret] != `{"access_token":"live"}` {
+		t.Errorf("Expected the session in the vault, got %v", store)
+	}
+	data, err := os.ReadFile("config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "live") || !strings.Contains(string(data), "secret://wealthsimple-session") {
+		t.Errorf("Expected config.yaml to reference the session, got:\n%s", data)
+	}
+
+	// without a vault the session stays in config.yaml
+	configMutex.Lock()
+	secretStore = nil
+	configMutex.Unlock()
+	UseSecretStoreOpener(func() (SecretStore, error) { return nil, nil })
+	if err := SetWealthsimplePrevSession(`{"access_token":"plain"}`); err != nil {
+		t.Fatalf("Failed to set session: %v", err)
+	}
+	if data, _ := os.ReadFile("config.yaml"); !strings.Contains(string(data), "plain") {
+		t.Errorf("Expected the session in config.yaml without a vault, got:\n%s", data)
+	}
+}
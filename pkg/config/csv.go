@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultCSVDateFormat is used by CSV profiles without a date format
+const DefaultCSVDateFormat = time.DateOnly
+
+// CSVProfile describes the columns of a bank's CSV statement. Columns are header
+// names, or 1-based column numbers when the file has no header row. Amounts come
+// either from a signed amount column or from separate debit and credit columns.
+type CSVProfile struct {
+	// DateFormat is the Go layout of the date column, e.g. "01/02/2006"
+	DateFormat string `yaml:"dateFormat,omitempty"`
+	// Delimiter separates the fields, it defaults to a comma
+	Delimiter string `yaml:"delimiter,omitempty"`
+	// SkipRows is the number of lines before the header row, or before the first
+	// transaction when there is no header
+	SkipRows int `yaml:"skipRows,omitempty"`
+	// NoHeader is set when the file starts with transactions, columns are then numbers
+	NoHeader bool `yaml:"noHeader,omitempty"`
+
+	DateColumn        string `yaml:"dateColumn"`
+	DescriptionColumn string `yaml:"descriptionColumn"`
+	// AmountColumn holds signed amounts, outflows are negative unless OutflowsPositive is set
+	AmountColumn string `yaml:"amountColumn,omitempty"`
+	// OutflowsPositive is set when the amount column has positive outflows
+	OutflowsPositive bool `yaml:"outflowsPositive,omitempty"`
+	// DebitColumn holds outflows and CreditColumn inflows, both as positive amounts
+	DebitColumn  string `yaml:"debitColumn,omitempty"`
+	CreditColumn string `yaml:"creditColumn,omitempty"`
+	// ReferenceColumn is the bank's transaction id, references are synthesized from
+	// the row content when it is not set
+	ReferenceColumn string `yaml:"referenceColumn,omitempty"`
+	// Currency of the amounts, it defaults to CAD
+	Currency string `yaml:"currency,omitempty"`
+}
+
+// Validate checks that the profile describes a date, a description and an amount
+func (p *CSVProfile) Validate() error {
+	if p.DateColumn == "" || p.DescriptionColumn == "" {
+		return fmt.Errorf("dateColumn and descriptionColumn must be set")
+	}
+	if (p.AmountColumn != "") == (p.DebitColumn != "" || p.CreditColumn != "") {
+		return fmt.Errorf("exactly one of amountColumn or debitColumn/creditColumn must be set")
+	}
+	if p.Delimiter != "" && utf8.RuneCountInString(p.Delimiter) != 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	if p.SkipRows < 0 {
+		return fmt.Errorf("skipRows must not be negative")
+	}
+
+	if p.NoHeader {
+		for _, column := range []string{p.DateColumn, p.DescriptionColumn, p.AmountColumn,
+			p.DebitColumn, p.CreditColumn, p.ReferenceColumn} {
+			if column == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(column); err != nil || n < 1 {
+				return fmt.Errorf("column %q must be a column number when noHeader is set", column)
+			}
+		}
+	}
+	return nil
+}
+
+// GetDateFormat returns the date layout of the profile
+func (p *CSVProfile) GetDateFormat() string {
+	if p.DateFormat == "" {
+		return DefaultCSVDateFormat
+	}
+	return p.DateFormat
+}
+
+// GetDelimiter returns the field separator of the profile
+func (p *CSVProfile) GetDelimiter() rune {
+	if p.Delimiter == "" {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(p.Delimiter)
+	return r
+}
+
+// GetCurrency returns the currency of the profile's amounts
+func (p *CSVProfile) GetCurrency() string {
+	if p.Currency == "" {
+		return "CAD"
+	}
+	return p.Currency
+}
+
+// GetCSVProfile returns the named CSV profile
+func (c *Config) GetCSVProfile(name string) (*CSVProfile, error) {
+	profile, ok := c.CSVProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown CSV profile %q", name)
+	}
+	return &profile, nil
+}
+
+// validateCSVProfiles checks every configured CSV profile
+func (c *Config) validateCSVProfiles() error {
+	for name, profile := range c.CSVProfiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid CSV profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
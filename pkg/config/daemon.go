@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/scheduler"
+)
+
+const (
+	// DefaultDaemonSchedule is used for the providers without a schedule
+	DefaultDaemonSchedule = "6h"
+	// DefaultRetryBackoff and DefaultMaxRetryBackoff are used when no backoff is configured
+	DefaultRetryBackoff    = time.Minute
+	DefaultMaxRetryBackoff = time.Hour
+)
+
+// DaemonOptions controls when the daemon fetches from the providers and syncs
+type DaemonOptions struct {
+	// Schedule is an interval such as "6h" or a cron expression such as "0 */6 * * *"
+	Schedule string `yaml:"schedule,omitempty"`
+	// Providers overrides Schedule for individual providers by name
+	Providers map[string]string `yaml:"providers,omitempty"`
+	// RetryBackoff is the wait before retrying a failed run, it doubles with every
+	// failure in a row up to MaxRetryBackoff
+	RetryBackoff    time.Duration `yaml:"retryBackoff,omitempty"`
+	MaxRetryBackoff time.Duration `yaml:"maxRetryBackoff,omitempty"`
+}
+
+// ProviderSchedule returns the schedule a provider is fetched on
+func (o DaemonOptions) ProviderSchedule(provider string) string {
+	if schedule, ok := o.Providers[provider]; ok && schedule != "" {
+		return schedule
+	}
+	if o.Schedule != "" {
+		return o.Schedule
+	}
+	return DefaultDaemonSchedule
+}
+
+// Backoff returns how failed runs are retried
+func (o DaemonOptions) Backoff() scheduler.Backoff {
+	backoff := scheduler.Backoff{Initial: o.RetryBackoff, Max: o.MaxRetryBackoff}
+	if backoff.Initial <= 0 {
+		backoff.Initial = DefaultRetryBackoff
+	}
+	if backoff.Max <= 0 {
+		backoff.Max = max(DefaultMaxRetryBackoff, backoff.Initial)
+	}
+	return backoff
+}
+
+// validateDaemon checks the daemon schedules and backoff
+func (c *Config) validateDaemon() error {
+	if c.Daemon.Schedule != "" {
+		if _, err := scheduler.Parse(c.Daemon.Schedule); err != nil {
+			return fmt.Errorf("invalid daemon schedule: %w", err)
+		}
+	}
+	for provider, schedule := range c.Daemon.Providers {
+		if _, err := scheduler.Parse(schedule); err != nil {
+			return fmt.Errorf("invalid daemon schedule for provider %s: %w", provider, err)
+		}
+	}
+	if c.Daemon.RetryBackoff < 0 || c.Daemon.MaxRetryBackoff < 0 {
+		return fmt.Errorf("invalid daemon backoff: must not be negative")
+	}
+	if c.Daemon.MaxRetryBackoff != 0 && c.Daemon.MaxRetryBackoff < c.Daemon.RetryBackoff {
+		return fmt.Errorf("invalid daemon backoff: maxRetryBackoff is shorter than retryBackoff")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import "fmt"
+
+const (
+	// DefaultDuplicateDateToleranceDays is used when no date tolerance is configured
+	DefaultDuplicateDateToleranceDays = 3
+	// DefaultDuplicateThreshold is used when no match threshold is configured
+	DefaultDuplicateThreshold = 0.8
+	// DefaultDuplicateAmbiguousThreshold is used when no ambiguous threshold is configured
+	DefaultDuplicateAmbiguousThreshold = 0.6
+)
+
+// DuplicateOptions controls how local transactions are matched against the ones
+// already in LunchMoney, e.g. entries synced by Plaid. Candidates must have the
+// same amount and are scored from 0 to 1 on their date, payee and account.
+type DuplicateOptions struct {
+	// DateToleranceDays is how many days apart a duplicate may be dated
+	DateToleranceDays int `yaml:"dateToleranceDays,omitempty"`
+	// Threshold is the score from which a candidate is a duplicate
+	Threshold float64 `yaml:"threshold,omitempty"`
+	// AmbiguousThreshold is the score from which a candidate needs to be confirmed
+	// before the transaction is inserted
+	AmbiguousThreshold float64 `yaml:"ambiguousThreshold,omitempty"`
+}
+
+// DateTolerance returns the number of days a duplicate may be dated apart
+func (o DuplicateOptions) DateTolerance() int {
+	if o.DateToleranceDays > 0 {
+		return o.DateToleranceDays
+	}
+	return DefaultDuplicateDateToleranceDays
+}
+
+// MatchThreshold returns the score from which a candidate is a duplicate
+func (o DuplicateOptions) MatchThreshold() float64 {
+	if o.Threshold > 0 {
+		return o.Threshold
+	}
+	return DefaultDuplicateThreshold
+}
+
+// AmbiguityThreshold returns the score from which a candidate needs to be confirmed
+func (o DuplicateOptions) AmbiguityThreshold() float64 {
+	if o.AmbiguousThreshold > 0 {
+		return o.AmbiguousThreshold
+	}
+	return min(DefaultDuplicateAmbiguousThreshold, o.MatchThreshold())
+}
+
+// validateDuplicates checks the duplicate options
+func (c *Config) validateDuplicates() error {
+	options := c.Duplicates
+	if options.DateToleranceDays < 0 {
+		return fmt.Errorf("invalid duplicates: dateToleranceDays must not be negative")
+	}
+	if options.Threshold < 0 || options.Threshold > 1 {
+		return fmt.Errorf("invalid duplicates: threshold must be between 0 and 1")
+	}
+	if options.AmbiguousThreshold < 0 || options.AmbiguousThreshold > 1 {
+		return fmt.Errorf("invalid duplicates: ambiguousThreshold must be between 0 and 1")
+	}
+	if options.AmbiguityThreshold() > options.MatchThreshold() {
+		return fmt.Errorf("invalid duplicates: ambiguousThreshold must not be above threshold")
+	}
+	return nil
+}
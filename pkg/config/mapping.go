@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// AccountMappingRule maps external accounts to a LunchMoney asset without prompting.
+// Exactly one of Name, Glob or Regex selects the external accounts and exactly one of
+// LunchMoneyId or Ignore says where their transactions go.
+type AccountMappingRule struct {
+	// Name matches the external account name exactly
+	Name string `yaml:"name,omitempty"`
+	// Glob matches the external account name with a shell pattern, e.g. "TFSA*"
+	Glob string `yaml:"glob,omitempty"`
+	// Regex matches the external account name with a regular expression
+	Regex string `yaml:"regex,omitempty"`
+	// LunchMoneyId is the LunchMoney asset the matched accounts are mapped to
+	LunchMoneyId int64 `yaml:"lunchMoneyId,omitempty"`
+	// Ignore always ignores the matched accounts
+	Ignore bool `yaml:"ignore,omitempty"`
+}
+
+// Validate checks that the rule has exactly one matcher and one target
+func (r *AccountMappingRule) Validate() error {
+	if err := validateMatchers(r.Regex,
+		ruleMatcher{"name", r.Name}, ruleMatcher{"glob", r.Glob}, ruleMatcher{"regex", r.Regex}); err != nil {
+		return err
+	}
+
+	if r.Ignore == (r.LunchMoneyId != 0) {
+		return fmt.Errorf("exactly one of lunchMoneyId or ignore must be set")
+	}
+	if r.LunchMoneyId < 0 {
+		return fmt.Errorf("lunchMoneyId must be positive, use ignore to ignore accounts")
+	}
+
+	if r.Glob != "" {
+		if _, err := path.Match(r.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", r.Glob, err)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the rule applies to the external account name
+func (r *AccountMappingRule) Matches(externalName string) bool {
+	switch {
+	case r.Name != "":
+		return r.Name == externalName
+	case r.Glob != "":
+		matched, err := path.Match(r.Glob, externalName)
+		return err == nil && matched
+	case r.Regex != "":
+		re, err := regexp.Compile(r.Regex)
+		return err == nil && re.MatchString(externalName)
+	}
+	return false
+}
+
+// String describes the rule's matcher
+func (r *AccountMappingRule) String() string {
+	switch {
+	case r.Name != "":
+		return "name " + r.Name
+	case r.Glob != "":
+		return "glob " + r.Glob
+	}
+	return "regex " + r.Regex
+}
+
+// validateAccountMappings checks every configured account mapping rule
+func (c *Config) validateAccountMappings() error {
+	for i := range c.AccountMappings {
+		if err := c.AccountMappings[i].Validate(); err != nil {
+			return fmt.Errorf("invalid account mapping rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ruleMatcher is one of the ways a rule selects what it applies to, Key is its YAML name
+type ruleMatcher struct {
+	Key   string
+	Value string
+}
+
+// validateMatchers checks that exactly one of the matchers is set, and that regex
+// compiles when it is set
+func validateMatchers(regex string, matchers ...ruleMatcher) error {
+	set := 0
+	keys := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		keys[i] = matcher.Key
+		if matcher.Value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		last := len(keys) - 1
+		return fmt.Errorf("exactly one of %s or %s must be set", strings.Join(keys[:last], ", "), keys[last])
+	}
+
+	if regex != "" {
+		if _, err := regexp.Compile(regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", regex, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+)
+
+// MerchantRule rewrites the merchant names it matches into a canonical payee.
+// Exactly one of Contains or Regex selects the names, rules with a higher priority
+// are tried first and rules with the same priority in the order they are configured.
+type MerchantRule struct {
+	// Contains matches names containing the text, ignoring case
+	Contains string `yaml:"contains,omitempty"`
+	// Regex matches names with a regular expression, use (?i) to ignore case
+	Regex string `yaml:"regex,omitempty"`
+	// Name is the canonical payee, regex rules can refer to groups like $1
+	Name     string `yaml:"name"`
+	Priority int    `yaml:"priority,omitempty"`
+}
+
+// Validate checks that the rule has exactly one matcher and a name
+func (r *MerchantRule) Validate() error {
+	if err := validateMatchers(r.Regex, ruleMatcher{"contains", r.Contains}, ruleMatcher{"regex", r.Regex}); err != nil {
+		return err
+	}
+	if r.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	return nil
+}
+
+// String describes the rule's matcher
+func (r *MerchantRule) String() string {
+	if r.Contains != "" {
+		return "contains " + r.Contains
+	}
+	return "regex " + r.Regex
+}
+
+// validateMerchantRules checks every configured merchant rule
+func (c *Config) validateMerchantRules() error {
+	for i := range c.MerchantRules {
+		if err := c.MerchantRules[i].Validate(); err != nil {
+			return fmt.Errorf("invalid merchant rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
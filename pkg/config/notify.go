@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/notify"
+)
+
+// DefaultNotificationRepeatAfter is used when no repeat interval is configured
+const DefaultNotificationRepeatAfter = 24 * time.Hour
+
+// NotificationOptions controls who is notified of failures and notable transactions
+type NotificationOptions struct {
+	// RepeatAfter is how long a failure that lasts is not notified again
+	RepeatAfter time.Duration `yaml:"repeatAfter,omitempty"`
+	// LargeTransactionAmount notifies of fetched transactions of at least this amount,
+	// in any currency, when set. Backfilled and imported transactions are not notified.
+	LargeTransactionAmount float64 `yaml:"largeTransactionAmount,omitempty"`
+	// Sinks receive the notifications
+	Sinks []NotificationSink `yaml:"sinks,omitempty"`
+}
+
+// NotificationSink is where notifications are sent, the fields used depend on its type:
+// webhook (url, headers), ntfy (url, token, priority), email (host, port, username,
+// password, from, to) or command (command)
+type NotificationSink struct {
+	Type string `yaml:"type"`
+	// Events limits the sink to some notification events, every event when empty
+	Events   []string          `yaml:"events,omitempty"`
+	URL      string            `yaml:"url,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Token    string            `yaml:"token,omitempty"`
+	Priority string            `yaml:"priority,omitempty"`
+	Host     string            `yaml:"host,omitempty"`
+	Port     int               `yaml:"port,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	From     string            `yaml:"from,omitempty"`
+	To       []string          `yaml:"to,omitempty"`
+	Command  []string          `yaml:"command,omitempty"`
+}
+
+// Repeat returns how long a failure that lasts is not notified again
+func (o NotificationOptions) Repeat() time.Duration {
+	if o.RepeatAfter > 0 {
+		return o.RepeatAfter
+	}
+	return DefaultNotificationRepeatAfter
+}
+
+// Routes returns the configured sinks with the events they receive
+func (o NotificationOptions) Routes() ([]notify.Route, error) {
+	routes := make([]notify.Route, 0, len(o.Sinks))
+	for i, sink := range o.Sinks {
+		route, err := sink.route()
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification sink %d: %w", i+1, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (s NotificationSink) route() (notify.Route, error) {
+	route := notify.Route{Name: s.Type}
+	for _, event := range s.Events {
+		kind, err := notify.ParseKind(event)
+		if err != nil {
+			return route, err
+		}
+		route.Kinds = append(route.Kinds, kind)
+	}
+
+	switch s.Type {
+	case "webhook":
+		if s.URL == "" {
+			return route, fmt.Errorf("webhook requires a url")
+		}
+		route.Sink = &notify.WebhookSink{URL: s.URL, Headers: s.Headers}
+	case "ntfy":
+		if s.URL == "" {
+			return route, fmt.Errorf("ntfy requires a url")
+		}
+		route.Sink = &notify.NtfySink{URL: s.URL, Token: s.Token, Priority: s.Priority}
+	case "email":
+		if s.Host == "" || s.From == "" || len(s.To) == 0 {
+			return route, fmt.Errorf("email requires a host, from and to")
+		}
+		port := s.Port
+		if port == 0 {
+			port = 587
+		}
+		route.Sink = &notify.EmailSink{
+			Host: s.Host, Port: port, Username: s.Username, Password: s.Password, From: s.From, To: s.To,
+		}
+	case "command":
+		if len(s.Command) == 0 {
+			return route, fmt.Errorf("command requires a command")
+		}
+		route.Sink = &notify.CommandSink{Command: s.Command}
+	default:
+		return route, fmt.Errorf("unknown type %q, must be webhook, ntfy, email or command", s.Type)
+	}
+	return route, nil
+}
+
+// validateNotifications checks the notification sinks
+func (c *Config) validateNotifications() error {
+	if c.Notifications.RepeatAfter < 0 {
+		return fmt.Errorf("invalid notifications: repeatAfter must not be negative")
+	}
+	if c.Notifications.LargeTransactionAmount < 0 {
+		return fmt.Errorf("invalid notifications: largeTransactionAmount must not be negative")
+	}
+	_, err := c.Notifications.Routes()
+	return err
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+	"github.com/vpnda/sandwich-sync/pkg/secrets"
+)
+
+// WealthsimpleSessionSecret names the secret the Wealthsimple session is kept in,
+// unless prevSession references another one
+const WealthsimpleSessionSecret = "wealthsimple-session"
+
+// SecretStore holds the secrets the configuration references as secret://<name>
+type SecretStore interface {
+	Get(name string) (string, bool)
+	Set(name string, value string)
+	Save() error
+}
+
+// secretStore keeps the session tokens once UseSecrets was called, it is guarded by configMutex
+var secretStore SecretStore
+
+// openSecretStore opens the store the session tokens are kept in when the configuration
+// references no secret, it returns nil when there is no vault and none can be created.
+// It is guarded by configMutex.
+var openSecretStore func() (SecretStore, error)
+
+// secretFields returns the configuration values that may reference a secret
+func (c *Config) secretFields() []*string {
+	fields := []*string{
+		&c.LunchMoneyAPIKey,
+		&c.RogersApiOptions.Username,
+		&c.RogersApiOptions.Password,
+		&c.RogersApiOptions.DeviceId,
+		&c.WealthsimpleApiOptions.Username,
+		&c.WealthsimpleApiOptions.Password,
+		&c.ScotiabankOptions.Username,
+		&c.ScotiabankOptions.Password,
+	}
+	for i := range c.Notifications.Sinks {
+		sink := &c.Notifications.Sinks[i]
+		fields = append(fields, &sink.URL, &sink.Token, &sink.Username, &sink.Password)
+	}
+	return fields
+}
+
+// SecretRefs returns the names of the secrets the configuration references
+func (c *Config) SecretRefs() []string {
+	names := make([]string, 0)
+	for _, field := range append(c.secretFields(), &c.WealthsimpleApiOptions.PrevSession) {
+		if name, ok := secrets.ParseRef(*field); ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, sink := range c.Notifications.Sinks {
+		for _, value := range sink.Headers {
+			if name, ok := secrets.ParseRef(value); ok && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ResolveSecrets replaces the secret references with the secrets of store. The
+// Wealthsimple session is empty until one was stored, other secrets must exist.
+func (c *Config) ResolveSecrets(store SecretStore) error {
+	resolve := func(value string) (string, error) {
+		name, ok := secrets.ParseRef(value)
+		if !ok {
+			return value, nil
+		}
+		secret, ok := store.Get(name)
+		if !ok {
+			return "", fmt.Errorf("secret %q referenced by the configuration is not in the vault", name)
+		}
+		return secret, nil
+	}
+
+	for _, field := range c.secretFields() {
+		value, err := resolve(*field)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+	for _, sink := range c.Notifications.Sinks {
+		for header, value := range sink.Headers {
+			resolved, err := resolve(value)
+			if err != nil {
+				return err
+			}
+			sink.Headers[header] = resolved
+		}
+	}
+
+	if name, ok := secrets.ParseRef(c.WealthsimpleApiOptions.PrevSession); ok {
+		c.WealthsimpleApiOptions.PrevSession, _ = store.Get(name)
+	}
+	return nil
+}
+
+// UseSecrets resolves the secret references of the global configuration with store
+// and keeps the session tokens in it from now on
+func UseSecrets(store SecretStore) error {
+	config, err := GetConfig()
+	if err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if err := config.ResolveSecrets(store); err != nil {
+		return err
+	}
+	secretStore = store
+	return nil
+}
+
+// UseSecretStoreOpener keeps the session tokens in the store open returns, it is only
+// called once a session is stored so the vault is not unlocked for nothing
+func UseSecretStoreOpener(open func() (SecretStore, error)) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	openSecretStore = open
+}
+
+// updateConfigFile applies update to config.yaml as stored on disk, so the resolved
+// secrets are never written back
+func updateConfigFile(update func(raw *Config) error) error {
+	raw, err := LoadConfig("config.yaml")
+	if err != nil {
+		return err
+	}
+	if err := update(raw); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("error marshalling config: %w", err)
+	}
+	if err := os.WriteFile("config.yaml", data, 0600); err != nil {
+		return fmt.Errorf("error writing config file: %w", err)
+	}
+	// WriteFile keeps the permissions of an existing file
+	if err := os.Chmod("config.yaml", 0600); err != nil {
+		return fmt.Errorf("error restricting config file permissions: %w", err)
+	}
+	return nil
+}
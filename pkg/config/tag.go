@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagRule adds tags to the transactions it matches. Exactly one of Contains, Regex
+// or Account selects the transactions.
+type TagRule struct {
+	// Contains matches merchant names containing the text, ignoring case
+	Contains string `yaml:"contains,omitempty"`
+	// Regex matches merchant names with a regular expression
+	Regex string `yaml:"regex,omitempty"`
+	// Account matches the external account name, ignoring case
+	Account string   `yaml:"account,omitempty"`
+	Tags    []string `yaml:"tags"`
+}
+
+// Validate checks that the rule has exactly one matcher and at least one tag
+func (r *TagRule) Validate() error {
+	if err := validateMatchers(r.Regex,
+		ruleMatcher{"contains", r.Contains}, ruleMatcher{"regex", r.Regex}, ruleMatcher{"account", r.Account}); err != nil {
+		return err
+	}
+
+	if len(r.Tags) == 0 {
+		return fmt.Errorf("tags must be set")
+	}
+	for _, tag := range r.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("tags must not be empty")
+		}
+	}
+	return nil
+}
+
+// String describes the rule's matcher
+func (r *TagRule) String() string {
+	switch {
+	case r.Contains != "":
+		return "contains " + r.Contains
+	case r.Regex != "":
+		return "regex " + r.Regex
+	}
+	return "account " + r.Account
+}
+
+// validateTagRules checks every configured tag rule
+func (c *Config) validateTagRules() error {
+	for i := range c.TagRules {
+		if err := c.TagRules[i].Validate(); err != nil {
+			return fmt.Errorf("invalid tag rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
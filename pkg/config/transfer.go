@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// DefaultTransferToleranceDays is used when no transfer tolerance is configured
+const DefaultTransferToleranceDays = 3
+
+// TransferOptions controls how transfers between our own accounts are detected
+type TransferOptions struct {
+	// Disabled turns transfer detection off
+	Disabled bool `yaml:"disabled,omitempty"`
+	// ToleranceDays is how many days apart both sides of a transfer may be dated
+	ToleranceDays int `yaml:"toleranceDays,omitempty"`
+	// CategoryId is the LunchMoney category transfers are inserted with, e.g. a
+	// category excluded from totals. Transfers are only tagged when it is unset.
+	CategoryId int64 `yaml:"categoryId,omitempty"`
+}
+
+// Tolerance returns the number of days both sides of a transfer may be apart
+func (o TransferOptions) Tolerance() int {
+	if o.ToleranceDays > 0 {
+		return o.ToleranceDays
+	}
+	return DefaultTransferToleranceDays
+}
+
+// validateTransfers checks the transfer options
+func (c *Config) validateTransfers() error {
+	if c.Transfers.ToleranceDays < 0 {
+		return fmt.Errorf("invalid transfers: toleranceDays must not be negative")
+	}
+	if c.Transfers.CategoryId < 0 {
+		return fmt.Errorf("invalid transfers: categoryId must not be negative")
+	}
+	return nil
+}
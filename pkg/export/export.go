@@ -0,0 +1,134 @@
+// Package export writes the local transactions and balances to CSV, JSON lines
+// and ledger/hledger journal files
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSONL  Format = "jsonl"
+	FormatLedger Format = "ledger"
+)
+
+// Formats are the supported export formats
+var Formats = []Format{FormatCSV, FormatJSONL, FormatLedger}
+
+// ParseFormat returns the format named s, "json" and "hledger" are accepted as aliases
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "jsonl", "json":
+		return FormatJSONL, nil
+	case "ledger", "hledger", "journal":
+		return FormatLedger, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, supported formats are csv, jsonl and ledger", s)
+}
+
+// TransactionRecord is an exported transaction
+type TransactionRecord struct {
+	ReferenceNumber string `json:"referenceNumber"`
+	Date            string `json:"date"`
+	PostedDate      string `json:"postedDate,omitempty"`
+	// Amount is positive for outflows
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	Merchant      string `json:"merchant"`
+	Category      string `json:"category,omitempty"`
+	SourceAccount string `json:"sourceAccount"`
+	// LunchMoneyAccountID is the LunchMoney account the source account is mapped to
+	LunchMoneyAccountID int64                    `json:"lunchMoneyAccountId,omitempty"`
+	LunchMoneyID        int64                    `json:"lunchMoneyId,omitempty"`
+	SyncState           models.SyncState         `json:"syncState"`
+	Status              models.TransactionStatus `json:"status,omitempty"`
+	Notes               string                   `json:"notes,omitempty"`
+	Tags                []string                 `json:"tags,omitempty"`
+	// Liability is set when the source account is a credit card or loan
+	Liability bool `json:"liability,omitempty"`
+}
+
+// BalanceRecord is an exported account balance
+type BalanceRecord struct {
+	Account             string `json:"account"`
+	LunchMoneyAccountID int64  `json:"lunchMoneyAccountId,omitempty"`
+	Amount              string `json:"amount"`
+	Currency            string `json:"currency"`
+	// UpdatedAt is the date the balance was fetched
+	UpdatedAt string `json:"updatedAt"`
+	// Liability is set for credit cards and loans, their amount is what is owed
+	Liability bool `json:"liability,omitempty"`
+}
+
+// NewTransactionRecords builds the export records of the transactions, liabilities are
+// the LunchMoney accounts that are credit cards or loans
+func NewTransactionRecords(transactions []*models.TransactionWithAccount, mappings []*models.AccountMappingDetails,
+	liabilities map[int64]bool) []*TransactionRecord {
+	byName := make(map[string]*models.AccountMappingDetails, len(mappings))
+	for _, mapping := range mappings {
+		byName[mapping.ExternalName] = mapping
+	}
+
+	records := make([]*TransactionRecord, 0, len(transactions))
+	for _, tx := range transactions {
+		record := &TransactionRecord{
+			ReferenceNumber: tx.ReferenceNumber,
+			Date:            tx.Date,
+			PostedDate:      tx.PostedDate,
+			Amount:          tx.Amount.Value,
+			Currency:        tx.Amount.Currency,
+			SourceAccount:   tx.SourceAccountName,
+			LunchMoneyID:    max(tx.LunchMoneyID, 0),
+			Status:          tx.Status,
+			Notes:           tx.Notes,
+			Tags:            tx.Tags,
+		}
+		if tx.Merchant != nil {
+			record.Merchant = tx.Merchant.Name
+			record.Category = tx.Merchant.CategoryCode
+		}
+
+		mapping := byName[tx.SourceAccountName]
+		record.SyncState = models.SyncStateOf(&tx.Transaction, mapping != nil && mapping.IsIgnored())
+		if mapping != nil && !mapping.IsIgnored() {
+			record.LunchMoneyAccountID = mapping.LunchMoneyId
+			record.Liability = liabilities[mapping.LunchMoneyId]
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// NewBalanceRecords builds the export records of the fetched balances of the mapped accounts
+func NewBalanceRecords(mappings []*models.AccountMappingDetails, account string, liabilities map[int64]bool) []*BalanceRecord {
+	records := make([]*BalanceRecord, 0, len(mappings))
+	for _, mapping := range mappings {
+		if mapping.Balance == nil {
+			continue
+		}
+		if account != "" && !strings.EqualFold(account, mapping.ExternalName) {
+			continue
+		}
+		record := &BalanceRecord{
+			Account:             mapping.ExternalName,
+			LunchMoneyAccountID: max(mapping.LunchMoneyId, 0),
+			Amount:              mapping.Balance.Value,
+			Currency:            mapping.Balance.Currency,
+			Liability:           liabilities[mapping.LunchMoneyId],
+		}
+		if mapping.BalanceUpdatedAt != nil {
+			record.UpdatedAt = mapping.BalanceUpdatedAt.Format(time.DateOnly)
+		}
+		records = append(records, record)
+	}
+	return records
+}
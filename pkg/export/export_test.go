@@ -0,0 +1,143 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func newExportTx(ref, date, account string, lunchMoneyID int64) *models.TransactionWithAccount {
+	return &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: ref,
+			LunchMoneyID:    lunchMoneyID,
+			Amount:          models.Amount{Value: "4.50", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Coffee; Shop", CategoryCode: "Dining"},
+			Date:            date,
+			Status:          models.TransactionStatusPosted,
+		},
+		SourceAccountName: account,
+	}
+}
+
+func testRecords() []*TransactionRecord {
+	transactions := []*models.TransactionWithAccount{
+		newExportTx("TX1", "2025-04-10", "Visa", 42),
+		newExportTx("TX2", "2025-04-11", "Visa", 0),
+		newExportTx("TX3", "2025-03-01", "TFSA", 0),
+	}
+	mappings := []*models.AccountMappingDetails{
+		{AccountMapping: models.AccountMapping{ExternalName: "Visa", LunchMoneyId: 7}},
+		{AccountMapping: models.AccountMapping{ExternalName: "TFSA", LunchMoneyId: -1}},
+	}
+	return NewTransactionRecords(transactions, mappings, map[int64]bool{7: true})
+}
+
+func TestNewTransactionRecords(t *testing.T) {
+	records := testRecords()
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	states := []models.SyncState{models.SyncStateSynced, models.SyncStateUnsynced, models.SyncStateIgnored}
+	for i, state := range states {
+		if records[i].SyncState != state {
+			t.Errorf("Expected %s to be %s, got %s", records[i].ReferenceNumber, state, records[i].SyncState)
+		}
+	}
+	if records[0].LunchMoneyAccountID != 7 || records[2].LunchMoneyAccountID != 0 {
+		t.Errorf("Expected the mapped LunchMoney account, got %d and %d",
+			records[0].LunchMoneyAccountID, records[2].LunchMoneyAccountID)
+	}
+
+	if !records[0].Liability || records[2].Liability {
+		t.Errorf("Expected only the Visa records to be liabilities")
+	}
+}
+
+func TestWriteTransactions(t *testing.T) {
+	records := testRecords()[:1]
+	records[0].Notes = "Client lunch"
+	records[0].Tags = []string{"reimbursable", "source:rogers"}
+
+	var buf bytes.Buffer
+	if err := WriteTransactions(&buf, FormatCSV, records); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[1] != `TX1,2025-04-10,,4.50,CAD,Coffee; Shop,Dining,Visa,7,42,synced,posted,Client lunch,"reimbursable,source:rogers"` {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteTransactions(&buf, FormatJSONL, records); err != nil {
+		t.Fatalf("Failed to write JSON lines: %v", err)
+	}
+	var decoded TransactionRecord
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.ReferenceNumber != "TX1" {
+		t.Errorf("Unexpected JSON line %s: %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := WriteTransactions(&buf, FormatLedger, records); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	expected := "2025-04-10 * Coffee, Shop  ; ref:TX1, sync:synced, reimbursable:, source:rogers\n    Expenses:Dining  4.50 CAD\n    Liabilities:Visa\n\n"
+	if buf.String() != expected {
+		t.Errorf("Expected journal:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteBalances(t *testing.T) {
+	updatedAt := time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC)
+	mappings := []*models.AccountMappingDetails{
+		{
+			AccountMapping:   models.AccountMapping{ExternalName: "Visa: Infinite", LunchMoneyId: 7},
+			Balance:          &models.Amount{Value: "125.00", Currency: "CAD"},
+			BalanceUpdatedAt: &updatedAt,
+		},
+		{
+			AccountMapping:   models.AccountMapping{ExternalName: "Chequing", LunchMoneyId: 9},
+			Balance:          &models.Amount{Value: "980.10", Currency: "CAD"},
+			BalanceUpdatedAt: &updatedAt,
+		},
+		{AccountMapping: models.AccountMapping{ExternalName: "Never fetched", LunchMoneyId: 8}},
+	}
+	records := NewBalanceRecords(mappings, "", map[int64]bool{7: true})
+	if len(records) != 2 {
+		t.Fatalf("Expected only fetched balances, got %d", len(records))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBalances(&buf, FormatLedger, records); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	expected := "2025-04-15 * Balance\n    Liabilities:Visa- Infinite  0 CAD = -125.00 CAD\n\n" +
+		"2025-04-15 * Balance\n    Assets:Chequing  0 CAD = 980.10 CAD\n\n"
+	if buf.String() != expected {
+		t.Errorf("Expected journal:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestNegateAmount(t *testing.T) {
+	for input, expected := range map[string]string{"125.00": "-125.00", "-3.5": "3.5", "0.00": "0.00", "+7": "-7"} {
+		if got := negateAmount(input); got != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, input, got)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for input, expected := range map[string]Format{"CSV": FormatCSV, "json": FormatJSONL, "hledger": FormatLedger} {
+		if format, err := ParseFormat(input); err != nil || format != expected {
+			t.Errorf("Expected %s for %s, got %s: %v", expected, input, format, err)
+		}
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
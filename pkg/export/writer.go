@@ -0,0 +1,176 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+var transactionHeader = []string{
+	"reference_number", "date", "posted_date", "amount", "currency", "merchant", "category",
+	"source_account", "lunchmoney_account_id", "lunchmoney_id", "sync_state", "status", "notes", "tags",
+}
+
+var balanceHeader = []string{"account", "lunchmoney_account_id", "amount", "currency", "updated_at"}
+
+// WriteTransactions writes the transaction records in format
+func WriteTransactions(w io.Writer, format Format, records []*TransactionRecord) error {
+	switch format {
+	case FormatCSV:
+		rows := make([][]string, 0, len(records)+1)
+		rows = append(rows, transactionHeader)
+		for _, r := range records {
+			rows = append(rows, []string{
+				r.ReferenceNumber, r.Date, r.PostedDate, r.Amount, r.Currency, r.Merchant, r.Category,
+				r.SourceAccount, formatID(r.LunchMoneyAccountID), formatID(r.LunchMoneyID), string(r.SyncState), string(r.Status),
+				r.Notes, strings.Join(r.Tags, ","),
+			})
+		}
+		return writeCSV(w, rows)
+	case FormatJSONL:
+		return writeJSONLines(w, records)
+	case FormatLedger:
+		return writeLedgerTransactions(w, records)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// WriteBalances writes the balance records in format
+func WriteBalances(w io.Writer, format Format, records []*BalanceRecord) error {
+	switch format {
+	case FormatCSV:
+		rows := make([][]string, 0, len(records)+1)
+		rows = append(rows, balanceHeader)
+		for _, r := range records {
+			rows = append(rows, []string{r.Account, formatID(r.LunchMoneyAccountID), r.Amount, r.Currency, r.UpdatedAt})
+		}
+		return writeCSV(w, rows)
+	case FormatJSONL:
+		return writeJSONLines(w, records)
+	case FormatLedger:
+		return writeLedgerBalances(w, records)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+func writeJSONLines[T any](w io.Writer, records []T) error {
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write JSON line: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeLedgerTransactions writes a journal readable by ledger and hledger. Outflows
+// are positive, so they are booked to the expense account and taken from the
+// source account, which balances the posting without an amount. Credit cards and
+// loans are liabilities, so an outflow raises what is owed.
+func writeLedgerTransactions(w io.Writer, records []*TransactionRecord) error {
+	for _, r := range records {
+		mark := "*"
+		if r.Status == models.TransactionStatusPending {
+			mark = "!"
+		}
+		category := "Unknown"
+		if r.Category != "" {
+			category = ledgerAccountName(r.Category)
+		}
+
+		_, err := fmt.Fprintf(w, "%s %s %s  ; ref:%s, sync:%s%s\n    Expenses:%s  %s %s\n    %s:%s\n\n",
+			r.Date, mark, ledgerPayee(r.Merchant), r.ReferenceNumber, r.SyncState, ledgerTags(r.Tags),
+			category, r.Amount, r.Currency, ledgerAccountType(r.Liability), ledgerAccountName(r.SourceAccount))
+		if err != nil {
+			return fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeLedgerBalances writes the balances as balance assertions, the amount owed on
+// a liability is a negative balance
+func writeLedgerBalances(w io.Writer, records []*BalanceRecord) error {
+	for _, r := range records {
+		amount := r.Amount
+		if r.Liability {
+			amount = negateAmount(amount)
+		}
+		_, err := fmt.Fprintf(w, "%s * Balance\n    %s:%s  0 %s = %s %s\n\n",
+			r.UpdatedAt, ledgerAccountType(r.Liability), ledgerAccountName(r.Account), r.Currency, amount, r.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// ledgerAccountType returns the top level account of a source account
+func ledgerAccountType(liability bool) string {
+	if liability {
+		return "Liabilities"
+	}
+	return "Assets"
+}
+
+// negateAmount flips the sign of a decimal amount without rounding it
+func negateAmount(amount string) string {
+	amount = strings.TrimSpace(amount)
+	if negated, ok := strings.CutPrefix(amount, "-"); ok {
+		return negated
+	}
+	if strings.Trim(amount, "0.") == "" {
+		return amount
+	}
+	return "-" + strings.TrimPrefix(amount, "+")
+}
+
+// ledgerAccountName makes name usable as a journal account, which ends at two spaces
+// and uses colons to separate sub-accounts
+func ledgerAccountName(name string) string {
+	name = strings.NewReplacer(":", "-", ";", "-", "\t", " ").Replace(name)
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "Unknown"
+	}
+	return name
+}
+
+// ledgerTags appends the tags to the transaction comment, tags like "source:rogers"
+// already are name:value tags and the others become tags without a value
+func ledgerTags(tags []string) string {
+	var b strings.Builder
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.NewReplacer(",", " ", ";", " ").Replace(tag)), "-")
+		if !strings.Contains(tag, ":") {
+			tag += ":"
+		}
+		b.WriteString(", " + tag)
+	}
+	return b.String()
+}
+
+// ledgerPayee keeps the payee on the transaction line
+func ledgerPayee(payee string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(payee, ";", ",")), " ")
+}
+
+func formatID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
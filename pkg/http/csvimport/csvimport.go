@@ -0,0 +1,292 @@
+// Package csvimport reads transactions from downloaded bank CSV statements
+package csvimport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+// ReferencePrefix starts the reference numbers synthesized for rows without one
+const ReferencePrefix = "csv-"
+
+// Importer reads the transactions of one external account from a CSV statement
+type Importer struct {
+	path        string
+	profile     *config.CSVProfile
+	accountName string
+}
+
+// Ensure Importer implements http.TransactionFetcher
+var _ http.TransactionFetcher = (*Importer)(nil)
+
+// NewImporter creates an importer for the statement at path, its transactions
+// belong to the external account accountName
+func NewImporter(path string, profile *config.CSVProfile, accountName string) *Importer {
+	return &Importer{path: path, profile: profile, accountName: accountName}
+}
+
+// FetchTransactions returns the statement's transactions within window, a zero
+// window returns every transaction
+func (i *Importer) FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error) {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV statement: %w", err)
+	}
+	defer f.Close()
+
+	transactions, err := Parse(f, i.profile, i.accountName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV statement %s: %w", i.path, err)
+	}
+
+	if window.Start.IsZero() {
+		return transactions, nil
+	}
+	inWindow := make([]models.TransactionWithAccount, 0, len(transactions))
+	for _, tx := range transactions {
+		if ok, _ := window.ContainsDate(tx.Date); ok {
+			inWindow = append(inWindow, tx)
+		}
+	}
+	return inWindow, nil
+}
+
+// Parse reads the transactions of a CSV statement described by profile
+func Parse(r io.Reader, profile *config.CSVProfile, accountName string) ([]models.TransactionWithAccount, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = profile.GetDelimiter()
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if profile.SkipRows > len(records) {
+		return nil, fmt.Errorf("statement has fewer than %d rows", profile.SkipRows)
+	}
+	records = records[profile.SkipRows:]
+
+	columns, err := newColumns(profile, records)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.NoHeader && len(records) > 0 {
+		records = records[1:]
+	}
+
+	// identical rows, e.g. two coffees on the same day, get their own reference
+	occurrences := make(map[string]int)
+	transactions := make([]models.TransactionWithAccount, 0, len(records))
+	for n, record := range records {
+		line := n + profile.SkipRows + 1
+		if !profile.NoHeader {
+			line++
+		}
+		if isBlank(record) {
+			continue
+		}
+
+		tx, err := parseRecord(profile, columns, record, accountName)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if tx.ReferenceNumber == "" {
+			key := strings.Join([]string{accountName, tx.Date, tx.Amount.Value, tx.Merchant.Name}, "\x1f")
+			tx.ReferenceNumber = synthesizeReference(key, occurrences[key])
+			occurrences[key]++
+		}
+		transactions = append(transactions, *tx)
+	}
+	return transactions, nil
+}
+
+// columns holds the 0-based index of each profile column, -1 when unused
+type columns struct {
+	date, description, amount, debit, credit, reference int
+}
+
+func newColumns(profile *config.CSVProfile, records [][]string) (*columns, error) {
+	var header []string
+	if !profile.NoHeader {
+		if len(records) == 0 {
+			return nil, fmt.Errorf("statement has no header row")
+		}
+		header = records[0]
+	}
+
+	index := func(column string) (int, error) {
+		if column == "" {
+			return -1, nil
+		}
+		if profile.NoHeader {
+			n, err := strconv.Atoi(column)
+			if err != nil || n < 1 {
+				return -1, fmt.Errorf("invalid column number %q", column)
+			}
+			return n - 1, nil
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found in header %v", column, header)
+	}
+
+	var c columns
+	var err error
+	for _, col := range []struct {
+		name string
+		dest *int
+	}{
+		{profile.DateColumn, &c.date},
+		{profile.DescriptionColumn, &c.description},
+		{profile.AmountColumn, &c.amount},
+		{profile.DebitColumn, &c.debit},
+		{profile.CreditColumn, &c.credit},
+		{profile.ReferenceColumn, &c.reference},
+	} {
+		if *col.dest, err = index(col.name); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func parseRecord(profile *config.CSVProfile, c *columns, record []string, accountName string) (*models.TransactionWithAccount, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	date, err := time.Parse(profile.GetDateFormat(), field(c.date))
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", field(c.date), err)
+	}
+
+	var amount int64
+	if c.amount >= 0 {
+		amount, err = parseAmount(field(c.amount))
+		if err != nil {
+			return nil, err
+		}
+		// outflows are stored as positive amounts
+		if !profile.OutflowsPositive {
+			amount = -amount
+		}
+	} else {
+		debit, err := parseAmount(field(c.debit))
+		if err != nil {
+			return nil, err
+		}
+		credit, err := parseAmount(field(c.credit))
+		if err != nil {
+			return nil, err
+		}
+		amount = debit - credit
+	}
+
+	description := field(c.description)
+	if description == "" {
+		return nil, fmt.Errorf("empty description")
+	}
+
+	return &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: field(c.reference),
+			Amount: models.Amount{
+				Value:    formatCents(amount),
+				Currency: profile.GetCurrency(),
+			},
+			Merchant: &models.Merchant{
+				Name:    utils.Capitalize(description),
+				Address: &models.Address{},
+			},
+			Date:       date.Format(time.DateOnly),
+			PostedDate: date.Format(time.DateOnly),
+			Status:     models.TransactionStatusPosted,
+		},
+		SourceAccountName: accountName,
+	}, nil
+}
+
+// parseAmount parses amounts like "1,234.56", "$-12.00" or "(12.00)" into cents without
+// going through floats, an empty amount is zero
+func parseAmount(s string) (int64, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	negative := strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")")
+	cleaned = strings.Trim(cleaned, "()")
+	if cleaned == "" {
+		return 0, nil
+	}
+	if unsigned, ok := strings.CutPrefix(cleaned, "-"); ok {
+		negative = !negative
+		cleaned = unsigned
+	} else {
+		cleaned = strings.TrimPrefix(cleaned, "+")
+	}
+
+	whole, fraction, _ := strings.Cut(cleaned, ".")
+	if len(fraction) > 2 && strings.Trim(fraction[2:], "0") != "" {
+		return 0, fmt.Errorf("invalid amount %q: more than two decimals", s)
+	}
+	fraction = (fraction + "00")[:2]
+	if whole == "" {
+		whole = "0"
+	}
+	if strings.Trim(whole+fraction, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	cents, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+// formatCents formats cents as a decimal amount, zero is never signed
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// synthesizeReference derives a stable reference number from the content of a row
+// and how many identical rows came before it, so re-importing a statement does not
+// duplicate its transactions
+func synthesizeReference(key string, occurrence int) string {
+	sum := sha256.Sum256([]byte(key + "\x1f" + strconv.Itoa(occurrence)))
+	return ReferencePrefix + hex.EncodeToString(sum[:])[:16]
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package csvimport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestParseSignedAmounts(t *testing.T) {
+	statement := `Account Activity
+Date,Description,Amount,Transaction ID
+2025-04-10,COFFEE SHOP,-4.50,T1
+2025-04-11,PAYROLL,"1,200.00",T2
+
+`
+	profile := &config.CSVProfile{
+		SkipRows:          1,
+		DateColumn:        "Date",
+		DescriptionColumn: "Description",
+		AmountColumn:      "Amount",
+		ReferenceColumn:   "Transaction ID",
+	}
+
+	transactions, err := Parse(strings.NewReader(statement), profile, "Chequing")
+	if err != nil {
+		t.Fatalf("Failed to parse statement: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+
+	coffee := transactions[0]
+	if coffee.ReferenceNumber != "T1" || coffee.Amount.Value != "4.50" || coffee.Amount.Currency != "CAD" {
+		t.Errorf("Expected T1 to be a 4.50 CAD outflow, got %s %s %s",
+			coffee.ReferenceNumber, coffee.Amount.Value, coffee.Amount.Currency)
+	}
+	if coffee.Merchant.Name != "Coffee Shop" || coffee.Date != "2025-04-10" || coffee.SourceAccountName != "Chequing" {
+		t.Errorf("Unexpected transaction %+v", coffee)
+	}
+	if transactions[1].Amount.Value != "-1200.00" {
+		t.Errorf("Expected the payroll to be a 1200.00 inflow, got %s", transactions[1].Amount.Value)
+	}
+}
+
+func TestParseDebitCreditColumns(t *testing.T) {
+	statement := "04/10/2025;COFFEE;4.50;\n04/10/2025;COFFEE;4.50;\n04/12/2025;REFUND;;(2.00)\n"
+	profile := &config.CSVProfile{
+		DateFormat:        "01/02/2006",
+		Delimiter:         ";",
+		NoHeader:          true,
+		DateColumn:        "1",
+		DescriptionColumn: "2",
+		DebitColumn:       "3",
+		CreditColumn:      "4",
+		Currency:          "USD",
+	}
+
+	transactions, err := Parse(strings.NewReader(statement), profile, "Visa")
+	if err != nil {
+		t.Fatalf("Failed to parse statement: %v", err)
+	}
+	if len(transactions) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(transactions))
+	}
+
+	if transactions[0].Amount.Value != "4.50" || transactions[2].Amount.Value != "2.00" {
+		t.Errorf("Expected debits to be outflows, got %s and %s", transactions[0].Amount.Value, transactions[2].Amount.Value)
+	}
+
+	// identical rows get distinct references that are stable across imports
+	if !strings.HasPrefix(transactions[0].ReferenceNumber, ReferencePrefix) {
+		t.Errorf("Expected a synthesized reference, got %s", transactions[0].ReferenceNumber)
+	}
+	if transactions[0].ReferenceNumber == transactions[1].ReferenceNumber {
+		t.Errorf("Expected identical rows to get distinct references")
+	}
+	again, err := Parse(strings.NewReader(statement), profile, "Visa")
+	if err != nil {
+		t.Fatalf("Failed to parse statement again: %v", err)
+	}
+	for i := range transactions {
+		if transactions[i].ReferenceNumber != again[i].ReferenceNumber {
+			t.Errorf("Expected reference %d to be stable, got %s and %s", i, transactions[i].ReferenceNumber, again[i].ReferenceNumber)
+		}
+	}
+
+	other, err := Parse(strings.NewReader(statement), profile, "Mastercard")
+	if err != nil {
+		t.Fatalf("Failed to parse statement for another account: %v", err)
+	}
+	if other[0].ReferenceNumber == transactions[0].ReferenceNumber {
+		t.Errorf("Expected references to depend on the account")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	profile := &config.CSVProfile{DateColumn: "Date", DescriptionColumn: "Description", AmountColumn: "Amount"}
+
+	tests := []struct {
+		name      string
+		statement string
+	}{
+		{"missing column", "Date,Description\n2025-04-10,Coffee\n"},
+		{"invalid date", "Date,Description,Amount\n10/04/2025,Coffee,1.00\n"},
+		{"invalid amount", "Date,Description,Amount\n2025-04-10,Coffee,abc\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(strings.NewReader(tt.statement), profile, "Visa"); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"1,234.56", "1234.56"},
+		{"$-12.1", "-12.10"},
+		{"(12.00)", "-12.00"},
+		{"-0.00", "0.00"},
+		{".5", "0.50"},
+		{"+3", "3.00"},
+		{"0.1000", "0.10"},
+		{"", "0.00"},
+	}
+	for _, tt := range tests {
+		cents, err := parseAmount(tt.value)
+		if err != nil {
+			t.Errorf("parseAmount(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if formatted := formatCents(cents); formatted != tt.expected {
+			t.Errorf("parseAmount(%q) = %s, expected %s", tt.value, formatted, tt.expected)
+		}
+	}
+
+	for _, value := range []string{"abc", "1.234", "1.2.3", "--1", "1e3"} {
+		if _, err := parseAmount(value); err == nil {
+			t.Errorf("parseAmount(%q): expected an error", value)
+		}
+	}
+}
+
+func TestFetchTransactionsWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statement.csv")
+	statement := "Date,Description,Amount\n2025-03-01,Old,-1.00\n2025-04-10,Recent,-2.00\n"
+	if err := os.WriteFile(path, []byte(statement), 0600); err != nil {
+		t.Fatalf("Failed to write statement: %v", err)
+	}
+
+	importer := NewImporter(path, &config.CSVProfile{
+		DateColumn: "Date", DescriptionColumn: "Description", AmountColumn: "Amount",
+	}, "Visa")
+
+	all, err := importer.FetchTransactions(context.Background(), models.DateRange{})
+	if err != nil {
+		t.Fatalf("Failed to fetch transactions: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected a zero window to return every transaction, got %d", len(all))
+	}
+
+	window := models.DateRange{
+		Start: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC),
+	}
+	recent, err := importer.FetchTransactions(context.Background(), window)
+	if err != nil {
+		t.Fatalf("Failed to fetch transactions: %v", err)
+	}
+	if len(recent) != 1 || recent[0].Merchant.Name != "Recent" {
+		t.Errorf("Expected only the transaction within the window, got %+v", recent)
+	}
+}
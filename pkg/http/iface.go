@@ -12,7 +12,8 @@ type Fetcher interface {
 }
 
 type TransactionFetcher interface {
-	FetchTransactions(ctx context.Context) ([]models.TransactionWithAccount, error)
+	// FetchTransactions returns the transactions that happened within window
+	FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error)
 }
 
 type BalanceFetcher interface {
@@ -2,6 +2,8 @@ package lm
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +23,7 @@ func NewLunchMoneyClient(ctx context.Context, apiKey string) (*LunchMoneyClient,
 	if err != nil {
 		return nil, err
 	}
+	client.HTTP.Transport = &statusTransport{next: &metricsTransport{next: client.HTTP.Transport}}
 
 	return &LunchMoneyClient{
 		client: client,
@@ -46,6 +49,7 @@ func (c *LunchMoneyClient) ListAccounts(ctx context.Context) ([]models.LunchMone
 				Currency: asset.Currency,
 			},
 			BalanceLastUpdated: &asset.BalanceAsOf,
+			TypeName:           asset.TypeName,
 		})
 	}
 
@@ -75,6 +79,11 @@ func (c *LunchMoneyClient) ListTransaction(ctx context.Context, filter *lunchmon
 
 	var translatedTrns []models.Transaction
 	for _, lmTransaction := range lmTrns {
+		// Plaid transactions belong to their Plaid account, the others to an asset
+		accountID := lmTransaction.AssetID
+		if lmTransaction.PlaidAccountID != 0 {
+			accountID = lmTransaction.PlaidAccountID
+		}
 
 		translatedTrns = append(translatedTrns, models.Transaction{
 			ReferenceNumber: lmTransaction.ExternalID,
@@ -86,36 +95,134 @@ func (c *LunchMoneyClient) ListTransaction(ctx context.Context, filter *lunchmon
 				Value:    lmTransaction.Amount,
 				Currency: lmTransaction.Currency,
 			},
-			LunchMoneyID: lmTransaction.ID,
-			Date:         lmTransaction.Date,
+			LunchMoneyID:             lmTransaction.ID,
+			Date:                     lmTransaction.Date,
+			Notes:                    lmTransaction.Notes,
+			LunchMoneyAccountID:      accountID,
+			LunchMoneyAccountIsPlaid: lmTransaction.PlaidAccountID != 0,
 		})
 	}
 	return translatedTrns, nil
 }
 
+// insertTransaction mirrors lunchmoney.InsertTransaction with tags sent by name,
+// LunchMoney creates the tags that do not exist yet
+type insertTransaction struct {
+	Date       string   `json:"date"`
+	Amount     string   `json:"amount"`
+	CategoryID *int64   `json:"category_id,omitempty"`
+	Payee      string   `json:"payee,omitempty"`
+	Currency   string   `json:"currency,omitempty"`
+	AssetID    *int64   `json:"asset_id,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+	ExternalID string   `json:"external_id,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+type insertTransactionsRequest struct {
+	ApplyRules   bool                `json:"apply_rules,omitempty"`
+	Transactions []insertTransaction `json:"transactions"`
+}
+
 func (c *LunchMoneyClient) InsertTransactions(ctx context.Context, transactions []*models.TransactionWithAccountMapping) ([]int64, error) {
-	var lmTrns []lunchmoney.InsertTransaction
+	var lmTrns []insertTransaction
 	for _, transaction := range transactions {
 		// Create a new transaction object for LunchMoney
-		lmTrns = append(lmTrns, lunchmoney.InsertTransaction{
+		lmTrns = append(lmTrns, insertTransaction{
 			Date:       transaction.Date,
 			Amount:     transaction.Amount.Value,
 			Currency:   strings.ToLower(transaction.Amount.Currency),
 			ExternalID: transaction.ReferenceNumber,
 			Payee:      transaction.Merchant.Name,
 			AssetID:    &transaction.Mapping.LunchMoneyId,
+			CategoryID: lo.Ternary(transaction.CategoryID != models.Uncategorized, &transaction.CategoryID, nil),
+			Notes:      transaction.Notes,
+			Tags:       transaction.Tags,
 		})
 	}
 
 	// Insert the transaction into LunchMoney
-	response, err := c.client.InsertTransactions(ctx, lunchmoney.InsertTransactionsRequest{
+	body, err := c.client.Post(ctx, "/v1/transactions", &insertTransactionsRequest{
 		ApplyRules:   true,
 		Transactions: lmTrns,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("insert transactions: %w", err)
+	}
+
+	response := &lunchmoney.InsertTransactionsResponse{}
+	if err := json.NewDecoder(body).Decode(response); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
 
+	return response.IDs, nil
+}
+
+// ListCategories implements LunchMoneyClientInterface.
+func (c *LunchMoneyClient) ListCategories(ctx context.Context) ([]models.LunchMoneyCategory, error) {
+	lmCategories, err := c.client.GetCategories(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return response.IDs, nil
+	categories := make([]models.LunchMoneyCategory, 0, len(lmCategories))
+	for _, category := range lmCategories {
+		categories = append(categories, models.LunchMoneyCategory{
+			ID:       category.ID,
+			Name:     category.Name,
+			IsIncome: category.IsIncome,
+			IsGroup:  category.IsGroup,
+			GroupID:  category.GroupID,
+		})
+	}
+	return categories, nil
+}
+
+// updateTransaction mirrors lunchmoney.UpdateTransaction with the fields the
+// library does not expose yet, such as the amount
+type updateTransaction struct {
+	Date     *string `json:"date,omitempty"`
+	Amount   *string `json:"amount,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+	Payee    *string `json:"payee,omitempty"`
+	Notes    *string `json:"notes,omitempty"`
+	Status   *string `json:"status,omitempty"`
+	// ExternalID is the reference number of the transaction
+	ExternalID *string `json:"external_id,omitempty"`
+	AssetID    *int64  `json:"asset_id,omitempty"`
+}
+
+type updateTransactionRequest struct {
+	Transaction *updateTransaction `json:"transaction"`
+}
+
+// UpdateTransaction implements LunchMoneyClientInterface.
+func (c *LunchMoneyClient) UpdateTransaction(ctx context.Context, id int64, update *models.TransactionUpdate) error {
+	ut := &updateTransaction{
+		Date:       update.Date,
+		Payee:      update.Payee,
+		Notes:      update.Notes,
+		Status:     update.Status,
+		ExternalID: update.ExternalID,
+		AssetID:    update.AssetID,
+	}
+	if update.Amount != nil {
+		ut.Amount = &update.Amount.Value
+		ut.Currency = lo.ToPtr(strings.ToLower(update.Amount.Currency))
+	}
+
+	body, err := c.client.Put(ctx, fmt.Sprintf("/v1/transactions/%d", id), &updateTransactionRequest{Transaction: ut})
+	if err != nil {
+		return fmt.Errorf("update transaction %d: %w", id, err)
+	}
+
+	resp := &lunchmoney.UpdateTransactionResp{}
+	if err := json.NewDecoder(body).Decode(resp); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	if !resp.Updated {
+		return fmt.Errorf("transaction %d was not updated", id)
+	}
+	return nil
 }
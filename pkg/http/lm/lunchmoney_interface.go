@@ -13,6 +13,8 @@ type LunchMoneyClientInterface interface {
 	ListAccounts(ctx context.Context) ([]models.LunchMoneyAccount, error)
 	ListTransaction(ctx context.Context, filter *lunchmoney.TransactionFilters) ([]models.Transaction, error)
 	InsertTransactions(ctx context.Context, transactions []*models.TransactionWithAccountMapping) ([]int64, error)
+	UpdateTransaction(ctx context.Context, id int64, update *models.TransactionUpdate) error
+	ListCategories(ctx context.Context) ([]models.LunchMoneyCategory, error)
 
 	UpdateAccountBalance(ctx context.Context, id int64, balance models.Amount, since *time.Time) error
 }
@@ -22,3 +24,6 @@ var _ LunchMoneyClientInterface = (*LunchMoneyClient)(nil)
 
 // Ensure MockLunchMoneyClient implements LunchMoneyClientInterface
 var _ LunchMoneyClientInterface = (*MockLunchMoneyClient)(nil)
+
+// Ensure ResilientClient implements LunchMoneyClientInterface
+var _ LunchMoneyClientInterface = (*ResilientClient)(nil)
@@ -0,0 +1,58 @@
+package lm
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
+)
+
+var (
+	requestDuration = promauto.With(metrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sandwich_sync_lunchmoney_request_duration_seconds",
+		Help:    "Latency of the LunchMoney API requests.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"method", "endpoint"})
+	requestsTotal = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "sandwich_sync_lunchmoney_requests_total",
+		Help: "LunchMoney API requests by status code, the code is error when no response was received.",
+	}, []string{"method", "endpoint", "code"})
+	retriesTotal = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "sandwich_sync_lunchmoney_retries_total",
+		Help: "LunchMoney API requests retried after a failure.",
+	}, []string{"operation"})
+)
+
+// metricsTransport records the latency and status code of every request
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	endpoint := endpointOf(req.URL.Path)
+	requestDuration.WithLabelValues(req.Method, endpoint).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	requestsTotal.WithLabelValues(req.Method, endpoint, code).Inc()
+	return resp, err
+}
+
+// endpointOf replaces the IDs in a request path so the requests to an endpoint share labels
+func endpointOf(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
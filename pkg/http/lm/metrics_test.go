@@ -0,0 +1,36 @@
+package lm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
+)
+
+func TestMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &metricsTransport{next: http.DefaultTransport}}
+	resp, err := client.Get(server.URL + "/v1/assets/4521")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	b := recorder.Body
+	for _, line := range []string{
+		`sandwich_sync_lunchmoney_requests_total{code="404",endpoint="/v1/assets/:id",method="GET"} 1`,
+		`sandwich_sync_lunchmoney_request_duration_seconds_count{endpoint="/v1/assets/:id",method="GET"} 1`,
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", line, b.String())
+		}
+	}
+}
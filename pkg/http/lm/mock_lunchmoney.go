@@ -14,11 +14,20 @@ type MockLunchMoneyClient struct {
 	Accounts     []models.LunchMoneyAccount
 	Transactions []models.Transaction
 	InsertedIDs  []int64
+	Categories   []models.LunchMoneyCategory
+
+	// Transactions received by InsertTransactions
+	Inserted []*models.TransactionWithAccountMapping
+
+	// Updates received by UpdateTransaction keyed by LunchMoney ID
+	UpdatedTransactions map[int64]*models.TransactionUpdate
 
 	// Error values to return
 	ListAccountsErr       error
 	ListTransactionErr    error
 	InsertTransactionsErr error
+	UpdateTransactionErr  error
+	ListCategoriesErr     error
 }
 
 // UpdateAccountBalance implements LunchMoneyClientInterface.
@@ -51,10 +60,32 @@ func (m *MockLunchMoneyClient) ListTransaction(ctx context.Context, filter *lunc
 	return m.Transactions, nil
 }
 
+// UpdateTransaction records the update
+func (m *MockLunchMoneyClient) UpdateTransaction(ctx context.Context, id int64, update *models.TransactionUpdate) error {
+	if m.UpdateTransactionErr != nil {
+		return m.UpdateTransactionErr
+	}
+
+	if m.UpdatedTransactions == nil {
+		m.UpdatedTransactions = make(map[int64]*models.TransactionUpdate)
+	}
+	m.UpdatedTransactions[id] = update
+	return nil
+}
+
 // InsertTransactions returns the mock inserted IDs
 func (m *MockLunchMoneyClient) InsertTransactions(ctx context.Context, transactions []*models.TransactionWithAccountMapping) ([]int64, error) {
 	if m.InsertTransactionsErr != nil {
 		return nil, m.InsertTransactionsErr
 	}
+	m.Inserted = append(m.Inserted, transactions...)
 	return m.InsertedIDs, nil
 }
+
+// ListCategories returns the mock categories
+func (m *MockLunchMoneyClient) ListCategories(ctx context.Context) ([]models.LunchMoneyCategory, error) {
+	if m.ListCategoriesErr != nil {
+		return nil, m.ListCategoriesErr
+	}
+	return m.Categories, nil
+}
@@ -0,0 +1,380 @@
+package lm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icco/lunchmoney"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// StatusError is returned for LunchMoney responses that may succeed when the
+// request is retried, i.e. 429 Too Many Requests and server errors
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the server asked to wait, it is zero when it did not tell
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// statusTransport turns the responses that may succeed when retried into a *StatusError,
+// the lunchmoney library only returns the status text of failed requests
+type statusTransport struct {
+	next http.RoundTripper
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return nil, &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now))
+	}
+	return 0
+}
+
+// ResilientOptions controls how the resilient client paces and retries requests
+type ResilientOptions struct {
+	// BatchSize is the number of transactions inserted per request
+	BatchSize int
+	// RequestsPerSecond is the average request rate, Burst requests may be sent at once
+	RequestsPerSecond float64
+	Burst             int
+	// MaxRetries is how many times a failed request is retried
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry, it doubles with every
+	// retry up to MaxBackoff unless the server asks for another wait
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// InsertBatchSize is the default number of transactions inserted per request
+const InsertBatchSize = 100
+
+// DefaultResilientOptions returns the options used to talk to LunchMoney
+func DefaultResilientOptions() ResilientOptions {
+	return ResilientOptions{
+		BatchSize:         InsertBatchSize,
+		RequestsPerSecond: 4,
+		Burst:             4,
+		MaxRetries:        5,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        30 * time.Second,
+	}
+}
+
+// ResilientClient wraps a LunchMoney client with rate limiting, retries of failed
+// requests with exponential backoff and chunked transaction inserts
+type ResilientClient struct {
+	next    LunchMoneyClientInterface
+	options ResilientOptions
+	limiter *tokenBucket
+	// sleep waits for d unless ctx is done, it is replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientClient wraps next, unset options other than MaxRetries fall back to the defaults
+func NewResilientClient(next LunchMoneyClientInterface, options ResilientOptions) *ResilientClient {
+	defaults := DefaultResilientOptions()
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaults.BatchSize
+	}
+	if options.RequestsPerSecond <= 0 {
+		options.RequestsPerSecond = defaults.RequestsPerSecond
+	}
+	if options.Burst <= 0 {
+		options.Burst = defaults.Burst
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaults.InitialBackoff
+	}
+	if options.MaxBackoff < options.InitialBackoff {
+		options.MaxBackoff = max(defaults.MaxBackoff, options.InitialBackoff)
+	}
+
+	return &ResilientClient{
+		next:    next,
+		options: options,
+		limiter: newTokenBucket(options.RequestsPerSecond, options.Burst, time.Now),
+		sleep:   sleepContext,
+	}
+}
+
+// ListAccounts implements LunchMoneyClientInterface.
+func (c *ResilientClient) ListAccounts(ctx context.Context) ([]models.LunchMoneyAccount, error) {
+	var accounts []models.LunchMoneyAccount
+	err := c.retry(ctx, "list accounts", func() (err error) {
+		accounts, err = c.next.ListAccounts(ctx)
+		return err
+	})
+	return accounts, err
+}
+
+// ListTransaction implements LunchMoneyClientInterface.
+func (c *ResilientClient) ListTransaction(ctx context.Context, filter *lunchmoney.TransactionFilters) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := c.retry(ctx, "list transactions", func() (err error) {
+		transactions, err = c.next.ListTransaction(ctx, filter)
+		return err
+	})
+	return transactions, err
+}
+
+// InsertTransactions inserts the transactions in chunks of BatchSize. When a chunk
+// fails, the IDs of the transactions LunchMoney accepted before it are returned in
+// order with the error, so they can be stored before the error is handled.
+func (c *ResilientClient) InsertTransactions(ctx context.Context,
+	transactions []*models.TransactionWithAccountMapping) ([]int64, error) {
+	ids := make([]int64, 0, len(transactions))
+	for _, chunk := range lo.Chunk(transactions, c.options.BatchSize) {
+		chunkIDs, err := c.insertChunk(ctx, chunk)
+		if err != nil {
+			return ids, err
+		}
+		if len(chunkIDs) != len(chunk) {
+			return ids, fmt.Errorf("failed to insert all transactions, expected %d, got %d", len(chunk), len(chunkIDs))
+		}
+		ids = append(ids, chunkIDs...)
+		log.Debug().Int("inserted", len(ids)).Int("total", len(transactions)).Msg("Inserted transactions into LunchMoney")
+	}
+	return ids, nil
+}
+
+// insertChunk inserts a chunk of transactions with retries. A request that timed out
+// may still have been applied, LunchMoney then rejects the retry since the external
+// IDs already exist. The IDs of the existing transactions are looked up by external
+// ID instead and only the others are inserted again.
+func (c *ResilientClient) insertChunk(ctx context.Context,
+	chunk []*models.TransactionWithAccountMapping) ([]int64, error) {
+	var ids []int64
+	err := c.retry(ctx, "insert transactions", func() (err error) {
+		ids, err = c.next.InsertTransactions(ctx, chunk)
+		return err
+	})
+	if err == nil || !isDuplicateError(err) {
+		return ids, err
+	}
+
+	existing, lookupErr := c.findInserted(ctx, chunk)
+	if lookupErr != nil {
+		return nil, fmt.Errorf("%w, looking up the existing transactions failed: %v", err, lookupErr)
+	}
+	if len(existing) == 0 {
+		return nil, err
+	}
+	log.Warn().Err(err).Int("existing", len(existing)).Int("total", len(chunk)).
+		Msg("Transactions already exist in LunchMoney, using their IDs")
+
+	missing := lo.Filter(chunk, func(tx *models.TransactionWithAccountMapping, _ int) bool {
+		_, ok := existing[tx]
+		return !ok
+	})
+	if len(missing) != 0 {
+		missingIDs, err := c.insertChunk(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		if len(missingIDs) != len(missing) {
+			return nil, fmt.Errorf("failed to insert all transactions, expected %d, got %d", len(missing), len(missingIDs))
+		}
+		for i, tx := range missing {
+			existing[tx] = missingIDs[i]
+		}
+	}
+
+	ids = make([]int64, 0, len(chunk))
+	for _, tx := range chunk {
+		ids = append(ids, existing[tx])
+	}
+	return ids, nil
+}
+
+// findInserted returns the LunchMoney IDs of the transactions of chunk that already
+// exist in their LunchMoney account with their reference number as external ID
+func (c *ResilientClient) findInserted(ctx context.Context,
+	chunk []*models.TransactionWithAccountMapping) (map[*models.TransactionWithAccountMapping]int64, error) {
+	dates := lo.Map(chunk, func(tx *models.TransactionWithAccountMapping, _ int) string { return tx.Date })
+	lunchTransactions, err := c.ListTransaction(ctx, &lunchmoney.TransactionFilters{
+		StartDate: lo.ToPtr(lo.Min(dates)),
+		EndDate:   lo.ToPtr(lo.Max(dates)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[*models.TransactionWithAccountMapping]int64)
+	for _, tx := range chunk {
+		lunchTransaction, ok := lo.Find(lunchTransactions, func(lt models.Transaction) bool {
+			return tx.ReferenceNumber != "" && lt.ReferenceNumber == tx.ReferenceNumber &&
+				(tx.Mapping == nil || lt.LunchMoneyAccountID == tx.Mapping.LunchMoneyId)
+		})
+		if ok {
+			found[tx] = lunchTransaction.LunchMoneyID
+		}
+	}
+	return found, nil
+}
+
+// isDuplicateError returns whether LunchMoney rejected an insert because an external
+// ID already exists in the account
+func isDuplicateError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "already exists") || strings.Contains(message, "duplicate")
+}
+
+// UpdateTransaction implements LunchMoneyClientInterface.
+func (c *ResilientClient) UpdateTransaction(ctx context.Context, id int64, update *models.TransactionUpdate) error {
+	return c.retry(ctx, "update transaction", func() error {
+		return c.next.UpdateTransaction(ctx, id, update)
+	})
+}
+
+// ListCategories implements LunchMoneyClientInterface.
+func (c *ResilientClient) ListCategories(ctx context.Context) ([]models.LunchMoneyCategory, error) {
+	var categories []models.LunchMoneyCategory
+	err := c.retry(ctx, "list categories", func() (err error) {
+		categories, err = c.next.ListCategories(ctx)
+		return err
+	})
+	return categories, err
+}
+
+// UpdateAccountBalance implements LunchMoneyClientInterface.
+func (c *ResilientClient) UpdateAccountBalance(ctx context.Context, id int64, balance models.Amount, since *time.Time) error {
+	return c.retry(ctx, "update account balance", func() error {
+		return c.next.UpdateAccountBalance(ctx, id, balance, since)
+	})
+}
+
+// retry calls call once the rate limit allows it and retries it while it fails with
+// a retryable error, waiting as long as the server asked or with exponential backoff
+func (c *ResilientClient) retry(ctx context.Context, operation string, call func() error) error {
+	backoff := c.options.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, c.sleep); err != nil {
+			return err
+		}
+
+		err := call()
+		if err == nil {
+			return nil
+		}
+		retryAfter, retryable := retryDelay(err)
+		if !retryable || attempt >= c.options.MaxRetries {
+			return err
+		}
+
+		delay := backoff
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		backoff = min(backoff*2, c.options.MaxBackoff)
+		retriesTotal.WithLabelValues(operation).Inc()
+
+		log.Warn().Err(err).Str("operation", operation).Int("attempt", attempt+1).Dur("delay", delay).
+			Msg("LunchMoney request failed, retrying")
+		if err := c.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// retryDelay returns whether err may go away when the request is retried and how
+// long the server asked to wait first
+func retryDelay(err error) (time.Duration, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d unless ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tokenBucket allows bursts of up to burst requests and rate requests per second on average
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now(), now: now}
+}
+
+// wait takes a token, sleeping until one is available
+func (b *tokenBucket) wait(ctx context.Context, sleep func(ctx context.Context, d time.Duration) error) error {
+	for {
+		b.mu.Lock()
+		now := b.now()
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
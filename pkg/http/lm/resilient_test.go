@@ -0,0 +1,228 @@
+package lm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// flakyClient fails the insert requests with the errors in failures before
+// accepting them and assigns increasing LunchMoney IDs
+type flakyClient struct {
+	*MockLunchMoneyClient
+	failures []error
+	nextID   int64
+	requests [][]*models.TransactionWithAccountMapping
+}
+
+func (c *flakyClient) InsertTransactions(ctx context.Context,
+	transactions []*models.TransactionWithAccountMapping) ([]int64, error) {
+	c.requests = append(c.requests, transactions)
+	if len(c.failures) != 0 {
+		err := c.failures[0]
+		c.failures = c.failures[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]int64, 0, len(transactions))
+	for range transactions {
+		c.nextID++
+		ids = append(ids, c.nextID)
+	}
+	return ids, nil
+}
+
+// newTestResilientClient returns a client that records its sleeps instead of sleeping
+func newTestResilientClient(next LunchMoneyClientInterface, options ResilientOptions) (*ResilientClient, *[]time.Duration) {
+	sleeps := make([]time.Duration, 0)
+	c := NewResilientClient(next, options)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return c, &sleeps
+}
+
+func testInserts(count int) []*models.TransactionWithAccountMapping {
+	transactions := make([]*models.TransactionWithAccountMapping, 0, count)
+	for range count {
+		transactions = append(transactions, &models.TransactionWithAccountMapping{})
+	}
+	return transactions
+}
+
+func TestResilientInsertChunks(t *testing.T) {
+	next := &flakyClient{MockLunchMoneyClient: NewMockLunchMoneyClient()}
+	c, _ := newTestResilientClient(next, ResilientOptions{BatchSize: 2, Burst: 10})
+
+	ids, err := c.InsertTransactions(context.Background(), testInserts(5))
+	if err != nil {
+		t.Fatalf("Failed to insert transactions: %v", err)
+	}
+	if len(next.requests) != 3 {
+		t.Errorf("Expected 3 insert requests, got %d", len(next.requests))
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Errorf("Expected ID %d at %d, got %d", i+1, i, id)
+		}
+	}
+}
+
+func TestResilientRetries(t *testing.T) {
+	next := &flakyClient{
+		MockLunchMoneyClient: NewMockLunchMoneyClient(),
+		failures: []error{
+			&StatusError{StatusCode: http.StatusServiceUnavailable},
+			&StatusError{StatusCode: http.StatusServiceUnavailable},
+			&StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second},
+		},
+	}
+	c, sleeps := newTestResilientClient(next, ResilientOptions{
+		BatchSize: 10, Burst: 10, MaxRetries: 3, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second,
+	})
+
+	ids, err := c.InsertTransactions(context.Background(), testInserts(2))
+	if err != nil {
+		t.Fatalf("Failed to insert transactions: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected 2 IDs, got %d", len(ids))
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 7 * time.Second}
+	if len(*sleeps) != len(expected) {
+		t.Fatalf("Expected sleeps %v, got %v", expected, *sleeps)
+	}
+	for i := range expected {
+		if (*sleeps)[i] != expected[i] {
+			t.Errorf("Expected sleep %d to be %v, got %v", i, expected[i], (*sleeps)[i])
+		}
+	}
+}
+
+func TestResilientPartialInsert(t *testing.T) {
+	failed := errors.New("bad request")
+	next := &flakyClient{
+		MockLunchMoneyClient: NewMockLunchMoneyClient(),
+		failures:             []error{nil, failed},
+	}
+	c, sleeps := newTestResilientClient(next, ResilientOptions{BatchSize: 2, Burst: 10, MaxRetries: 3})
+
+	ids, err := c.InsertTransactions(context.Background(), testInserts(5))
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected the insert error, got %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected the IDs of the first chunk, got %v", ids)
+	}
+	if len(*sleeps) != 0 {
+		t.Errorf("Expected errors that are not retryable to fail at once, slept %v", *sleeps)
+	}
+}
+
+func TestResilientDuplicateInsert(t *testing.T) {
+	// the first request timed out after LunchMoney stored A, the retry is rejected
+	duplicate := errors.New("200 OK: [Key (user_external_id, asset_id)=(A, 1) already exists.]")
+	next := &flakyClient{
+		MockLunchMoneyClient: NewMockLunchMoneyClient(),
+		failures:             []error{duplicate},
+	}
+	next.Transactions = []models.Transaction{
+		{ReferenceNumber: "A", LunchMoneyID: 500, LunchMoneyAccountID: 1},
+		{ReferenceNumber: "B", LunchMoneyID: 600, LunchMoneyAccountID: 2},
+	}
+	c, _ := newTestResilientClient(next, ResilientOptions{BatchSize: 10, Burst: 10})
+
+	mapping := &models.AccountMapping{LunchMoneyId: 1}
+	transactions := []*models.TransactionWithAccountMapping{
+		{Transaction: models.Transaction{ReferenceNumber: "A", Date: "2025-05-01"}, Mapping: mapping},
+		{Transaction: models.Transaction{ReferenceNumber: "B", Date: "2025-05-02"}, Mapping: mapping},
+	}
+	ids, err := c.InsertTransactions(context.Background(), transactions)
+	if err != nil {
+		t.Fatalf("Expected the existing transaction to be looked up, got %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 500 || ids[1] != 1 {
+		t.Errorf("Expected the existing ID of A and a new ID for B, got %v", ids)
+	}
+	if len(next.requests) != 2 || len(next.requests[1]) != 1 || next.requests[1][0].ReferenceNumber != "B" {
+		t.Errorf("Expected only B to be inserted again, got %d requests", len(next.requests))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(2, 2, func() time.Time { return now })
+	sleeps := make([]time.Duration, 0)
+	sleep := func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	for range 4 {
+		if err := bucket.wait(context.Background(), sleep); err != nil {
+			t.Fatalf("Failed to wait: %v", err)
+		}
+	}
+	// the burst is free, then a request every half second
+	if len(sleeps) != 2 || sleeps[0] != 500*time.Millisecond || sleeps[1] != 500*time.Millisecond {
+		t.Errorf("Expected two half second sleeps, got %v", sleeps)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"12":                            12 * time.Second,
+		"soon":                          0,
+		"Wed, 01 Jan 2025 00:00:30 GMT": 30 * time.Second,
+		"Tue, 31 Dec 2024 23:00:00 GMT": 0,
+	}
+	for value, expected := range tests {
+		if got := parseRetryAfter(value, now); got != expected {
+			t.Errorf("parseRetryAfter(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+}
+
+func TestStatusTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &statusTransport{next: http.DefaultTransport}}
+
+	_, err := client.Get(server.URL + "/limited")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected a StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests || statusErr.RetryAfter != 3*time.Second {
+		t.Errorf("Expected 429 with a 3s Retry-After, got %+v", statusErr)
+	}
+
+	resp, err := client.Get(server.URL + "/invalid")
+	if err != nil {
+		t.Fatalf("Expected client errors to be returned as responses, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", resp.StatusCode)
+	}
+}
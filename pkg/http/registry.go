@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+)
+
+// ProviderFactory builds an authenticated Fetcher from the application configuration
+type ProviderFactory func(ctx context.Context, cfg *config.Config) (Fetcher, error)
+
+// Provider describes an institution that transactions and balances can be fetched from
+type Provider struct {
+	// Name is the unique identifier used on the command line (e.g. "rogers")
+	Name string
+	// Description is a human readable description shown in help output
+	Description string
+	// Validate checks that the provider's configuration section is usable,
+	// it is optional and a nil Validate means the provider needs no configuration
+	Validate func(cfg *config.Config) error
+	// New builds an authenticated fetcher for the provider
+	New ProviderFactory
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// Register makes a provider available by name. It is meant to be called from
+// the init function of the provider package and panics if the name is empty,
+// the factory is nil or the name is registered twice.
+func Register(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if p.Name == "" {
+		panic("http: Register provider with empty name")
+	}
+	if p.New == nil {
+		panic("http: Register provider " + p.Name + " with nil factory")
+	}
+	if _, dup := providers[p.Name]; dup {
+		panic("http: Register called twice for provider " + p.Name)
+	}
+	providers[p.Name] = p
+}
+
+// GetProvider returns the provider registered under name
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers returns all registered providers sorted by name
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// ProviderNames returns the names of all registered providers sorted alphabetically
+func ProviderNames() []string {
+	list := Providers()
+	names := make([]string, 0, len(list))
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ValidateConfig checks the provider's configuration section
+func (p Provider) ValidateConfig(cfg *config.Config) error {
+	if p.Validate == nil {
+		return nil
+	}
+	return p.Validate(cfg)
+}
+
+// NewFetcher validates the configuration and builds an authenticated fetcher
+func (p Provider) NewFetcher(ctx context.Context, cfg *config.Config) (Fetcher, error) {
+	if err := p.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration for provider %s: %w", p.Name, err)
+	}
+	return p.New(ctx, cfg)
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	errNotConfigured := errors.New("not configured")
+	factoryCalled := false
+
+	Register(Provider{
+		Name: "zz-test-provider",
+		Validate: func(cfg *config.Config) error {
+			if cfg.LunchMoneyAPIKey == "" {
+				return errNotConfigured
+			}
+			return nil
+		},
+		New: func(ctx context.Context, cfg *config.Config) (Fetcher, error) {
+			factoryCalled = true
+			return nil, nil
+		},
+	})
+
+	t.Run("Lookup registered provider", func(t *testing.T) {
+		p, ok := GetProvider("zz-test-provider")
+		assert.True(t, ok)
+		assert.Equal(t, "zz-test-provider", p.Name)
+		assert.Contains(t, ProviderNames(), "zz-test-provider")
+
+		_, ok = GetProvider("does-not-exist")
+		assert.False(t, ok)
+	})
+
+	t.Run("Factory is not called with invalid configuration", func(t *testing.T) {
+		p, _ := GetProvider("zz-test-provider")
+		_, err := p.NewFetcher(context.Background(), &config.Config{})
+		assert.ErrorIs(t, err, errNotConfigured)
+		assert.False(t, factoryCalled)
+
+		_, err = p.NewFetcher(context.Background(), &config.Config{LunchMoneyAPIKey: "key"})
+		assert.NoError(t, err)
+		assert.True(t, factoryCalled)
+	})
+
+	t.Run("Duplicate registration panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register(Provider{
+				Name: "zz-test-provider",
+				New: func(ctx context.Context, cfg *config.Config) (Fetcher, error) {
+					return nil, nil
+				},
+			})
+		})
+	})
+}
@@ -0,0 +1,40 @@
+package rogers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	iface "github.com/vpnda/sandwich-sync/pkg/http"
+)
+
+const providerName = "rogers"
+
+func init() {
+	iface.Register(iface.Provider{
+		Name:        providerName,
+		Description: "Rogers Bank credit card",
+		Validate:    validateConfig,
+		New:         newFetcher,
+	})
+}
+
+func validateConfig(cfg *config.Config) error {
+	opts := cfg.RogersApiOptions
+	if opts.Username == "" || opts.Password == "" {
+		return fmt.Errorf("rogers username and password not set in configuration")
+	}
+	if opts.DeviceId == "" {
+		return fmt.Errorf("rogers device fingerprint not set in configuration")
+	}
+	return nil
+}
+
+func newFetcher(ctx context.Context, cfg *config.Config) (iface.Fetcher, error) {
+	opts := cfg.RogersApiOptions
+	client := NewRogersBankClient(opts.DeviceId)
+	if err := client.Authenticate(ctx, opts.Username, opts.Password); err != nil {
+		return nil, fmt.Errorf("failed to authenticate Rogers client: %w", err)
+	}
+	return client, nil
+}
@@ -145,7 +145,7 @@ func (c *RogersBankClient) IsAuthenticated() bool {
 	return c.accountId != "" && c.customerId != ""
 }
 
-func (c *RogersBankClient) FetchTransactions(ctx context.Context) ([]models.TransactionWithAccount, error) {
+func (c *RogersBankClient) FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error) {
 	if !c.IsAuthenticated() {
 		return nil, fmt.Errorf("client is not authenticated")
 	}
@@ -172,11 +172,25 @@ func (c *RogersBankClient) FetchTransactions(ctx context.Context) ([]models.Tran
 
 	var result []models.TransactionWithAccount
 	for _, activity := range transactions.Activities {
+		// The activity feed only returns recent activity, filter it down to the window
+		inWindow, err := window.ContainsDate(activity.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse transaction date %s: %w", activity.Date, err)
+		}
+		if !inWindow {
+			continue
+		}
+
 		tx := models.TransactionWithAccount{
 			Transaction:       activity,
 			SourceAccountName: externalAccountName,
 		}
 		tx.Merchant.Name = utils.Capitalize(activity.Merchant.Name)
+		// Authorizations are only posted once they settle
+		tx.Status = models.TransactionStatusPosted
+		if activity.PostedDate == "" {
+			tx.Status = models.TransactionStatusPending
+		}
 		result = append(result, tx)
 	}
 
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/config"
 )
 
 type cookie struct {
@@ -80,15 +81,24 @@ var scotiaAuthPyScript string
 
 func (s *ScotiaClient) authCreate(ctx context.Context) error {
 	// Create a command with context
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
 	cmd := exec.CommandContext(ctx, "python3", "-")
 	cmd.Stdin = strings.NewReader(scotiaAuthPyScript)
+	// config.yaml may only reference the credentials in the vault, pass them resolved
+	cmd.Env = append(os.Environ(),
+		"SCOTIA_USERNAME="+cfg.ScotiabankOptions.Username,
+		"SCOTIA_PASSWORD="+cfg.ScotiabankOptions.Password)
 	log.Info().Msg("Executing Scotia authentication script")
 
 	// Run the command
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() != nil {
 			return ctx.Err()
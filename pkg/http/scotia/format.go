@@ -26,11 +26,20 @@ func formatAmount(transactionType TransactionType,
 	}
 }
 
-func formatTransactionDescription(transaction *openapiclient.ApiCreditCreditIdTransactionsGet200ResponseDataSettledInner) string {
-	switch TransactionType(transaction.GetTransactionType()) {
+func formatTransactionDescription(transactionType TransactionType, merchantName, cleanDescription string) string {
+	switch transactionType {
 	case TransactionTypeDebit:
 		// Only use merchant name on "purchases" / debit transactions
-		return utils.Capitalize(*transaction.Merchant.Name)
+		return utils.Capitalize(merchantName)
 	}
-	return utils.Capitalize(transaction.GetCleanDescription())
+	return utils.Capitalize(cleanDescription)
+}
+
+// depositStatus returns the status of a deposit account transaction, they have no
+// posted date until they settle
+func depositStatus(postedDate string) models.TransactionStatus {
+	if postedDate == "" {
+		return models.TransactionStatusPending
+	}
+	return models.TransactionStatusPosted
 }
@@ -0,0 +1,39 @@
+package scotia
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	iface "github.com/vpnda/sandwich-sync/pkg/http"
+)
+
+const providerName = "scotia"
+
+func init() {
+	iface.Register(iface.Provider{
+		Name:        providerName,
+		Description: "Scotiabank chequing and credit cards",
+		Validate:    validateConfig,
+		New:         newFetcher,
+	})
+}
+
+func validateConfig(cfg *config.Config) error {
+	// The session script is passed the credentials resolved from the vault
+	if cfg.ScotiabankOptions.Username == "" || cfg.ScotiabankOptions.Password == "" {
+		return fmt.Errorf("scotia username and password not set in configuration")
+	}
+	return nil
+}
+
+func newFetcher(ctx context.Context, _ *config.Config) (iface.Fetcher, error) {
+	client, err := NewScotiaClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Scotia client: %w", err)
+	}
+	if err := client.AuthenticateDynamic(ctx); err != nil {
+		return nil, fmt.Errorf("failed to authenticate Scotia client: %w", err)
+	}
+	return client, nil
+}
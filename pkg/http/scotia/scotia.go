@@ -47,7 +47,7 @@ var (
 )
 
 // FetchTransactions implements http.TransactionFetcher.
-func (s *ScotiaClient) FetchTransactions(ctx context.Context) ([]models.TransactionWithAccount, error) {
+func (s *ScotiaClient) FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error) {
 	resp, r, err := s.apiClient.DefaultAPI.ApiAccountsSummaryGet(ctx).Execute()
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch accounts summary: %w", err)
@@ -77,6 +77,14 @@ func (s *ScotiaClient) FetchTransactions(ctx context.Context) ([]models.Transact
 		}
 
 		for _, transaction := range transactions.GetData() {
+			inWindow, err := window.ContainsDate(*transaction.TransactionDate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse transaction date %s: %w", *transaction.TransactionDate, err)
+			}
+			if !inWindow {
+				continue
+			}
+
 			// Convert to models.TransactionWithAccount
 			transactionWithAccount := models.TransactionWithAccount{
 				SourceAccountName: AccountName(&account),
@@ -87,7 +95,9 @@ func (s *ScotiaClient) FetchTransactions(ctx context.Context) ([]models.Transact
 					Merchant: &models.Merchant{
 						Name: *transaction.CleanDescription,
 					},
-					Date: *transaction.TransactionDate,
+					Date:       *transaction.TransactionDate,
+					PostedDate: transaction.GetPostedDate(),
+					Status:     depositStatus(transaction.GetPostedDate()),
 				},
 			}
 			result = append(result, transactionWithAccount)
@@ -110,22 +120,53 @@ func (s *ScotiaClient) FetchTransactions(ctx context.Context) ([]models.Transact
 		}
 
 		for _, transaction := range transactions.GetData().Settled {
-			date, err := time.Parse("2006-01-02T15:04:05", *transaction.TransactionDate)
+			date, err := time.Parse(creditDateLayout, *transaction.TransactionDate)
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse transaction date %s: %w", *transaction.TransactionDate, err)
 			}
+			if !window.Contains(date) {
+				continue
+			}
+			transactionType := TransactionType(*transaction.TransactionType)
 			transactionWithAccount := models.TransactionWithAccount{
 				SourceAccountName: AccountName(&account),
 				Transaction: models.Transaction{
 					ReferenceNumber: *transaction.Key,
-					Amount: formatAmount(
-						TransactionType(*transaction.TransactionType),
-						transaction.GetTransactionAmount()),
+					Amount:          formatAmount(transactionType, transaction.GetTransactionAmount()),
 					Merchant: &models.Merchant{
-						Name:         formatTransactionDescription(&transaction),
+						Name: formatTransactionDescription(transactionType,
+							transaction.Merchant.GetName(), transaction.GetCleanDescription()),
 						CategoryCode: transaction.Category.GetCode(),
 					},
-					Date: date.Format(time.DateOnly),
+					Date:   date.Format(time.DateOnly),
+					Status: models.TransactionStatusPosted,
+				},
+			}
+			result = append(result, transactionWithAccount)
+		}
+
+		// Pending authorizations get a new key once they settle
+		for _, transaction := range transactions.GetData().Pending {
+			date, err := time.Parse(creditDateLayout, transaction.GetTransactionDate())
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse transaction date %s: %w", transaction.GetTransactionDate(), err)
+			}
+			if !window.Contains(date) {
+				continue
+			}
+			transactionType := TransactionType(transaction.GetTransactionType())
+			transactionWithAccount := models.TransactionWithAccount{
+				SourceAccountName: AccountName(&account),
+				Transaction: models.Transaction{
+					ReferenceNumber: transaction.GetKey(),
+					Amount:          formatAmount(transactionType, transaction.GetTransactionAmount()),
+					Merchant: &models.Merchant{
+						Name: formatTransactionDescription(transactionType,
+							transaction.Merchant.GetName(), transaction.GetCleanDescription()),
+						CategoryCode: transaction.Merchant.GetCategoryCode(),
+					},
+					Date:   date.Format(time.DateOnly),
+					Status: models.TransactionStatusPending,
 				},
 			}
 			result = append(result, transactionWithAccount)
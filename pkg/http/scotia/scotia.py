@@ -54,7 +54,9 @@ class ScotiaClient:
         # Magic found on the JS client
         self.clientId = "4ecf7e39-be56-4a66-816c-13cb94e62da5"
 
-        if os.path.exists(credentials_file):
+        if os.environ.get("SCOTIA_USERNAME") and os.environ.get("SCOTIA_PASSWORD"):
+            self.credentials = (os.environ["SCOTIA_USERNAME"], os.environ["SCOTIA_PASSWORD"])
+        elif os.path.exists(credentials_file):
             with open(credentials_file, "r") as f:
                 cred_file = yaml.safe_load(f)
                 scotia_block = cred_file.get("scotia")
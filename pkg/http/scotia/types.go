@@ -18,6 +18,9 @@ const (
 	TransactionTypeCredit TransactionType = "CREDIT"
 )
 
+// creditDateLayout is the layout of credit card transaction dates
+const creditDateLayout = "2006-01-02T15:04:05"
+
 var (
 	ErrAuthRedirect      = fmt.Errorf("got redirect")
 	ErrReadingConfigFile = fmt.Errorf("error reading config file")
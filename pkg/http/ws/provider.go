@@ -0,0 +1,39 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	iface "github.com/vpnda/sandwich-sync/pkg/http"
+)
+
+const providerName = "wealthsimple"
+
+func init() {
+	iface.Register(iface.Provider{
+		Name:        providerName,
+		Description: "Wealthsimple cash and investment accounts",
+		Validate:    validateConfig,
+		New:         newFetcher,
+	})
+}
+
+func validateConfig(cfg *config.Config) error {
+	opts := cfg.WealthsimpleApiOptions
+	if opts.PrevSession == "" && (opts.Username == "" || opts.Password == "") {
+		return fmt.Errorf("wealthsimple credentials or previous session not set in configuration")
+	}
+	if opts.StartSyncDate.IsZero() {
+		return fmt.Errorf("wealthsimple start sync date not set in configuration")
+	}
+	return nil
+}
+
+func newFetcher(ctx context.Context, _ *config.Config) (iface.Fetcher, error) {
+	client, err := NewWealthsimpleClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Wealthsimple client: %w", err)
+	}
+	return client, nil
+}
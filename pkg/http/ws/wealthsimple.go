@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -105,7 +106,9 @@ func (w *WealthsimpleClient) getActivityForAccount(ctx context.Context, account
 					Value:    client.GetFormattedAmount(&trn),
 					Currency: *trn.Currency,
 				},
-				Date: trn.OccurredAt.Format(time.DateOnly),
+				Date:   trn.OccurredAt.Format(time.DateOnly),
+				Status: activityStatus(trn.Status),
+				Tags:   activityTags(&trn),
 			},
 			SourceAccountName: account.Id,
 		})
@@ -113,8 +116,29 @@ func (w *WealthsimpleClient) getActivityForAccount(ctx context.Context, account
 	return transactions, nil
 }
 
+// activityTags tags the transaction with the activity type, e.g. "activity:internal_transfer"
+func activityTags(trn *generated.Activity) []string {
+	if trn.Type == "" {
+		return nil
+	}
+	return []string{"activity:" + strings.ToLower(string(trn.Type))}
+}
+
+// activityStatus maps the status of an activity, card purchases are "authorized"
+// or "pending" until they settle
+func activityStatus(status *string) models.TransactionStatus {
+	if status == nil {
+		return ""
+	}
+	switch strings.ToLower(*status) {
+	case "authorized", "pending", "new":
+		return models.TransactionStatusPending
+	}
+	return models.TransactionStatusPosted
+}
+
 // FetchTransactions implements http.TransactionFetcher.
-func (w *WealthsimpleClient) FetchTransactions(ctx context.Context) ([]models.TransactionWithAccount, error) {
+func (w *WealthsimpleClient) FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error) {
 	accounts, err := w.c.GetAccounts(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get accounts: %w", err)
@@ -122,8 +146,8 @@ func (w *WealthsimpleClient) FetchTransactions(ctx context.Context) ([]models.Tr
 
 	log.Info().Msgf("Found %d accounts", len(accounts))
 	var transactions []models.TransactionWithAccount
-	from := time.Now().Add(-30 * 24 * time.Hour)
-	until := time.Now()
+	from := window.Start
+	until := window.Until()
 
 	wg := sync.WaitGroup{}
 	ch := make(chan []models.TransactionWithAccount, len(accounts))
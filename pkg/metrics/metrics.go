@@ -0,0 +1,32 @@
+// Package metrics holds the Prometheus registry of this module, served on /metrics
+// or written to a file for the node_exporter textfile collector.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are the histogram buckets in seconds used for durations
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Registry is the registry the metrics of this module are registered in, it holds
+// none of the Go runtime metrics of the default registry
+var Registry = prometheus.NewRegistry()
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// WriteFile writes the metrics to path for the node_exporter textfile collector. The
+// file is replaced atomically so the collector never reads a partial file.
+func WriteFile(path string) error {
+	if err := prometheus.WriteToTextfile(path, Registry); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var testUp = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{Name: "test_up", Help: "Up."})
+
+func TestHandler(t *testing.T) {
+	testUp.Set(1)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), "# TYPE test_up gauge\ntest_up 1\n") {
+		t.Errorf("Expected the gauge to be served, got:\n%s", body)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	testUp.Set(1)
+
+	path := filepath.Join(t.TempDir(), "sandwich.prom")
+	if err := WriteFile(path); err != nil {
+		t.Fatalf("Failed to write metrics file: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), "test_up 1\n") {
+		t.Errorf("Expected the gauge in the metrics file, got:\n%s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the metrics file to be readable by the collector, got %v: %v", info.Mode(), err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be renamed, found %d files", len(entries))
+	}
+}
@@ -7,6 +7,23 @@ type AccountMapping struct {
 	// External Name is optional
 	ExternalName string
 	IsPlaid      bool
+	// FromRule is true when a configured mapping rule mapped the account
+	FromRule bool
+}
+
+// AccountMappingDetails is an account mapping with the last balance fetched for
+// its LunchMoney account
+type AccountMappingDetails struct {
+	AccountMapping
+	// Balance is the last fetched balance, it is nil when none was fetched yet
+	Balance *Amount
+	// BalanceUpdatedAt is when the balance was fetched
+	BalanceUpdatedAt *time.Time
+}
+
+// IsIgnored returns true if the external account is always ignored
+func (am *AccountMapping) IsIgnored() bool {
+	return am.LunchMoneyId < 0
 }
 
 type ExternalAccount struct {
@@ -17,6 +34,8 @@ type ExternalAccount struct {
 	Description string
 	// Balance is the balance of the account
 	Balance Amount
+	// BalanceAsOf is when the balance was reported, it is zero for a live balance
+	BalanceAsOf time.Time
 }
 
 type SyncOption int
@@ -46,6 +65,18 @@ type LunchMoneyAccount struct {
 	BalanceLastUpdated *time.Time
 	// IsPlaid indicates if the account is linked via Plaid
 	IsPlaid bool
+	// TypeName is the LunchMoney account type, e.g. cash, credit or loan
+	TypeName string
 	// Sync strategy
 	SyncStrategy SyncOption
 }
+
+// IsLiability returns true for accounts that hold what is owed, their balance is
+// the positive amount owed
+func (a *LunchMoneyAccount) IsLiability() bool {
+	switch a.TypeName {
+	case "credit", "loan", "other liability":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package models
+
+// Uncategorized is the LunchMoney category ID of transactions inserted without a category
+const Uncategorized int64 = 0
+
+// LunchMoneyCategory is a LunchMoney category as cached locally
+type LunchMoneyCategory struct {
+	// ID is the ID of the category in LunchMoney
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// IsIncome is true for categories of income
+	IsIncome bool `json:"isIncome"`
+	// IsGroup is true for category groups, transactions can not be assigned to them
+	IsGroup bool `json:"isGroup"`
+	// GroupID is the ID of the group the category belongs to, it is zero for ungrouped categories
+	GroupID int64 `json:"groupId,omitempty"`
+}
+
+// CategoryMapping maps a provider category code to a LunchMoney category
+type CategoryMapping struct {
+	CategoryCode string
+	// LunchMoneyCategoryId is Uncategorized when transactions with the code are
+	// inserted without a category
+	LunchMoneyCategoryId int64
+}
+
+// IsUncategorized returns true if transactions with the code are inserted without a category
+func (cm *CategoryMapping) IsUncategorized() bool {
+	return cm.LunchMoneyCategoryId == Uncategorized
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// DateRange is an inclusive range of calendar days
+type DateRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// LastDays returns the range covering the n days before today up to and including today
+func LastDays(n int) DateRange {
+	today := truncateToDay(time.Now())
+	return DateRange{
+		Start: today.AddDate(0, 0, -n),
+		End:   today,
+	}
+}
+
+// NewDateRange parses start and end dates in the YYYY-MM-DD format
+func NewDateRange(start, end string) (DateRange, error) {
+	s, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return DateRange{}, err
+	}
+	e, err := time.Parse(time.DateOnly, end)
+	if err != nil {
+		return DateRange{}, err
+	}
+	return DateRange{Start: s, End: e}, nil
+}
+
+// Contains returns true if the day of t is within the range
+func (r DateRange) Contains(t time.Time) bool {
+	day := truncateToDay(t)
+	return !day.Before(r.Start) && !day.After(r.End)
+}
+
+// ContainsDate parses a YYYY-MM-DD date and checks whether it is within the range
+func (r DateRange) ContainsDate(date string) (bool, error) {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return false, err
+	}
+	return r.Contains(t), nil
+}
+
+// Covers returns true if other is fully within the range
+func (r DateRange) Covers(other DateRange) bool {
+	return !other.Start.Before(r.Start) && !other.End.After(r.End)
+}
+
+// StartDate returns the start of the range in the YYYY-MM-DD format
+func (r DateRange) StartDate() string {
+	return r.Start.Format(time.DateOnly)
+}
+
+// EndDate returns the end of the range in the YYYY-MM-DD format
+func (r DateRange) EndDate() string {
+	return r.End.Format(time.DateOnly)
+}
+
+// Until returns the first instant after the range, useful for APIs with exclusive upper bounds
+func (r DateRange) Until() time.Time {
+	return r.End.AddDate(0, 0, 1)
+}
+
+func (r DateRange) String() string {
+	return r.StartDate() + ".." + r.EndDate()
+}
+
+// truncateToDay drops the time of day, keeping the calendar day of t in its own location
+func truncateToDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
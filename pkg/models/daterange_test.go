@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateRangeContainsDate(t *testing.T) {
+	r, err := NewDateRange("2025-04-01", "2025-04-30")
+	if err != nil {
+		t.Fatalf("Failed to create date range: %v", err)
+	}
+
+	testCases := []struct {
+		date     string
+		expected bool
+	}{
+		{"2025-03-31", false},
+		{"2025-04-01", true},
+		{"2025-04-15", true},
+		{"2025-04-30", true},
+		{"2025-05-01", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.date, func(t *testing.T) {
+			result, err := r.ContainsDate(tc.date)
+			if err != nil {
+				t.Fatalf("Failed to check date: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %s in %s to be %t", tc.date, r, tc.expected)
+			}
+		})
+	}
+
+	if _, err := r.ContainsDate("not-a-date"); err == nil {
+		t.Errorf("Expected error for invalid date")
+	}
+}
+
+func TestLastDays(t *testing.T) {
+	r := LastDays(30)
+	if !r.Contains(time.Now()) {
+		t.Errorf("Expected %s to contain today", r)
+	}
+	if r.Contains(time.Now().AddDate(0, 0, -31)) {
+		t.Errorf("Expected %s not to contain 31 days ago", r)
+	}
+	if !r.Covers(LastDays(10)) || r.Covers(LastDays(40)) {
+		t.Errorf("Expected %s to cover the last 10 days but not the last 40", r)
+	}
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// DuplicateRejection records that a local transaction is not the same as a
+// LunchMoney transaction the duplicate matcher found ambiguous
+type DuplicateRejection struct {
+	ReferenceNumber string    `json:"referenceNumber"`
+	LunchMoneyID    int64     `json:"lunchMoneyId"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
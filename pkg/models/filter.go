@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SyncState tells whether a transaction was pushed to LunchMoney
+type SyncState string
+
+const (
+	SyncStateSynced   SyncState = "synced"
+	SyncStateUnsynced SyncState = "unsynced"
+	// SyncStateIgnored is used for transactions of ignored accounts or marked to never sync
+	SyncStateIgnored SyncState = "ignored"
+)
+
+// ParseSyncState returns the sync state named s
+func ParseSyncState(s string) (SyncState, error) {
+	switch state := SyncState(strings.ToLower(s)); state {
+	case SyncStateSynced, SyncStateUnsynced, SyncStateIgnored:
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown sync status %q, supported statuses are synced, unsynced and ignored", s)
+}
+
+// SyncStateOf returns the sync state of a transaction, ignoredAccount is set when its
+// source account is always ignored
+func SyncStateOf(t *Transaction, ignoredAccount bool) SyncState {
+	switch {
+	case t.LunchMoneyID > 0:
+		return SyncStateSynced
+	case t.LunchMoneyID < 0 || ignoredAccount:
+		return SyncStateIgnored
+	}
+	return SyncStateUnsynced
+}
+
+// TransactionSort is the order of filtered transactions
+type TransactionSort string
+
+const (
+	SortByDate     TransactionSort = "date"
+	SortByAmount   TransactionSort = "amount"
+	SortByMerchant TransactionSort = "merchant"
+	SortByAccount  TransactionSort = "account"
+)
+
+// ParseTransactionSort returns the sort order named s
+func ParseTransactionSort(s string) (TransactionSort, error) {
+	switch sortBy := TransactionSort(strings.ToLower(s)); sortBy {
+	case SortByDate, SortByAmount, SortByMerchant, SortByAccount:
+		return sortBy, nil
+	}
+	return "", fmt.Errorf("unknown sort %q, supported sorts are date, amount, merchant and account", s)
+}
+
+// TransactionFilter selects, orders and pages local transactions, zero fields match everything
+type TransactionFilter struct {
+	// Window is the range of transaction dates, the zero range matches every date
+	Window DateRange
+	// SourceAccount matches the external account name, ignoring case
+	SourceAccount string
+	// Merchant matches a substring of the merchant name, ignoring case
+	Merchant string
+	// MerchantPattern matches the merchant name with a regular expression
+	MerchantPattern *regexp.Regexp
+	// MinAmount and MaxAmount bound the amount, outflows are positive
+	MinAmount *float64
+	MaxAmount *float64
+	SyncState SyncState
+	Currency  string
+	// Tag matches transactions with the tag, ignoring case
+	Tag string
+
+	// SortBy defaults to the most recent transactions first
+	SortBy    TransactionSort
+	Ascending bool
+	// Limit is the maximum number of transactions, zero means no limit
+	Limit  int
+	Offset int
+}
+
+// Page returns the transactions within the filter's offset and limit
+func (f *TransactionFilter) Page(transactions []*TransactionWithAccount) []*TransactionWithAccount {
+	if f.Offset >= len(transactions) {
+		return transactions[:0]
+	}
+	transactions = transactions[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(transactions) {
+		transactions = transactions[:f.Limit]
+	}
+	return transactions
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// SyncRunKind is the command a run recorded
+type SyncRunKind string
+
+const (
+	// SyncRunKindFetch is a fetch from a provider into the local database
+	SyncRunKindFetch SyncRunKind = "fetch"
+	// SyncRunKindSync is a sync from the local database to LunchMoney
+	SyncRunKindSync SyncRunKind = "sync"
+)
+
+// SyncRun is the record of a fetch or sync
+type SyncRun struct {
+	ID         int64       `json:"id"`
+	Kind       SyncRunKind `json:"kind"`
+	Provider   string      `json:"provider,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Fetched    int         `json:"fetched"`
+	Inserted   int         `json:"inserted"`
+	Updated    int         `json:"updated"`
+	Skipped    int         `json:"skipped"`
+	// Error is the error the run failed with, it is empty for successful runs
+	Error string `json:"error,omitempty"`
+}
+
+// TransactionEventType is a state change of a transaction
+type TransactionEventType string
+
+const (
+	// TransactionEventFetched is recorded when a provider returns a new transaction
+	TransactionEventFetched TransactionEventType = "fetched"
+	// TransactionEventMapped is recorded when the LunchMoney account of a transaction is chosen
+	TransactionEventMapped TransactionEventType = "mapped"
+	// TransactionEventPushed is recorded when a transaction is inserted into LunchMoney
+	TransactionEventPushed TransactionEventType = "pushed"
+	// TransactionEventLinked is recorded when a transaction is matched to an existing LunchMoney transaction
+	TransactionEventLinked TransactionEventType = "linked"
+	// TransactionEventUpdated is recorded when a transaction changes locally or in LunchMoney
+	TransactionEventUpdated TransactionEventType = "updated"
+	// TransactionEventDeleted is recorded when a transaction is removed locally
+	TransactionEventDeleted TransactionEventType = "deleted"
+)
+
+// TransactionEvent is an entry of the audit trail of a transaction
+type TransactionEvent struct {
+	ID int64 `json:"id"`
+	// RunID is the run that caused the event, it is zero for events outside of a run
+	RunID           int64                `json:"runId,omitempty"`
+	ReferenceNumber string               `json:"referenceNumber"`
+	Event           TransactionEventType `json:"event"`
+	LunchMoneyID    int64                `json:"lunchMoneyId,omitempty"`
+	Detail          string               `json:"detail,omitempty"`
+	CreatedAt       time.Time            `json:"createdAt"`
+}
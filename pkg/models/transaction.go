@@ -2,8 +2,10 @@ package models
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Rhymond/go-money"
 )
@@ -11,6 +13,8 @@ import (
 type TransactionWithAccountMapping struct {
 	Transaction
 	Mapping *AccountMapping `json:"accountMapping"`
+	// CategoryID is the LunchMoney category to insert the transaction with
+	CategoryID int64 `json:"categoryId,omitempty"`
 }
 
 type TransactionWithAccount struct {
@@ -26,6 +30,126 @@ type Transaction struct {
 	Merchant        *Merchant `json:"merchant"`
 	Date            string    `json:"date"`
 	PostedDate      string    `json:"postedDate"`
+	// Status tells whether the institution settled the transaction, it is empty
+	// when the provider does not tell
+	Status TransactionStatus `json:"status,omitempty"`
+	// Synced holds the values last pushed to LunchMoney, it is nil until the
+	// transaction has been synced
+	Synced *SyncedFields `json:"synced,omitempty"`
+	// VanishedAt is set when the provider stopped returning the transaction,
+	// e.g. a reversed charge or an expired pre-authorization
+	VanishedAt *time.Time `json:"vanishedAt,omitempty"`
+	// VanishedAction records how a vanished transaction was handled
+	VanishedAction VanishedAction `json:"vanishedAction,omitempty"`
+	// Notes and Tags are sent to LunchMoney when the transaction is inserted
+	Notes string   `json:"notes,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	// LunchMoneyAccountID is the LunchMoney asset or Plaid account of transactions
+	// listed from LunchMoney, it is not stored locally
+	LunchMoneyAccountID int64 `json:"lunchMoneyAccountId,omitempty"`
+	// LunchMoneyAccountIsPlaid tells whether LunchMoneyAccountID is a Plaid account,
+	// assets and Plaid accounts are numbered separately
+	LunchMoneyAccountIsPlaid bool `json:"lunchMoneyAccountIsPlaid,omitempty"`
+}
+
+// SourceTag is the tag added to the transactions fetched from a provider
+func SourceTag(provider string) string {
+	return "source:" + provider
+}
+
+// HasTag returns true if the transaction has the tag, ignoring case
+func (t *Transaction) HasTag(tag string) bool {
+	return slices.ContainsFunc(t.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// AddTags adds the tags the transaction does not have yet and returns true if any was added
+func (t *Transaction) AddTags(tags ...string) bool {
+	added := false
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || t.HasTag(tag) {
+			continue
+		}
+		t.Tags = append(t.Tags, tag)
+		added = true
+	}
+	return added
+}
+
+// RemoveTags removes the tags, ignoring case, and returns true if any was removed
+func (t *Transaction) RemoveTags(tags ...string) bool {
+	before := len(t.Tags)
+	t.Tags = slices.DeleteFunc(t.Tags, func(existing string) bool {
+		return slices.ContainsFunc(tags, func(tag string) bool { return strings.EqualFold(existing, strings.TrimSpace(tag)) })
+	})
+	return len(t.Tags) != before
+}
+
+// TransactionStatus is the settlement state of a transaction at the institution
+type TransactionStatus string
+
+const (
+	// TransactionStatusPending is an authorized transaction that has not settled yet,
+	// its amount, date and reference number may still change
+	TransactionStatusPending TransactionStatus = "pending"
+	// TransactionStatusPosted is a settled transaction
+	TransactionStatusPosted TransactionStatus = "posted"
+)
+
+// IsPending returns true if the institution has not settled the transaction yet
+func (t *Transaction) IsPending() bool {
+	return t.Status == TransactionStatusPending
+}
+
+// VanishedAction is how a transaction that disappeared upstream was handled
+type VanishedAction string
+
+const (
+	// VanishedActionMarked means the transaction was marked as vanished in LunchMoney
+	VanishedActionMarked VanishedAction = "marked"
+	// VanishedActionDismissed means the user handled the transaction themselves
+	VanishedActionDismissed VanishedAction = "dismissed"
+)
+
+// SyncedFields are the values of a transaction as they were last pushed to LunchMoney.
+// They tell changes made upstream apart from edits made in LunchMoney.
+type SyncedFields struct {
+	Amount string `json:"amount"`
+	Date   string `json:"date"`
+	Payee  string `json:"payee"`
+}
+
+// SyncedFieldsOf returns the values of t that are pushed to LunchMoney
+func SyncedFieldsOf(t *Transaction) *SyncedFields {
+	payee := ""
+	if t.Merchant != nil {
+		payee = t.Merchant.Name
+	}
+	return &SyncedFields{
+		Amount: t.Amount.Value,
+		Date:   t.Date,
+		Payee:  payee,
+	}
+}
+
+// TransactionUpdate holds the fields to change on a LunchMoney transaction,
+// nil fields are left untouched
+type TransactionUpdate struct {
+	Amount *Amount `json:"amount,omitempty"`
+	Date   *string `json:"date,omitempty"`
+	Payee  *string `json:"payee,omitempty"`
+	Notes  *string `json:"notes,omitempty"`
+	// Status is either "cleared" or "uncleared"
+	Status     *string `json:"status,omitempty"`
+	ExternalID *string `json:"externalId,omitempty"`
+	// AssetID moves the transaction to another LunchMoney account
+	AssetID *int64 `json:"assetId,omitempty"`
+}
+
+// IsEmpty returns true if the update does not change any field
+func (u *TransactionUpdate) IsEmpty() bool {
+	return u.Amount == nil && u.Date == nil && u.Payee == nil && u.Notes == nil && u.Status == nil &&
+		u.ExternalID == nil && u.AssetID == nil
 }
 
 // PrintFormatted prints the transaction in a formatted way
@@ -42,13 +166,16 @@ func (t *Transaction) PrintFormatted() {
 		if t.Merchant.Name != "" {
 			fmt.Printf("	Merchant Name: %s\n", t.Merchant.Name)
 		}
+		if t.Merchant.OriginalName != "" && t.Merchant.OriginalName != t.Merchant.Name {
+			fmt.Printf("	Original Description: %s\n", t.Merchant.OriginalName)
+		}
 		if t.Merchant.CategoryCode != "" {
 			fmt.Printf("	Merchant Category: %s\n", t.Merchant.CategoryCode)
 		}
 		if t.Merchant.Address != nil {
 			address := t.Merchant.Address
 			if address.City != "" || address.StateProvince != "" {
-				fmt.Printf("	Merchant Address: %s, %sn",
+				fmt.Printf("	Merchant Address: %s, %s\n",
 					address.City,
 					address.StateProvince)
 			}
@@ -60,6 +187,15 @@ func (t *Transaction) PrintFormatted() {
 	if t.PostedDate != "" {
 		fmt.Printf("	Posted Date: %s\n", t.PostedDate)
 	}
+	if t.Status != "" {
+		fmt.Printf("	Status: %s\n", t.Status)
+	}
+	if t.Notes != "" {
+		fmt.Printf("	Notes: %s\n", t.Notes)
+	}
+	if len(t.Tags) != 0 {
+		fmt.Printf("	Tags: %s\n", strings.Join(t.Tags, ", "))
+	}
 	if t.LunchMoneyID != 0 {
 		fmt.Printf("	LunchMoney ID: %d\n", t.LunchMoneyID)
 	}
@@ -90,9 +226,18 @@ func (a *Amount) ToMoney() *money.Money {
 	return money.New(intTranslation, a.Currency)
 }
 
+// Equal compares two amounts by value, ignoring formatting and the case of the currency code
+func (a *Amount) Equal(other *Amount) bool {
+	eq, err := a.ToMoney().Equals(other.ToMoney())
+	return err == nil && eq
+}
+
 // Merchant represents a merchant in a transaction
 type Merchant struct {
-	Name         string   `json:"name"`
+	Name string `json:"name"`
+	// OriginalName is the name returned by the provider when a merchant rule
+	// rewrote Name, it is empty until rules were applied
+	OriginalName string   `json:"originalName,omitempty"`
 	CategoryCode string   `json:"categoryCode"`
 	Address      *Address `json:"address"`
 }
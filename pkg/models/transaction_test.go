@@ -113,3 +113,27 @@ func TestMoneyEquals(t *testing.T) {
 		t.Errorf("Expected error when comparing different currencies")
 	}
 }
+
+func TestTransactionTags(t *testing.T) {
+	tx := &Transaction{Tags: []string{"source:rogers"}}
+
+	if !tx.AddTags("Reimbursable", " work ", "") {
+		t.Errorf("Expected tags to be added")
+	}
+	if tx.AddTags("reimbursable", "SOURCE:ROGERS") {
+		t.Errorf("Expected tags differing in case not to be added again")
+	}
+	if len(tx.Tags) != 3 || tx.Tags[2] != "work" {
+		t.Errorf("Unexpected tags %v", tx.Tags)
+	}
+	if !tx.HasTag("REIMBURSABLE") {
+		t.Errorf("Expected HasTag to ignore case")
+	}
+
+	if !tx.RemoveTags("reimbursable", "unknown") || tx.HasTag("Reimbursable") || len(tx.Tags) != 2 {
+		t.Errorf("Expected reimbursable to be removed, got %v", tx.Tags)
+	}
+	if tx.RemoveTags("unknown") {
+		t.Errorf("Expected removing a missing tag to change nothing")
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// TransferStatus is the review state of a transfer pair
+type TransferStatus string
+
+const (
+	// TransferStatusMatched is a pair found by the transfer matcher that was not reviewed yet
+	TransferStatusMatched TransferStatus = "matched"
+	// TransferStatusConfirmed is a pair confirmed as a transfer
+	TransferStatusConfirmed TransferStatus = "confirmed"
+	// TransferStatusBroken is a pair that is not a transfer, it is kept so the
+	// same transactions are not matched again
+	TransferStatusBroken TransferStatus = "broken"
+)
+
+// TransferPair links both sides of a transfer between two of our own accounts,
+// e.g. a credit card payment made from a chequing account. Following the sign
+// convention of the providers, the outflow has a positive and the inflow a
+// negative amount.
+type TransferPair struct {
+	ID               int64          `json:"id"`
+	OutflowReference string         `json:"outflowReference"`
+	InflowReference  string         `json:"inflowReference"`
+	Status           TransferStatus `json:"status"`
+	CreatedAt        time.Time      `json:"createdAt"`
+}
+
+// IsActive returns true unless the pair was broken
+func (p *TransferPair) IsActive() bool {
+	return p.Status != TransferStatusBroken
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/db"
+)
+
+// Kind is what a notification is about
+type Kind string
+
+const (
+	KindAuthFailure      Kind = "auth_failure"
+	KindFetchError       Kind = "fetch_error"
+	KindSyncError        Kind = "sync_error"
+	KindUnmappedAccount  Kind = "unmapped_account"
+	KindLargeTransaction Kind = "large_transaction"
+	KindTransferReview   Kind = "transfer_review"
+)
+
+// Kinds returns every kind of notification
+func Kinds() []Kind {
+	return []Kind{KindAuthFailure, KindFetchError, KindSyncError, KindUnmappedAccount, KindLargeTransaction, KindTransferReview}
+}
+
+// ParseKind returns the kind of notification with a name
+func ParseKind(name string) (Kind, error) {
+	if !slices.Contains(Kinds(), Kind(name)) {
+		return "", fmt.Errorf("unknown notification event %q", name)
+	}
+	return Kind(name), nil
+}
+
+// IsFailure returns true for the kinds that report a failure, they are repeated
+// while the failure lasts instead of being sent once
+func (k Kind) IsFailure() bool {
+	return k == KindAuthFailure || k == KindFetchError || k == KindSyncError
+}
+
+// Event is a notification
+type Event struct {
+	Kind Kind `json:"kind"`
+	// Key deduplicates the notifications, e.g. auth_failure:rogers
+	Key     string    `json:"key"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Sink delivers notifications
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Route sends the notifications of some kinds to a sink, every kind when Kinds is empty
+type Route struct {
+	Name  string
+	Sink  Sink
+	Kinds []Kind
+}
+
+func (r Route) accepts(kind Kind) bool {
+	return len(r.Kinds) == 0 || slices.Contains(r.Kinds, kind)
+}
+
+// Notifier sends notifications to the routes and remembers what was sent so that a
+// failure is repeated at most every repeatAfter and other events are sent once.
+// Delivery failures are logged and a nil notifier sends nothing.
+type Notifier struct {
+	database    db.DBInterface
+	routes      []Route
+	repeatAfter time.Duration
+	now         func() time.Time
+}
+
+// New returns a notifier sending to routes, or nil when there are none
+func New(database db.DBInterface, routes []Route, repeatAfter time.Duration) *Notifier {
+	if len(routes) == 0 {
+		return nil
+	}
+	return &Notifier{database: database, routes: routes, repeatAfter: repeatAfter, now: time.Now}
+}
+
+// Notify sends an event unless it was already sent
+func (n *Notifier) Notify(ctx context.Context, event Event) {
+	if n == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = n.now()
+	}
+
+	sentAt, err := n.database.GetNotificationSentAt(event.Key)
+	if err != nil {
+		log.Warn().Err(err).Str("notification", event.Key).Msg("Failed to check notification")
+	} else if sentAt != nil && (!event.Kind.IsFailure() || event.Time.Sub(*sentAt) < n.repeatAfter) {
+		log.Debug().Str("notification", event.Key).Msg("Notification already sent")
+		return
+	}
+
+	sent := false
+	for _, route := range n.routes {
+		if !route.accepts(event.Kind) {
+			continue
+		}
+		if err := route.Sink.Send(ctx, event); err != nil {
+			log.Warn().Err(err).Str("sink", route.Name).Str("notification", event.Key).Msg("Failed to send notification")
+			continue
+		}
+		sent = true
+	}
+
+	// retry on the next occurrence when no sink could deliver it
+	if !sent {
+		return
+	}
+	if err := n.database.RecordNotificationSent(event.Key, event.Time); err != nil {
+		log.Warn().Err(err).Str("notification", event.Key).Msg("Failed to record notification")
+	}
+}
+
+// Resolve forgets the notifications with keys, so that the next occurrence of a
+// failure that was fixed is sent right away
+func (n *Notifier) Resolve(keys ...string) {
+	if n == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := n.database.ClearNotification(key); err != nil {
+			log.Warn().Err(err).Str("notification", key).Msg("Failed to clear notification")
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+)
+
+// recordingSink records the events it is sent and fails with err
+type recordingSink struct {
+	events []Event
+	err    error
+}
+
+func (s *recordingSink) Send(ctx context.Context, event Event) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestNotifierDeduplicates(t *testing.T) {
+	sink := &recordingSink{}
+	n := New(db.NewMockDB(), []Route{{Name: "test", Sink: sink}}, 24*time.Hour)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	authFailure := Event{Kind: KindAuthFailure, Key: "auth_failure:rogers", Title: "Rogers login failed"}
+	n.Notify(context.Background(), authFailure)
+	now = now.Add(6 * time.Hour)
+	n.Notify(context.Background(), authFailure)
+	if len(sink.events) != 1 {
+		t.Fatalf("Expected a failure to be sent once within a day, got %d", len(sink.events))
+	}
+
+	now = now.Add(24 * time.Hour)
+	n.Notify(context.Background(), authFailure)
+	if len(sink.events) != 2 {
+		t.Fatalf("Expected a failure to be repeated after a day, got %d", len(sink.events))
+	}
+
+	n.Resolve(authFailure.Key)
+	n.Notify(context.Background(), authFailure)
+	if len(sink.events) != 3 {
+		t.Fatalf("Expected a resolved failure to be sent again right away, got %d", len(sink.events))
+	}
+
+	unmapped := Event{Kind: KindUnmappedAccount, Key: "unmapped_account:Visa", Title: "New account"}
+	n.Notify(context.Background(), unmapped)
+	now = now.Add(48 * time.Hour)
+	n.Notify(context.Background(), unmapped)
+	if len(sink.events) != 4 {
+		t.Fatalf("Expected a new account to be sent once, got %d", len(sink.events))
+	}
+	if !sink.events[3].Time.Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("Expected the event time to be set, got %v", sink.events[3].Time)
+	}
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	failures, broken := &recordingSink{}, &recordingSink{err: errors.New("unreachable")}
+	mockDB := db.NewMockDB()
+	n := New(mockDB, []Route{
+		{Name: "failures", Sink: failures, Kinds: []Kind{KindSyncError}},
+		{Name: "broken", Sink: broken},
+	}, time.Hour)
+
+	n.Notify(context.Background(), Event{Kind: KindLargeTransaction, Key: "large_transaction:TX1"})
+	if len(failures.events) != 0 || len(broken.events) != 1 {
+		t.Errorf("Expected the event to go to the route without kinds only, got %d and %d",
+			len(failures.events), len(broken.events))
+	}
+	if _, ok := mockDB.Notifications["large_transaction:TX1"]; ok {
+		t.Errorf("Expected an event no sink delivered not to be recorded")
+	}
+
+	n.Notify(context.Background(), Event{Kind: KindSyncError, Key: "sync_error"})
+	if len(failures.events) != 1 {
+		t.Errorf("Expected the sync error to go to the failures route")
+	}
+	if _, ok := mockDB.Notifications["sync_error"]; !ok {
+		t.Errorf("Expected an event delivered by a sink to be recorded")
+	}
+
+	if New(mockDB, nil, time.Hour) != nil {
+		t.Errorf("Expected no notifier without routes")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Event
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	sink := &WebhookSink{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer hook"}}
+	event := Event{Kind: KindSyncError, Key: "sync_error", Title: "Sync failed", Message: "LunchMoney is down"}
+	if err := sink.Send(context.Background(), event); err != nil {
+		t.Fatalf("Failed to send webhook: %v", err)
+	}
+	if received.Kind != KindSyncError || received.Message != "LunchMoney is down" {
+		t.Errorf("Unexpected webhook payload %+v", received)
+	}
+	if auth != "Bearer hook" {
+		t.Errorf("Expected the configured headers to be sent, got %q", auth)
+	}
+
+	failing := &WebhookSink{URL: server.URL + "/missing"}
+	if err := failing.Send(context.Background(), event); err == nil {
+		t.Errorf("Expected an error status to fail the notification")
+	}
+}
+
+func TestNtfySink(t *testing.T) {
+	var title, priority, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title, priority = r.Header.Get("Title"), r.Header.Get("Priority")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	sink := &NtfySink{URL: server.URL + "/sandwich", Priority: "high"}
+	err := sink.Send(context.Background(), Event{Kind: KindAuthFailure, Title: "Rogers login failed", Message: "invalid credentials"})
+	if err != nil {
+		t.Fatalf("Failed to publish to ntfy: %v", err)
+	}
+	if title != "Rogers login failed" || priority != "high" || body != "invalid credentials" {
+		t.Errorf("Unexpected ntfy message: title %q, priority %q, body %q", title, priority, body)
+	}
+}
+
+func TestCommandSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification")
+	sink := &CommandSink{Command: []string{"sh", "-c", `printf '%s: %s' "$0" "$1" > ` + path, "{title}", "{message}"}}
+	if err := sink.Send(context.Background(), Event{Title: "Sync failed", Message: "it's down"}); err != nil {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read command output: %v", err)
+	}
+	if string(data) != "Sync failed: it's down" {
+		t.Errorf("Expected the placeholders to be replaced, got %q", data)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	sink := &EmailSink{From: "sandwich@example.com", To: []string{"me@example.com", "you@example.com"}}
+	message := string(sink.message(Event{
+		Title:   "Scotia session\nfailed",
+		Message: "script exited\nwith 1",
+		Time:    time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}))
+	for _, line := range []string{
+		"To: me@example.com, you@example.com\r\n",
+		"Subject: [sandwich-sync] Scotia session failed\r\n",
+		"\r\n\r\nscript exited\r\nwith 1\r\n",
+	} {
+		if !strings.Contains(message, line) {
+			t.Errorf("Expected the email to contain %q, got:\n%s", line, message)
+		}
+	}
+}
+
+func TestEmailSinkTimeout(t *testing.T) {
+	// a server that accepts connections but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	sink := &EmailSink{Host: "127.0.0.1", Port: addr.Port, From: "a@example.com", To: []string{"b@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := sink.Send(ctx, Event{Title: "Test"}); err == nil {
+		t.Fatalf("Expected an error from an unresponsive server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Send to give up with the context, took %v", elapsed)
+	}
+}
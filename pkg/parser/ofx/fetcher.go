@@ -0,0 +1,124 @@
+package ofx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/http"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// FileFetcher serves the statements of an OFX file like a live provider
+type FileFetcher struct {
+	path        string
+	accountName string
+}
+
+// Ensure FileFetcher implements http.Fetcher
+var _ http.Fetcher = (*FileFetcher)(nil)
+
+// NewFileFetcher creates a fetcher for the OFX file at path. Accounts are named
+// after their type and id unless accountName is set, which requires the file to
+// hold a single statement.
+func NewFileFetcher(path, accountName string) *FileFetcher {
+	return &FileFetcher{path: path, accountName: accountName}
+}
+
+// FetchTransactions implements http.TransactionFetcher, a zero window returns every transaction
+func (f *FileFetcher) FetchTransactions(ctx context.Context, window models.DateRange) ([]models.TransactionWithAccount, error) {
+	file, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]models.TransactionWithAccount, 0)
+	for _, statement := range file.Statements {
+		for _, tx := range statement.TransactionsWithAccount(f.statementAccountName(statement)) {
+			if !window.Start.IsZero() {
+				if ok, _ := window.ContainsDate(tx.Date); !ok {
+					continue
+				}
+			}
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, nil
+}
+
+// FetchAccountBalances implements http.BalanceFetcher with the ledger balances of the statements
+func (f *FileFetcher) FetchAccountBalances(ctx context.Context) ([]models.ExternalAccount, error) {
+	file, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]models.ExternalAccount, 0, len(file.Statements))
+	for _, statement := range file.Statements {
+		if balance, ok := statement.Balance(f.statementAccountName(statement)); ok {
+			balances = append(balances, balance)
+		}
+	}
+	return balances, nil
+}
+
+// Window returns the dates covered by the statements of the file
+func (f *FileFetcher) Window() (models.DateRange, error) {
+	file, err := f.load()
+	if err != nil {
+		return models.DateRange{}, err
+	}
+
+	var window models.DateRange
+	extend := func(start, end string) {
+		r, err := models.NewDateRange(start, end)
+		if err != nil {
+			return
+		}
+		if window.Start.IsZero() || r.Start.Before(window.Start) {
+			window.Start = r.Start
+		}
+		if r.End.After(window.End) {
+			window.End = r.End
+		}
+	}
+	for _, statement := range file.Statements {
+		if !statement.Start.IsZero() && !statement.End.IsZero() {
+			extend(statement.Start.Format(time.DateOnly), statement.End.Format(time.DateOnly))
+			continue
+		}
+		// fall back to the dates of the transactions without a statement range
+		for _, tx := range statement.TransactionsWithAccount("") {
+			extend(tx.Date, tx.Date)
+		}
+	}
+	if window.Start.IsZero() {
+		return models.DateRange{}, fmt.Errorf("OFX file %s covers no dates", f.path)
+	}
+	return window, nil
+}
+
+func (f *FileFetcher) load() (*File, error) {
+	r, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OFX file: %w", err)
+	}
+	defer r.Close()
+
+	file, err := Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OFX file %s: %w", f.path, err)
+	}
+	if f.accountName != "" && len(file.Statements) != 1 {
+		return nil, fmt.Errorf("OFX file %s holds %d statements, an account name can only be set for one", f.path, len(file.Statements))
+	}
+	return file, nil
+}
+
+func (f *FileFetcher) statementAccountName(statement *Statement) string {
+	if f.accountName != "" {
+		return f.accountName
+	}
+	return statement.AccountName()
+}
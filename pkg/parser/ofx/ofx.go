@@ -0,0 +1,361 @@
+// Package ofx parses OFX and QFX bank statements, both the SGML based 1.x
+// format and the XML based 2.x format
+package ofx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+	"github.com/vpnda/sandwich-sync/pkg/utils"
+)
+
+// File is a parsed OFX file, it holds one statement per account
+type File struct {
+	Statements []*Statement
+}
+
+// Statement is the statement of one bank or credit card account
+type Statement struct {
+	AccountID   string
+	AccountType string
+	CreditCard  bool
+	Currency    string
+	// Start and End are the dates covered by the statement, they are zero when missing
+	Start, End   time.Time
+	Transactions []*Transaction
+	// LedgerBalance is the balance as of LedgerAsOf, it is empty when missing
+	LedgerBalance string
+	LedgerAsOf    time.Time
+}
+
+// Transaction is a STMTTRN entry
+type Transaction struct {
+	FITID     string
+	Type      string
+	Posted    time.Time
+	User      time.Time
+	Amount    string
+	Name      string
+	Memo      string
+	Currency  string
+	CheckNum  string
+	Reference string
+}
+
+// node is an element of the OFX document, leaf elements only have a value
+type node struct {
+	name     string
+	value    string
+	children []*node
+}
+
+// Parse reads an OFX or QFX document
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OFX file: %w", err)
+	}
+
+	root, err := parseTree(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	ofx := root.child("OFX")
+	if ofx == nil {
+		return nil, fmt.Errorf("no OFX element found")
+	}
+
+	file := &File{}
+	for _, rs := range ofx.findAll("STMTRS") {
+		statement, err := parseStatement(rs, false)
+		if err != nil {
+			return nil, err
+		}
+		file.Statements = append(file.Statements, statement)
+	}
+	for _, rs := range ofx.findAll("CCSTMTRS") {
+		statement, err := parseStatement(rs, true)
+		if err != nil {
+			return nil, err
+		}
+		file.Statements = append(file.Statements, statement)
+	}
+
+	if len(file.Statements) == 0 {
+		return nil, fmt.Errorf("no bank or credit card statement found")
+	}
+	return file, nil
+}
+
+func parseStatement(rs *node, creditCard bool) (*Statement, error) {
+	statement := &Statement{
+		CreditCard: creditCard,
+		Currency:   rs.childValue("CURDEF"),
+	}
+
+	from := rs.child("BANKACCTFROM")
+	if creditCard {
+		from = rs.child("CCACCTFROM")
+	}
+	if from == nil || from.childValue("ACCTID") == "" {
+		return nil, fmt.Errorf("statement without an account id")
+	}
+	statement.AccountID = from.childValue("ACCTID")
+	statement.AccountType = from.childValue("ACCTTYPE")
+	if creditCard {
+		statement.AccountType = "CREDITCARD"
+	}
+
+	var err error
+	if list := rs.child("BANKTRANLIST"); list != nil {
+		if statement.Start, err = parseDate(list.childValue("DTSTART")); err != nil {
+			return nil, err
+		}
+		if statement.End, err = parseDate(list.childValue("DTEND")); err != nil {
+			return nil, err
+		}
+
+		for _, trn := range list.findAll("STMTTRN") {
+			tx := &Transaction{
+				FITID:     trn.childValue("FITID"),
+				Type:      trn.childValue("TRNTYPE"),
+				Amount:    trn.childValue("TRNAMT"),
+				Name:      trn.childValue("NAME"),
+				Memo:      trn.childValue("MEMO"),
+				Currency:  statement.Currency,
+				CheckNum:  trn.childValue("CHECKNUM"),
+				Reference: trn.childValue("REFNUM"),
+			}
+			if payee := trn.child("PAYEE"); payee != nil && tx.Name == "" {
+				tx.Name = payee.childValue("NAME")
+			}
+			if currency := trn.child("CURRENCY"); currency != nil {
+				tx.Currency = currency.childValue("CURSYM")
+			}
+			if tx.FITID == "" {
+				return nil, fmt.Errorf("transaction without FITID in account %s", statement.AccountID)
+			}
+			if _, err := strconv.ParseFloat(tx.Amount, 64); err != nil {
+				return nil, fmt.Errorf("invalid amount %q of transaction %s: %w", tx.Amount, tx.FITID, err)
+			}
+			if tx.Posted, err = parseDate(trn.childValue("DTPOSTED")); err != nil {
+				return nil, err
+			}
+			if tx.Posted.IsZero() {
+				return nil, fmt.Errorf("transaction %s without a posted date", tx.FITID)
+			}
+			if tx.User, err = parseDate(trn.childValue("DTUSER")); err != nil {
+				return nil, err
+			}
+			statement.Transactions = append(statement.Transactions, tx)
+		}
+	}
+
+	if ledger := rs.child("LEDGERBAL"); ledger != nil {
+		statement.LedgerBalance = ledger.childValue("BALAMT")
+		if statement.LedgerAsOf, err = parseDate(ledger.childValue("DTASOF")); err != nil {
+			return nil, err
+		}
+	}
+	return statement, nil
+}
+
+// AccountName returns the external account name of the statement
+func (s *Statement) AccountName() string {
+	return s.AccountType + " " + s.AccountID
+}
+
+// TransactionsWithAccount converts the statement's transactions, outflows are stored
+// as positive amounts while OFX uses negative ones. FITIDs are only unique within an
+// account, so reference numbers are prefixed with a hash of the statement's account
+// ID, they are sent to LunchMoney which should not see the account number.
+func (s *Statement) TransactionsWithAccount(accountName string) []models.TransactionWithAccount {
+	sum := sha256.Sum256([]byte(s.AccountID))
+	prefix := hex.EncodeToString(sum[:])[:8] + ":"
+	transactions := make([]models.TransactionWithAccount, 0, len(s.Transactions))
+	for _, tx := range s.Transactions {
+		date := tx.Posted
+		if !tx.User.IsZero() {
+			date = tx.User
+		}
+		name := tx.Name
+		if name == "" {
+			name = tx.Memo
+		}
+
+		transactions = append(transactions, models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: prefix + tx.FITID,
+				Amount:          models.Amount{Value: negate(tx.Amount), Currency: tx.Currency},
+				Merchant: &models.Merchant{
+					Name:    utils.Capitalize(name),
+					Address: &models.Address{},
+				},
+				Date:       date.Format(time.DateOnly),
+				PostedDate: tx.Posted.Format(time.DateOnly),
+				Status:     models.TransactionStatusPosted,
+			},
+			SourceAccountName: accountName,
+		})
+	}
+	return transactions
+}
+
+// Balance returns the ledger balance of the statement as of its date, credit card
+// balances are stored as the positive amount owed like the live providers do
+func (s *Statement) Balance(accountName string) (models.ExternalAccount, bool) {
+	if s.LedgerBalance == "" {
+		return models.ExternalAccount{}, false
+	}
+	value := s.LedgerBalance
+	if s.CreditCard {
+		value = negate(value)
+	}
+	return models.ExternalAccount{
+		Name:        accountName,
+		Description: s.AccountType + " " + s.AccountID,
+		Balance:     models.Amount{Value: value, Currency: s.Currency},
+		BalanceAsOf: s.LedgerAsOf,
+	}, true
+}
+
+// negate flips the sign of a decimal amount without going through floats
+func negate(amount string) string {
+	amount = strings.TrimPrefix(strings.TrimSpace(amount), "+")
+	if strings.HasPrefix(amount, "-") {
+		return amount[1:]
+	}
+	if strings.Trim(amount, "0.") == "" {
+		return amount
+	}
+	return "-" + amount
+}
+
+// parseDate parses the leading YYYYMMDD of an OFX date like 20250410120000.000[-5:EST],
+// an empty date is the zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid OFX date %q", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid OFX date %q: %w", value, err)
+	}
+	return date, nil
+}
+
+// parseTree builds the element tree of an OFX document. OFX 1.x is SGML where leaf
+// elements are not closed, so an element followed by text is a leaf and its
+// closing tag, if present, is skipped. An element without text is an aggregate
+// until the closing tag of an enclosing aggregate shows it was never closed, it is
+// then an empty leaf and the elements after it move back to its parent. Headers
+// and processing instructions are ignored.
+func parseTree(data string) (*node, error) {
+	root := &node{}
+	stack := []*node{root}
+
+	for {
+		start := strings.IndexByte(data, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(data[start:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated tag")
+		}
+		tag := strings.TrimSpace(data[start+1 : start+end])
+		data = data[start+end+1:]
+
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		if tag[0] == '/' {
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			// close the aggregate, the unclosed elements within it are empty leaves
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name != name {
+					continue
+				}
+				for j := len(stack) - 1; j > i; j-- {
+					leaf := stack[j]
+					stack[j-1].children = append(stack[j-1].children, leaf.children...)
+					leaf.children = nil
+				}
+				stack = stack[:i]
+				break
+			}
+			continue
+		}
+
+		name := strings.ToUpper(strings.Fields(tag)[0])
+		selfClosing := strings.HasSuffix(tag, "/")
+		name = strings.TrimSuffix(name, "/")
+
+		next := strings.IndexByte(data, '<')
+		if next < 0 {
+			next = len(data)
+		}
+		value := strings.TrimSpace(unescape(data[:next]))
+
+		elem := &node{name: name}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, elem)
+
+		if value != "" || selfClosing {
+			elem.value = value
+			data = data[next:]
+			// skip the optional closing tag of the leaf
+			closing := "</" + name + ">"
+			if len(data) >= len(closing) && strings.EqualFold(data[:len(closing)], closing) {
+				data = data[len(closing):]
+			}
+			continue
+		}
+		stack = append(stack, elem)
+	}
+	return root, nil
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", "\"", "&apos;", "'", "&amp;", "&").Replace(s)
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) childValue(name string) string {
+	if c := n.child(name); c != nil {
+		return c.value
+	}
+	return ""
+}
+
+// findAll returns the descendants named name, without descending into matches
+func (n *node) findAll(name string) []*node {
+	var found []*node
+	for _, c := range n.children {
+		if c.name == name {
+			found = append(found, c)
+			continue
+		}
+		found = append(found, c.findAll(name)...)
+	}
+	return found
+}
@@ -0,0 +1,211 @@
+package ofx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20250415120000</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>1
+<STMTRS>
+<CURDEF>CAD
+<BANKACCTFROM>
+<BANKID>00002
+<ACCTID>12345
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20250401
+<DTEND>20250415120000.000[-5:EST]
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250411
+<DTUSER>20250410
+<TRNAMT>-4.50
+<FITID>90001
+<NAME>COFFEE &amp; CO
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250412
+<TRNAMT>1200.00
+<FITID>90002
+<MEMO>PAYROLL
+</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>2500.10
+<DTASOF>20250415
+</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>CAD</CURDEF>
+        <CCACCTFROM><ACCTID>4500XXXX1234</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <DTSTART>20250301</DTSTART>
+          <DTEND>20250331</DTEND>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20250305</DTPOSTED>
+            <TRNAMT>-25.00</TRNAMT>
+            <FITID>CC1</FITID>
+            <NAME>GROCERY</NAME>
+          </STMTTRN>
+        </BANKTRANLIST>
+        <LEDGERBAL><BALAMT>-125.00</BALAMT><DTASOF>20250331</DTASOF></LEDGERBAL>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseSGML(t *testing.T) {
+	file, err := Parse(strings.NewReader(sgmlStatement))
+	if err != nil {
+		t.Fatalf("Failed to parse OFX: %v", err)
+	}
+	if len(file.Statements) != 1 {
+		t.Fatalf("Expected 1 statement, got %d", len(file.Statements))
+	}
+
+	statement := file.Statements[0]
+	if statement.AccountName() != "CHECKING 12345" || statement.Currency != "CAD" {
+		t.Errorf("Unexpected statement account %s %s", statement.AccountName(), statement.Currency)
+	}
+
+	transactions := statement.TransactionsWithAccount("Chequing")
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+
+	coffee := transactions[0]
+	if coffee.ReferenceNumber != "5994471a:90001" || coffee.Amount.Value != "4.50" || coffee.Merchant.Name != "Coffee & Co" {
+		t.Errorf("Unexpected transaction %s %s %s", coffee.ReferenceNumber, coffee.Amount.Value, coffee.Merchant.Name)
+	}
+	if coffee.Date != "2025-04-10" || coffee.PostedDate != "2025-04-11" {
+		t.Errorf("Expected the user date and posted date, got %s and %s", coffee.Date, coffee.PostedDate)
+	}
+
+	payroll := transactions[1]
+	if payroll.Amount.Value != "-1200.00" || payroll.Merchant.Name != "Payroll" || payroll.Date != "2025-04-12" {
+		t.Errorf("Expected an inflow named after the memo, got %s %s %s", payroll.Amount.Value, payroll.Merchant.Name, payroll.Date)
+	}
+
+	balance, ok := statement.Balance("Chequing")
+	if !ok || balance.Balance.Value != "2500.10" || balance.Name != "Chequing" ||
+		balance.BalanceAsOf.Format(time.DateOnly) != "2025-04-15" {
+		t.Errorf("Unexpected ledger balance %+v", balance)
+	}
+}
+
+func TestParseEmptyLeaf(t *testing.T) {
+	// an empty unclosed MEMO must not swallow the elements after it
+	content := strings.Replace(sgmlStatement, "<FITID>90001\n", "<MEMO>\n<FITID>90001\n", 1)
+	file, err := Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to parse OFX: %v", err)
+	}
+
+	transactions := file.Statements[0].Transactions
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+	coffee := transactions[0]
+	if coffee.FITID != "90001" || coffee.Memo != "" || coffee.Name != "COFFEE & CO" {
+		t.Errorf("Unexpected transaction %+v", coffee)
+	}
+	if file.Statements[0].LedgerBalance != "2500.10" {
+		t.Errorf("Expected the ledger balance after the transactions, got %q", file.Statements[0].LedgerBalance)
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	file, err := Parse(strings.NewReader(xmlStatement))
+	if err != nil {
+		t.Fatalf("Failed to parse OFX: %v", err)
+	}
+
+	statement := file.Statements[0]
+	if !statement.CreditCard || statement.AccountName() != "CREDITCARD 4500XXXX1234" {
+		t.Errorf("Expected a credit card statement, got %s", statement.AccountName())
+	}
+	transactions := statement.TransactionsWithAccount(statement.AccountName())
+	if len(transactions) != 1 || transactions[0].Amount.Value != "25.00" {
+		t.Errorf("Unexpected transactions %+v", transactions)
+	}
+
+	// credit card balances are the amount owed
+	balance, ok := statement.Balance(statement.AccountName())
+	if !ok || balance.Balance.Value != "125.00" {
+		t.Errorf("Expected a balance of 125.00 owed, got %+v", balance)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"not an ofx file",
+		"<OFX><BANKMSGSRSV1></BANKMSGSRSV1></OFX>",
+		strings.Replace(sgmlStatement, "<FITID>90001\n", "", 1),
+		strings.Replace(sgmlStatement, "<TRNAMT>-4.50", "<TRNAMT>abc", 1),
+	}
+	for _, content := range invalid {
+		if _, err := Parse(strings.NewReader(content)); err == nil {
+			t.Errorf("Expected error for invalid OFX:\n%s", content)
+		}
+	}
+}
+
+func TestFileFetcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statement.qfx")
+	if err := os.WriteFile(path, []byte(sgmlStatement), 0600); err != nil {
+		t.Fatalf("Failed to write OFX file: %v", err)
+	}
+
+	fetcher := NewFileFetcher(path, "")
+	window, err := fetcher.Window()
+	if err != nil {
+		t.Fatalf("Failed to get window: %v", err)
+	}
+	if window.String() != "2025-04-01..2025-04-15" {
+		t.Errorf("Expected the statement dates, got %s", window)
+	}
+
+	transactions, err := fetcher.FetchTransactions(context.Background(), window)
+	if err != nil {
+		t.Fatalf("Failed to fetch transactions: %v", err)
+	}
+	if len(transactions) != 2 || transactions[0].SourceAccountName != "CHECKING 12345" {
+		t.Errorf("Unexpected transactions %+v", transactions)
+	}
+
+	balances, err := NewFileFetcher(path, "Chequing").FetchAccountBalances(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to fetch balances: %v", err)
+	}
+	if len(balances) != 1 || balances[0].Name != "Chequing" {
+		t.Errorf("Expected the balance of the named account, got %+v", balances)
+	}
+}
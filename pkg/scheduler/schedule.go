@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next
+type Schedule interface {
+	// Next returns the first time after t the job runs at, or the zero time if it never runs again
+	Next(t time.Time) time.Time
+}
+
+// Parse parses an interval such as "6h" or "@every 6h", one of @hourly, @daily and
+// @weekly, or a five field cron expression such as "30 */6 * * 1-5"
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "":
+		return nil, fmt.Errorf("empty schedule")
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+
+	if interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every"))); err == nil {
+		if interval < time.Minute {
+			return nil, fmt.Errorf("interval %s is shorter than a minute", interval)
+		}
+		return Interval(interval), nil
+	}
+	return parseCron(spec)
+}
+
+// Interval runs a job at a fixed interval
+type Interval time.Duration
+
+// Next implements Schedule.
+func (i Interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// cronField is the set of values a cron field matches, bit n is set if n matches
+type cronField uint64
+
+func (f cronField) has(n int) bool {
+	return f&(1<<uint(n)) != 0
+}
+
+// Cron runs a job at the minutes matching a cron expression, in the local time zone
+type Cron struct {
+	minute, hour, dom, month, dow cronField
+	// anyDom and anyDow are set when the day fields are *, when both are restricted
+	// a day matches if either of them does
+	anyDom, anyDow bool
+}
+
+// cronBounds are the allowed values of the cron fields
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func parseCron(spec string) (*Cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected an interval or 5 cron fields", spec)
+	}
+
+	parsed := make([]cronField, 5)
+	for i, field := range fields {
+		f, err := parseCronField(field, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		parsed[i] = f
+	}
+	// Sunday is either 0 or 7
+	if parsed[4].has(7) {
+		parsed[4] |= 1
+	}
+
+	c := &Cron{
+		minute: parsed[0], hour: parsed[1], dom: parsed[2], month: parsed[3], dow: parsed[4],
+		anyDom: fields[2] == "*", anyDow: fields[4] == "*",
+	}
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never matches", spec)
+	}
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of *, values and ranges with an optional step
+func parseCronField(field string, low, high int) (cronField, error) {
+	var f cronField
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+		}
+
+		start, end := low, high
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", item)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid range in %q", item)
+				}
+			} else if hasStep {
+				end = high
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("%q is out of range %d-%d", item, low, high)
+		}
+
+		for n := start; n <= end; n += step {
+			f |= 1 << uint(n)
+		}
+	}
+	return f, nil
+}
+
+// matchesDay returns true if the day of t matches the day of month and day of week fields
+func (c *Cron) matchesDay(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if !c.anyDom && !c.anyDow {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next implements Schedule.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// a matching minute is found within a few years unless the expression never
+	// matches, e.g. on February 30th
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	start := time.Date(2025, 3, 10, 8, 15, 0, 0, time.UTC)
+	for _, spec := range []string{"6h", "@every 6h"} {
+		schedule, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", spec, err)
+		}
+		if next := schedule.Next(start); !next.Equal(start.Add(6 * time.Hour)) {
+			t.Errorf("Expected %q to run 6 hours later, got %v", spec, next)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "30s", "every day", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "0 0 30 2 *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Monday
+	start := time.Date(2025, 3, 10, 8, 15, 0, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/10 * * * *", time.Date(2025, 3, 10, 8, 20, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"30 7 * * 1-5", time.Date(2025, 3, 11, 7, 30, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 3, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week matches when both are set
+		{"0 12 1 * 3", time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC)},
+		{"15 8 * * *", time.Date(2025, 3, 11, 8, 15, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.spec, err)
+		}
+		if next := schedule.Next(start); !next.Equal(tt.expected) {
+			t.Errorf("Expected %q to run at %v, got %v", tt.spec, tt.expected, next)
+		}
+	}
+}
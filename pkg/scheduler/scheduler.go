@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Job is a task run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+}
+
+// Backoff controls how soon a failed job is retried, the wait doubles with every
+// failure in a row up to Max and is jittered so failing jobs do not retry together
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// delay returns the wait before retrying after the given number of failures in a row
+func (b Backoff) delay(failures int) time.Duration {
+	d := b.Initial
+	for i := 1; i < failures && d < b.Max; i++ {
+		d *= 2
+	}
+	d = min(d, b.Max)
+	if d <= 0 {
+		return 0
+	}
+	// wait between half and all of the backoff
+	return d/2 + rand.N(d/2+1)
+}
+
+// Scheduler runs jobs on their schedules until its context is canceled. Runs never
+// overlap, a job that is due while another one runs waits for it to finish.
+type Scheduler struct {
+	jobs    []Job
+	backoff Backoff
+	// mu serializes the runs
+	mu sync.Mutex
+	// now and wait are replaced in tests
+	now  func() time.Time
+	wait func(ctx context.Context, d time.Duration) error
+}
+
+// New creates a scheduler for jobs that retries failed runs with backoff
+func New(jobs []Job, backoff Backoff) *Scheduler {
+	return &Scheduler{
+		jobs:    jobs,
+		backoff: backoff,
+		now:     time.Now,
+		wait:    waitContext,
+	}
+}
+
+// Run runs every job once and then on its schedule until ctx is canceled. It returns
+// once the run in progress, if any, finished.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop runs a job until ctx is canceled or its schedule ends
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	failures := 0
+	next := s.now()
+	for {
+		if err := s.wait(ctx, next.Sub(s.now())); err != nil {
+			return
+		}
+		err := s.run(ctx, job)
+		if ctx.Err() != nil {
+			return
+		}
+
+		now := s.now()
+		next = job.Schedule.Next(now)
+		if err != nil {
+			failures++
+			// retry early unless the next scheduled run comes first
+			retry := now.Add(s.backoff.delay(failures))
+			if next.IsZero() || retry.Before(next) {
+				next = retry
+			}
+			log.Error().Err(err).Str("job", job.Name).Int("failures", failures).Time("next", next).Msg("Scheduled run failed")
+		} else {
+			failures = 0
+		}
+
+		if next.IsZero() {
+			log.Info().Str("job", job.Name).Msg("Schedule ended, job stopped")
+			return
+		}
+		log.Info().Str("job", job.Name).Time("next", next).Msg("Next run scheduled")
+	}
+}
+
+// run runs a job once no other job is running
+func (s *Scheduler) run(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Info().Str("job", job.Name).Msg("Scheduled run started")
+	start := s.now()
+	err := job.Run(ctx)
+	if err == nil {
+		log.Info().Str("job", job.Name).Dur("duration", s.now().Sub(start)).Msg("Scheduled run finished")
+	}
+	return err
+}
+
+// waitContext waits for d unless ctx is done first
+func waitContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
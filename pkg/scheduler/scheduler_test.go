@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances when the scheduler waits instead of sleeping
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Wait(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(max(0, d))
+	return nil
+}
+
+func newTestScheduler(jobs []Job, backoff Backoff) (*Scheduler, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)}
+	s := New(jobs, backoff)
+	s.now = clock.Now
+	s.wait = clock.Wait
+	return s, clock
+}
+
+func TestSchedulerRetriesWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := 0
+	job := Job{
+		Name:     "rogers",
+		Schedule: Interval(6 * time.Hour),
+		Run: func(ctx context.Context) error {
+			runs++
+			if runs == 5 {
+				cancel()
+			}
+			if runs <= 3 {
+				return errors.New("login failed")
+			}
+			return nil
+		},
+	}
+	s, clock := newTestScheduler([]Job{job}, Backoff{Initial: time.Minute, Max: 3 * time.Minute})
+	s.Run(ctx)
+
+	if runs != 5 {
+		t.Fatalf("Expected 5 runs, got %d", runs)
+	}
+	// run at once, retry after 1, 2 and 3 minutes jittered down to half, then run on schedule
+	bounds := [][2]time.Duration{
+		{0, 0},
+		{30 * time.Second, time.Minute},
+		{time.Minute, 2 * time.Minute},
+		{90 * time.Second, 3 * time.Minute},
+		{6 * time.Hour, 6 * time.Hour},
+	}
+	if len(clock.waits) != len(bounds) {
+		t.Fatalf("Expected %d waits, got %v", len(bounds), clock.waits)
+	}
+	for i, wait := range clock.waits {
+		if wait < bounds[i][0] || wait > bounds[i][1] {
+			t.Errorf("Expected wait %d within %v, got %v", i, bounds[i], wait)
+		}
+	}
+}
+
+func TestSchedulerRetriesNoLaterThanSchedule(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := 0
+	job := Job{
+		Name:     "ws",
+		Schedule: Interval(time.Minute),
+		Run: func(ctx context.Context) error {
+			runs++
+			if runs == 2 {
+				cancel()
+			}
+			return errors.New("unavailable")
+		},
+	}
+	s, clock := newTestScheduler([]Job{job}, Backoff{Initial: time.Hour, Max: time.Hour})
+	s.Run(ctx)
+
+	if len(clock.waits) != 2 || clock.waits[1] != time.Minute {
+		t.Errorf("Expected the retry on the next scheduled run, got %v", clock.waits)
+	}
+}
+
+func TestSchedulerSerializesRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	running, overlaps, runs := 0, 0, 0
+	run := func(ctx context.Context) error {
+		mu.Lock()
+		running++
+		if running > 1 {
+			overlaps++
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		running--
+		runs++
+		if runs == 6 {
+			cancel()
+		}
+		return nil
+	}
+
+	jobs := []Job{
+		{Name: "rogers", Schedule: Interval(time.Hour), Run: run},
+		{Name: "scotia", Schedule: Interval(time.Hour), Run: run},
+		{Name: "ws", Schedule: Interval(time.Hour), Run: run},
+	}
+	s, _ := newTestScheduler(jobs, Backoff{Initial: time.Minute, Max: time.Hour})
+	s.Run(ctx)
+
+	if overlaps != 0 {
+		t.Errorf("Expected runs not to overlap, got %d overlaps", overlaps)
+	}
+}
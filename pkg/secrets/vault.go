@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// RefPrefix marks configuration values that name a secret of the vault, e.g. secret://rogers
+const RefPrefix = "secret://"
+
+// ErrWrongPassphrase is returned when the vault can not be decrypted with a passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted vault")
+
+// ParseRef returns the name of the secret a configuration value references
+func ParseRef(value string) (string, bool) {
+	name, ok := strings.CutPrefix(value, RefPrefix)
+	if !ok || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// Ref returns the configuration value referencing a secret
+func Ref(name string) string {
+	return RefPrefix + name
+}
+
+// scryptWorkFactor is the log2 of the scrypt work factor of new vaults, it takes 32MB
+// of memory to unlock them so small machines can open the vault too
+var scryptWorkFactor = 15
+
+// Vault holds named secrets encrypted with age under a passphrase (scrypt recipient).
+// Changes are kept in memory until Save.
+type Vault struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// Open decrypts the vault at path with passphrase, an empty vault is returned when
+// the file does not exist yet
+func Open(path string, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("the vault passphrase must not be empty")
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Vault{path: path, passphrase: passphrase, secrets: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+	defer file.Close()
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault identity: %w", err)
+	}
+	reader, err := age.Decrypt(file, identity)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrWrongPassphrase
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault: %w", err)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.NewDecoder(reader).Decode(&secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse vault secrets: %w", err)
+	}
+	return &Vault{path: path, passphrase: passphrase, secrets: secrets}, nil
+}
+
+// Path returns where the vault is stored
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Get returns a secret by name
+func (v *Vault) Get(name string) (string, bool) {
+	value, ok := v.secrets[name]
+	return value, ok
+}
+
+// Set adds or replaces a secret
+func (v *Vault) Set(name string, value string) {
+	v.secrets[name] = value
+}
+
+// Delete removes a secret and returns false if there was none
+func (v *Vault) Delete(name string) bool {
+	_, ok := v.secrets[name]
+	delete(v.secrets, name)
+	return ok
+}
+
+// Names returns the names of the secrets in order
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Save encrypts the secrets and replaces the vault file, only the owner can read it
+func (v *Vault) Save() error {
+	plaintext, err := json.Marshal(v.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode vault secrets: %w", err)
+	}
+	recipient, err := age.NewScryptRecipient(v.passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to create vault recipient: %w", err)
+	}
+	recipient.SetWorkFactor(scryptWorkFactor)
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create vault file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	writer, err := age.Encrypt(tmp, recipient)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.path); err != nil {
+		return fmt.Errorf("failed to replace vault: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// keep the tests fast, the work factor is stored with each vault
+	scryptWorkFactor = 10
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	vault, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to create vault: %v", err)
+	}
+	vault.Set("rogers", "hunter2")
+	vault.Set("lunchmoney", "api-key")
+	if err := vault.Save(); err != nil {
+		t.Fatalf("Failed to save vault: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read vault: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "rogers") {
+		t.Errorf("Expected the secrets and their names to be encrypted, got:\n%s", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the vault to be readable by its owner only, got %v", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to open vault: %v", err)
+	}
+	if value, ok := reopened.Get("rogers"); !ok || value != "hunter2" {
+		t.Errorf("Expected the rogers secret, got %q", value)
+	}
+	if names := strings.Join(reopened.Names(), ","); names != "lunchmoney,rogers" {
+		t.Errorf("Expected the secret names in order, got %s", names)
+	}
+	if !reopened.Delete("rogers") || reopened.Delete("rogers") {
+		t.Errorf("Expected a secret to be deleted once")
+	}
+
+	if _, err := Open(path, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected the wrong passphrase to be rejected, got %v", err)
+	}
+	if _, err := Open(path, ""); err == nil {
+		t.Errorf("Expected an empty passphrase to be rejected")
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		value string
+		name  string
+		ok    bool
+	}{
+		{"secret://rogers", "rogers", true},
+		{Ref("wealthsimple-session"), "wealthsimple-session", true},
+		{"secret://", "", false},
+		{"hunter2", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := ParseRef(tt.value)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("ParseRef(%q) = %q, %v, expected %q, %v", tt.value, name, ok, tt.name, tt.ok)
+		}
+	}
+}
@@ -3,21 +3,36 @@ package services
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
 func (l *LunchMoneySyncer) SyncBalances(ctx context.Context) error {
-	//knownAccounts, err := l.database.GetAccounts()
-	localAccounts, err := l.database.GetAccounts()
+	plan, err := l.PlanBalances(ctx)
 	if err != nil {
 		return err
 	}
+	if err := l.ApplyBalances(ctx, plan); err != nil {
+		return err
+	}
+	observeAccountSyncs(plan.balanceAccounts, time.Now())
+	return nil
+}
+
+// PlanBalances computes which LunchMoney account balances are older than the local ones
+func (l *LunchMoneySyncer) PlanBalances(ctx context.Context) (*SyncPlan, error) {
+	plan := &SyncPlan{}
+
+	localAccounts, err := l.database.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
 
 	lunchMoneyAccounts, err := l.client.ListAccounts(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	lunchMoneyMap := make(map[int64]models.LunchMoneyAccount)
@@ -33,6 +48,8 @@ func (l *LunchMoneySyncer) SyncBalances(ctx context.Context) error {
 			continue
 		}
 		lunchMoneyAccount := lunchMoneyMap[localAccount.LunchMoneyId]
+		observeBalanceDrift(localAccount, lunchMoneyAccount)
+		plan.balanceAccounts = append(plan.balanceAccounts, lunchMoneyAccount)
 
 		if localAccount.Balance.Value == lunchMoneyAccount.Balance.Value {
 			continue
@@ -48,17 +65,37 @@ func (l *LunchMoneySyncer) SyncBalances(ctx context.Context) error {
 		}
 
 		if localAccount.BalanceLastUpdated.After(*lunchMoneyAccount.BalanceLastUpdated) {
-			log.Info().Int64("account", localAccount.LunchMoneyId).Msg("Updating balance in LunchMoney to match local balance")
-			// lower-case the curreny code to match LunchMoney's format
-			localAccount.Balance.Currency = strings.ToLower(localAccount.Balance.Currency)
-			err := l.client.UpdateAccountBalance(ctx,
-				localAccount.LunchMoneyId,
-				localAccount.Balance,
-				localAccount.BalanceLastUpdated)
-			if err != nil {
-				return err
+			accountName := lunchMoneyAccount.DisplayName
+			if accountName == "" {
+				accountName = lunchMoneyAccount.Name
 			}
+			plan.BalanceUpdates = append(plan.BalanceUpdates, &BalanceUpdate{
+				LunchMoneyId: localAccount.LunchMoneyId,
+				AccountName:  accountName,
+				From:         lunchMoneyAccount.Balance,
+				To:           localAccount.Balance,
+				AsOf:         localAccount.BalanceLastUpdated,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// ApplyBalances pushes the planned balance updates to LunchMoney
+func (l *LunchMoneySyncer) ApplyBalances(ctx context.Context, plan *SyncPlan) error {
+	for _, update := range plan.BalanceUpdates {
+		log.Info().Int64("account", update.LunchMoneyId).Msg("Updating balance in LunchMoney to match local balance")
+		// lower-case the curreny code to match LunchMoney's format
+		balance := update.To
+		balance.Currency = strings.ToLower(balance.Currency)
+		err := l.client.UpdateAccountBalance(ctx,
+			update.LunchMoneyId,
+			balance,
+			update.AsOf)
+		if err != nil {
+			return err
 		}
+		clearBalanceDrift(update)
 	}
 	return nil
 }
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// CategoryMapper assigns LunchMoney categories to the transactions inserted into
+// LunchMoney. Configured rules are tried first, then the stored mapping of the
+// provider category code, and unknown codes are prompted for. A nil mapper leaves
+// every transaction uncategorized.
+type CategoryMapper struct {
+	client         lm.LunchMoneyClientInterface
+	db             db.DBInterface
+	rules          []config.CategoryRule
+	nonInteractive bool
+	// categories are the cached LunchMoney categories, loaded on first use
+	categories []models.LunchMoneyCategory
+	// uncategorized are the category codes left uncategorized in non-interactive mode
+	uncategorized map[string]bool
+}
+
+// NewCategoryMapper creates a category mapper looking up categories with the client
+func NewCategoryMapper(client lm.LunchMoneyClientInterface, database db.DBInterface) *CategoryMapper {
+	return &CategoryMapper{
+		client: client,
+		db:     database,
+	}
+}
+
+// SetCategoryRules sets the configured rules applied before the stored category code mappings
+func (cm *CategoryMapper) SetCategoryRules(rules []config.CategoryRule) {
+	cm.rules = rules
+}
+
+// SetNonInteractive makes the mapper leave unknown category codes uncategorized instead of prompting
+func (cm *CategoryMapper) SetNonInteractive(nonInteractive bool) {
+	cm.nonInteractive = nonInteractive
+}
+
+// Uncategorized returns the category codes left uncategorized in non-interactive mode
+func (cm *CategoryMapper) Uncategorized() []string {
+	if cm == nil {
+		return nil
+	}
+	codes := lo.Keys(cm.uncategorized)
+	sort.Strings(codes)
+	return codes
+}
+
+// Categories returns the LunchMoney categories from the local cache, they are
+// fetched from LunchMoney when nothing is cached yet
+func (cm *CategoryMapper) Categories(ctx context.Context) ([]models.LunchMoneyCategory, error) {
+	if cm.categories != nil {
+		return cm.categories, nil
+	}
+
+	categories, err := cm.db.GetLunchMoneyCategories()
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return cm.RefreshCategories(ctx)
+	}
+	cm.categories = categories
+	return categories, nil
+}
+
+// RefreshCategories fetches the categories from LunchMoney and replaces the local cache
+func (cm *CategoryMapper) RefreshCategories(ctx context.Context) ([]models.LunchMoneyCategory, error) {
+	categories, err := cm.client.ListCategories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories from LunchMoney: %w", err)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+
+	if err := cm.db.ReplaceLunchMoneyCategories(categories); err != nil {
+		return nil, err
+	}
+	cm.categories = categories
+	return categories, nil
+}
+
+// findCategory returns the LunchMoney category with the given ID, the cache is
+// refreshed once when it does not know the category
+func (cm *CategoryMapper) findCategory(ctx context.Context, id int64) (*models.LunchMoneyCategory, error) {
+	categories, err := cm.Categories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	category, ok := lo.Find(categories, func(c models.LunchMoneyCategory) bool { return c.ID == id })
+	if !ok {
+		if categories, err = cm.RefreshCategories(ctx); err != nil {
+			return nil, err
+		}
+		category, ok = lo.Find(categories, func(c models.LunchMoneyCategory) bool { return c.ID == id })
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown LunchMoney category %d", id)
+	}
+	if category.IsGroup {
+		return nil, fmt.Errorf("LunchMoney category %d is a category group", id)
+	}
+	return &category, nil
+}
+
+// LookupCategoryForTransaction returns the LunchMoney category the configured rules
+// or the stored mappings give a transaction, found is false when its category code
+// is unknown. It never prompts nor stores a mapping, so it is safe for dry runs.
+func (cm *CategoryMapper) LookupCategoryForTransaction(ctx context.Context,
+	transaction *models.TransactionWithAccount) (categoryId int64, found bool, err error) {
+	if cm == nil || transaction.Merchant == nil {
+		return models.Uncategorized, true, nil
+	}
+	merchant := transaction.Merchant
+
+	for _, rule := range cm.rules {
+		if !rule.Matches(merchant.CategoryCode, merchant.Name, merchant.OriginalName) {
+			continue
+		}
+		if rule.Uncategorized {
+			return models.Uncategorized, true, nil
+		}
+		if _, err := cm.findCategory(ctx, rule.CategoryId); err != nil {
+			return 0, false, fmt.Errorf("invalid category rule %s: %w", rule.String(), err)
+		}
+		return rule.CategoryId, true, nil
+	}
+
+	if merchant.CategoryCode == "" {
+		return models.Uncategorized, true, nil
+	}
+
+	mapping, err := cm.db.GetCategoryMapping(merchant.CategoryCode)
+	if err != nil {
+		return 0, false, err
+	}
+	if mapping != nil {
+		return mapping.LunchMoneyCategoryId, true, nil
+	}
+	return models.Uncategorized, false, nil
+}
+
+// FindCategoryForTransaction returns the LunchMoney category a transaction is inserted
+// with, it is models.Uncategorized when the transaction gets no category. Unknown
+// category codes are prompted for unless the mapper is non-interactive.
+func (cm *CategoryMapper) FindCategoryForTransaction(ctx context.Context, transaction *models.TransactionWithAccount) (int64, error) {
+	categoryId, found, err := cm.LookupCategoryForTransaction(ctx, transaction)
+	if err != nil || found {
+		return categoryId, err
+	}
+	merchant := transaction.Merchant
+
+	if cm.nonInteractive {
+		cm.skipUncategorized(merchant.CategoryCode)
+		return models.Uncategorized, nil
+	}
+
+	fmt.Printf("Could not find a category for category code %q of transaction [%s] %s (%s). Please select one:\n",
+		merchant.CategoryCode, transaction.ReferenceNumber, merchant.Name, transaction.Amount.ToMoney().Display())
+	mapping, err := cm.selectCategoryInteractive(ctx, merchant.CategoryCode)
+	if err != nil {
+		return 0, err
+	}
+	if mapping == nil {
+		return models.Uncategorized, nil
+	}
+	return mapping.LunchMoneyCategoryId, nil
+}
+
+// skipUncategorized records a category code that can not be prompted for
+func (cm *CategoryMapper) skipUncategorized(categoryCode string) {
+	if cm.uncategorized == nil {
+		cm.uncategorized = make(map[string]bool)
+	}
+	if !cm.uncategorized[categoryCode] {
+		log.Warn().Str("categoryCode", categoryCode).Msg("Leaving unmapped category code uncategorized in non-interactive mode")
+	}
+	cm.uncategorized[categoryCode] = true
+}
+
+// selectCategoryInteractive prompts for the category of a category code and stores
+// the mapping, it returns nil when nothing was selected
+func (cm *CategoryMapper) selectCategoryInteractive(ctx context.Context, categoryCode string) (*models.CategoryMapping, error) {
+	categories, err := cm.Categories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	categories = lo.Reject(categories, func(c models.LunchMoneyCategory, _ int) bool { return c.IsGroup })
+
+	for i, category := range categories {
+		fmt.Printf("\t %-3d. %s\n", i, category.Name)
+	}
+
+	// invalid selections are asked again, without input the transaction is left
+	// uncategorized this time and the category code is asked for on the next sync
+	var selection int
+	for {
+		fmt.Printf("Enter the number of the category you want to map %q to or -1 to leave it uncategorized: ", categoryCode)
+		line, err := readLine(promptInput)
+		if err != nil {
+			fmt.Println()
+			log.Warn().Err(err).Str("categoryCode", categoryCode).Msg("No category selected, leaving the transaction uncategorized")
+			return nil, nil
+		}
+		selection, err = strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && selection >= -1 && selection < len(categories) {
+			break
+		}
+		fmt.Printf("Invalid selection %q, enter a number between -1 and %d.\n", strings.TrimSpace(line), len(categories)-1)
+	}
+
+	mapping := &models.CategoryMapping{CategoryCode: categoryCode, LunchMoneyCategoryId: models.Uncategorized}
+	if selection != -1 {
+		mapping.LunchMoneyCategoryId = categories[selection].ID
+	}
+	if err := cm.db.UpsertCategoryMapping(mapping); err != nil {
+		return nil, fmt.Errorf("failed to save category mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// promptInput is where the answers to prompts are read from
+var promptInput io.Reader = os.Stdin
+
+// readLine reads a line from r one byte at a time, so nothing after the line is
+// consumed. The last line may end without a newline.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), nil
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) != 0 {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// SetMapping maps a provider category code to a LunchMoney category, or leaves it
+// uncategorized when categoryId is models.Uncategorized
+func (cm *CategoryMapper) SetMapping(ctx context.Context, categoryCode string, categoryId int64) (*models.CategoryMapping, error) {
+	if categoryId != models.Uncategorized {
+		if _, err := cm.findCategory(ctx, categoryId); err != nil {
+			return nil, err
+		}
+	}
+
+	mapping := &models.CategoryMapping{CategoryCode: categoryCode, LunchMoneyCategoryId: categoryId}
+	if err := cm.db.UpsertCategoryMapping(mapping); err != nil {
+		return nil, fmt.Errorf("failed to save category mapping: %w", err)
+	}
+	return mapping, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestFindCategoryForTransaction(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.CategoryMappings = map[string]*models.CategoryMapping{
+		"5411": {CategoryCode: "5411", LunchMoneyCategoryId: 20},
+		"FEES": {CategoryCode: "FEES", LunchMoneyCategoryId: models.Uncategorized},
+	}
+	mockClient := &lm.MockLunchMoneyClient{Categories: []models.LunchMoneyCategory{
+		{ID: 1, Name: "Food", IsGroup: true},
+		{ID: 10, Name: "Coffee", GroupID: 1},
+		{ID: 20, Name: "Groceries", GroupID: 1},
+	}}
+
+	mapper := NewCategoryMapper(mockClient, mockDB)
+	mapper.SetNonInteractive(true)
+	mapper.SetCategoryRules([]config.CategoryRule{
+		{Contains: "starbucks", CategoryId: 10},
+		{Regex: "(?i)^amazon", Uncategorized: true},
+	})
+
+	newTx := func(merchant, code string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{Transaction: models.Transaction{
+			ReferenceNumber: merchant,
+			Merchant:        &models.Merchant{Name: merchant, CategoryCode: code},
+		}}
+	}
+	tests := []struct {
+		tx       *models.TransactionWithAccount
+		category int64
+	}{
+		{newTx("STARBUCKS #123", "5814"), 10},
+		{newTx("Amazon", "5411"), models.Uncategorized},
+		{newTx("Loblaws", "5411"), 20},
+		{newTx("Monthly fee", "FEES"), models.Uncategorized},
+		{newTx("Corner Store", ""), models.Uncategorized},
+		{newTx("Hardware Store", "5251"), models.Uncategorized},
+	}
+	for _, tt := range tests {
+		category, err := mapper.FindCategoryForTransaction(context.Background(), tt.tx)
+		if err != nil {
+			t.Fatalf("FindCategoryForTransaction(%s) returned error: %v", tt.tx.Merchant.Name, err)
+		}
+		if category != tt.category {
+			t.Errorf("FindCategoryForTransaction(%s): expected %d, got %d", tt.tx.Merchant.Name, tt.category, category)
+		}
+	}
+
+	if uncategorized := mapper.Uncategorized(); len(uncategorized) != 1 || uncategorized[0] != "5251" {
+		t.Errorf("Expected only 5251 to be reported as uncategorized, got %v", uncategorized)
+	}
+	if len(mockDB.Categories) != 3 {
+		t.Errorf("Expected the categories to be cached, got %+v", mockDB.Categories)
+	}
+
+	if _, err := mapper.SetMapping(context.Background(), "5251", 1); err == nil {
+		t.Errorf("Expected error mapping a category code to a category group")
+	}
+	if _, err := mapper.SetMapping(context.Background(), "5251", 99); err == nil {
+		t.Errorf("Expected error mapping a category code to an unknown category")
+	}
+}
+
+func TestInsertTransactionsWithCategory(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Mapped Account": {LunchMoneyId: 1, ExternalName: "Mapped Account"},
+	}
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
+	mockDB.CategoryMappings = map[string]*models.CategoryMapping{
+		"5411": {CategoryCode: "5411", LunchMoneyCategoryId: 20},
+	}
+	mockDB.Transactions["TX1"] = &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "TX1",
+			Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Loblaws", CategoryCode: "5411", Address: &models.Address{}},
+			Date:            time.Now().Format(time.DateOnly),
+		},
+		SourceAccountName: "Mapped Account",
+	}
+
+	mockClient := &lm.MockLunchMoneyClient{InsertedIDs: []int64{100}}
+	syncer := &LunchMoneySyncer{
+		client:         mockClient,
+		database:       mockDB,
+		accountMapper:  NewAccountMapperWithClient(mockClient, mockDB),
+		categoryMapper: NewCategoryMapper(mockClient, mockDB),
+	}
+
+	if err := syncer.SyncTransactions(context.Background()); err != nil {
+		t.Fatalf("SyncTransactions returned error: %v", err)
+	}
+	if len(mockClient.Inserted) != 1 || mockClient.Inserted[0].CategoryID != 20 {
+		t.Errorf("Expected TX1 to be inserted with category 20, got %+v", mockClient.Inserted)
+	}
+}
+
+func TestSelectCategoryInteractive(t *testing.T) {
+	t.Cleanup(func() { promptInput = os.Stdin })
+	mockDB := db.NewMockDB()
+	mockClient := &lm.MockLunchMoneyClient{Categories: []models.LunchMoneyCategory{
+		{ID: 10, Name: "Coffee"},
+		{ID: 20, Name: "Groceries"},
+	}}
+	mapper := NewCategoryMapper(mockClient, mockDB)
+	tx := &models.TransactionWithAccount{Transaction: models.Transaction{
+		ReferenceNumber: "TX1",
+		Amount:          models.Amount{Value: "4.50", Currency: "CAD"},
+		Merchant:        &models.Merchant{Name: "Cafe", CategoryCode: "5814"},
+	}}
+
+	// invalid selections are asked again
+	promptInput = strings.NewReader("coffee\n7\n1\n")
+	category, err := mapper.FindCategoryForTransaction(context.Background(), tx)
+	if err != nil || category != 20 {
+		t.Fatalf("Expected category 20 after the invalid selections, got %d: %v", category, err)
+	}
+	if mapping := mockDB.CategoryMappings["5814"]; mapping == nil || mapping.LunchMoneyCategoryId != 20 {
+		t.Errorf("Expected the selection to be stored, got %+v", mapping)
+	}
+
+	// without input the transaction is left uncategorized and nothing is stored
+	tx.Merchant.CategoryCode = "5411"
+	promptInput = strings.NewReader("")
+	category, err = mapper.FindCategoryForTransaction(context.Background(), tx)
+	if err != nil || category != models.Uncategorized {
+		t.Fatalf("Expected the transaction to be left uncategorized, got %d: %v", category, err)
+	}
+	if _, ok := mockDB.CategoryMappings["5411"]; ok {
+		t.Errorf("Expected no mapping to be stored without a selection")
+	}
+}
+
+func TestPlanDoesNotPromptForCategories(t *testing.T) {
+	t.Cleanup(func() { promptInput = os.Stdin })
+	promptInput = strings.NewReader("0\n")
+
+	mockDB := db.NewMockDB()
+	mockDB.UpsertAccountMapping(&models.AccountMapping{LunchMoneyId: 1, ExternalName: "Mapped Account"})
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
+	mockDB.Transactions["TX1"] = &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "TX1",
+			Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Loblaws", CategoryCode: "5411", Address: &models.Address{}},
+			Date:            time.Now().Format(time.DateOnly),
+		},
+		SourceAccountName: "Mapped Account",
+	}
+	mockClient := &lm.MockLunchMoneyClient{Categories: []models.LunchMoneyCategory{{ID: 20, Name: "Groceries"}}}
+	syncer := &LunchMoneySyncer{
+		client:         mockClient,
+		database:       mockDB,
+		accountMapper:  NewAccountMapperWithClient(mockClient, mockDB),
+		categoryMapper: NewCategoryMapper(mockClient, mockDB),
+	}
+
+	plan, err := syncer.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if len(plan.Inserts) != 1 || plan.Inserts[0].CategoryID != models.Uncategorized {
+		t.Errorf("Expected TX1 to be planned uncategorized, got %+v", plan.Inserts)
+	}
+	if len(mockDB.CategoryMappings) != 0 {
+		t.Errorf("Expected the plan not to store category mappings, got %+v", mockDB.CategoryMappings)
+	}
+}
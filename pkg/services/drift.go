@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// PlannedUpdate is a synced transaction whose upstream values drifted from LunchMoney
+type PlannedUpdate struct {
+	Transaction *models.TransactionWithAccount `json:"transaction"`
+	Update      *models.TransactionUpdate      `json:"update"`
+	// Preserved lists the fields that were edited in LunchMoney and are left untouched
+	Preserved []string `json:"preserved,omitempty"`
+}
+
+// planUpdates compares the synced local transactions against their LunchMoney
+// counterpart. A field is only updated when it changed locally since it was last
+// pushed and LunchMoney still holds the pushed value, otherwise the user edited it
+// in LunchMoney and it is preserved.
+// Transactions synced before their pushed values were recorded are returned as
+// baselines, LunchMoney's current values become their pushed values.
+func (l *LunchMoneySyncer) planUpdates(transactions []*models.TransactionWithAccount,
+	lunchTransactions []models.Transaction) ([]*PlannedUpdate, []*models.TransactionWithAccount) {
+	lunchById := make(map[int64]*models.Transaction, len(lunchTransactions))
+	for i := range lunchTransactions {
+		lunchById[lunchTransactions[i].LunchMoneyID] = &lunchTransactions[i]
+	}
+
+	updates, baselines := make([]*PlannedUpdate, 0), make([]*models.TransactionWithAccount, 0)
+	for _, transaction := range transactions {
+		if transaction.LunchMoneyID <= 0 {
+			continue
+		}
+
+		lunchTransaction, ok := lunchById[transaction.LunchMoneyID]
+		if !ok {
+			continue
+		}
+
+		if transaction.Synced == nil {
+			transaction.Synced = models.SyncedFieldsOf(lunchTransaction)
+			baselines = append(baselines, transaction)
+			continue
+		}
+
+		update, preserved := diffTransaction(transaction, lunchTransaction)
+		if len(preserved) != 0 {
+			log.Info().Str("transactionId", transaction.ReferenceNumber).Strs("fields", preserved).
+				Msg("Preserving fields edited in LunchMoney")
+		}
+		if update.IsEmpty() {
+			continue
+		}
+
+		updates = append(updates, &PlannedUpdate{
+			Transaction: transaction,
+			Update:      update,
+			Preserved:   preserved,
+		})
+	}
+	return updates, baselines
+}
+
+// diffTransaction returns the fields to push for a synced transaction and the
+// fields that were edited in LunchMoney
+func diffTransaction(local *models.TransactionWithAccount, remote *models.Transaction) (*models.TransactionUpdate, []string) {
+	update := &models.TransactionUpdate{}
+	preserved := make([]string, 0)
+	synced := local.Synced
+	syncedAmount := &models.Amount{Value: synced.Amount, Currency: local.Amount.Currency}
+
+	switch {
+	case !remote.Amount.Equal(syncedAmount):
+		preserved = append(preserved, "amount")
+	case !local.Amount.Equal(syncedAmount):
+		update.Amount = &models.Amount{Value: local.Amount.Value, Currency: local.Amount.Currency}
+	}
+
+	switch {
+	case remote.Date != synced.Date:
+		preserved = append(preserved, "date")
+	case local.Date != synced.Date:
+		update.Date = &local.Date
+	}
+
+	switch {
+	case remote.Merchant.Name != synced.Payee:
+		preserved = append(preserved, "payee")
+	case local.Merchant.Name != synced.Payee:
+		update.Payee = &local.Merchant.Name
+	}
+
+	return update, preserved
+}
+
+// applyUpdate pushes a planned update and records the new pushed values locally
+func (l *LunchMoneySyncer) applyUpdate(ctx context.Context, update *PlannedUpdate) error {
+	transaction := update.Transaction
+	log.Info().Str("transactionId", transaction.ReferenceNumber).Int64("lunchId", transaction.LunchMoneyID).
+		Msg("Updating transaction in LunchMoney with upstream changes")
+	if err := l.client.UpdateTransaction(ctx, transaction.LunchMoneyID, update.Update); err != nil {
+		return err
+	}
+
+	if transaction.Synced != nil {
+		synced := *transaction.Synced
+		if update.Update.Amount != nil {
+			synced.Amount = update.Update.Amount.Value
+		}
+		if update.Update.Date != nil {
+			synced.Date = *update.Update.Date
+		}
+		if update.Update.Payee != nil {
+			synced.Payee = *update.Update.Payee
+		}
+		transaction.Synced = &synced
+	}
+	if err := l.database.UpdateTransaction(transaction); err != nil {
+		return err
+	}
+	l.record(&transaction.Transaction, models.TransactionEventUpdated, "pushed upstream changes to LunchMoney")
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestPlanUpdates(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+	newSyncedTx := func(ref string, lunchId int64, amount, payee string, synced *models.SyncedFields) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				LunchMoneyID:    lunchId,
+				Amount:          models.Amount{Value: amount, Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: payee, Address: &models.Address{}},
+				Date:            today,
+				Synced:          synced,
+			},
+			SourceAccountName: "Test Account",
+		}
+	}
+
+	mockDB := db.NewMockDB()
+	// Tip added upstream after the transaction was pushed
+	mockDB.Transactions["TX1"] = newSyncedTx("TX1", 1, "55.00", "Restaurant", &models.SyncedFields{Amount: "45.00", Date: today, Payee: "Restaurant"})
+	// Payee renamed in LunchMoney, amount changed upstream
+	mockDB.Transactions["TX2"] = newSyncedTx("TX2", 2, "12.00", "Coffee", &models.SyncedFields{Amount: "10.00", Date: today, Payee: "Coffee"})
+	// Synced before pushed values were tracked
+	mockDB.Transactions["TX3"] = newSyncedTx("TX3", 3, "20.00", "Grocery", nil)
+	// Nothing changed
+	mockDB.Transactions["TX4"] = newSyncedTx("TX4", 4, "5.00", "Bakery", &models.SyncedFields{Amount: "5.00", Date: today, Payee: "Bakery"})
+
+	mockClient := &lm.MockLunchMoneyClient{
+		Transactions: []models.Transaction{
+			{LunchMoneyID: 1, ReferenceNumber: "TX1", Amount: models.Amount{Value: "45.0000", Currency: "cad"}, Merchant: &models.Merchant{Name: "Restaurant"}, Date: today},
+			{LunchMoneyID: 2, ReferenceNumber: "TX2", Amount: models.Amount{Value: "10.0000", Currency: "cad"}, Merchant: &models.Merchant{Name: "My Coffee Place"}, Date: today},
+			{LunchMoneyID: 3, ReferenceNumber: "TX3", Amount: models.Amount{Value: "20.0000", Currency: "cad"}, Merchant: &models.Merchant{Name: "Grocery Store"}, Date: today},
+			{LunchMoneyID: 4, ReferenceNumber: "TX4", Amount: models.Amount{Value: "5.0000", Currency: "cad"}, Merchant: &models.Merchant{Name: "Bakery"}, Date: today},
+		},
+	}
+
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
+	}
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to plan transactions: %v", err)
+	}
+
+	if len(plan.Updates) != 2 {
+		t.Fatalf("Expected 2 updates, got %d", len(plan.Updates))
+	}
+	if len(plan.Backfills) != 1 || plan.Backfills[0].ReferenceNumber != "TX3" {
+		t.Fatalf("Expected TX3 to be baselined, got %+v", plan.Backfills)
+	}
+
+	if err := syncer.ApplyTransactions(context.Background(), plan); err != nil {
+		t.Fatalf("Failed to apply plan: %v", err)
+	}
+
+	update := mockClient.UpdatedTransactions[1]
+	if update == nil || update.Amount == nil || update.Amount.Value != "55.00" || update.Payee != nil {
+		t.Errorf("Expected TX1 to only update the amount to 55.00, got %+v", update)
+	}
+
+	update = mockClient.UpdatedTransactions[2]
+	if update == nil || update.Amount == nil || update.Payee != nil {
+		t.Errorf("Expected TX2 to update the amount but keep the LunchMoney payee, got %+v", update)
+	}
+
+	if _, ok := mockClient.UpdatedTransactions[4]; ok {
+		t.Errorf("Expected TX4 not to be updated")
+	}
+
+	if synced := mockDB.Transactions["TX1"].Synced; synced.Amount != "55.00" {
+		t.Errorf("Expected TX1 pushed amount to be recorded, got %s", synced.Amount)
+	}
+	if synced := mockDB.Transactions["TX3"].Synced; synced == nil || synced.Payee != "Grocery Store" {
+		t.Errorf("Expected TX3 baseline to use the LunchMoney payee, got %+v", synced)
+	}
+}
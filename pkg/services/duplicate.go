@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+const (
+	// weights of the date, payee and account scores of a duplicate candidate,
+	// candidates must have the same amount
+	duplicateDateWeight    = 0.35
+	duplicatePayeeWeight   = 0.45
+	duplicateAccountWeight = 0.2
+	// duplicateMargin is how much better than the runner-up the best candidate
+	// must score to be matched without confirmation
+	duplicateMargin = 0.1
+	// sameReferenceReason is the reason of a LunchMoney transaction with the same reference number
+	sameReferenceReason = "same reference number"
+)
+
+// DuplicateCandidate is a LunchMoney transaction that may be the same as a local one
+type DuplicateCandidate struct {
+	LunchMoney models.Transaction `json:"lunchMoney"`
+	// Score is between 0 and 1, a higher score is a more likely duplicate
+	Score float64 `json:"score"`
+	// Reason explains the score
+	Reason string `json:"reason"`
+	// otherAccount is set when the transactions belong to different LunchMoney accounts
+	otherAccount bool
+}
+
+// DuplicateMatch is a local transaction and the LunchMoney transactions it may be the same as, best first
+type DuplicateMatch struct {
+	Transaction *models.TransactionWithAccount `json:"transaction"`
+	Candidates  []*DuplicateCandidate          `json:"candidates"`
+}
+
+// Best returns the candidate with the highest score
+func (m *DuplicateMatch) Best() *DuplicateCandidate {
+	return m.Candidates[0]
+}
+
+// SetDuplicateOptions configures how local transactions are matched against LunchMoney
+func (l *LunchMoneySyncer) SetDuplicateOptions(options config.DuplicateOptions) {
+	l.duplicates = options
+}
+
+// accountKey identifies a LunchMoney account, assets and Plaid accounts are numbered
+// separately so the same ID may be both. The zero key is an unknown account.
+type accountKey struct {
+	id      int64
+	isPlaid bool
+}
+
+// duplicateMatcher scores local transactions against the LunchMoney transactions of the sync window
+type duplicateMatcher struct {
+	accountMapper     *AccountMapper
+	options           config.DuplicateOptions
+	lunchTransactions []models.Transaction
+	// accounts are the LunchMoney accounts of the source accounts, zero when unknown
+	accounts map[string]accountKey
+	// rejected are the LunchMoney IDs each local transaction is known not to be the same as
+	rejected map[string]map[int64]bool
+	// claimed are the LunchMoney IDs already linked to a local transaction
+	claimed map[int64]bool
+}
+
+func (l *LunchMoneySyncer) newDuplicateMatcher(lunchTransactions []models.Transaction) (*duplicateMatcher, error) {
+	rejections, err := l.database.GetDuplicateRejections()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &duplicateMatcher{
+		accountMapper:     l.accountMapper,
+		options:           l.duplicates,
+		lunchTransactions: lunchTransactions,
+		accounts:          make(map[string]accountKey),
+		rejected:          make(map[string]map[int64]bool),
+		claimed:           make(map[int64]bool),
+	}
+	for _, rejection := range rejections {
+		if m.rejected[rejection.ReferenceNumber] == nil {
+			m.rejected[rejection.ReferenceNumber] = make(map[int64]bool)
+		}
+		m.rejected[rejection.ReferenceNumber][rejection.LunchMoneyID] = true
+	}
+	return m, nil
+}
+
+// accountOf returns the LunchMoney account a source account is mapped to like the inserts
+// are, or zero if it is unknown
+func (m *duplicateMatcher) accountOf(ctx context.Context, sourceAccountName string) (accountKey, error) {
+	if account, ok := m.accounts[sourceAccountName]; ok {
+		return account, nil
+	}
+
+	mapping, err := m.accountMapper.LookupAccount(ctx, sourceAccountName)
+	if err != nil {
+		return accountKey{}, err
+	}
+	var account accountKey
+	if mapping != nil && !mapping.IsIgnored() {
+		account = accountKey{id: mapping.LunchMoneyId, isPlaid: mapping.IsPlaid}
+	}
+	m.accounts[sourceAccountName] = account
+	return account, nil
+}
+
+// candidates returns the LunchMoney transactions that may be the same as transaction,
+// best first. A LunchMoney transaction with the same reference number is the only candidate.
+func (m *duplicateMatcher) candidates(transaction *models.TransactionWithAccount, account accountKey) []*DuplicateCandidate {
+	candidates := make([]*DuplicateCandidate, 0)
+	for i := range m.lunchTransactions {
+		lunchTransaction := m.lunchTransactions[i]
+		if transaction.ReferenceNumber == lunchTransaction.ReferenceNumber {
+			return []*DuplicateCandidate{{LunchMoney: lunchTransaction, Score: 1, Reason: sameReferenceReason}}
+		}
+		if m.claimed[lunchTransaction.LunchMoneyID] || m.rejected[transaction.ReferenceNumber][lunchTransaction.LunchMoneyID] {
+			continue
+		}
+
+		candidate := scoreDuplicate(transaction, account, &lunchTransaction, m.options.DateTolerance())
+		if candidate != nil && candidate.Score >= m.options.AmbiguityThreshold() {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// isMatch returns true if the best candidate is a duplicate without confirmation, a
+// candidate of another account always needs to be confirmed
+func (m *duplicateMatcher) isMatch(candidates []*DuplicateCandidate) bool {
+	best := candidates[0]
+	if best.Reason == sameReferenceReason {
+		return true
+	}
+	if best.otherAccount || best.Score < m.options.MatchThreshold() {
+		return false
+	}
+	return len(candidates) == 1 || best.Score-candidates[1].Score >= duplicateMargin
+}
+
+// scoreDuplicate scores how likely a LunchMoney transaction is the same as a local one.
+// It returns nil when the amounts differ or the dates are further apart than toleranceDays.
+// Transactions known to belong to different LunchMoney accounts get no account score.
+func scoreDuplicate(transaction *models.TransactionWithAccount, account accountKey,
+	lunchTransaction *models.Transaction, toleranceDays int) *DuplicateCandidate {
+	if !transaction.Amount.Equal(&lunchTransaction.Amount) {
+		return nil
+	}
+
+	date, err := time.Parse(time.DateOnly, transaction.Date)
+	if err != nil {
+		return nil
+	}
+	lunchDate, err := time.Parse(time.DateOnly, lunchTransaction.Date)
+	if err != nil {
+		return nil
+	}
+	days := int(lunchDate.Sub(date).Hours() / 24)
+	if days < 0 {
+		days = -days
+	}
+	if days > toleranceDays {
+		return nil
+	}
+
+	reasons := []string{"same amount"}
+	dateScore := 1 - float64(days)/float64(toleranceDays+1)
+	switch days {
+	case 0:
+		reasons = append(reasons, "same date")
+	case 1:
+		reasons = append(reasons, "1 day apart")
+	default:
+		reasons = append(reasons, fmt.Sprintf("%d days apart", days))
+	}
+
+	// rules may have renamed the local merchant, the original name may be closer to the LunchMoney payee
+	payeeScore := 0.0
+	if transaction.Merchant != nil && lunchTransaction.Merchant != nil {
+		payeeScore = payeeSimilarity(transaction.Merchant.Name, lunchTransaction.Merchant.Name)
+		if transaction.Merchant.OriginalName != "" {
+			payeeScore = max(payeeScore, payeeSimilarity(transaction.Merchant.OriginalName, lunchTransaction.Merchant.Name))
+		}
+	}
+	if payeeScore == 1 {
+		reasons = append(reasons, "same payee")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("payee %.0f%% similar", payeeScore*100))
+	}
+
+	accountScore, otherAccount := 0.5, false
+	lunchAccount := accountKey{id: lunchTransaction.LunchMoneyAccountID, isPlaid: lunchTransaction.LunchMoneyAccountIsPlaid}
+	switch {
+	case account.id == 0 || lunchAccount.id == 0:
+		reasons = append(reasons, "account unknown")
+	case account == lunchAccount:
+		accountScore = 1
+		reasons = append(reasons, "same account")
+	default:
+		accountScore, otherAccount = 0, true
+		reasons = append(reasons, "different account")
+	}
+
+	score := duplicateDateWeight*dateScore + duplicatePayeeWeight*payeeScore + duplicateAccountWeight*accountScore
+	return &DuplicateCandidate{
+		LunchMoney:   *lunchTransaction,
+		Score:        score,
+		Reason:       fmt.Sprintf("%s (score %.2f)", strings.Join(reasons, ", "), score),
+		otherAccount: otherAccount,
+	}
+}
+
+// payeeSimilarity compares two payees from 0 to 1 ignoring case, punctuation and
+// store numbers. It is the higher of their edit distance similarity and the share
+// of words they have in common.
+func payeeSimilarity(a, b string) float64 {
+	wordsA, wordsB := payeeWords(a), payeeWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	joinedA, joinedB := []rune(strings.Join(wordsA, " ")), []rune(strings.Join(wordsB, " "))
+	editScore := 1 - float64(levenshtein(joinedA, joinedB))/float64(max(len(joinedA), len(joinedB)))
+
+	common := len(lo.Intersect(lo.Uniq(wordsA), lo.Uniq(wordsB)))
+	wordScore := float64(common) / float64(min(len(lo.Uniq(wordsA)), len(lo.Uniq(wordsB))))
+	// a payee that only shares some words with a longer one is not as good as an equal payee
+	if len(lo.Uniq(wordsA)) != len(lo.Uniq(wordsB)) {
+		wordScore *= 0.9
+	}
+	return max(editScore, wordScore)
+}
+
+// payeeWords splits a payee into lower case words without punctuation and numbers
+func payeeWords(payee string) []string {
+	words := strings.FieldsFunc(strings.ToLower(payee), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return lo.Filter(words, func(word string, _ int) bool {
+		return strings.IndexFunc(word, unicode.IsLetter) >= 0
+	})
+}
+
+// levenshtein returns the number of single character edits between a and b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// FindAmbiguousDuplicates returns the unsynced transactions of the sync window that may be
+// the same as a LunchMoney transaction and are not synced until they are reviewed
+func (l *LunchMoneySyncer) FindAmbiguousDuplicates(ctx context.Context) ([]*DuplicateMatch, error) {
+	transactions, err := l.database.GetTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	window := l.syncWindow()
+	recentTransactions := make([]*models.TransactionWithAccount, 0)
+	for _, transaction := range transactions {
+		inWindow, err := window.ContainsDate(transaction.Date)
+		if err != nil {
+			return nil, err
+		}
+		if inWindow && transaction.VanishedAt == nil {
+			recentTransactions = append(recentTransactions, transaction)
+		}
+	}
+
+	lunchTransactions, err := l.listLunchMoneyTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transactions are only read, matched ones are not saved
+	_, _, ambiguous, err := l.matchLunchMoneyTransactions(ctx, recentTransactions, lunchTransactions)
+	return ambiguous, err
+}
+
+// findAmbiguousDuplicate returns the ambiguous match of a local transaction
+func (l *LunchMoneySyncer) findAmbiguousDuplicate(ctx context.Context, referenceNumber string) (*DuplicateMatch, error) {
+	ambiguous, err := l.FindAmbiguousDuplicates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	match, ok := lo.Find(ambiguous, func(m *DuplicateMatch) bool {
+		return m.Transaction.ReferenceNumber == referenceNumber
+	})
+	if !ok {
+		return nil, fmt.Errorf("transaction %s has no possible duplicate to review", referenceNumber)
+	}
+	return match, nil
+}
+
+// ConfirmDuplicate links a local transaction to the LunchMoney transaction it is the same as,
+// it is not inserted and only gets the LunchMoney ID stored
+func (l *LunchMoneySyncer) ConfirmDuplicate(ctx context.Context, referenceNumber string, lunchMoneyID int64) error {
+	match, err := l.findAmbiguousDuplicate(ctx, referenceNumber)
+	if err != nil {
+		return err
+	}
+	candidate, ok := lo.Find(match.Candidates, func(c *DuplicateCandidate) bool {
+		return c.LunchMoney.LunchMoneyID == lunchMoneyID
+	})
+	if !ok {
+		return fmt.Errorf("LunchMoney transaction %d is not a possible duplicate of %s", lunchMoneyID, referenceNumber)
+	}
+
+	transaction := match.Transaction
+	transaction.LunchMoneyID = lunchMoneyID
+	transaction.Synced = models.SyncedFieldsOf(&candidate.LunchMoney)
+	if err := l.database.UpdateTransaction(transaction); err != nil {
+		return err
+	}
+	l.record(&transaction.Transaction, models.TransactionEventLinked,
+		fmt.Sprintf("confirmed as a duplicate of LunchMoney transaction %d: %s", lunchMoneyID, candidate.Reason))
+	return nil
+}
+
+// RejectDuplicate records that a local transaction is none of its possible duplicates,
+// it is inserted into LunchMoney by the next sync
+func (l *LunchMoneySyncer) RejectDuplicate(ctx context.Context, referenceNumber string) error {
+	match, err := l.findAmbiguousDuplicate(ctx, referenceNumber)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(match.Candidates))
+	for _, candidate := range match.Candidates {
+		if err := l.database.AddDuplicateRejection(referenceNumber, candidate.LunchMoney.LunchMoneyID); err != nil {
+			return err
+		}
+		ids = append(ids, fmt.Sprint(candidate.LunchMoney.LunchMoneyID))
+	}
+	l.record(&match.Transaction.Transaction, models.TransactionEventUpdated,
+		"not a duplicate of LunchMoney transactions "+strings.Join(ids, ", "))
+	return nil
+}
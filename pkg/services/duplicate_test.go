@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestPayeeSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"STARBUCKS #1234", "Starbucks", 1, 1},
+		{"Starbucks Coffee", "Starbucks", 0.9, 0.9},
+		{"TIM HORTONS", "Tim Horton's", 0.8, 0.95},
+		{"Loblaws", "Shell", 0, 0.3},
+		{"1234", "Shell", 0, 0},
+	}
+	for _, tt := range tests {
+		if similarity := payeeSimilarity(tt.a, tt.b); similarity < tt.min || similarity > tt.max {
+			t.Errorf("payeeSimilarity(%q, %q) = %.2f, expected between %.2f and %.2f", tt.a, tt.b, similarity, tt.min, tt.max)
+		}
+	}
+}
+
+func TestFuzzyDuplicateMatching(t *testing.T) {
+	day := func(offset int) string {
+		return time.Now().AddDate(0, 0, offset).Format(time.DateOnly)
+	}
+	newTx := func(ref, merchant, amount, date string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				Amount:          models.Amount{Value: amount, Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: merchant, Address: &models.Address{}},
+				Date:            date,
+			},
+			SourceAccountName: "Visa",
+		}
+	}
+	lunchTx := func(id int64, payee, amount, date string, account int64) models.Transaction {
+		return models.Transaction{
+			LunchMoneyID:             id,
+			Amount:                   models.Amount{Value: amount, Currency: "CAD"},
+			Merchant:                 &models.Merchant{Name: payee},
+			Date:                     date,
+			LunchMoneyAccountID:      account,
+			LunchMoneyAccountIsPlaid: true,
+		}
+	}
+	// asset 7 is another account than Plaid account 7
+	assetTx := lunchTx(105, "Impark", "12.00", day(-1), 7)
+	assetTx.LunchMoneyAccountIsPlaid = false
+
+	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 7, SyncStrategy: models.AllSyncOption}}
+	mockClient := &lm.MockLunchMoneyClient{Accounts: []models.LunchMoneyAccount{{LunchMoneyId: 7, IsPlaid: true}}, Transactions: []models.Transaction{
+		// a Plaid entry posted a day later with a cleaner payee
+		lunchTx(100, "Starbucks", "5.25", day(-1), 7),
+		// the same payee in another account needs to be reviewed
+		lunchTx(101, "Shell", "60.00", day(-2), 8),
+		// another payee in another account is not a duplicate
+		assetTx,
+		// two equally close candidates need to be reviewed
+		lunchTx(102, "Amazon", "20.00", day(-3), 7),
+		lunchTx(103, "Amazon", "20.00", day(-3), 7),
+		// a payee that only shares a word needs to be reviewed
+		lunchTx(104, "Cineplex Entertainment LP", "30.00", day(-4), 7),
+	}}
+	// the account is only known from its rule when the duplicates are scored
+	accountMapper := NewAccountMapperWithClient(mockClient, mockDB)
+	accountMapper.SetMappingRules([]config.AccountMappingRule{{Name: "Visa", LunchMoneyId: 7}})
+	syncer := &LunchMoneySyncer{
+		client:         mockClient,
+		database:       mockDB,
+		accountMapper:  accountMapper,
+		categoryMapper: NewCategoryMapper(mockClient, mockDB),
+	}
+
+	transactions := []*models.TransactionWithAccount{
+		newTx("COFFEE", "STARBUCKS #1234", "5.25", day(-2)),
+		newTx("GAS", "Shell", "60.00", day(-2)),
+		newTx("BOOKS", "AMAZON.CA", "20.00", day(-3)),
+		newTx("MOVIE", "CINEPLEX ODEON", "30.00", day(-4)),
+		newTx("PARKING", "City Parking", "12.00", day(-1)),
+	}
+	for _, tx := range transactions {
+		mockDB.Transactions[tx.ReferenceNumber] = tx
+	}
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("PlanTransactions returned error: %v", err)
+	}
+
+	if len(plan.Matches) != 1 || plan.Matches[0].Transaction.ReferenceNumber != "COFFEE" ||
+		plan.Matches[0].Best().LunchMoney.LunchMoneyID != 100 {
+		t.Fatalf("Expected COFFEE to match LunchMoney transaction 100, got %+v", plan.Matches)
+	}
+	if reason := plan.Matches[0].Best().Reason; reason != "same amount, 1 day apart, same payee, same account (score 0.91)" {
+		t.Errorf("Unexpected match reason %q", reason)
+	}
+
+	ambiguous := make(map[string]int)
+	for _, match := range plan.Ambiguous {
+		ambiguous[match.Transaction.ReferenceNumber] = len(match.Candidates)
+	}
+	if len(ambiguous) != 3 || ambiguous["BOOKS"] != 2 || ambiguous["MOVIE"] != 1 || ambiguous["GAS"] != 1 {
+		t.Errorf("Expected BOOKS, MOVIE and GAS to need a review, got %v", ambiguous)
+	}
+
+	inserted := make(map[string]bool)
+	for _, insert := range plan.Inserts {
+		inserted[insert.Transaction.ReferenceNumber] = true
+	}
+	if len(inserted) != 1 || !inserted["PARKING"] {
+		t.Errorf("Expected only PARKING to be inserted, got %v", inserted)
+	}
+
+	// reviewing the ambiguous transactions
+	if err := syncer.ConfirmDuplicate(context.Background(), "BOOKS", 104); err == nil {
+		t.Errorf("Expected error confirming a LunchMoney transaction that is not a candidate")
+	}
+	if err := syncer.ConfirmDuplicate(context.Background(), "BOOKS", 103); err != nil {
+		t.Fatalf("ConfirmDuplicate returned error: %v", err)
+	}
+	if tx := mockDB.Transactions["BOOKS"]; tx.LunchMoneyID != 103 || tx.Synced == nil {
+		t.Errorf("Expected BOOKS to be linked to 103, got %d", tx.LunchMoneyID)
+	}
+
+	for _, ref := range []string{"MOVIE", "GAS"} {
+		if err := syncer.RejectDuplicate(context.Background(), ref); err != nil {
+			t.Fatalf("RejectDuplicate returned error: %v", err)
+		}
+	}
+	remaining, err := syncer.FindAmbiguousDuplicates(context.Background())
+	if err != nil {
+		t.Fatalf("FindAmbiguousDuplicates returned error: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("Expected no transaction left to review, got %d", len(remaining))
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// RunRecorder records a fetch or sync run and the events of the transactions it
+// touched. Recording failures are logged and never fail the run itself, and a nil
+// recorder records nothing.
+type RunRecorder struct {
+	database db.DBInterface
+	run      *models.SyncRun
+}
+
+// StartRun records the start of a run, provider is empty for sync runs
+func StartRun(database db.DBInterface, kind models.SyncRunKind, provider string) *RunRecorder {
+	run := &models.SyncRun{Kind: kind, Provider: provider, StartedAt: time.Now()}
+	if err := database.StartSyncRun(run); err != nil {
+		log.Warn().Err(err).Msg("Failed to record run start")
+	}
+	return &RunRecorder{database: database, run: run}
+}
+
+// RunID returns the ID of the recorded run, it is zero when there is none
+func (r *RunRecorder) RunID() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.run.ID
+}
+
+// SetCounts sets the transaction counts of the run
+func (r *RunRecorder) SetCounts(fetched, inserted, updated, skipped int) {
+	if r == nil {
+		return
+	}
+	r.run.Fetched, r.run.Inserted, r.run.Updated, r.run.Skipped = fetched, inserted, updated, skipped
+}
+
+// Record appends an event of the run to the audit trail of a transaction
+func (r *RunRecorder) Record(transaction *models.Transaction, event models.TransactionEventType, detail string) {
+	if r == nil {
+		return
+	}
+	RecordTransactionEvent(r.database, r.run.ID, transaction, event, detail)
+}
+
+// Finish records the end of the run and the error it failed with, if any
+func (r *RunRecorder) Finish(runErr error) {
+	if r == nil {
+		return
+	}
+	r.run.FinishedAt = lo.ToPtr(time.Now())
+	if runErr != nil {
+		r.run.Error = runErr.Error()
+	}
+	observeRun(r.run)
+	if err := r.database.FinishSyncRun(r.run); err != nil {
+		log.Warn().Err(err).Int64("run", r.run.ID).Msg("Failed to record run end")
+	}
+}
+
+// RecordTransactionEvent appends an event to the audit trail of a transaction, runID
+// is zero for changes made outside of a fetch or sync
+func RecordTransactionEvent(database db.DBInterface, runID int64, transaction *models.Transaction,
+	event models.TransactionEventType, detail string) {
+	err := database.AddTransactionEvent(&models.TransactionEvent{
+		RunID:           runID,
+		ReferenceNumber: transaction.ReferenceNumber,
+		Event:           event,
+		LunchMoneyID:    max(transaction.LunchMoneyID, 0),
+		Detail:          detail,
+		CreatedAt:       time.Now(),
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("transactionId", transaction.ReferenceNumber).Str("event", string(event)).
+			Msg("Failed to record transaction event")
+	}
+}
+
+// SetRecorder records the events of the following syncs as part of the run
+func (l *LunchMoneySyncer) SetRecorder(recorder *RunRecorder) {
+	l.recorder = recorder
+}
+
+// record appends an event to the audit trail of a transaction as part of the current run
+func (l *LunchMoneySyncer) record(transaction *models.Transaction, event models.TransactionEventType, detail string) {
+	RecordTransactionEvent(l.database, l.recorder.RunID(), transaction, event, detail)
+}
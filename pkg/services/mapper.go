@@ -3,8 +3,14 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
 	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
 	"github.com/vpnda/sandwich-sync/pkg/http/lm"
 	"github.com/vpnda/sandwich-sync/pkg/models"
 )
@@ -17,6 +23,14 @@ type AccountMapper struct {
 	client          lm.LunchMoneyClientInterface
 	db              db.DBInterface
 	selectedAccount *models.AccountMapping
+	rules           []config.AccountMappingRule
+	// ruleMappings caches the mappings of the configured rules by external account,
+	// the API server reads them while a sync runs
+	ruleMappings   map[string]*models.AccountMapping
+	ruleMappingsMu sync.Mutex
+	nonInteractive bool
+	// unmapped are the external accounts skipped in non-interactive mode
+	unmapped map[string]bool
 }
 
 func NewAccountMapper(ctx context.Context, apiKey string, database db.DBInterface) (*AccountMapper, error) {
@@ -39,19 +53,315 @@ func NewAccountMapperWithClient(client lm.LunchMoneyClientInterface, database db
 	}
 }
 
-func (is *AccountMapper) FindPossibleAccountForTransaction(ctx context.Context, transaction *models.TransactionWithAccount) (*models.AccountMapping, error) {
-	// Fetch mapping from the database
-	mapping, err := is.db.GetAccountMapping(transaction.SourceAccountName)
+// SetMappingRules sets the configured rules applied to unknown external accounts before prompting
+func (is *AccountMapper) SetMappingRules(rules []config.AccountMappingRule) {
+	is.ruleMappingsMu.Lock()
+	defer is.ruleMappingsMu.Unlock()
+	is.rules = rules
+	is.ruleMappings = nil
+}
+
+// SetNonInteractive makes the mapper skip unknown external accounts instead of prompting
+func (is *AccountMapper) SetNonInteractive(nonInteractive bool) {
+	is.nonInteractive = nonInteractive
+}
+
+// Unmapped returns the external accounts that were skipped in non-interactive mode
+func (is *AccountMapper) Unmapped() []string {
+	names := make([]string, 0, len(is.unmapped))
+	for name := range is.unmapped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MatchingRule returns the first configured rule matching an external account, or nil
+func (is *AccountMapper) MatchingRule(externalName string) *config.AccountMappingRule {
+	for i := range is.rules {
+		if is.rules[i].Matches(externalName) {
+			return &is.rules[i]
+		}
+	}
+	return nil
+}
+
+// ruleMapping returns the mapping the first matching configured rule gives an external
+// account, or nil when no rule matches
+func (is *AccountMapper) ruleMapping(ctx context.Context, externalName string) (*models.AccountMapping, error) {
+	is.ruleMappingsMu.Lock()
+	defer is.ruleMappingsMu.Unlock()
+	if mapping, ok := is.ruleMappings[externalName]; ok {
+		return mapping, nil
+	}
+
+	rule := is.MatchingRule(externalName)
+	if rule == nil {
+		return nil, nil
+	}
+
+	mapping := &models.AccountMapping{ExternalName: externalName, LunchMoneyId: -1, FromRule: true}
+	if !rule.Ignore {
+		account, err := is.findLunchMoneyAccount(ctx, rule.LunchMoneyId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account mapping rule %s: %w", rule.String(), err)
+		}
+		mapping.LunchMoneyId = account.LunchMoneyId
+		mapping.IsPlaid = account.IsPlaid
+	}
+
+	if is.ruleMappings == nil {
+		is.ruleMappings = make(map[string]*models.AccountMapping)
+	}
+	is.ruleMappings[externalName] = mapping
+	log.Info().Str("account", externalName).Str("rule", rule.String()).Int64("lunchMoneyId", mapping.LunchMoneyId).
+		Msg("Mapped account with configured rule")
+	return mapping, nil
+}
+
+// findMapping returns the mapping of an external account. The first matching configured
+// rule wins over the stored mappings, so editing a rule takes effect on the next run.
+// With store, the rule mapping is stored marked as coming from a rule, so the balance
+// and sync options of its account are kept like for a selected mapping, and a stored
+// rule mapping is removed once no rule matches the account anymore.
+func (is *AccountMapper) findMapping(ctx context.Context, externalName string, store bool) (*models.AccountMapping, error) {
+	mapping, err := is.ruleMapping(ctx, externalName)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := is.db.GetAccountMapping(externalName)
 	if err != nil {
 		return nil, err
 	}
 
-	if mapping != nil {
+	if mapping == nil {
+		if stored == nil || !stored.FromRule {
+			return stored, nil
+		}
+		if store {
+			if err := is.db.DeleteAccountMapping(externalName); err != nil {
+				return nil, err
+			}
+			log.Info().Str("account", externalName).Msg("Removed account mapping of a rule that no longer matches")
+		}
+		return nil, nil
+	}
+
+	if stored != nil && stored.FromRule && stored.IsPlaid == mapping.IsPlaid &&
+		(stored.LunchMoneyId == mapping.LunchMoneyId || stored.IsIgnored() && mapping.IsIgnored()) {
+		return stored, nil
+	}
+	if !store {
 		return mapping, nil
 	}
 
-	if is.selectedAccount != nil {
-		return is.selectedAccount, nil
+	// the stored ignored mapping gets the ID of its ignored account, the cached one is kept
+	stored = lo.ToPtr(*mapping)
+	if err := is.db.UpsertAccountMapping(stored); err != nil {
+		return nil, fmt.Errorf("failed to save account mapping: %w", err)
+	}
+	return stored, nil
+}
+
+// ApplyMappingRules returns the stored mappings as the next sync maps their accounts, the
+// configured rules win over them, followed by the mappings the rules give the other
+// external accounts. A balance is dropped when a rule maps its account elsewhere.
+func (is *AccountMapper) ApplyMappingRules(ctx context.Context, stored []*models.AccountMappingDetails,
+	externalNames []string) ([]*models.AccountMappingDetails, error) {
+	mappings := make([]*models.AccountMappingDetails, 0, len(stored))
+	known := make(map[string]bool)
+	for _, details := range stored {
+		known[details.ExternalName] = true
+		mapping, err := is.LookupAccount(ctx, details.ExternalName)
+		if err != nil {
+			return nil, err
+		}
+		if mapping == nil {
+			continue
+		}
+		applied := *details
+		if mapping.LunchMoneyId != details.LunchMoneyId {
+			applied.Balance, applied.BalanceUpdatedAt = nil, nil
+		}
+		applied.AccountMapping = *mapping
+		mappings = append(mappings, &applied)
+	}
+
+	for _, externalName := range externalNames {
+		if known[externalName] {
+			continue
+		}
+		known[externalName] = true
+		mapping, err := is.LookupAccount(ctx, externalName)
+		if err != nil {
+			return nil, err
+		}
+		if mapping != nil {
+			mappings = append(mappings, &models.AccountMappingDetails{AccountMapping: *mapping})
+		}
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].ExternalName < mappings[j].ExternalName
+	})
+	return mappings, nil
+}
+
+// findLunchMoneyAccount returns the LunchMoney account with the given ID
+func (is *AccountMapper) findLunchMoneyAccount(ctx context.Context, lunchMoneyId int64) (*models.LunchMoneyAccount, error) {
+	accounts, err := is.client.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, ok := lo.Find(accounts, func(a models.LunchMoneyAccount) bool {
+		return a.LunchMoneyId == lunchMoneyId
+	})
+	if !ok {
+		return nil, fmt.Errorf("unknown LunchMoney account %d", lunchMoneyId)
+	}
+	return &account, nil
+}
+
+// reassignedDetail is the detail of the event recorded for a transaction moved to a
+// LunchMoney account by SetMapping
+const reassignedDetail = "reassigned to LunchMoney account %d"
+
+// SetMapping maps an external account to a LunchMoney account, or always ignores it
+// when lunchMoneyId is -1. With reassign, the transactions already synced from the
+// external account are moved to the new LunchMoney account and their count returned.
+// The mapping is saved before the transactions are moved, when one can not be moved
+// setting the mapping again moves the remaining ones. Accounts matched by a configured
+// rule follow the rule and can not be mapped.
+func (is *AccountMapper) SetMapping(ctx context.Context, externalName string, lunchMoneyId int64, reassign bool) (*models.AccountMapping, int, error) {
+	if rule := is.MatchingRule(externalName); rule != nil {
+		return nil, 0, fmt.Errorf("%s is mapped by the configured rule %s, change the rule instead", externalName, rule.String())
+	}
+
+	mapping := &models.AccountMapping{ExternalName: externalName, LunchMoneyId: -1}
+	if lunchMoneyId >= 0 {
+		account, err := is.findLunchMoneyAccount(ctx, lunchMoneyId)
+		if err != nil {
+			return nil, 0, err
+		}
+		mapping.LunchMoneyId = account.LunchMoneyId
+		mapping.IsPlaid = account.IsPlaid
+	}
+
+	if reassign && mapping.IsIgnored() {
+		return nil, 0, fmt.Errorf("can not reassign transactions to an ignored account")
+	}
+	if reassign && mapping.IsPlaid {
+		return nil, 0, fmt.Errorf("can not reassign transactions to Plaid account %d", mapping.LunchMoneyId)
+	}
+
+	if err := is.db.UpsertAccountMapping(mapping); err != nil {
+		return nil, 0, fmt.Errorf("failed to save account mapping: %w", err)
+	}
+	if !reassign {
+		return mapping, 0, nil
+	}
+
+	moved, err := is.reassignTransactions(ctx, externalName, mapping.LunchMoneyId)
+	if err != nil {
+		return nil, moved, fmt.Errorf("mapping of %s saved, set it again to move the remaining transactions: %w", externalName, err)
+	}
+	return mapping, moved, nil
+}
+
+// reassignTransactions moves the synced transactions of an external account to a
+// LunchMoney account and records an event for each, the ones an earlier call already
+// moved there are skipped
+func (is *AccountMapper) reassignTransactions(ctx context.Context, externalName string, lunchMoneyId int64) (int, error) {
+	transactions, err := is.db.GetTransactions()
+	if err != nil {
+		return 0, err
+	}
+
+	detail := fmt.Sprintf(reassignedDetail, lunchMoneyId)
+	moved := 0
+	for _, transaction := range transactions {
+		if transaction.SourceAccountName != externalName || transaction.LunchMoneyID <= 0 {
+			continue
+		}
+		reassigned, err := is.isReassigned(&transaction.Transaction, detail)
+		if err != nil {
+			return moved, err
+		}
+		if reassigned {
+			continue
+		}
+
+		err = is.client.UpdateTransaction(ctx, transaction.LunchMoneyID, &models.TransactionUpdate{
+			AssetID: lo.ToPtr(lunchMoneyId),
+		})
+		if err != nil {
+			return moved, fmt.Errorf("failed to reassign transaction %s: %w", transaction.ReferenceNumber, err)
+		}
+		RecordTransactionEvent(is.db, 0, &transaction.Transaction, models.TransactionEventUpdated, detail)
+		moved++
+	}
+	return moved, nil
+}
+
+// isReassigned reports whether the last reassignment recorded for a transaction has the given detail
+func (is *AccountMapper) isReassigned(transaction *models.Transaction, detail string) (bool, error) {
+	events, err := is.db.GetTransactionEvents(transaction.ReferenceNumber)
+	if err != nil {
+		return false, err
+	}
+	prefix, _, _ := strings.Cut(reassignedDetail, "%d")
+	for i := len(events) - 1; i >= 0; i-- {
+		if strings.HasPrefix(events[i].Detail, prefix) {
+			return events[i].Detail == detail, nil
+		}
+	}
+	return false, nil
+}
+
+// skipUnmapped records an unknown external account that can not be prompted for
+func (is *AccountMapper) skipUnmapped(externalName string) {
+	if is.unmapped == nil {
+		is.unmapped = make(map[string]bool)
+	}
+	if !is.unmapped[externalName] {
+		log.Warn().Str("account", externalName).Msg("Skipping unmapped account in non-interactive mode")
+	}
+	is.unmapped[externalName] = true
+}
+
+// LookupAccount returns the mapping the configured rules or the stored mappings give an
+// external account, or nil when it is unknown. It never prompts nor stores a mapping.
+func (is *AccountMapper) LookupAccount(ctx context.Context, externalName string) (*models.AccountMapping, error) {
+	return is.findMapping(ctx, externalName, false)
+}
+
+// LookupAccountForTransaction returns the mapping the configured rules, the stored
+// mappings or the selected default account give a transaction, or nil when its
+// source account is unknown. It never prompts nor stores a mapping, so it is safe
+// for dry runs.
+func (is *AccountMapper) LookupAccountForTransaction(ctx context.Context, transaction *models.TransactionWithAccount) (*models.AccountMapping, error) {
+	return is.findAccountForTransaction(ctx, transaction, false)
+}
+
+// findAccountForTransaction returns the mapping of the source account of a transaction
+// or the selected default account, with store the mapping of a rule is stored
+func (is *AccountMapper) findAccountForTransaction(ctx context.Context, transaction *models.TransactionWithAccount, store bool) (*models.AccountMapping, error) {
+	mapping, err := is.findMapping(ctx, transaction.SourceAccountName, store)
+	if err != nil || mapping != nil {
+		return mapping, err
+	}
+	return is.selectedAccount, nil
+}
+
+func (is *AccountMapper) FindPossibleAccountForTransaction(ctx context.Context, transaction *models.TransactionWithAccount) (*models.AccountMapping, error) {
+	mapping, err := is.findAccountForTransaction(ctx, transaction, true)
+	if err != nil || mapping != nil {
+		return mapping, err
+	}
+
+	if is.nonInteractive {
+		is.skipUnmapped(transaction.SourceAccountName)
+		return nil, nil
 	}
 
 	fmt.Printf("Could not find account for transaction [%s] %s (%s). Please select one:\n",
@@ -60,8 +370,7 @@ func (is *AccountMapper) FindPossibleAccountForTransaction(ctx context.Context,
 }
 
 func (is *AccountMapper) FindPossibleAccountForExternal(ctx context.Context, externalAccount *models.ExternalAccount) (*models.AccountMapping, error) {
-	// Fetch mapping from the database
-	mapping, err := is.db.GetAccountMapping(externalAccount.Name)
+	mapping, err := is.findMapping(ctx, externalAccount.Name, true)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +379,11 @@ func (is *AccountMapper) FindPossibleAccountForExternal(ctx context.Context, ext
 		return mapping, nil
 	}
 
+	if is.nonInteractive {
+		is.skipUnmapped(externalAccount.Name)
+		return nil, nil
+	}
+
 	fmt.Printf("Could not find account for external account [%s] %s (%s). Please select one:\n",
 		externalAccount.Name, externalAccount.Name, externalAccount.Balance.ToMoney().Display())
 	return is.selectAccountInteractive(externalAccount.Name, externalAccount.Description)
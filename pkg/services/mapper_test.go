@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestAccountMapperRules(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockClient := lm.NewMockLunchMoneyClient()
+	mockClient.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 7, Name: "Visa", IsPlaid: true}}
+
+	mapper := NewAccountMapperWithClient(mockClient, mockDB)
+	mapper.SetMappingRules([]config.AccountMappingRule{
+		{Glob: "Visa*", LunchMoneyId: 7},
+		{Regex: "^TFSA", Ignore: true},
+		{Name: "Typo", LunchMoneyId: 99},
+	})
+	mapper.SetNonInteractive(true)
+
+	tx := func(account string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction:       models.Transaction{ReferenceNumber: "TX", Merchant: &models.Merchant{}},
+			SourceAccountName: account,
+		}
+	}
+
+	mapping, err := mapper.FindPossibleAccountForTransaction(context.Background(), tx("Visa Infinite"))
+	if err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if mapping == nil || mapping.LunchMoneyId != 7 || !mapping.IsPlaid {
+		t.Errorf("Expected Visa Infinite to be mapped to account 7, got %+v", mapping)
+	}
+	if stored := mockDB.AccountMappings["Visa Infinite"]; stored == nil || stored.LunchMoneyId != 7 || !stored.FromRule {
+		t.Errorf("Expected the rule mapping to be stored as coming from a rule, got %+v", stored)
+	}
+
+	mapping, err = mapper.FindPossibleAccountForTransaction(context.Background(), tx("TFSA 2024"))
+	if err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if mapping == nil || mapping.LunchMoneyId >= 0 {
+		t.Errorf("Expected TFSA 2024 to be ignored, got %+v", mapping)
+	}
+	if stored := mockDB.AccountMappings["TFSA 2024"]; stored == nil || !stored.IsIgnored() || !stored.FromRule {
+		t.Errorf("Expected the ignore rule to be stored as coming from a rule, got %+v", stored)
+	}
+
+	// rules win over the stored mappings
+	if err := mockDB.UpsertAccountMapping(&models.AccountMapping{ExternalName: "Visa Gold", LunchMoneyId: -1}); err != nil {
+		t.Fatalf("Failed to store mapping: %v", err)
+	}
+	mapping, err = mapper.FindPossibleAccountForTransaction(context.Background(), tx("Visa Gold"))
+	if err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if mapping == nil || mapping.LunchMoneyId != 7 {
+		t.Errorf("Expected Visa Gold to be mapped to account 7 by its rule, got %+v", mapping)
+	}
+
+	if _, err := mapper.FindPossibleAccountForTransaction(context.Background(), tx("Typo")); err == nil {
+		t.Errorf("Expected an error for a rule referring to an unknown LunchMoney account")
+	}
+
+	mapping, err = mapper.FindPossibleAccountForTransaction(context.Background(), tx("Chequing"))
+	if err != nil {
+		t.Fatalf("Expected unmapped account to be skipped, got error: %v", err)
+	}
+	if mapping != nil {
+		t.Errorf("Expected no mapping for Chequing, got %+v", mapping)
+	}
+	if unmapped := mapper.Unmapped(); len(unmapped) != 1 || unmapped[0] != "Chequing" {
+		t.Errorf("Expected Chequing to be reported as unmapped, got %v", unmapped)
+	}
+
+	if _, _, err := mapper.SetMapping(context.Background(), "Visa Infinite", 7, false); err == nil {
+		t.Errorf("Expected an error setting the mapping of an account matched by a rule")
+	}
+
+	// the stored mapping of a removed rule is removed, the selected ones are kept
+	mapper.SetMappingRules([]config.AccountMappingRule{{Regex: "^TFSA", Ignore: true}})
+	mapping, err = mapper.FindPossibleAccountForTransaction(context.Background(), tx("Visa Infinite"))
+	if err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if mapping != nil || mockDB.AccountMappings["Visa Infinite"] != nil {
+		t.Errorf("Expected the mapping of the removed rule to be removed, got %+v", mapping)
+	}
+	mapping, err = mapper.FindPossibleAccountForTransaction(context.Background(), tx("Visa Gold"))
+	if err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if mapping != nil {
+		t.Errorf("Expected the rule mapping stored over the selected one to be removed, got %+v", mapping)
+	}
+}
+
+func TestApplyMappingRules(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockClient := lm.NewMockLunchMoneyClient()
+	mockClient.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 7, Name: "Visa"}}
+	mapper := NewAccountMapperWithClient(mockClient, mockDB)
+	mapper.SetMappingRules([]config.AccountMappingRule{{Glob: "Visa*", LunchMoneyId: 7}})
+
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Chequing":  {ExternalName: "Chequing", LunchMoneyId: 1},
+		"Visa Gold": {ExternalName: "Visa Gold", LunchMoneyId: 2},
+	}
+	balance := &models.Amount{Value: "10.00", Currency: "CAD"}
+	stored := []*models.AccountMappingDetails{
+		{AccountMapping: *mockDB.AccountMappings["Chequing"], Balance: balance},
+		{AccountMapping: *mockDB.AccountMappings["Visa Gold"], Balance: balance},
+	}
+	mappings, err := mapper.ApplyMappingRules(context.Background(), stored, []string{"Visa Infinite", "Chequing", "Unknown"})
+	if err != nil {
+		t.Fatalf("Failed to apply mapping rules: %v", err)
+	}
+	if len(mappings) != 3 {
+		t.Fatalf("Expected 3 mappings, got %d", len(mappings))
+	}
+	if m := mappings[0]; m.ExternalName != "Chequing" || m.LunchMoneyId != 1 || m.FromRule || m.Balance == nil {
+		t.Errorf("Expected the stored mapping of Chequing to be kept, got %+v", m)
+	}
+	if m := mappings[1]; m.ExternalName != "Visa Gold" || m.LunchMoneyId != 7 || !m.FromRule || m.Balance != nil {
+		t.Errorf("Expected Visa Gold to be mapped by its rule without the balance of account 2, got %+v", m)
+	}
+	if m := mappings[2]; m.ExternalName != "Visa Infinite" || m.LunchMoneyId != 7 || !m.FromRule {
+		t.Errorf("Expected Visa Infinite to be mapped by its rule, got %+v", m)
+	}
+}
+
+func TestRuleMappedAccountSyncs(t *testing.T) {
+	database, err := db.New(filepath.Join(t.TempDir(), "sandwich.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+	if err := database.Initialize(); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	mockClient := lm.NewMockLunchMoneyClient()
+	mockClient.Accounts = []models.LunchMoneyAccount{{
+		LunchMoneyId:       7,
+		Name:               "Visa",
+		Balance:            models.Amount{Value: "10.00", Currency: "CAD"},
+		BalanceLastUpdated: lo.ToPtr(time.Now().AddDate(0, 0, -7)),
+	}}
+	mapper := NewAccountMapperWithClient(mockClient, database)
+	mapper.SetMappingRules([]config.AccountMappingRule{{Glob: "Visa*", LunchMoneyId: 7}})
+	mapper.SetNonInteractive(true)
+	syncer := &LunchMoneySyncer{
+		client:         mockClient,
+		database:       database,
+		accountMapper:  mapper,
+		categoryMapper: NewCategoryMapper(mockClient, database),
+	}
+
+	// a fetch maps the account before storing its balance
+	account := &models.ExternalAccount{Name: "Visa Infinite", Balance: models.Amount{Value: "25.00", Currency: "CAD"}}
+	if _, err := mapper.FindPossibleAccountForExternal(context.Background(), account); err != nil {
+		t.Fatalf("Failed to map account: %v", err)
+	}
+	if err := database.UpsertAccountBalance(account.Name, account.Balance, time.Now()); err != nil {
+		t.Fatalf("Failed to store balance: %v", err)
+	}
+	err = database.SaveTransaction(&models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "TX1",
+			Amount:          models.Amount{Value: "5.00", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Cafe", Address: &models.Address{}},
+			Date:            time.Now().Format(time.DateOnly),
+		},
+		SourceAccountName: account.Name,
+	})
+	if err != nil {
+		t.Fatalf("Failed to store transaction: %v", err)
+	}
+
+	plan, err := syncer.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+	if len(plan.Inserts) != 1 || plan.Inserts[0].Mapping.LunchMoneyId != 7 {
+		t.Errorf("Expected the transaction of the rule mapped account to be inserted, got %+v skipped %+v", plan.Inserts, plan.Skipped)
+	}
+	if len(plan.BalanceUpdates) != 1 || plan.BalanceUpdates[0].To.Value != "25.00" {
+		t.Errorf("Expected the balance of the rule mapped account to be updated, got %+v", plan.BalanceUpdates)
+	}
+}
+
+func TestAccountMapperSetMapping(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Visa": {ExternalName: "Visa", LunchMoneyId: 1},
+	}
+	mockDB.Transactions["SYNCED"] = &models.TransactionWithAccount{
+		Transaction:       models.Transaction{ReferenceNumber: "SYNCED", LunchMoneyID: 500},
+		SourceAccountName: "Visa",
+	}
+	mockDB.Transactions["UNSYNCED"] = &models.TransactionWithAccount{
+		Transaction:       models.Transaction{ReferenceNumber: "UNSYNCED"},
+		SourceAccountName: "Visa",
+	}
+
+	mockClient := lm.NewMockLunchMoneyClient()
+	mockClient.Accounts = []models.LunchMoneyAccount{
+		{LunchMoneyId: 1, Name: "Old"},
+		{LunchMoneyId: 2, Name: "New"},
+		{LunchMoneyId: 3, Name: "Plaid", IsPlaid: true},
+	}
+	mapper := NewAccountMapperWithClient(mockClient, mockDB)
+
+	if _, _, err := mapper.SetMapping(context.Background(), "Visa", 42, false); err == nil {
+		t.Errorf("Expected an error for an unknown LunchMoney account")
+	}
+	if _, _, err := mapper.SetMapping(context.Background(), "Visa", 3, true); err == nil {
+		t.Errorf("Expected an error when reassigning to a Plaid account")
+	}
+
+	// a failed reassign saves the mapping, setting it again moves the remaining transactions
+	mockClient.UpdateTransactionErr = errors.New("request failed")
+	if _, _, err := mapper.SetMapping(context.Background(), "Visa", 2, true); err == nil {
+		t.Errorf("Expected an error when a transaction can not be moved")
+	}
+	if mockDB.AccountMappings["Visa"].LunchMoneyId != 2 {
+		t.Errorf("Expected Visa to be mapped to account 2, got %+v", mockDB.AccountMappings["Visa"])
+	}
+	mockClient.UpdateTransactionErr = nil
+
+	mapping, reassigned, err := mapper.SetMapping(context.Background(), "Visa", 2, true)
+	if err != nil {
+		t.Fatalf("Failed to set mapping: %v", err)
+	}
+	if mapping.LunchMoneyId != 2 || mockDB.AccountMappings["Visa"].LunchMoneyId != 2 {
+		t.Errorf("Expected Visa to be mapped to account 2, got %+v", mockDB.AccountMappings["Visa"])
+	}
+	if reassigned != 1 {
+		t.Errorf("Expected 1 reassigned transaction, got %d", reassigned)
+	}
+	update, ok := mockClient.UpdatedTransactions[500]
+	if !ok || update.AssetID == nil || *update.AssetID != 2 {
+		t.Errorf("Expected transaction 500 to be moved to account 2, got %+v", update)
+	}
+	if events, _ := mockDB.GetTransactionEvents("SYNCED"); len(events) != 1 || events[0].Detail != "reassigned to LunchMoney account 2" {
+		t.Errorf("Expected the move of SYNCED to be recorded, got %+v", events)
+	}
+
+	// the transactions already moved are not moved again
+	_, reassigned, err = mapper.SetMapping(context.Background(), "Visa", 2, true)
+	if err != nil || reassigned != 0 {
+		t.Errorf("Expected no transaction to be moved again, got %d: %v", reassigned, err)
+	}
+	_, reassigned, err = mapper.SetMapping(context.Background(), "Visa", 1, true)
+	if err != nil || reassigned != 1 {
+		t.Errorf("Expected SYNCED to be moved back to account 1, got %d: %v", reassigned, err)
+	}
+}
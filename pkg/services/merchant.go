@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// MerchantNormalizer rewrites raw merchant names into canonical payees with the
+// configured merchant rules. A nil normalizer leaves names untouched.
+type MerchantNormalizer struct {
+	rules []merchantRule
+}
+
+type merchantRule struct {
+	config.MerchantRule
+	re *regexp.Regexp
+}
+
+// NewMerchantNormalizer compiles the rules in the order they are tried
+func NewMerchantNormalizer(rules []config.MerchantRule) (*MerchantNormalizer, error) {
+	n := &MerchantNormalizer{rules: make([]merchantRule, 0, len(rules))}
+	for i, rule := range rules {
+		compiled := merchantRule{MerchantRule: rule}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid merchant rule %d: %w", i+1, err)
+			}
+			compiled.re = re
+		}
+		n.rules = append(n.rules, compiled)
+	}
+	sort.SliceStable(n.rules, func(i, j int) bool {
+		return n.rules[i].Priority > n.rules[j].Priority
+	})
+	return n, nil
+}
+
+// Rules returns the rules in the order they are tried
+func (n *MerchantNormalizer) Rules() []config.MerchantRule {
+	if n == nil {
+		return nil
+	}
+	rules := make([]config.MerchantRule, 0, len(n.rules))
+	for _, rule := range n.rules {
+		rules = append(rules, rule.MerchantRule)
+	}
+	return rules
+}
+
+// Canonical returns the payee of the first rule matching raw, or false when none matches
+func (n *MerchantNormalizer) Canonical(raw string) (string, bool) {
+	if n == nil {
+		return raw, false
+	}
+	for _, rule := range n.rules {
+		if rule.re == nil {
+			if strings.Contains(strings.ToLower(raw), strings.ToLower(rule.Contains)) {
+				return rule.Name, true
+			}
+			continue
+		}
+		if match := rule.re.FindStringSubmatchIndex(raw); match != nil {
+			return string(rule.re.ExpandString(nil, rule.Name, raw, match)), true
+		}
+	}
+	return raw, false
+}
+
+// Normalize rewrites the merchant name of a transaction from its original name and
+// returns true if it changed. The original name is kept while a rule matches and
+// restored once no rule matches anymore, so the rules can be re-applied at any time.
+func (n *MerchantNormalizer) Normalize(tx *models.Transaction) bool {
+	if n == nil || tx.Merchant == nil {
+		return false
+	}
+
+	raw := tx.Merchant.OriginalName
+	if raw == "" {
+		raw = tx.Merchant.Name
+	}
+
+	name, matched := n.Canonical(raw)
+	originalName := ""
+	if matched {
+		originalName = raw
+	}
+
+	changed := name != tx.Merchant.Name || originalName != tx.Merchant.OriginalName
+	tx.Merchant.Name = name
+	tx.Merchant.OriginalName = originalName
+	return changed
+}
+
+// SetMerchantNormalizer rewrites the merchant names of unsynced transactions before they are inserted
+func (l *LunchMoneySyncer) SetMerchantNormalizer(normalizer *MerchantNormalizer) {
+	l.normalizer = normalizer
+}
+
+// GetMerchantNormalizer returns the merchant normalizer, it is nil when none is set
+func (l *LunchMoneySyncer) GetMerchantNormalizer() *MerchantNormalizer {
+	return l.normalizer
+}
+
+// MerchantRename is a stored transaction whose merchant name changes with the current rules
+type MerchantRename struct {
+	Transaction *models.TransactionWithAccount
+	From        string
+	To          string
+}
+
+// ApplyMerchantRules re-applies the merchant rules to every stored transaction and returns
+// the renamed ones, nothing is saved on a dry run. Synced transactions keep the payee
+// pushed to LunchMoney as their baseline, so the next sync updates them in LunchMoney.
+func (l *LunchMoneySyncer) ApplyMerchantRules(dryRun bool) ([]*MerchantRename, error) {
+	transactions, err := l.database.GetTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	renames := make([]*MerchantRename, 0)
+	for _, transaction := range transactions {
+		if transaction.Merchant == nil {
+			continue
+		}
+		// rename a copy so a dry run leaves the loaded transactions untouched
+		renamed, merchant := *transaction, *transaction.Merchant
+		renamed.Merchant = &merchant
+		if !l.normalizer.Normalize(&renamed.Transaction) {
+			continue
+		}
+
+		from := transaction.Merchant.Name
+		if from != merchant.Name {
+			renames = append(renames, &MerchantRename{Transaction: &renamed, From: from, To: merchant.Name})
+		}
+		if dryRun {
+			continue
+		}
+
+		// a changed original name alone is saved without an event
+		if err := l.database.UpdateTransaction(&renamed); err != nil {
+			return nil, err
+		}
+		if from != merchant.Name {
+			l.record(&renamed.Transaction, models.TransactionEventUpdated,
+				fmt.Sprintf("merchant renamed from %q to %q", from, merchant.Name))
+		}
+	}
+	return renames, nil
+}
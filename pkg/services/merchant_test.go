@@ -0,0 +1,103 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestMerchantNormalizer(t *testing.T) {
+	normalizer, err := NewMerchantNormalizer([]config.MerchantRule{
+		{Contains: "amzn mktp", Name: "Amazon"},
+		{Regex: `(?i)^uber`, Name: "Uber"},
+		{Regex: `(?i)^uber\s*\*?\s*eats`, Name: "Uber Eats", Priority: 10},
+		{Regex: `^SQ \*(\w+)`, Name: "$1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+
+	tests := []struct {
+		raw     string
+		name    string
+		matched bool
+	}{
+		{"AMZN Mktp CA*2X4KL", "Amazon", true},
+		{"UBER *EATS PENDING", "Uber Eats", true},
+		{"UBER *TRIP", "Uber", true},
+		{"SQ *BLUEBOTTLE Toronto", "BLUEBOTTLE", true},
+		{"Corner Store", "Corner Store", false},
+	}
+	for _, tt := range tests {
+		name, matched := normalizer.Canonical(tt.raw)
+		if name != tt.name || matched != tt.matched {
+			t.Errorf("Canonical(%q): expected %q %v, got %q %v", tt.raw, tt.name, tt.matched, name, matched)
+		}
+	}
+
+	tx := &models.Transaction{Merchant: &models.Merchant{Name: "UBER *TRIP"}}
+	if !normalizer.Normalize(tx) || tx.Merchant.Name != "Uber" || tx.Merchant.OriginalName != "UBER *TRIP" {
+		t.Errorf("Expected merchant to be renamed and keep its original name, got %+v", tx.Merchant)
+	}
+	if normalizer.Normalize(tx) {
+		t.Errorf("Expected normalizing twice to change nothing")
+	}
+
+	// the original name is restored once no rule matches
+	var none *MerchantNormalizer
+	if none.Normalize(tx) {
+		t.Errorf("Expected nil normalizer to change nothing")
+	}
+	empty, _ := NewMerchantNormalizer(nil)
+	if !empty.Normalize(tx) || tx.Merchant.Name != "UBER *TRIP" || tx.Merchant.OriginalName != "" {
+		t.Errorf("Expected original name to be restored, got %+v", tx.Merchant)
+	}
+}
+
+func TestApplyMerchantRules(t *testing.T) {
+	mockDB := db.NewMockDB()
+	newTx := func(ref, merchant string, lunchMoneyId int64) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: merchant, Address: &models.Address{}},
+				Date:            "2025-04-20",
+				LunchMoneyID:    lunchMoneyId,
+			},
+		}
+	}
+	mockDB.Transactions["AMZN"] = newTx("AMZN", "AMZN Mktp CA*2X4KL", 42)
+	mockDB.Transactions["OTHER"] = newTx("OTHER", "Corner Store", 0)
+
+	normalizer, err := NewMerchantNormalizer([]config.MerchantRule{{Contains: "amzn", Name: "Amazon"}})
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+	syncer := &LunchMoneySyncer{database: mockDB}
+	syncer.SetMerchantNormalizer(normalizer)
+
+	renames, err := syncer.ApplyMerchantRules(true)
+	if err != nil {
+		t.Fatalf("ApplyMerchantRules returned error: %v", err)
+	}
+	if len(renames) != 1 || renames[0].To != "Amazon" {
+		t.Fatalf("Expected one rename to Amazon, got %+v", renames)
+	}
+	if mockDB.Transactions["AMZN"].Merchant.Name != "AMZN Mktp CA*2X4KL" || len(mockDB.TransactionEvents) != 0 {
+		t.Errorf("Expected dry run to leave the database untouched")
+	}
+
+	if _, err := syncer.ApplyMerchantRules(false); err != nil {
+		t.Fatalf("ApplyMerchantRules returned error: %v", err)
+	}
+	renamed := mockDB.Transactions["AMZN"]
+	if renamed.Merchant.Name != "Amazon" || renamed.Merchant.OriginalName != "AMZN Mktp CA*2X4KL" {
+		t.Errorf("Expected merchant to be renamed, got %+v", renamed.Merchant)
+	}
+	if len(mockDB.TransactionEvents) != 1 || mockDB.TransactionEvents[0].Event != models.TransactionEventUpdated {
+		t.Errorf("Expected one updated event, got %+v", mockDB.TransactionEvents)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+var (
+	runDuration = promauto.With(metrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sandwich_sync_run_duration_seconds",
+		Help:    "Duration of the fetch and sync runs.",
+		Buckets: metrics.DefaultBuckets,
+	}, []string{"kind", "provider"})
+	runsTotal = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "sandwich_sync_runs_total",
+		Help: "Fetch and sync runs by result.",
+	}, []string{"kind", "provider", "result"})
+	runTransactions = promauto.With(metrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "sandwich_sync_run_transactions_total",
+		Help: "Transactions fetched, inserted, updated and skipped by the runs.",
+	}, []string{"kind", "provider", "action"})
+	lastSuccessfulRun = promauto.With(metrics.Registry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandwich_sync_last_success_timestamp_seconds",
+		Help: "When a run last succeeded, as a Unix timestamp.",
+	}, []string{"kind", "provider"})
+	accountLastSync = promauto.With(metrics.Registry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandwich_sync_account_last_sync_timestamp_seconds",
+		Help: "When the transactions and balances were last synced successfully with a LunchMoney account, as a Unix timestamp.",
+	}, []string{"account_id", "account"})
+	balanceDrift = promauto.With(metrics.Registry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandwich_sync_balance_drift",
+		Help: "Local balance of an account minus its LunchMoney balance.",
+	}, []string{"account_id", "account", "currency"})
+)
+
+// observeRun records the duration, result and counts of a finished run
+func observeRun(run *models.SyncRun) {
+	kind, provider := string(run.Kind), run.Provider
+	if run.FinishedAt != nil {
+		runDuration.WithLabelValues(kind, provider).Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+	}
+
+	if run.Error != "" {
+		runsTotal.WithLabelValues(kind, provider, "error").Inc()
+		return
+	}
+	runsTotal.WithLabelValues(kind, provider, "success").Inc()
+	lastSuccessfulRun.WithLabelValues(kind, provider).SetToCurrentTime()
+	for action, count := range map[string]int{
+		"fetched": run.Fetched, "inserted": run.Inserted, "updated": run.Updated, "skipped": run.Skipped,
+	} {
+		runTransactions.WithLabelValues(kind, provider, action).Add(float64(count))
+	}
+}
+
+// accountLabel returns the name an account is labeled with
+func accountLabel(account models.LunchMoneyAccount) string {
+	if account.DisplayName != "" {
+		return account.DisplayName
+	}
+	return account.Name
+}
+
+// observeBalanceDrift records how far the local balance of an account is from LunchMoney's
+func observeBalanceDrift(local models.LunchMoneyAccount, lunchMoney models.LunchMoneyAccount) {
+	localBalance, err := strconv.ParseFloat(local.Balance.Value, 64)
+	if err != nil {
+		return
+	}
+	lunchMoneyBalance, err := strconv.ParseFloat(lunchMoney.Balance.Value, 64)
+	if err != nil {
+		return
+	}
+	balanceDrift.WithLabelValues(strconv.FormatInt(lunchMoney.LunchMoneyId, 10), accountLabel(lunchMoney), local.Balance.Currency).
+		Set(localBalance - lunchMoneyBalance)
+}
+
+// clearBalanceDrift records that LunchMoney was updated with the local balance
+func clearBalanceDrift(update *BalanceUpdate) {
+	balanceDrift.WithLabelValues(strconv.FormatInt(update.LunchMoneyId, 10), update.AccountName, update.To.Currency).Set(0)
+}
+
+// observeAccountSyncs records when the accounts were synced
+func observeAccountSyncs(accounts []models.LunchMoneyAccount, at time.Time) {
+	for _, account := range accounts {
+		accountLastSync.WithLabelValues(strconv.FormatInt(account.LunchMoneyId, 10), accountLabel(account)).Set(float64(at.Unix()))
+	}
+}
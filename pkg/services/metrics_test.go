@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/metrics"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func writtenMetrics(t *testing.T) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestRunMetrics(t *testing.T) {
+	mockDB := db.NewMockDB()
+
+	recorder := StartRun(mockDB, models.SyncRunKindFetch, "metrics-test")
+	recorder.SetCounts(5, 3, 1, 1)
+	recorder.Finish(nil)
+	StartRun(mockDB, models.SyncRunKindFetch, "metrics-test").Finish(errors.New("login failed"))
+
+	written := writtenMetrics(t)
+	for _, line := range []string{
+		`sandwich_sync_runs_total{kind="fetch",provider="metrics-test",result="success"} 1`,
+		`sandwich_sync_runs_total{kind="fetch",provider="metrics-test",result="error"} 1`,
+		`sandwich_sync_run_transactions_total{action="inserted",kind="fetch",provider="metrics-test"} 3`,
+		`sandwich_sync_run_duration_seconds_count{kind="fetch",provider="metrics-test"} 2`,
+		`sandwich_sync_last_success_timestamp_seconds{kind="fetch",provider="metrics-test"}`,
+	} {
+		if !strings.Contains(written, line) {
+			t.Errorf("Expected metrics to contain %s", line)
+		}
+	}
+}
+
+func TestBalanceMetrics(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{
+		LunchMoneyId:       9001,
+		Balance:            models.Amount{Value: "150.25", Currency: "CAD"},
+		BalanceLastUpdated: lo.ToPtr(time.Now()),
+		SyncStrategy:       models.AllSyncOption,
+	}}
+	mockClient := &metricsBalanceClient{MockLunchMoneyClient: &lm.MockLunchMoneyClient{
+		Accounts: []models.LunchMoneyAccount{{
+			LunchMoneyId:       9001,
+			Name:               "Metrics Visa",
+			Balance:            models.Amount{Value: "100.00", Currency: "CAD"},
+			BalanceLastUpdated: lo.ToPtr(time.Now().Add(-time.Hour)),
+		}},
+	}}
+	syncer := &LunchMoneySyncer{client: mockClient, database: mockDB}
+
+	if _, err := syncer.PlanBalances(context.Background()); err != nil {
+		t.Fatalf("Failed to plan balances: %v", err)
+	}
+	drift := `sandwich_sync_balance_drift{account="Metrics Visa",account_id="9001",currency="CAD"} `
+	if written := writtenMetrics(t); !strings.Contains(written, drift+"50.25\n") {
+		t.Errorf("Expected a drift of 50.25, got:\n%s", written)
+	}
+
+	if err := syncer.SyncBalances(context.Background()); err != nil {
+		t.Fatalf("Failed to sync balances: %v", err)
+	}
+	written := writtenMetrics(t)
+	if !strings.Contains(written, drift+"0\n") {
+		t.Errorf("Expected no drift once the balance was synced, got:\n%s", written)
+	}
+	if !strings.Contains(written, `sandwich_sync_account_last_sync_timestamp_seconds{account="Metrics Visa",account_id="9001"}`) {
+		t.Errorf("Expected the last sync of the account to be recorded, got:\n%s", written)
+	}
+}
+
+// metricsBalanceClient accepts balance updates
+type metricsBalanceClient struct {
+	*lm.MockLunchMoneyClient
+}
+
+func (c *metricsBalanceClient) UpdateAccountBalance(ctx context.Context, id int64, balance models.Amount, since *time.Time) error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// pendingSettleDays is how many days after its authorization a pending transaction may post
+const pendingSettleDays = 7
+
+// PostedMatch is a posted transaction that replaces the pending transaction it settled from
+type PostedMatch struct {
+	Pending *models.TransactionWithAccount `json:"pending"`
+	Posted  *models.TransactionWithAccount `json:"posted"`
+}
+
+// matchPostedTransactions pairs the posted transactions that were never synced with the
+// pending transaction they settled from. Institutions often assign a new reference number
+// when a transaction posts, so the pair is matched on account, amount and date instead.
+// A pending transaction is only matched once the provider stopped returning it, so two
+// identical purchases are not merged. A posted transaction that already took over the
+// LunchMoney ID of a pending one that is still stored is matched to it again, so an
+// interrupted sync finishes removing the pending transaction.
+func matchPostedTransactions(transactions []*models.TransactionWithAccount) []*PostedMatch {
+	pending := make([]*models.TransactionWithAccount, 0)
+	pendingIDs := make(map[int64]bool)
+	for _, transaction := range transactions {
+		if transaction.IsPending() && transaction.VanishedAt != nil && transaction.VanishedAction == "" &&
+			transaction.LunchMoneyID >= 0 {
+			pending = append(pending, transaction)
+			if transaction.LunchMoneyID > 0 {
+				pendingIDs[transaction.LunchMoneyID] = true
+			}
+		}
+	}
+	posted := make([]*models.TransactionWithAccount, 0)
+	for _, transaction := range transactions {
+		if transaction.Status == models.TransactionStatusPosted && transaction.VanishedAt == nil &&
+			(transaction.LunchMoneyID == 0 || pendingIDs[transaction.LunchMoneyID]) {
+			posted = append(posted, transaction)
+		}
+	}
+
+	// match in a stable order, the oldest posted transactions first
+	sort.Slice(posted, func(i, j int) bool {
+		if posted[i].Date != posted[j].Date {
+			return posted[i].Date < posted[j].Date
+		}
+		return posted[i].ReferenceNumber < posted[j].ReferenceNumber
+	})
+
+	matches := make([]*PostedMatch, 0)
+	used := make(map[*models.TransactionWithAccount]bool)
+	for _, transaction := range posted {
+		postedDate, err := time.Parse(time.DateOnly, transaction.Date)
+		if err != nil {
+			continue
+		}
+
+		var best *models.TransactionWithAccount
+		var bestDays int
+		for _, candidate := range pending {
+			if used[candidate] || candidate.SourceAccountName != transaction.SourceAccountName ||
+				!candidate.Amount.Equal(&transaction.Amount) ||
+				(transaction.LunchMoneyID != 0 && candidate.LunchMoneyID != transaction.LunchMoneyID) {
+				continue
+			}
+
+			pendingDate, err := time.Parse(time.DateOnly, candidate.Date)
+			if err != nil {
+				continue
+			}
+			days := int(postedDate.Sub(pendingDate).Hours() / 24)
+			if days < 0 || days > pendingSettleDays {
+				continue
+			}
+			if best == nil || days < bestDays ||
+				(days == bestDays && candidate.ReferenceNumber < best.ReferenceNumber) {
+				best, bestDays = candidate, days
+			}
+		}
+
+		if best == nil {
+			continue
+		}
+		used[best] = true
+		log.Info().Str("pendingId", best.ReferenceNumber).Str("transactionId", transaction.ReferenceNumber).
+			Msg("Pending transaction posted")
+		matches = append(matches, &PostedMatch{Pending: best, Posted: transaction})
+	}
+	return matches
+}
+
+// withPostedReferences makes the updates of posted transactions that took over a
+// LunchMoney entry also replace the pending reference number stored in LunchMoney
+func withPostedReferences(updates []*PlannedUpdate, matches []*PostedMatch) []*PlannedUpdate {
+	for _, match := range matches {
+		transaction := match.Posted
+		if transaction.LunchMoneyID <= 0 || transaction.Synced == nil {
+			continue
+		}
+
+		update, ok := lo.Find(updates, func(u *PlannedUpdate) bool { return u.Transaction == transaction })
+		if !ok {
+			update = &PlannedUpdate{Transaction: transaction, Update: &models.TransactionUpdate{}}
+			updates = append(updates, update)
+		}
+		update.Update.ExternalID = &transaction.ReferenceNumber
+	}
+	return updates
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// newTestTx returns a coffee purchase of the account, the services tests share it
+func newTestTx(ref, account, date, amount string, status models.TransactionStatus) *models.TransactionWithAccount {
+	return &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: ref,
+			Amount:          models.Amount{Value: amount, Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Coffee", Address: &models.Address{}},
+			Date:            date,
+			Status:          status,
+		},
+		SourceAccountName: account,
+	}
+}
+
+func TestMatchPostedTransactions(t *testing.T) {
+	vanishedAt := time.Now()
+	pending := newTestTx("P1", "Visa", "2025-04-10", "4.50", models.TransactionStatusPending)
+	pending.VanishedAt = &vanishedAt
+	stillPending := newTestTx("P2", "Visa", "2025-04-10", "4.50", models.TransactionStatusPending)
+	posted := newTestTx("S1", "Visa", "2025-04-12", "4.50", models.TransactionStatusPosted)
+	otherAmount := newTestTx("S2", "Visa", "2025-04-12", "9.00", models.TransactionStatusPosted)
+	tooLate := newTestTx("S3", "Visa", "2025-04-30", "4.50", models.TransactionStatusPosted)
+
+	matches := matchPostedTransactions([]*models.TransactionWithAccount{
+		pending, stillPending, posted, otherAmount, tooLate,
+	})
+
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matches))
+	}
+	if matches[0].Pending != pending || matches[0].Posted != posted {
+		t.Errorf("Expected P1 to be matched to S1, got %s -> %s",
+			matches[0].Pending.ReferenceNumber, matches[0].Posted.ReferenceNumber)
+	}
+}
+
+func TestMatchPostedTransactionsInterrupted(t *testing.T) {
+	vanishedAt := time.Now()
+	pending := newTestTx("P1", "Visa", "2025-04-10", "4.50", models.TransactionStatusPending)
+	pending.VanishedAt = &vanishedAt
+	pending.LunchMoneyID = 42
+	// the posted transaction took over the LunchMoney ID but the pending one was not removed
+	posted := newTestTx("S1", "Visa", "2025-04-12", "4.50", models.TransactionStatusPosted)
+	posted.LunchMoneyID = 42
+	otherSynced := newTestTx("S2", "Visa", "2025-04-12", "4.50", models.TransactionStatusPosted)
+	otherSynced.LunchMoneyID = 7
+
+	matches := matchPostedTransactions([]*models.TransactionWithAccount{otherSynced, pending, posted})
+
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matches))
+	}
+	if matches[0].Pending != pending || matches[0].Posted != posted {
+		t.Errorf("Expected P1 to be matched to S1, got %s -> %s",
+			matches[0].Pending.ReferenceNumber, matches[0].Posted.ReferenceNumber)
+	}
+}
+
+func TestPlanPostedTransactions(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+	vanishedAt := time.Now()
+
+	pending := newTestTx("PENDING", "Visa", today, "4.50", models.TransactionStatusPending)
+	pending.VanishedAt = &vanishedAt
+	pending.LunchMoneyID = 42
+	pending.Synced = models.SyncedFieldsOf(&pending.Transaction)
+	posted := newTestTx("POSTED", "Visa", today, "4.50", models.TransactionStatusPosted)
+	posted.Merchant.Name = "Coffee Shop"
+
+	mockDB := db.NewMockDB()
+	mockDB.Transactions["PENDING"] = pending
+	mockDB.Transactions["POSTED"] = posted
+
+	lunchTx := pending.Transaction
+	lunchTx.Merchant = &models.Merchant{Name: "Coffee"}
+	mockClient := &lm.MockLunchMoneyClient{Transactions: []models.Transaction{lunchTx}}
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
+	}
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to plan transactions: %v", err)
+	}
+
+	if len(plan.Inserts) != 0 {
+		t.Errorf("Expected the posted transaction not to be inserted, got %+v", plan.Inserts)
+	}
+	if len(plan.Vanished) != 0 {
+		t.Errorf("Expected the pending transaction not to be reported as vanished, got %+v", plan.Vanished)
+	}
+	if len(plan.Updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(plan.Updates))
+	}
+	update := plan.Updates[0].Update
+	if update.Payee == nil || *update.Payee != "Coffee Shop" || update.ExternalID == nil || *update.ExternalID != "POSTED" {
+		t.Errorf("Expected payee and reference number to be updated, got %+v", update)
+	}
+
+	if err := syncer.ApplyTransactions(context.Background(), plan); err != nil {
+		t.Fatalf("Failed to apply plan: %v", err)
+	}
+	if _, ok := mockDB.Transactions["PENDING"]; ok {
+		t.Errorf("Expected the pending transaction to be removed locally")
+	}
+	if mockDB.Transactions["POSTED"].LunchMoneyID != 42 {
+		t.Errorf("Expected POSTED to take over LunchMoney ID 42, got %d", mockDB.Transactions["POSTED"].LunchMoneyID)
+	}
+	if _, ok := mockClient.UpdatedTransactions[42]; !ok {
+		t.Errorf("Expected LunchMoney transaction 42 to be updated")
+	}
+}
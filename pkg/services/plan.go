@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// SkipReason explains why a transaction is not pushed to LunchMoney
+type SkipReason string
+
+const (
+	// SkipReasonIgnoredAccount is used when the source account is marked as always ignored
+	SkipReasonIgnoredAccount SkipReason = "ignored-account"
+	// SkipReasonSyncDisabled is used when transaction sync is disabled for the mapped account
+	SkipReasonSyncDisabled SkipReason = "sync-disabled"
+	// SkipReasonNoAccount is used when no LunchMoney account could be found for the transaction
+	SkipReasonNoAccount SkipReason = "no-account"
+	// SkipReasonVanished is used when the transaction disappeared upstream before it was synced
+	SkipReasonVanished SkipReason = "vanished"
+	// SkipReasonTransferReview is used for both sides of a matched transfer that was not
+	// confirmed or broken yet
+	SkipReasonTransferReview SkipReason = "transfer-review"
+)
+
+// SyncPlan describes every change a sync would make to LunchMoney and to the
+// local database. It is computed without calling any mutating endpoint so it
+// can be reviewed before being applied.
+type SyncPlan struct {
+	// Inserts are the transactions that will be created in LunchMoney
+	Inserts []*PlannedInsert `json:"inserts"`
+	// Updates are synced transactions that changed upstream and will be updated in LunchMoney
+	Updates []*PlannedUpdate `json:"updates"`
+	// Backfills are local transactions that already exist in LunchMoney and only
+	// need their LunchMoney ID or pushed values stored locally
+	Backfills []*models.TransactionWithAccount `json:"backfills"`
+	// Skipped are transactions that will not be pushed and why
+	Skipped []*SkippedTransaction `json:"skipped"`
+	// BalanceUpdates are the account balances that will be updated in LunchMoney
+	BalanceUpdates []*BalanceUpdate `json:"balanceUpdates"`
+	// Posted are pending transactions that posted under a new reference number, the
+	// posted transaction takes over the pending one's LunchMoney entry
+	Posted []*PostedMatch `json:"posted"`
+	// Vanished are synced transactions that disappeared upstream, applying the plan
+	// leaves them untouched, they can be marked or dismissed with the vanished command
+	Vanished []*models.TransactionWithAccount `json:"vanished"`
+	// Transfers are the new transfer pairs found among the inserts, they are saved
+	// when the plan is applied
+	Transfers []*TransferMatch `json:"transfers"`
+	// Matches are the LunchMoney transactions the back-filled transactions were
+	// matched with and why
+	Matches []*DuplicateMatch `json:"matches"`
+	// Ambiguous are transactions that may already exist in LunchMoney, they are neither
+	// inserted nor linked until they are reviewed with the duplicates command
+	Ambiguous []*DuplicateMatch `json:"ambiguous"`
+
+	// balanceAccounts are the LunchMoney accounts whose balances were compared
+	balanceAccounts []models.LunchMoneyAccount
+}
+
+// PlannedInsert is a local transaction and the LunchMoney account it will be inserted into
+type PlannedInsert struct {
+	Transaction *models.TransactionWithAccount `json:"transaction"`
+	Mapping     *models.AccountMapping         `json:"accountMapping"`
+	// CategoryID is the LunchMoney category the transaction is inserted with
+	CategoryID int64 `json:"categoryId,omitempty"`
+	// TransferWith is the reference number of the other side when the transaction
+	// is inserted as a transfer
+	TransferWith string `json:"transferWith,omitempty"`
+}
+
+// SkippedTransaction is a local transaction that will not be synced
+type SkippedTransaction struct {
+	Transaction *models.TransactionWithAccount `json:"transaction"`
+	Reason      SkipReason                     `json:"reason"`
+}
+
+// BalanceUpdate is a LunchMoney account balance that will be overwritten by the local balance
+type BalanceUpdate struct {
+	LunchMoneyId int64         `json:"lunchMoneyId"`
+	AccountName  string        `json:"accountName"`
+	From         models.Amount `json:"from"`
+	To           models.Amount `json:"to"`
+	AsOf         *time.Time    `json:"asOf"`
+}
+
+// IsEmpty returns true when applying the plan would not change anything
+func (p *SyncPlan) IsEmpty() bool {
+	return len(p.Inserts) == 0 && len(p.Updates) == 0 && len(p.Backfills) == 0 && len(p.BalanceUpdates) == 0 &&
+		len(p.Posted) == 0
+}
+
+// Plan computes the full set of transaction and balance changes without applying them
+// or prompting. Transactions of unknown source accounts are skipped and unknown
+// category codes are left uncategorized.
+func (l *LunchMoneySyncer) Plan(ctx context.Context) (*SyncPlan, error) {
+	plan, err := l.planTransactions(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	balancePlan, err := l.PlanBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.BalanceUpdates = balancePlan.BalanceUpdates
+	return plan, nil
+}
+
+// Apply pushes a previously computed plan to LunchMoney and the local database
+func (l *LunchMoneySyncer) Apply(ctx context.Context, plan *SyncPlan) error {
+	if err := l.ApplyTransactions(ctx, plan); err != nil {
+		return err
+	}
+	return l.ApplyBalances(ctx, plan)
+}
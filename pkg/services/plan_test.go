@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestPlanTransactions(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Mapped Account":  {LunchMoneyId: 1, ExternalName: "Mapped Account"},
+		"Ignored Account": {LunchMoneyId: -3, ExternalName: "Ignored Account"},
+		"Balance Only":    {LunchMoneyId: 2, ExternalName: "Balance Only"},
+	}
+	mockDB.Accounts = []models.LunchMoneyAccount{
+		{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption},
+		{LunchMoneyId: 2, SyncStrategy: models.SyncOptionBalance},
+	}
+
+	newTx := func(ref, account string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: "Merchant " + ref, Address: &models.Address{}},
+				Date:            time.Now().Format(time.DateOnly),
+			},
+			SourceAccountName: account,
+		}
+	}
+	mockDB.Transactions["TX1"] = newTx("TX1", "Mapped Account")
+	mockDB.Transactions["TX2"] = newTx("TX2", "Ignored Account")
+	mockDB.Transactions["TX3"] = newTx("TX3", "Balance Only")
+
+	mockClient := &lm.MockLunchMoneyClient{InsertedIDs: []int64{100}}
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
+	}
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to plan transactions: %v", err)
+	}
+
+	if len(plan.Inserts) != 1 || plan.Inserts[0].Transaction.ReferenceNumber != "TX1" {
+		t.Fatalf("Expected TX1 to be planned for insert, got %+v", plan.Inserts)
+	}
+	if plan.Inserts[0].Mapping.LunchMoneyId != 1 {
+		t.Errorf("Expected TX1 to be inserted into account 1, got %d", plan.Inserts[0].Mapping.LunchMoneyId)
+	}
+	reasons := make(map[string]SkipReason)
+	for _, skipped := range plan.Skipped {
+		reasons[skipped.Transaction.ReferenceNumber] = skipped.Reason
+	}
+	if len(reasons) != 2 || reasons["TX2"] != SkipReasonIgnoredAccount || reasons["TX3"] != SkipReasonSyncDisabled {
+		t.Fatalf("Expected TX2 to be skipped as ignored and TX3 as sync disabled, got %v", reasons)
+	}
+
+	// Planning must not touch the local database
+	if mockDB.Transactions["TX1"].LunchMoneyID != 0 {
+		t.Errorf("Expected TX1 to remain unsynced after planning, got %d", mockDB.Transactions["TX1"].LunchMoneyID)
+	}
+
+	if err := syncer.ApplyTransactions(context.Background(), plan); err != nil {
+		t.Fatalf("Failed to apply plan: %v", err)
+	}
+	if mockDB.Transactions["TX1"].LunchMoneyID != 100 {
+		t.Errorf("Expected TX1 to have LunchMoneyID 100 after applying, got %d", mockDB.Transactions["TX1"].LunchMoneyID)
+	}
+}
+
+func TestPlanDoesNotPromptForAccounts(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.Transactions["TX1"] = &models.TransactionWithAccount{
+		Transaction: models.Transaction{
+			ReferenceNumber: "TX1",
+			Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+			Merchant:        &models.Merchant{Name: "Merchant", Address: &models.Address{}},
+			Date:            time.Now().Format(time.DateOnly),
+		},
+		SourceAccountName: "New Account",
+	}
+	mockClient := &lm.MockLunchMoneyClient{Accounts: []models.LunchMoneyAccount{{LunchMoneyId: 1, Name: "Visa"}}}
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
+	}
+
+	plan, err := syncer.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Reason != SkipReasonNoAccount {
+		t.Errorf("Expected TX1 to be skipped without an account, got %+v", plan.Skipped)
+	}
+	if len(mockDB.AccountMappings) != 0 {
+		t.Errorf("Expected the plan not to store account mappings, got %+v", mockDB.AccountMappings)
+	}
+}
@@ -4,31 +4,40 @@ import (
 	"context"
 
 	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
 	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
 )
 
 type LunchMoneySyncer struct {
 	client        lm.LunchMoneyClientInterface
 	database      db.DBInterface
 	accountMapper *AccountMapper
-	forceSync     bool
+	// categoryMapper categorizes the inserted transactions, NewLunchMoneySyncer always
+	// sets it and a syncer built without one leaves them uncategorized
+	categoryMapper *CategoryMapper
+	forceSync      bool
+	window         models.DateRange
+	recorder       *RunRecorder
+	normalizer     *MerchantNormalizer
+	tagger         *Tagger
+	transfers      config.TransferOptions
+	duplicates     config.DuplicateOptions
 }
 
 func NewLunchMoneySyncer(ctx context.Context, apiKey string, database db.DBInterface) (*LunchMoneySyncer, error) {
-	c, err := lm.NewLunchMoneyClient(ctx, apiKey)
-	if err != nil {
-		return nil, err
-	}
-
-	as, err := NewAccountMapper(ctx, apiKey, database)
+	lc, err := lm.NewLunchMoneyClient(ctx, apiKey)
 	if err != nil {
 		return nil, err
 	}
+	// the mapper shares the client so requests are rate limited together
+	c := lm.NewResilientClient(lc, lm.DefaultResilientOptions())
 
 	return &LunchMoneySyncer{
-		client:        c,
-		database:      database,
-		accountMapper: as,
+		client:         c,
+		database:       database,
+		accountMapper:  NewAccountMapperWithClient(c, database),
+		categoryMapper: NewCategoryMapper(c, database),
 	}, nil
 }
 
@@ -36,6 +45,24 @@ func (s *LunchMoneySyncer) GetAccountMapper() *AccountMapper {
 	return s.accountMapper
 }
 
+func (s *LunchMoneySyncer) GetCategoryMapper() *CategoryMapper {
+	return s.categoryMapper
+}
+
 func (s *LunchMoneySyncer) GetClient() lm.LunchMoneyClientInterface {
 	return s.client
 }
+
+// SetSyncWindow limits syncing to local transactions within window, the same
+// window is used when looking up existing LunchMoney transactions
+func (s *LunchMoneySyncer) SetSyncWindow(window models.DateRange) {
+	s.window = window
+}
+
+// syncWindow returns the configured window or the default one when unset
+func (s *LunchMoneySyncer) syncWindow() models.DateRange {
+	if s.window.Start.IsZero() {
+		return models.LastDays(config.DefaultSyncWindowDays)
+	}
+	return s.window
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// Tagger adds the tags of every matching tag rule to transactions. A nil tagger adds nothing.
+type Tagger struct {
+	rules []tagRule
+}
+
+type tagRule struct {
+	config.TagRule
+	re *regexp.Regexp
+}
+
+// NewTagger compiles the tag rules
+func NewTagger(rules []config.TagRule) (*Tagger, error) {
+	t := &Tagger{rules: make([]tagRule, 0, len(rules))}
+	for i, rule := range rules {
+		compiled := tagRule{TagRule: rule}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag rule %d: %w", i+1, err)
+			}
+			compiled.re = re
+		}
+		t.rules = append(t.rules, compiled)
+	}
+	return t, nil
+}
+
+// Tag adds the tags of the rules matching the transaction and returns true if any was added.
+// Merchant rules match the normalized and the original merchant name.
+func (t *Tagger) Tag(tx *models.TransactionWithAccount) bool {
+	if t == nil {
+		return false
+	}
+
+	names := make([]string, 0, 2)
+	if tx.Merchant != nil {
+		names = append(names, tx.Merchant.Name)
+		if tx.Merchant.OriginalName != "" {
+			names = append(names, tx.Merchant.OriginalName)
+		}
+	}
+
+	added := false
+	for _, rule := range t.rules {
+		if rule.matches(tx.SourceAccountName, names) && tx.AddTags(rule.Tags...) {
+			added = true
+		}
+	}
+	return added
+}
+
+func (r *tagRule) matches(account string, names []string) bool {
+	if r.Account != "" {
+		return strings.EqualFold(r.Account, account)
+	}
+	for _, name := range names {
+		if r.re != nil && r.re.MatchString(name) ||
+			r.re == nil && strings.Contains(strings.ToLower(name), strings.ToLower(r.Contains)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTagger adds tags to unsynced transactions before they are inserted
+func (l *LunchMoneySyncer) SetTagger(tagger *Tagger) {
+	l.tagger = tagger
+}
+
+// GetTagger returns the tagger, it is nil when none is set
+func (l *LunchMoneySyncer) GetTagger() *Tagger {
+	return l.tagger
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestTagger(t *testing.T) {
+	tagger, err := NewTagger([]config.TagRule{
+		{Account: "corporate visa", Tags: []string{"reimbursable"}},
+		{Regex: `(?i)^air canada`, Tags: []string{"travel", "reimbursable"}},
+		{Contains: "amzn", Tags: []string{"shopping"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create tagger: %v", err)
+	}
+
+	newTx := func(account, name, originalName string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				Merchant: &models.Merchant{Name: name, OriginalName: originalName},
+				Tags:     []string{"source:rogers"},
+			},
+			SourceAccountName: account,
+		}
+	}
+
+	tests := []struct {
+		tx   *models.TransactionWithAccount
+		tags []string
+	}{
+		{newTx("Corporate Visa", "AIR CANADA 0142", ""), []string{"source:rogers", "reimbursable", "travel"}},
+		{newTx("Visa", "Amazon", "AMZN Mktp CA"), []string{"source:rogers", "shopping"}},
+		{newTx("Visa", "Corner Store", ""), []string{"source:rogers"}},
+	}
+	for _, tt := range tests {
+		added := tagger.Tag(tt.tx)
+		if added != (len(tt.tags) > 1) || !slicesEqual(tt.tx.Tags, tt.tags) {
+			t.Errorf("Tag(%s): expected %v, got %v (added %v)", tt.tx.Merchant.Name, tt.tags, tt.tx.Tags, added)
+		}
+	}
+
+	var none *Tagger
+	if none.Tag(newTx("Corporate Visa", "Bistro", "")) {
+		t.Errorf("Expected nil tagger to add nothing")
+	}
+}
+
+func slicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,9 +3,9 @@ package services
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
 	"github.com/vpnda/sandwich-sync/pkg/models"
 
 	"github.com/icco/lunchmoney"
@@ -13,127 +13,351 @@ import (
 )
 
 func (l *LunchMoneySyncer) SyncTransactions(ctx context.Context) error {
+	plan, err := l.PlanTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	return l.ApplyTransactions(ctx, plan)
+}
+
+// PlanTransactions computes which local transactions would be inserted into LunchMoney,
+// which ones changed upstream since they were synced, which ones only need their
+// LunchMoney ID back-filled and which ones are skipped
+func (l *LunchMoneySyncer) PlanTransactions(ctx context.Context) (*SyncPlan, error) {
+	return l.planTransactions(ctx, false)
+}
+
+// planTransactions computes the transaction plan, a dry run never prompts for the
+// account of unknown source accounts or the category of unknown category codes
+// nor stores a mapping
+func (l *LunchMoneySyncer) planTransactions(ctx context.Context, dryRun bool) (*SyncPlan, error) {
+	plan := &SyncPlan{}
+
 	// fetch the transactions from our local database
 	transactions, err := l.database.GetTransactions()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Info().Int("count", len(transactions)).
 		Msg("Fetched transactions from local database")
 
-	// only consider transactions that are less than 30 days old
+	// only consider transactions within the sync window
+	window := l.syncWindow()
 	recentTransactions := make([]*models.TransactionWithAccount, 0)
 	for _, transaction := range transactions {
-		transactionDate, err := time.Parse(time.DateOnly, transaction.Date)
+		inWindow, err := window.ContainsDate(transaction.Date)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if transactionDate.After(time.Now().Add(-30 * 24 * time.Hour)) {
+		if inWindow {
 			recentTransactions = append(recentTransactions, transaction)
 		}
 	}
 
-	log.Info().Int("count", len(recentTransactions)).Msg("Filtered recent transactions")
+	log.Info().Int("count", len(recentTransactions)).Stringer("window", window).Msg("Filtered recent transactions")
+
+	// posted transactions take over the LunchMoney entry of their pending counterpart,
+	// which is dropped before it is reported as vanished
+	plan.Posted = matchPostedTransactions(recentTransactions)
+	superseded := make(map[*models.TransactionWithAccount]bool, len(plan.Posted))
+	for _, match := range plan.Posted {
+		match.Posted.LunchMoneyID = match.Pending.LunchMoneyID
+		match.Posted.Synced = match.Pending.Synced
+		superseded[match.Pending] = true
+	}
+	recentTransactions = lo.Reject(recentTransactions, func(tx *models.TransactionWithAccount, _ int) bool {
+		return superseded[tx]
+	})
+
+	// transactions that disappeared upstream are neither inserted nor updated
+	recentTransactions, vanished := lo.FilterReject(recentTransactions, func(tx *models.TransactionWithAccount, _ int) bool {
+		return tx.VanishedAt == nil
+	})
+	for _, transaction := range vanished {
+		switch {
+		case transaction.LunchMoneyID == 0:
+			plan.Skipped = append(plan.Skipped, &SkippedTransaction{Transaction: transaction, Reason: SkipReasonVanished})
+		case transaction.LunchMoneyID > 0 && transaction.VanishedAction == "":
+			plan.Vanished = append(plan.Vanished, transaction)
+		}
+	}
+
+	lunchTransactions, err := l.listLunchMoneyTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// compare the already synced transactions against LunchMoney first, the
+	// ones matched below only get their LunchMoney ID in this run
+	updates, baselines := l.planUpdates(recentTransactions, lunchTransactions)
+	plan.Updates = withPostedReferences(updates, plan.Posted)
 
 	// filter transactions to only those that are not already synced
-	unsyncedTransactions, syncedNeededToUpdateTransactions, err := l.filterUnsyncedTransactions(ctx, recentTransactions)
+	unsyncedTransactions, matches, ambiguous, err := l.matchLunchMoneyTransactions(ctx, recentTransactions, lunchTransactions)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	plan.Matches, plan.Ambiguous = matches, ambiguous
+	plan.Backfills = lo.Map(matches, func(match *DuplicateMatch, _ int) *models.TransactionWithAccount {
+		return match.Transaction
+	})
+	plan.Backfills = append(plan.Backfills, baselines...)
 
 	if len(unsyncedTransactions) != 0 {
-		enrichUnsyncedTransactions, err := l.enrichWithAccounts(ctx, unsyncedTransactions)
+		inserts, skipped, err := l.planInserts(ctx, unsyncedTransactions, dryRun)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		plan.Skipped = append(plan.Skipped, skipped...)
 
-		// filter out transactions that are marked for no sync
-		unsyncedTransactions, enrichUnsyncedTransactions, err = l.
-			filterOutNoSyncTransactions(unsyncedTransactions, enrichUnsyncedTransactions)
+		// transfers are matched once the accounts are mapped, both sides of a confirmed
+		// transfer are inserted with the transfer category instead of their own
+		inserts, plan.Transfers, skipped, err = l.planTransfers(inserts)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		plan.Inserts = inserts
+		plan.Skipped = append(plan.Skipped, skipped...)
+		if err := l.planCategories(ctx, inserts, dryRun); err != nil {
+			return nil, err
+		}
+	}
 
-		if len(unsyncedTransactions) != 0 {
-			// sync the transactions with the LunchMoney API
-			log.Info().Int("count", len(unsyncedTransactions)).Msg("Unsynced transactions with LunchMoney")
-			insertionIds, err := l.client.InsertTransactions(ctx, enrichUnsyncedTransactions)
-			if err != nil {
+	return plan, nil
+}
+
+// ApplyTransactions inserts the planned transactions into LunchMoney and stores
+// the resulting LunchMoney IDs locally
+func (l *LunchMoneySyncer) ApplyTransactions(ctx context.Context, plan *SyncPlan) error {
+	// replace the pending transactions that posted locally first. The posted transaction
+	// takes over the LunchMoney ID before the pending one is removed, so the link is
+	// never lost when the removal fails.
+	for _, match := range plan.Posted {
+		if match.Posted.LunchMoneyID != 0 {
+			if err := l.database.UpdateTransaction(match.Posted); err != nil {
 				return err
 			}
+			l.record(&match.Posted.Transaction, models.TransactionEventLinked,
+				fmt.Sprintf("took over the LunchMoney transaction of pending %s", match.Pending.ReferenceNumber))
+		}
+		if err := l.database.RemoveTransaction(match.Pending.ReferenceNumber); err != nil {
+			return err
+		}
+		l.record(&match.Pending.Transaction, models.TransactionEventDeleted,
+			fmt.Sprintf("pending transaction posted as %s", match.Posted.ReferenceNumber))
+	}
 
-			if len(insertionIds) != len(enrichUnsyncedTransactions) {
-				return fmt.Errorf("failed to insert all transactions, expected %d, got %d", len(enrichUnsyncedTransactions), len(insertionIds))
-			}
+	for _, match := range plan.Transfers {
+		if err := l.saveTransfer(match); err != nil {
+			return err
+		}
+	}
 
-			// update the local database with the insertion IDs
-			for i, transaction := range unsyncedTransactions {
-				if i < len(insertionIds) {
-					transaction.LunchMoneyID = insertionIds[i]
-					if err := l.database.UpdateTransaction(transaction); err != nil {
-						return err
-					}
-				}
+	if len(plan.Inserts) != 0 {
+		log.Info().Int("count", len(plan.Inserts)).Msg("Unsynced transactions with LunchMoney")
+		// the IDs are stored after every batch, so a sync that fails or is interrupted
+		// halfway does not insert the accepted transactions again
+		inserted := 0
+		for _, chunk := range lo.Chunk(plan.Inserts, lm.InsertBatchSize) {
+			count, err := l.insertTransactions(ctx, chunk)
+			inserted += count
+			if err != nil {
+				return fmt.Errorf("failed to insert transactions after %d of %d: %w", inserted, len(plan.Inserts), err)
 			}
 		}
 	}
 
-	if len(syncedNeededToUpdateTransactions) != 0 {
-		// update the transactions locally
-		for _, transaction := range syncedNeededToUpdateTransactions {
-			// update the transaction in the local database
-			if err := l.database.UpdateTransaction(transaction); err != nil {
-				return err
-			}
+	// push the upstream changes of already synced transactions
+	for _, update := range plan.Updates {
+		if err := l.applyUpdate(ctx, update); err != nil {
+			return err
 		}
 	}
+
+	// update the transactions that already exist in LunchMoney locally
+	reasons := make(map[*models.TransactionWithAccount]string, len(plan.Matches))
+	for _, match := range plan.Matches {
+		reasons[match.Transaction] = match.Best().Reason
+	}
+	for _, transaction := range plan.Backfills {
+		if err := l.database.UpdateTransaction(transaction); err != nil {
+			return err
+		}
+		detail := "matched an existing LunchMoney transaction"
+		if reason, ok := reasons[transaction]; ok {
+			detail += ": " + reason
+		}
+		l.record(&transaction.Transaction, models.TransactionEventLinked, detail)
+	}
+
+	if len(plan.Ambiguous) != 0 {
+		log.Warn().Int("count", len(plan.Ambiguous)).
+			Msg("Transactions may already exist in LunchMoney and were not synced, review them with 'duplicates list'")
+	}
+
+	l.recorder.SetCounts(0, len(plan.Inserts), len(plan.Updates)+len(plan.Backfills)+len(plan.Posted),
+		len(plan.Skipped)+len(plan.Ambiguous))
 	return nil
 }
 
-func (l *LunchMoneySyncer) filterOutNoSyncTransactions(unsyncedTransactions []*models.TransactionWithAccount,
-	enrichUnsyncedTransactions []*models.TransactionWithAccountMapping) ([]*models.TransactionWithAccount, []*models.TransactionWithAccountMapping, error) {
-	ut, eut := make([]*models.TransactionWithAccount, 0), make([]*models.TransactionWithAccountMapping, 0)
-	for i, e := range enrichUnsyncedTransactions {
-		shouldSync, err := l.database.IsSyncOptionEnabled(e.Mapping.LunchMoneyId, models.SyncOptionTransactions)
-		if err != nil {
-			return nil, nil, err
-		}
+// insertTransactions inserts the planned transactions into LunchMoney and stores their
+// LunchMoney IDs. On failure the IDs of the transactions LunchMoney accepted before it
+// are still returned by the client, they are stored and their count returned.
+func (l *LunchMoneySyncer) insertTransactions(ctx context.Context, inserts []*PlannedInsert) (int, error) {
+	enrichUnsyncedTransactions := make([]*models.TransactionWithAccountMapping, 0, len(inserts))
+	for _, insert := range inserts {
+		enrichUnsyncedTransactions = append(enrichUnsyncedTransactions, &models.TransactionWithAccountMapping{
+			Transaction: insert.Transaction.Transaction,
+			Mapping:     insert.Mapping,
+			CategoryID:  insert.CategoryID,
+		})
+	}
 
-		if shouldSync {
-			ut = append(ut, unsyncedTransactions[i])
-			eut = append(eut, e)
+	insertionIds, insertErr := l.client.InsertTransactions(ctx, enrichUnsyncedTransactions)
+	if insertErr == nil && len(insertionIds) != len(enrichUnsyncedTransactions) {
+		return 0, fmt.Errorf("failed to insert all transactions, expected %d, got %d", len(enrichUnsyncedTransactions), len(insertionIds))
+	}
+
+	// update the local database with the insertion IDs
+	stored := 0
+	for i, insert := range inserts[:min(len(insertionIds), len(inserts))] {
+		insert.Transaction.LunchMoneyID = insertionIds[i]
+		insert.Transaction.Synced = models.SyncedFieldsOf(&insert.Transaction.Transaction)
+		if err := l.database.UpdateTransaction(insert.Transaction); err != nil {
+			return stored, err
+		}
+		stored++
+		l.record(&insert.Transaction.Transaction, models.TransactionEventMapped,
+			fmt.Sprintf("account %s mapped to LunchMoney account %d", insert.Mapping.ExternalName, insert.Mapping.LunchMoneyId))
+		pushed := "inserted into LunchMoney"
+		if insert.CategoryID != models.Uncategorized {
+			pushed = fmt.Sprintf("inserted into LunchMoney with category %d", insert.CategoryID)
+		}
+		if insert.TransferWith != "" {
+			pushed += fmt.Sprintf(" as a transfer with %s", insert.TransferWith)
 		}
+		l.record(&insert.Transaction.Transaction, models.TransactionEventPushed, pushed)
 	}
-	return ut, eut, nil
+	return stored, insertErr
 }
 
-func (l *LunchMoneySyncer) enrichWithAccounts(ctx context.Context,
-	unsyncedTransactions []*models.TransactionWithAccount) ([]*models.TransactionWithAccountMapping, error) {
-	enrichUnsyncedTransactions := make([]*models.TransactionWithAccountMapping, 0)
-
+// planInserts maps the unsynced transactions to LunchMoney accounts and splits
+// them into the ones that will be inserted and the ones that are skipped. A dry
+// run skips the transactions of unknown accounts instead of prompting for them.
+func (l *LunchMoneySyncer) planInserts(ctx context.Context,
+	unsyncedTransactions []*models.TransactionWithAccount, dryRun bool) ([]*PlannedInsert, []*SkippedTransaction, error) {
+	inserts, skipped := make([]*PlannedInsert, 0), make([]*SkippedTransaction, 0)
 	for _, transaction := range unsyncedTransactions {
+		// rules changed since the transaction was saved apply before it is inserted,
+		// the new name is stored with its LunchMoney ID
+		l.normalizer.Normalize(&transaction.Transaction)
+		l.tagger.Tag(transaction)
+
 		// find the account for the transaction
-		account, err := l.accountMapper.FindPossibleAccountForTransaction(ctx, transaction)
+		findAccount := l.accountMapper.FindPossibleAccountForTransaction
+		if dryRun {
+			findAccount = l.accountMapper.LookupAccountForTransaction
+		}
+		mapping, err := findAccount(ctx, transaction)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		if account == nil {
+		if mapping == nil {
 			log.Info().Str("transactionId", transaction.ReferenceNumber).Msg("No account found for transaction")
+			skipped = append(skipped, &SkippedTransaction{Transaction: transaction, Reason: SkipReasonNoAccount})
+			continue
+		}
+
+		if mapping.IsIgnored() {
+			skipped = append(skipped, &SkippedTransaction{Transaction: transaction, Reason: SkipReasonIgnoredAccount})
 			continue
 		}
-		// create a new transaction with the account
-		transactionWithAccount := &models.TransactionWithAccountMapping{
-			Transaction: transaction.Transaction,
-			Mapping:     account,
+
+		// skip transactions that are marked for no sync
+		shouldSync, err := l.database.IsSyncOptionEnabled(mapping.LunchMoneyId, models.SyncOptionTransactions)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !shouldSync {
+			skipped = append(skipped, &SkippedTransaction{Transaction: transaction, Reason: SkipReasonSyncDisabled})
+			continue
 		}
-		enrichUnsyncedTransactions = append(enrichUnsyncedTransactions, transactionWithAccount)
+
+		inserts = append(inserts, &PlannedInsert{Transaction: transaction, Mapping: mapping})
+	}
+	return inserts, skipped, nil
+}
+
+// planCategories chooses the LunchMoney category of the planned inserts, transfers
+// get the configured transfer category and the transfer tag. A dry run leaves
+// unknown category codes uncategorized.
+func (l *LunchMoneySyncer) planCategories(ctx context.Context, inserts []*PlannedInsert, dryRun bool) error {
+	for _, insert := range inserts {
+		if insert.TransferWith != "" {
+			insert.CategoryID = l.transfers.CategoryId
+			insert.Transaction.AddTags(TransferTag)
+			continue
+		}
+
+		if dryRun {
+			categoryID, _, err := l.categoryMapper.LookupCategoryForTransaction(ctx, insert.Transaction)
+			if err != nil {
+				return err
+			}
+			insert.CategoryID = categoryID
+			continue
+		}
+
+		categoryID, err := l.categoryMapper.FindCategoryForTransaction(ctx, insert.Transaction)
+		if err != nil {
+			return err
+		}
+		insert.CategoryID = categoryID
+	}
+	return nil
+}
+
+// listLunchMoneyTransactions returns the LunchMoney transactions within the sync window
+func (l *LunchMoneySyncer) listLunchMoneyTransactions(ctx context.Context) ([]models.Transaction, error) {
+	window := l.syncWindow()
+	lunchTransactions, err := l.client.ListTransaction(ctx, &lunchmoney.TransactionFilters{
+		StartDate: lo.ToPtr(window.StartDate()),
+		EndDate:   lo.ToPtr(window.EndDate()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions from LunchMoney: %w", err)
 	}
-	return enrichUnsyncedTransactions, nil
+	return lunchTransactions, nil
 }
 
 func (l *LunchMoneySyncer) filterUnsyncedTransactions(ctx context.Context,
 	transactions []*models.TransactionWithAccount) ([]*models.TransactionWithAccount, []*models.TransactionWithAccount, error) {
+	lunchTransactions, err := l.listLunchMoneyTransactions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unsynced, matches, _, err := l.matchLunchMoneyTransactions(ctx, transactions, lunchTransactions)
+	if err != nil {
+		return nil, nil, err
+	}
+	missingUpdate := lo.Map(matches, func(match *DuplicateMatch, _ int) *models.TransactionWithAccount {
+		return match.Transaction
+	})
+	return unsynced, missingUpdate, nil
+}
+
+// matchLunchMoneyTransactions splits the local transactions without a LunchMoney ID into
+// the ones that already exist in LunchMoney, which get their ID set, the ones that may
+// exist in LunchMoney and need to be reviewed, and the unsynced ones
+func (l *LunchMoneySyncer) matchLunchMoneyTransactions(ctx context.Context, transactions []*models.TransactionWithAccount,
+	lunchTransactions []models.Transaction) ([]*models.TransactionWithAccount, []*DuplicateMatch, []*DuplicateMatch, error) {
+	matcher, err := l.newDuplicateMatcher(lunchTransactions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	missingLunchId := make([]*models.TransactionWithAccount, 0)
 	for _, transaction := range transactions {
 		if transaction.ReferenceNumber == "" {
@@ -148,50 +372,50 @@ func (l *LunchMoneySyncer) filterUnsyncedTransactions(ctx context.Context,
 
 		if l.forceSync || transaction.LunchMoneyID == 0 {
 			missingLunchId = append(missingLunchId, transaction)
+		} else {
+			// LunchMoney transactions that are already linked are not matched again
+			matcher.claimed[transaction.LunchMoneyID] = true
 		}
 	}
 
-	// Check if there are any unsynced transactions by getting transactions and match on
-	// date, amount and merchant name
-	lunchTransactions, err := l.client.ListTransaction(ctx, &lunchmoney.TransactionFilters{
-		StartDate: lo.ToPtr(time.Now().Add(-30 * 24 * time.Hour).Format(time.DateOnly)),
-		EndDate:   lo.ToPtr(time.Now().Format(time.DateOnly)),
-	})
-
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch transactions from LunchMoney: %w", err)
-	}
-
-	// Filter out transactions that are already synced
-	missingUpdate := make([]*models.TransactionWithAccount, 0)
+	// Filter out transactions that are already synced by scoring the LunchMoney
+	// transactions on date, amount, payee and account
+	matches := make([]*DuplicateMatch, 0)
+	ambiguous := make([]*DuplicateMatch, 0)
 	unsynced := make([]*models.TransactionWithAccount, 0)
 	for _, transaction := range missingLunchId {
-		transactionSynced := false
-		for _, lunchTransaction := range lunchTransactions {
-			amountEq, err := transaction.Amount.ToMoney().Equals(lunchTransaction.Amount.ToMoney())
-			if err != nil {
-				continue
-			}
-			if (transaction.Date == lunchTransaction.Date &&
-				amountEq &&
-				transaction.Merchant.Name == lunchTransaction.Merchant.Name) || transaction.ReferenceNumber == lunchTransaction.ReferenceNumber {
-				// This transaction is already synced
-				log.Info().Str("transactionId", transaction.ReferenceNumber).
-					Int64("lunchId", lunchTransaction.LunchMoneyID).Msg("Transaction is already synced with LunchMoney")
-				transaction.LunchMoneyID = lunchTransaction.LunchMoneyID
-				missingUpdate = append(missingUpdate, transaction)
-				transactionSynced = true
-				break
-			}
+		account, err := matcher.accountOf(ctx, transaction.SourceAccountName)
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
-		if transactionSynced {
+		candidates := matcher.candidates(transaction, account)
+		if len(candidates) == 0 {
+			// This transaction is not synced
+			unsynced = append(unsynced, transaction)
 			continue
 		}
 
-		// This transaction is not synced
-		unsynced = append(unsynced, transaction)
+		match := &DuplicateMatch{Transaction: transaction, Candidates: candidates}
+		if !matcher.isMatch(candidates) {
+			log.Info().Str("transactionId", transaction.ReferenceNumber).Int("candidates", len(candidates)).
+				Msg("Transaction may already exist in LunchMoney, review it with 'duplicates list'")
+			ambiguous = append(ambiguous, match)
+			continue
+		}
+
+		// This transaction is already synced
+		best := match.Best()
+		log.Info().Str("transactionId", transaction.ReferenceNumber).
+			Int64("lunchId", best.LunchMoney.LunchMoneyID).Str("reason", best.Reason).
+			Msg("Transaction is already synced with LunchMoney")
+		transaction.LunchMoneyID = best.LunchMoney.LunchMoneyID
+		// LunchMoney's current values are the baseline for detecting later changes
+		transaction.Synced = models.SyncedFieldsOf(&best.LunchMoney)
+		matcher.claimed[best.LunchMoney.LunchMoneyID] = true
+		match.Candidates = []*DuplicateCandidate{best}
+		matches = append(matches, match)
 	}
 
-	return unsynced, missingUpdate, nil
+	return unsynced, matches, ambiguous, nil
 }
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 func TestSyncTransactions(t *testing.T) {
 	// Create mock database
 	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
 
 	// Add some test transactions to the database
 	tx1 := &models.TransactionWithAccount{
@@ -149,8 +151,9 @@ func TestFilterUnsyncedTransactions(t *testing.T) {
 
 	// Create the syncer
 	syncer := &LunchMoneySyncer{
-		client:   mockClient,
-		database: mockDB,
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
 	}
 
 	// Create test transactions
@@ -233,9 +236,10 @@ func TestFilterUnsyncedTransactions(t *testing.T) {
 	}
 }
 
-func TestEnrichWithAccounts(t *testing.T) {
+func TestPlanInserts(t *testing.T) {
 	// Create mock database
 	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
 	// Create mock LunchMoney client
 	mockClient := &lm.MockLunchMoneyClient{
 		Accounts: []models.LunchMoneyAccount{
@@ -253,6 +257,7 @@ func TestEnrichWithAccounts(t *testing.T) {
 	// Create the syncer
 	syncer := &LunchMoneySyncer{
 		client:        mockClient,
+		database:      mockDB,
 		accountMapper: mockSelector,
 	}
 
@@ -291,18 +296,21 @@ func TestEnrichWithAccounts(t *testing.T) {
 
 	transactions := []*models.TransactionWithAccount{tx1, tx2}
 
-	// Test enriching transactions with accounts
-	enriched, err := syncer.enrichWithAccounts(context.Background(), transactions)
+	// Test mapping transactions to accounts
+	inserts, skipped, err := syncer.planInserts(context.Background(), transactions, false)
 	if err != nil {
-		t.Fatalf("Failed to enrich transactions: %v", err)
+		t.Fatalf("Failed to plan inserts: %v", err)
 	}
 
-	// Verify enriched transactions
-	if len(enriched) != 2 {
-		t.Errorf("Expected 2 enriched transactions, got %d", len(enriched))
+	// Verify planned inserts
+	if len(inserts) != 2 {
+		t.Errorf("Expected 2 planned inserts, got %d", len(inserts))
+	}
+	if len(skipped) != 0 {
+		t.Errorf("Expected no skipped transactions, got %d", len(skipped))
 	}
 
-	for _, tx := range enriched {
+	for _, tx := range inserts {
 		if tx.Mapping == nil {
 			t.Errorf("Expected transaction to have an account, got nil")
 		} else if tx.Mapping.LunchMoneyId != 1 {
@@ -312,3 +320,103 @@ func TestEnrichWithAccounts(t *testing.T) {
 		}
 	}
 }
+
+func TestSyncTransactionsRecordsHistory(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
+	tx := newTestTx("TX1", "Visa", time.Now().Format(time.DateOnly), "4.50", models.TransactionStatusPosted)
+	mockDB.Transactions[tx.ReferenceNumber] = tx
+
+	mockClient := &lm.MockLunchMoneyClient{
+		Accounts:    []models.LunchMoneyAccount{{LunchMoneyId: 1, Name: "Visa"}},
+		InsertedIDs: []int64{777},
+	}
+	mapper := NewAccountMapperWithClient(mockClient, mockDB)
+	mapper.selectedAccount = &models.AccountMapping{LunchMoneyId: 1, ExternalName: "Visa"}
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: mapper,
+	}
+
+	recorder := StartRun(mockDB, models.SyncRunKindSync, "")
+	syncer.SetRecorder(recorder)
+	err := syncer.SyncTransactions(context.Background())
+	recorder.Finish(err)
+	if err != nil {
+		t.Fatalf("Failed to sync transactions: %v", err)
+	}
+
+	if len(mockDB.SyncRuns) != 1 {
+		t.Fatalf("Expected 1 recorded run, got %d", len(mockDB.SyncRuns))
+	}
+	run := mockDB.SyncRuns[0]
+	if run.FinishedAt == nil || run.Error != "" || run.Inserted != 1 {
+		t.Errorf("Expected a finished run with 1 insert, got %+v", run)
+	}
+
+	events, _ := mockDB.GetTransactionEvents("TX1")
+	if len(events) != 2 {
+		t.Fatalf("Expected mapped and pushed events, got %d", len(events))
+	}
+	if events[0].Event != models.TransactionEventMapped {
+		t.Errorf("Expected the first event to be mapped, got %s", events[0].Event)
+	}
+	pushed := events[1]
+	if pushed.Event != models.TransactionEventPushed || pushed.LunchMoneyID != 777 || pushed.RunID != run.ID {
+		t.Errorf("Expected a pushed event with LunchMoney ID 777 in run %d, got %+v", run.ID, pushed)
+	}
+}
+
+// failingInsertClient accepts the first insert request and fails every later one
+type failingInsertClient struct {
+	*lm.MockLunchMoneyClient
+	calls int
+}
+
+func (c *failingInsertClient) InsertTransactions(ctx context.Context,
+	transactions []*models.TransactionWithAccountMapping) ([]int64, error) {
+	c.calls++
+	if c.calls > 1 {
+		return nil, errors.New("request failed")
+	}
+	return c.MockLunchMoneyClient.InsertTransactions(ctx, transactions)
+}
+
+func TestSyncTransactionsPartialInsert(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}}
+	today := time.Now().Format(time.DateOnly)
+	for _, ref := range []string{"TX1", "TX2"} {
+		mockDB.Transactions[ref] = newTestTx(ref, "Visa", today, "4.50", models.TransactionStatusPosted)
+	}
+
+	mockClient := &failingInsertClient{MockLunchMoneyClient: &lm.MockLunchMoneyClient{
+		Accounts:    []models.LunchMoneyAccount{{LunchMoneyId: 1, Name: "Visa"}},
+		InsertedIDs: []int64{777},
+	}}
+	client := lm.NewResilientClient(mockClient, lm.ResilientOptions{BatchSize: 1})
+	mapper := NewAccountMapperWithClient(client, mockDB)
+	mapper.selectedAccount = &models.AccountMapping{LunchMoneyId: 1, ExternalName: "Visa"}
+	syncer := &LunchMoneySyncer{
+		client:        client,
+		database:      mockDB,
+		accountMapper: mapper,
+	}
+
+	if err := syncer.SyncTransactions(context.Background()); err == nil {
+		t.Fatal("Expected the failed insert to be reported")
+	}
+
+	// the mock returns the transactions in any order, so either one is accepted first
+	accepted, unsynced := mockDB.Transactions["TX1"], mockDB.Transactions["TX2"]
+	if accepted.LunchMoneyID == 0 {
+		accepted, unsynced = unsynced, accepted
+	}
+	if accepted.LunchMoneyID != 777 {
+		t.Errorf("Expected %s to keep the LunchMoney ID of its accepted insert, got %d", accepted.ReferenceNumber, accepted.LunchMoneyID)
+	}
+	if unsynced.LunchMoneyID != 0 {
+		t.Errorf("Expected %s to stay unsynced, got LunchMoney ID %d", unsynced.ReferenceNumber, unsynced.LunchMoneyID)
+	}
+}
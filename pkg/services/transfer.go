@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// TransferTag is added to both sides of a transfer when they are inserted into LunchMoney
+const TransferTag = "transfer"
+
+// TransferMatch is a transfer pair with both of its transactions, a side is nil
+// when its transaction no longer exists locally
+type TransferMatch struct {
+	Pair    *models.TransferPair           `json:"pair"`
+	Outflow *models.TransactionWithAccount `json:"outflow"`
+	Inflow  *models.TransactionWithAccount `json:"inflow"`
+}
+
+// transferCandidate is an unsynced transaction and the LunchMoney account it is inserted into
+type transferCandidate struct {
+	transaction *models.TransactionWithAccount
+	accountID   int64
+}
+
+// SetTransferOptions configures how transfers between mapped accounts are detected
+func (l *LunchMoneySyncer) SetTransferOptions(options config.TransferOptions) {
+	l.transfers = options
+}
+
+// isTransferCandidate returns true for the transactions that may be one side of
+// a new transfer pair. Pending transactions are left out since they may post
+// under a new reference number.
+func isTransferCandidate(transaction *models.TransactionWithAccount) bool {
+	return transaction.ReferenceNumber != "" && transaction.LunchMoneyID == 0 && transaction.VanishedAt == nil &&
+		!transaction.IsPending()
+}
+
+// matchTransfers pairs opposite-signed transactions of equal amount that belong to
+// different LunchMoney accounts and are dated at most toleranceDays apart. Transactions
+// already in an active pair are not matched again, and neither are broken pairs.
+// Each outflow is matched with the closest inflow, the oldest outflows first.
+func matchTransfers(candidates []transferCandidate, pairs []*models.TransferPair, toleranceDays int) []*TransferMatch {
+	paired := make(map[string]bool)
+	broken := make(map[[2]string]bool)
+	for _, pair := range pairs {
+		if pair.IsActive() {
+			paired[pair.OutflowReference] = true
+			paired[pair.InflowReference] = true
+		} else {
+			broken[[2]string{pair.OutflowReference, pair.InflowReference}] = true
+		}
+	}
+
+	outflows := make([]transferCandidate, 0)
+	inflows := make([]transferCandidate, 0)
+	for _, candidate := range candidates {
+		if paired[candidate.transaction.ReferenceNumber] {
+			continue
+		}
+		switch amount := candidate.transaction.Amount.ToMoney().Amount(); {
+		case amount > 0:
+			outflows = append(outflows, candidate)
+		case amount < 0:
+			inflows = append(inflows, candidate)
+		}
+	}
+
+	// match in a stable order, the oldest outflows first
+	sort.Slice(outflows, func(i, j int) bool {
+		a, b := outflows[i].transaction, outflows[j].transaction
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.ReferenceNumber < b.ReferenceNumber
+	})
+
+	matches := make([]*TransferMatch, 0)
+	used := make(map[*models.TransactionWithAccount]bool)
+	for _, outflow := range outflows {
+		outflowDate, err := time.Parse(time.DateOnly, outflow.transaction.Date)
+		if err != nil {
+			continue
+		}
+		outflowAmount := outflow.transaction.Amount.ToMoney().Amount()
+
+		var best *models.TransactionWithAccount
+		var bestDays int
+		for _, inflow := range inflows {
+			candidate := inflow.transaction
+			if used[candidate] || inflow.accountID == outflow.accountID ||
+				!strings.EqualFold(candidate.Amount.Currency, outflow.transaction.Amount.Currency) ||
+				candidate.Amount.ToMoney().Amount() != -outflowAmount ||
+				broken[[2]string{outflow.transaction.ReferenceNumber, candidate.ReferenceNumber}] {
+				continue
+			}
+
+			inflowDate, err := time.Parse(time.DateOnly, candidate.Date)
+			if err != nil {
+				continue
+			}
+			days := int(inflowDate.Sub(outflowDate).Hours() / 24)
+			if days < 0 {
+				days = -days
+			}
+			if days > toleranceDays {
+				continue
+			}
+			if best == nil || days < bestDays || (days == bestDays && candidate.ReferenceNumber < best.ReferenceNumber) {
+				best, bestDays = candidate, days
+			}
+		}
+
+		if best == nil {
+			continue
+		}
+		used[best] = true
+		log.Info().Str("outflowId", outflow.transaction.ReferenceNumber).Str("inflowId", best.ReferenceNumber).
+			Msg("Matched transfer between accounts")
+		matches = append(matches, &TransferMatch{
+			Pair: &models.TransferPair{
+				OutflowReference: outflow.transaction.ReferenceNumber,
+				InflowReference:  best.ReferenceNumber,
+				Status:           models.TransferStatusMatched,
+			},
+			Outflow: outflow.transaction,
+			Inflow:  best,
+		})
+	}
+	return matches
+}
+
+// MatchTransfers pairs the unsynced transactions of mapped accounts that look like
+// transfers between them and saves the new pairs for review, unless dryRun is set.
+// Transactions of accounts that are not mapped yet are matched when they are synced.
+func (l *LunchMoneySyncer) MatchTransfers(ctx context.Context, dryRun bool) ([]*TransferMatch, error) {
+	if l.transfers.Disabled {
+		return nil, nil
+	}
+
+	transactions, err := l.database.GetTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]transferCandidate, 0)
+	mappings := make(map[string]*models.AccountMapping)
+	for _, transaction := range transactions {
+		if !isTransferCandidate(transaction) {
+			continue
+		}
+
+		mapping, ok := mappings[transaction.SourceAccountName]
+		if !ok {
+			mapping, err = l.accountMapper.LookupAccount(ctx, transaction.SourceAccountName)
+			if err != nil {
+				return nil, err
+			}
+			mappings[transaction.SourceAccountName] = mapping
+		}
+		if mapping == nil || mapping.IsIgnored() {
+			continue
+		}
+		candidates = append(candidates, transferCandidate{transaction: transaction, accountID: mapping.LunchMoneyId})
+	}
+
+	pairs, err := l.database.GetTransferPairs()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := matchTransfers(candidates, pairs, l.transfers.Tolerance())
+	if dryRun {
+		return matches, nil
+	}
+	for _, match := range matches {
+		if err := l.saveTransfer(match); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// saveTransfer stores a new transfer pair and records it on both transactions
+func (l *LunchMoneySyncer) saveTransfer(match *TransferMatch) error {
+	if err := l.database.SaveTransferPair(match.Pair); err != nil {
+		return err
+	}
+	l.record(&match.Outflow.Transaction, models.TransactionEventUpdated,
+		fmt.Sprintf("matched as a transfer to %s", match.Inflow.ReferenceNumber))
+	l.record(&match.Inflow.Transaction, models.TransactionEventUpdated,
+		fmt.Sprintf("matched as a transfer from %s", match.Outflow.ReferenceNumber))
+	return nil
+}
+
+// GetTransfers returns the transfer pairs that were not broken with their transactions
+func (l *LunchMoneySyncer) GetTransfers() ([]*TransferMatch, error) {
+	pairs, err := l.database.GetTransferPairs()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*TransferMatch, 0)
+	for _, pair := range pairs {
+		if !pair.IsActive() {
+			continue
+		}
+		match, err := l.transferMatch(pair)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// HeldTransfers returns the transfer pairs that are not reviewed yet, their transactions
+// are not synced until they are confirmed or broken
+func (l *LunchMoneySyncer) HeldTransfers() ([]*TransferMatch, error) {
+	matches, err := l.GetTransfers()
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(matches, func(match *TransferMatch, _ int) bool {
+		return match.Pair.Status == models.TransferStatusMatched
+	}), nil
+}
+
+// transferMatch looks up the transactions of a transfer pair
+func (l *LunchMoneySyncer) transferMatch(pair *models.TransferPair) (*TransferMatch, error) {
+	outflow, err := l.database.GetTransactionByReference(pair.OutflowReference)
+	if err != nil {
+		return nil, err
+	}
+	inflow, err := l.database.GetTransactionByReference(pair.InflowReference)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferMatch{Pair: pair, Outflow: outflow, Inflow: inflow}, nil
+}
+
+// findTransfer returns the active transfer pair with the given ID
+func (l *LunchMoneySyncer) findTransfer(id int64) (*TransferMatch, error) {
+	matches, err := l.GetTransfers()
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		if match.Pair.ID == id {
+			return match, nil
+		}
+	}
+	return nil, fmt.Errorf("no transfer pair found with id: %d", id)
+}
+
+// ConfirmTransfer marks a matched transfer pair as reviewed
+func (l *LunchMoneySyncer) ConfirmTransfer(id int64) error {
+	match, err := l.findTransfer(id)
+	if err != nil {
+		return err
+	}
+	if err := l.database.SetTransferStatus(id, models.TransferStatusConfirmed); err != nil {
+		return err
+	}
+	match.Pair.Status = models.TransferStatusConfirmed
+	l.recordTransfer(match, "transfer confirmed")
+	return nil
+}
+
+// BreakTransfer marks a transfer pair as not being a transfer so both transactions
+// are synced on their own. Pairs that were already synced can not be broken since
+// their category was pushed to LunchMoney.
+func (l *LunchMoneySyncer) BreakTransfer(id int64) error {
+	match, err := l.findTransfer(id)
+	if err != nil {
+		return err
+	}
+	for _, transaction := range []*models.TransactionWithAccount{match.Outflow, match.Inflow} {
+		if transaction != nil && transaction.LunchMoneyID > 0 {
+			return fmt.Errorf("transaction %s was already synced as a transfer, recategorize it in LunchMoney",
+				transaction.ReferenceNumber)
+		}
+	}
+
+	if err := l.database.SetTransferStatus(id, models.TransferStatusBroken); err != nil {
+		return err
+	}
+	match.Pair.Status = models.TransferStatusBroken
+	l.recordTransfer(match, "transfer pair broken")
+	return nil
+}
+
+// recordTransfer records an event on both transactions of a transfer pair
+func (l *LunchMoneySyncer) recordTransfer(match *TransferMatch, detail string) {
+	for _, transaction := range []*models.TransactionWithAccount{match.Outflow, match.Inflow} {
+		if transaction != nil {
+			l.record(&transaction.Transaction, models.TransactionEventUpdated, detail)
+		}
+	}
+}
+
+// planTransfers marks the planned inserts that are a side of a confirmed transfer and
+// returns the transfer pairs found among them that are not saved yet. Both sides of a
+// pair that was not reviewed yet are held back, so an unconfirmed match is neither
+// pushed as a transfer nor as spending and income.
+func (l *LunchMoneySyncer) planTransfers(inserts []*PlannedInsert) ([]*PlannedInsert, []*TransferMatch, []*SkippedTransaction, error) {
+	if l.transfers.Disabled {
+		return inserts, nil, nil, nil
+	}
+
+	pairs, err := l.database.GetTransferPairs()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	candidates := make([]transferCandidate, 0, len(inserts))
+	for _, insert := range inserts {
+		if isTransferCandidate(insert.Transaction) {
+			candidates = append(candidates, transferCandidate{transaction: insert.Transaction, accountID: insert.Mapping.LunchMoneyId})
+		}
+	}
+	matches := matchTransfers(candidates, pairs, l.transfers.Tolerance())
+
+	counterparts := make(map[string]string)
+	unreviewed := make(map[string]bool)
+	newPairs := lo.Map(matches, func(match *TransferMatch, _ int) *models.TransferPair { return match.Pair })
+	for _, pair := range append(newPairs, pairs...) {
+		if !pair.IsActive() {
+			continue
+		}
+		counterparts[pair.OutflowReference] = pair.InflowReference
+		counterparts[pair.InflowReference] = pair.OutflowReference
+		if pair.Status == models.TransferStatusMatched {
+			unreviewed[pair.OutflowReference] = true
+			unreviewed[pair.InflowReference] = true
+		}
+	}
+
+	planned := make([]*PlannedInsert, 0, len(inserts))
+	held := make([]*SkippedTransaction, 0)
+	for _, insert := range inserts {
+		reference := insert.Transaction.ReferenceNumber
+		if unreviewed[reference] {
+			log.Info().Str("transactionId", reference).Str("transferWith", counterparts[reference]).
+				Msg("Holding transfer until it is reviewed with 'transfers confirm' or 'transfers break'")
+			held = append(held, &SkippedTransaction{Transaction: insert.Transaction, Reason: SkipReasonTransferReview})
+			continue
+		}
+		insert.TransferWith = counterparts[reference]
+		planned = append(planned, insert)
+	}
+	return planned, matches, held, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/config"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestMatchTransfers(t *testing.T) {
+	candidates := []transferCandidate{
+		{newTestTx("PAY", "Chequing", "2025-05-01", "500.00", models.TransactionStatusPosted), 1},
+		{newTestTx("FAR", "Visa", "2025-05-09", "-500.00", models.TransactionStatusPosted), 2},
+		{newTestTx("CARD", "Visa", "2025-05-03", "-500", models.TransactionStatusPosted), 2},
+		{newTestTx("CLOSE", "Visa", "2025-05-02", "-500.00", models.TransactionStatusPosted), 2},
+		{newTestTx("SAME", "Chequing", "2025-05-01", "-500.00", models.TransactionStatusPosted), 1},
+		{newTestTx("OTHER", "Chequing", "2025-05-01", "42.00", models.TransactionStatusPosted), 1},
+	}
+
+	matches := matchTransfers(candidates, nil, 3)
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 transfer, got %d", len(matches))
+	}
+	if matches[0].Pair.OutflowReference != "PAY" || matches[0].Pair.InflowReference != "CLOSE" ||
+		matches[0].Pair.Status != models.TransferStatusMatched {
+		t.Errorf("Expected PAY to be matched with the closest inflow CLOSE, got %+v", matches[0].Pair)
+	}
+
+	// broken pairs are not matched again and paired transactions are left alone
+	pairs := []*models.TransferPair{
+		{OutflowReference: "PAY", InflowReference: "CLOSE", Status: models.TransferStatusBroken},
+	}
+	matches = matchTransfers(candidates, pairs, 3)
+	if len(matches) != 1 || matches[0].Pair.InflowReference != "CARD" {
+		t.Errorf("Expected PAY to be matched with CARD once the pair with CLOSE was broken, got %+v", matches)
+	}
+
+	pairs = append(pairs, &models.TransferPair{OutflowReference: "PAY", InflowReference: "CARD", Status: models.TransferStatusConfirmed})
+	if matches := matchTransfers(candidates, pairs, 3); len(matches) != 0 {
+		t.Errorf("Expected no new transfer for an already paired outflow, got %+v", matches)
+	}
+}
+
+func TestSyncTransfers(t *testing.T) {
+	today := time.Now().Format(time.DateOnly)
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Chequing": {LunchMoneyId: 1, ExternalName: "Chequing"},
+		"Visa":     {LunchMoneyId: 2, ExternalName: "Visa"},
+	}
+	mockDB.Accounts = []models.LunchMoneyAccount{{LunchMoneyId: 1, SyncStrategy: models.AllSyncOption}, {LunchMoneyId: 2, SyncStrategy: models.AllSyncOption}}
+	mockDB.Transactions["PAY"] = newTestTx("PAY", "Chequing", today, "500.00", models.TransactionStatusPosted)
+	mockDB.Transactions["CARD"] = newTestTx("CARD", "Visa", today, "-500.00", models.TransactionStatusPosted)
+	mockDB.Transactions["COFFEE"] = newTestTx("COFFEE", "Visa", today, "5.00", models.TransactionStatusPosted)
+
+	mockClient := &lm.MockLunchMoneyClient{InsertedIDs: []int64{100}}
+	syncer := &LunchMoneySyncer{
+		client:         mockClient,
+		database:       mockDB,
+		accountMapper:  NewAccountMapperWithClient(mockClient, mockDB),
+		categoryMapper: NewCategoryMapper(mockClient, mockDB),
+	}
+	syncer.SetTransferOptions(config.TransferOptions{CategoryId: 30})
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("PlanTransactions returned error: %v", err)
+	}
+	if len(plan.Transfers) != 1 || len(mockDB.TransferPairs) != 0 {
+		t.Fatalf("Expected 1 planned transfer that is not saved yet, got %d planned and %d saved",
+			len(plan.Transfers), len(mockDB.TransferPairs))
+	}
+	if len(plan.Inserts) != 1 || plan.Inserts[0].Transaction.ReferenceNumber != "COFFEE" {
+		t.Fatalf("Expected only COFFEE to be inserted, got %+v", plan.Inserts)
+	}
+	if len(plan.Skipped) != 2 || plan.Skipped[0].Reason != SkipReasonTransferReview ||
+		plan.Skipped[1].Reason != SkipReasonTransferReview {
+		t.Fatalf("Expected both sides of the unreviewed transfer to be held, got %+v", plan.Skipped)
+	}
+
+	if err := syncer.ApplyTransactions(context.Background(), plan); err != nil {
+		t.Fatalf("ApplyTransactions returned error: %v", err)
+	}
+	if len(mockDB.TransferPairs) != 1 {
+		t.Fatalf("Expected the transfer to be saved, got %+v", mockDB.TransferPairs)
+	}
+	if held, err := syncer.HeldTransfers(); err != nil || len(held) != 1 || held[0].Outflow.ReferenceNumber != "PAY" {
+		t.Fatalf("Expected the transfer to be held for review, got %+v: %v", held, err)
+	}
+
+	// confirmed transfers are inserted with the transfer category and tag
+	if err := syncer.ConfirmTransfer(mockDB.TransferPairs[0].ID); err != nil {
+		t.Fatalf("ConfirmTransfer returned error: %v", err)
+	}
+	mockClient.InsertedIDs = []int64{101, 102}
+	if err := syncer.SyncTransactions(context.Background()); err != nil {
+		t.Fatalf("SyncTransactions returned error: %v", err)
+	}
+	if len(mockClient.Inserted) != 3 {
+		t.Fatalf("Expected 3 inserted transactions, got %d", len(mockClient.Inserted))
+	}
+	if held, _ := syncer.HeldTransfers(); len(held) != 0 {
+		t.Errorf("Expected no transfer to be held once confirmed, got %+v", held)
+	}
+	for _, inserted := range mockClient.Inserted {
+		transfer := inserted.ReferenceNumber != "COFFEE"
+		if transfer != (inserted.CategoryID == 30) || transfer != inserted.HasTag(TransferTag) {
+			t.Errorf("Unexpected category %d and tags %v for %s", inserted.CategoryID, inserted.Tags, inserted.ReferenceNumber)
+		}
+	}
+
+	// synced transfers can not be broken anymore
+	if err := syncer.BreakTransfer(mockDB.TransferPairs[0].ID); err == nil {
+		t.Errorf("Expected error breaking a synced transfer")
+	}
+}
+
+func TestBreakTransfer(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Chequing": {LunchMoneyId: 1, ExternalName: "Chequing"},
+		"Ignored":  {LunchMoneyId: -1, ExternalName: "Ignored"},
+	}
+	mockDB.Transactions["PAY"] = newTestTx("PAY", "Chequing", "2025-05-01", "500.00", models.TransactionStatusPosted)
+	mockDB.Transactions["CARD"] = newTestTx("CARD", "Visa", "2025-05-02", "-500.00", models.TransactionStatusPosted)
+	mockDB.Transactions["IGNORED"] = newTestTx("IGNORED", "Ignored", "2025-05-01", "-500.00", models.TransactionStatusPosted)
+	// Visa is only mapped by its rule
+	mockClient := &lm.MockLunchMoneyClient{Accounts: []models.LunchMoneyAccount{{LunchMoneyId: 2, Name: "Visa"}}}
+	accountMapper := NewAccountMapperWithClient(mockClient, mockDB)
+	accountMapper.SetMappingRules([]config.AccountMappingRule{{Name: "Visa", LunchMoneyId: 2}})
+	syncer := &LunchMoneySyncer{database: mockDB, accountMapper: accountMapper}
+
+	matches, err := syncer.MatchTransfers(context.Background(), true)
+	if err != nil {
+		t.Fatalf("MatchTransfers returned error: %v", err)
+	}
+	if len(matches) != 1 || len(mockDB.TransferPairs) != 0 {
+		t.Fatalf("Expected a dry run to match 1 transfer without saving it, got %d", len(matches))
+	}
+
+	if _, err := syncer.MatchTransfers(context.Background(), false); err != nil {
+		t.Fatalf("MatchTransfers returned error: %v", err)
+	}
+	if len(mockDB.TransferPairs) != 1 || mockDB.TransferPairs[0].InflowReference != "CARD" {
+		t.Fatalf("Expected PAY and CARD to be paired, got %+v", mockDB.TransferPairs)
+	}
+
+	if err := syncer.BreakTransfer(mockDB.TransferPairs[0].ID); err != nil {
+		t.Fatalf("BreakTransfer returned error: %v", err)
+	}
+	transfers, err := syncer.GetTransfers()
+	if err != nil {
+		t.Fatalf("GetTransfers returned error: %v", err)
+	}
+	if len(transfers) != 0 {
+		t.Errorf("Expected broken transfers not to be listed, got %d", len(transfers))
+	}
+
+	if matches, _ := syncer.MatchTransfers(context.Background(), false); len(matches) != 0 {
+		t.Errorf("Expected a broken pair not to be matched again, got %+v", matches)
+	}
+}
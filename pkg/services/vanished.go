@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/icco/lunchmoney"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+// VanishedNote is the note set on transactions marked as vanished in LunchMoney
+const VanishedNote = "Vanished upstream: no longer returned by the institution"
+
+// RecordFetchWindows records which transactions a fetch over window returned for each
+// source account and returns the reference numbers of the local transactions that
+// disappeared upstream. Providers do not always return their full window (e.g. only
+// the current statement), so an account's window starts at its earliest returned
+// transaction, and accounts without any returned transaction are not checked since
+// an empty response can not be told apart from an unavailable one.
+func RecordFetchWindows(database db.DBInterface, window models.DateRange,
+	transactions []models.TransactionWithAccount) ([]string, error) {
+	byAccount := lo.GroupBy(transactions, func(tx models.TransactionWithAccount) string {
+		return tx.SourceAccountName
+	})
+
+	accounts := lo.Keys(byAccount)
+	sort.Strings(accounts)
+
+	vanished := make([]string, 0)
+	for _, account := range accounts {
+		accountTransactions := byAccount[account]
+		var earliest time.Time
+		referenceNumbers := make([]string, 0, len(accountTransactions))
+		for _, tx := range accountTransactions {
+			referenceNumbers = append(referenceNumbers, tx.ReferenceNumber)
+
+			date, err := time.Parse(time.DateOnly, tx.Date)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse transaction date %q: %w", tx.Date, err)
+			}
+			if earliest.IsZero() || date.Before(earliest) {
+				earliest = date
+			}
+		}
+
+		accountWindow := window
+		if earliest.After(accountWindow.Start) {
+			accountWindow.Start = earliest
+		}
+
+		accountVanished, err := database.RecordFetchWindow(account, accountWindow, referenceNumbers)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range accountVanished {
+			log.Warn().Str("account", account).Str("transactionId", ref).Msg("Transaction vanished upstream")
+		}
+		vanished = append(vanished, accountVanished...)
+	}
+	return vanished, nil
+}
+
+// MarkVanished marks a vanished transaction in LunchMoney by un-clearing it and
+// appending why to its notes. The LunchMoney API can not delete transactions, marked
+// ones can be reviewed and deleted from the LunchMoney web app.
+func (l *LunchMoneySyncer) MarkVanished(ctx context.Context, transaction *models.TransactionWithAccount) error {
+	if transaction.VanishedAt == nil {
+		return fmt.Errorf("transaction %s has not vanished", transaction.ReferenceNumber)
+	}
+
+	if transaction.LunchMoneyID > 0 {
+		notes, err := l.lunchMoneyNotes(ctx, transaction)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction %s from LunchMoney: %w", transaction.ReferenceNumber, err)
+		}
+		err = l.client.UpdateTransaction(ctx, transaction.LunchMoneyID, &models.TransactionUpdate{
+			Notes:  lo.ToPtr(appendNote(notes, VanishedNote)),
+			Status: lo.ToPtr("uncleared"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to mark transaction %s in LunchMoney: %w", transaction.ReferenceNumber, err)
+		}
+	}
+
+	transaction.VanishedAction = models.VanishedActionMarked
+	if err := l.database.SetVanishedAction(transaction.ReferenceNumber, models.VanishedActionMarked); err != nil {
+		return err
+	}
+	l.record(&transaction.Transaction, models.TransactionEventUpdated, "marked as vanished in LunchMoney")
+	return nil
+}
+
+// lunchMoneyNotes returns the current LunchMoney notes of a synced transaction, the
+// notes it was inserted with when it is not found on its date
+func (l *LunchMoneySyncer) lunchMoneyNotes(ctx context.Context, transaction *models.TransactionWithAccount) (string, error) {
+	lunchTransactions, err := l.client.ListTransaction(ctx, &lunchmoney.TransactionFilters{
+		StartDate: lo.ToPtr(transaction.Date),
+		EndDate:   lo.ToPtr(transaction.Date),
+	})
+	if err != nil {
+		return "", err
+	}
+	lunchTransaction, ok := lo.Find(lunchTransactions, func(tx models.Transaction) bool {
+		return tx.LunchMoneyID == transaction.LunchMoneyID
+	})
+	if !ok {
+		return transaction.Notes, nil
+	}
+	return lunchTransaction.Notes, nil
+}
+
+// appendNote appends note to the existing notes unless they already end with it
+func appendNote(notes string, note string) string {
+	notes = strings.TrimSpace(notes)
+	switch {
+	case notes == "":
+		return note
+	case strings.HasSuffix(notes, note):
+		return notes
+	default:
+		return notes + " | " + note
+	}
+}
+
+// DismissVanished stops reporting a vanished transaction, e.g. after it was deleted in LunchMoney
+func (l *LunchMoneySyncer) DismissVanished(transaction *models.TransactionWithAccount) error {
+	transaction.VanishedAction = models.VanishedActionDismissed
+	if err := l.database.SetVanishedAction(transaction.ReferenceNumber, models.VanishedActionDismissed); err != nil {
+		return err
+	}
+	l.record(&transaction.Transaction, models.TransactionEventUpdated, "vanished transaction dismissed")
+	return nil
+}
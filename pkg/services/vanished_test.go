@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vpnda/sandwich-sync/db"
+	"github.com/vpnda/sandwich-sync/pkg/http/lm"
+	"github.com/vpnda/sandwich-sync/pkg/models"
+)
+
+func TestRecordFetchWindows(t *testing.T) {
+	mockDB := db.NewMockDB()
+	local := func(ref, account, date string) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction:       models.Transaction{ReferenceNumber: ref, Date: date},
+			SourceAccountName: account,
+		}
+	}
+	for _, tx := range []*models.TransactionWithAccount{
+		local("seen", "Visa", "2025-04-20"),
+		local("gone", "Visa", "2025-04-18"),
+		local("before-fetched", "Visa", "2025-04-05"),
+		local("silent-account", "Chequing", "2025-04-18"),
+	} {
+		mockDB.Transactions[tx.ReferenceNumber] = tx
+	}
+
+	window, err := models.NewDateRange("2025-04-01", "2025-04-30")
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	fetched := []models.TransactionWithAccount{
+		*local("seen", "Visa", "2025-04-20"),
+		*local("new", "Visa", "2025-04-10"),
+	}
+	vanished, err := RecordFetchWindows(mockDB, window, fetched)
+	if err != nil {
+		t.Fatalf("RecordFetchWindows returned error: %v", err)
+	}
+
+	// transactions before the earliest fetched one and of accounts that returned
+	// nothing are not known to be gone
+	if len(vanished) != 1 || vanished[0] != "gone" {
+		t.Errorf("Expected only 'gone' to vanish, got %v", vanished)
+	}
+	if mockDB.Transactions["before-fetched"].VanishedAt != nil {
+		t.Errorf("Expected transaction before the fetched range to be kept")
+	}
+	if mockDB.Transactions["silent-account"].VanishedAt != nil {
+		t.Errorf("Expected transaction of an account without fetched transactions to be kept")
+	}
+}
+
+func TestPlanVanishedTransactions(t *testing.T) {
+	mockDB := db.NewMockDB()
+	mockDB.AccountMappings = map[string]*models.AccountMapping{
+		"Mapped Account": {LunchMoneyId: 1, ExternalName: "Mapped Account"},
+	}
+
+	vanishedAt := time.Now()
+	newTx := func(ref string, lunchMoneyId int64) *models.TransactionWithAccount {
+		return &models.TransactionWithAccount{
+			Transaction: models.Transaction{
+				ReferenceNumber: ref,
+				Amount:          models.Amount{Value: "10.00", Currency: "CAD"},
+				Merchant:        &models.Merchant{Name: "Merchant " + ref, Address: &models.Address{}},
+				Date:            time.Now().Format(time.DateOnly),
+				LunchMoneyID:    lunchMoneyId,
+				VanishedAt:      &vanishedAt,
+			},
+			SourceAccountName: "Mapped Account",
+		}
+	}
+	mockDB.Transactions["UNSYNCED"] = newTx("UNSYNCED", 0)
+	mockDB.Transactions["SYNCED"] = newTx("SYNCED", 42)
+
+	mockClient := &lm.MockLunchMoneyClient{}
+	syncer := &LunchMoneySyncer{
+		client:        mockClient,
+		database:      mockDB,
+		accountMapper: NewAccountMapperWithClient(mockClient, mockDB),
+	}
+
+	plan, err := syncer.PlanTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to plan transactions: %v", err)
+	}
+
+	if len(plan.Inserts) != 0 {
+		t.Errorf("Expected no inserts for vanished transactions, got %+v", plan.Inserts)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Reason != SkipReasonVanished {
+		t.Fatalf("Expected UNSYNCED to be skipped as vanished, got %+v", plan.Skipped)
+	}
+	if len(plan.Vanished) != 1 || plan.Vanished[0].ReferenceNumber != "SYNCED" {
+		t.Fatalf("Expected SYNCED to be reported as vanished, got %+v", plan.Vanished)
+	}
+
+	mockClient.Transactions = []models.Transaction{{LunchMoneyID: 42, Notes: "Split with Sam"}}
+	if err := syncer.MarkVanished(context.Background(), plan.Vanished[0]); err != nil {
+		t.Fatalf("Failed to mark vanished transaction: %v", err)
+	}
+	update, ok := mockClient.UpdatedTransactions[42]
+	if !ok || update.Status == nil || *update.Status != "uncleared" || update.Notes == nil ||
+		*update.Notes != "Split with Sam | "+VanishedNote {
+		t.Errorf("Expected transaction 42 to be un-cleared with a note appended, got %+v", update)
+	}
+	if mockDB.Transactions["SYNCED"].VanishedAction != models.VanishedActionMarked {
+		t.Errorf("Expected SYNCED to be marked, got %q", mockDB.Transactions["SYNCED"].VanishedAction)
+	}
+}
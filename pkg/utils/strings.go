@@ -10,3 +10,45 @@ import (
 func Capitalize(s string) string {
 	return cases.Title(language.English).String(strings.ToLower(s))
 }
+
+// SplitQuoted splits s around whitespace like strings.Fields, except that text
+// within double quotes is kept as a single field without the quotes. A backslash
+// escapes a double quote or another backslash, other backslashes are kept so
+// Windows paths need no escaping. An unterminated quote runs to the end of s.
+func SplitQuoted(s string) []string {
+	fields := make([]string, 0)
+	var current strings.Builder
+	inQuotes, inField, escaped := false, false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			inField = true
+		case r == '"':
+			inQuotes = !inQuotes
+			inField = true
+		case !inQuotes && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(r)
+			inField = true
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
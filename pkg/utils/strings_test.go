@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"fields", "  mapping set\tVisa  ", []string{"mapping", "set", "Visa"}},
+		{"empty", "", []string{}},
+		{"quoted", `mapping set "Visa Infinite" 7`, []string{"mapping", "set", "Visa Infinite", "7"}},
+		{"quotes within a field", `--account=My" "Card`, []string{"--account=My Card"}},
+		{"empty quotes", `export "" x`, []string{"export", "", "x"}},
+		{"escaped quote", `note "say \"hi\"" \"`, []string{"note", `say "hi"`, `"`}},
+		{"escaped backslash", `"a\\b"`, []string{`a\b`}},
+		{"other backslashes", `C:\exports\tx.csv "D:\new dir"`, []string{`C:\exports\tx.csv`, `D:\new dir`}},
+		{"trailing backslash", `path\`, []string{`path\`}},
+		{"unterminated quote", `set "Visa Infinite 7`, []string{"set", "Visa Infinite 7"}},
+	}
+	for _, tt := range tests {
+		if got := SplitQuoted(tt.input); !slices.Equal(got, tt.expected) {
+			t.Errorf("%s: SplitQuoted(%q) = %q, expected %q", tt.name, tt.input, got, tt.expected)
+		}
+	}
+}